@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+
+	"github.com/relab/credbench/pkg/ctree/bundle"
+)
+
+// proofClient adds the eth_getProof method to the Ethereum client.
+type proofClient struct {
+	*ethclient.Client
+	geth *gethclient.Client
+}
+
+func newProofClient(c *ethclient.Client) *proofClient {
+	return &proofClient{c, gethclient.New(c.Client())}
+}
+
+func (c *proofClient) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error) {
+	return c.geth.GetProof(ctx, account, keys, blockNumber)
+}
+
+func exportBundleCmd() *cobra.Command {
+	var block int64
+
+	c := &cobra.Command{
+		Use:   "export",
+		Short: "Exports the verification bundle of the credential tree of a subject",
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			cAddr := common.HexToAddress(args[0])
+			sAddr := common.HexToAddress(args[1])
+			var number *big.Int
+			if block >= 0 {
+				number = big.NewInt(block)
+			}
+
+			start := time.Now()
+			b, err := bundle.Export(context.Background(), newProofClient(backend), cAddr, sAddr, number)
+			if err != nil {
+				log.Fatal(err)
+			}
+			data, err := json.MarshalIndent(b, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := os.WriteFile(args[2], data, 0o644); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Bundle of %d accounts exported in %v\n", len(b.Accounts), time.Since(start))
+			fmt.Printf("Pinned block %v with hash %s\n", b.Header.Number, b.Header.Hash().Hex())
+		},
+	}
+
+	c.Flags().Int64Var(&block, "block", -1, "Block number to pin the bundle (default latest)")
+	return c
+}
+
+func verifyBundleCmd() *cobra.Command {
+	var trusted string
+	var asJSON bool
+
+	c := &cobra.Command{
+		Use:   "verify",
+		Short: "Verifies a bundle offline against a trusted block hash",
+		Long: `Verifies a bundle offline against a trusted block hash, exiting with
+status 1 when the credential tree is invalid.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			var b bundle.Bundle
+			if err := json.Unmarshal(data, &b); err != nil {
+				log.Fatal(err)
+			}
+
+			start := time.Now()
+			report, err := bundle.Verify(context.Background(), &b, common.HexToHash(trusted))
+			if err != nil {
+				log.Fatalf("Verification failed in %v with error: %v\n", time.Since(start), err)
+			}
+			elapsed := time.Since(start)
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					log.Fatal(err)
+				}
+				if report.Err() != nil {
+					os.Exit(1)
+				}
+				return
+			}
+			printReport(os.Stdout, report)
+			if err := report.Err(); err != nil {
+				fmt.Printf("%s credential tree at block %v: %v\n", Red("Invalid"), b.Header.Number, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s credential tree at block %v! Verified offline in %v\n", Green("Valid"), b.Header.Number, elapsed)
+		},
+	}
+
+	c.Flags().StringVar(&trusted, "trusted", "", "Trusted hash of the pinned block")
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	if err := c.MarkFlagRequired("trusted"); err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
+func newBundleCmd() *cobra.Command {
+	bundleCmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Export and verify offline verification bundles",
+	}
+	bundleCmd.AddCommand(
+		exportBundleCmd(),
+		verifyBundleCmd(),
+	)
+	return bundleCmd
+}
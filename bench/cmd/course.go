@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -14,9 +17,9 @@ import (
 	"github.com/relab/credbench/bench/datastore"
 	"github.com/relab/credbench/bench/transactor"
 	course "github.com/relab/credbench/pkg/course"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/schemes"
 	bindings "github.com/relab/go-credbindings/course"
-
-	pb "github.com/relab/credbench/pkg/schemes"
 )
 
 var addStudentCmd = &cobra.Command{
@@ -30,6 +33,11 @@ var addStudentCmd = &cobra.Command{
 		}
 		studentAddress := common.HexToAddress(args[1])
 
+		check := c.CheckAddStudent(context.Background(), nil, defaultSender, studentAddress)
+		if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "addStudent", studentAddress) {
+			return
+		}
+
 		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 		if err != nil {
 			log.Fatal(err)
@@ -62,6 +70,11 @@ var rmStudentCmd = &cobra.Command{
 		}
 		studentAddress := common.HexToAddress(args[1])
 
+		check := c.CheckRemoveStudent(context.Background(), nil, defaultSender, studentAddress)
+		if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "removeStudent", studentAddress) {
+			return
+		}
+
 		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 		if err != nil {
 			log.Fatal(err)
@@ -94,12 +107,17 @@ var renounceCourseCmd = &cobra.Command{
 		}
 
 		// Note: student is considered to be using the default wallet
+		check := c.CheckRenounceCourse(context.Background(), nil, defaultSender)
+		if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "renounceCourse") {
+			return
+		}
+
 		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		tx, err := renounce(executor, opts, c, opts.From)
+		tx, err := renounce(executor, opts, c)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -107,14 +125,16 @@ var renounceCourseCmd = &cobra.Command{
 	},
 }
 
-func renounce(e *transactor.Transactor, opts *bind.TransactOpts, c *course.Course, studentAddress common.Address) (*types.Transaction, error) {
-	tx, err := e.SendTX("course", opts, c.Address(), bindings.CourseABI, "renounceCourse", studentAddress)
+func renounce(e *transactor.Transactor, opts *bind.TransactOpts, c *course.Course) (*types.Transaction, error) {
+	tx, err := e.SendTX("course", opts, c.Address(), bindings.CourseABI, "renounceCourse")
 	if err != nil {
 		return nil, err
 	}
 	return tx, nil
 }
 
+var saltedOutput string
+
 var issueCourseCredentialCmd = &cobra.Command{
 	Use:   "issue",
 	Short: "Issue a new credential",
@@ -130,9 +150,33 @@ var issueCourseCredentialCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		studentAddress := common.HexToAddress(args[1])
-		a := &pb.AssignmentGradeCredential{}
-		pb.ParseJSON(args[2], a)
-		digest := pb.Hash(a)
+		doc := validateDocument(args[2], studentAddress)
+		var digest [32]byte
+		var salted *schemes.SaltedCredential
+		if saltedOutput != "" {
+			salted, digest = saltDocument(doc, c.Address())
+			log.Infof("Credential digest: %x (salted claims)\n", digest)
+		} else {
+			var alg schemes.HashAlgorithm
+			alg, digest = digestOf(doc)
+			log.Infof("Credential digest: %x (%s)\n", digest, alg)
+		}
+
+		check := c.CheckRegisterCredential(context.Background(), nil, defaultSender, studentAddress, digest, nil)
+		if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "registerCredential", studentAddress, digest, []common.Address{}) {
+			return
+		}
+
+		if salted != nil {
+			// the holder needs the salts to disclose the claims
+			data, err := json.MarshalIndent(salted, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := writeOutput(saltedOutput, data); err != nil {
+				log.Fatal(err)
+			}
+		}
 
 		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 		if err != nil {
@@ -155,34 +199,57 @@ func registerCourseCredential(e *transactor.Transactor, opts *bind.TransactOpts,
 	return tx, nil
 }
 
-var approveCourseCredentialCmd = &cobra.Command{
-	Use:   "approve",
-	Short: "Approve a credential using its hash",
-	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 2 {
-			return errors.New("Missing arguments. Please specify: course_address digest_hash")
-		}
-		return nil
-	},
-	Run: func(cmd *cobra.Command, args []string) {
-		c, err := getCourseContract(common.HexToAddress(args[0]))
-		if err != nil {
-			log.Fatal(err)
-		}
-		digest := common.HexToHash(args[1]) // 0x?
-		// FIXME: Validate inputs
+func approveCourseCredentialCmd() *cobra.Command {
+	var relayer string
 
-		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
-		if err != nil {
-			log.Fatal(err)
-		}
+	approveCmd := &cobra.Command{
+		Use:   "approve",
+		Short: "Approve a credential using its hash",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				return errors.New("Missing arguments. Please specify: course_address digest_hash")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := getCourseContract(common.HexToAddress(args[0]))
+			if err != nil {
+				log.Fatal(err)
+			}
+			digest := common.HexToHash(args[1]) // 0x?
+			// FIXME: Validate inputs
 
-		tx, err := approveCourseCredential(executor, opts, c, digest)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Infof("Transaction ID: %x\n", tx.Hash())
-	},
+			check := c.CheckApproveCredential(context.Background(), nil, defaultSender, digest)
+			if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "approveCredential", digest) {
+				return
+			}
+
+			if relayer != "" {
+				tx, err := relayApproval(context.Background(), relayer, defaultSender, c.Address(), digest)
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Infof("Transaction ID: %x\n", tx.Hash())
+				return
+			}
+
+			// the relayer pays the approval, so only a direct one takes a nonce
+			opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			tx, err := approveCourseCredential(executor, opts, c, digest)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Transaction ID: %x\n", tx.Hash())
+		},
+	}
+
+	approveCmd.Flags().StringVar(&relayer, "relayer", "", "URL of a relayer submitting the approval without ether")
+	addDryRunFlag(approveCmd)
+	return approveCmd
 }
 
 func approveCourseCredential(e *transactor.Transactor, opts *bind.TransactOpts, c *course.Course, digest [32]byte) (*types.Transaction, error) {
@@ -193,6 +260,61 @@ func approveCourseCredential(e *transactor.Transactor, opts *bind.TransactOpts,
 	return tx, nil
 }
 
+func revokeCourseCredentialCmd() *cobra.Command {
+	var reason string
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <course_address> <digest_hash>",
+		Short: "Revoke an issued credential",
+		Long: fmt.Sprintf(`Revoke an issued credential, recording the reason in its revocation proof.
+The reason is one of the standard reasons (%s),
+an institution-defined reason namespaced as <institution>/<code>, registered
+in the revocation_reasons of the config file, or a hex encoded 32 bytes code.`,
+			strings.Join(notary.DefaultReasons.Names(), ", ")),
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := getCourseContract(common.HexToAddress(args[0]))
+			if err != nil {
+				log.Fatal(err)
+			}
+			digest := common.HexToHash(args[1])
+			r, err := notary.ParseReason(reason)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			check := c.CheckRevoke(context.Background(), nil, defaultSender, digest)
+			if preflight(check, defaultSender, c.Address(), bindings.CourseABI, "revokeCredential", digest, [32]byte(r)) {
+				return
+			}
+
+			opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			tx, err := revokeCourseCredential(executor, opts, c, digest, r)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Revoking credential %s (reason %s)", digest.Hex(), r)
+			log.Infof("Transaction ID: %x\n", tx.Hash())
+		},
+	}
+
+	revokeCmd.Flags().StringVar(&reason, "reason", "unspecified", "Reason of the revocation")
+	addDryRunFlag(revokeCmd)
+	return revokeCmd
+}
+
+func revokeCourseCredential(e *transactor.Transactor, opts *bind.TransactOpts, c *course.Course, digest [32]byte, reason notary.Reason) (*types.Transaction, error) {
+	tx, err := e.SendTX("course", opts, c.Address(), bindings.CourseABI, "revokeCredential", digest, [32]byte(reason))
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
 func aggregateCourseCredentials(e *transactor.Transactor, opts *bind.TransactOpts, c *course.Course, student common.Address, digests [][32]byte) (*types.Transaction, error) {
 	tx, err := e.SendTX("course", opts, c.Address(), bindings.CourseABI, "aggregateCredentials", student, digests)
 	if err != nil {
@@ -218,7 +340,7 @@ var getStudentsCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		students, err := c.GetStudents(&bind.CallOpts{Pending: false})
+		students, err := c.GetStudentsContext(context.Background(), nil)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -239,7 +361,7 @@ var getRootCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		student := common.HexToAddress(args[1])
-		root, err := c.GetRoot(&bind.CallOpts{Pending: false}, student)
+		root, err := c.GetRoot(context.Background(), nil, student)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -257,7 +379,7 @@ var isEnrolledCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		student := common.HexToAddress(args[1])
-		ok, err := c.IsEnrolled(&bind.CallOpts{Pending: false}, student)
+		ok, err := c.IsEnrolledContext(context.Background(), nil, student)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -313,9 +435,16 @@ func newCourseCmd() *cobra.Command {
 		getStudentsCmd,
 		isEnrolledCmd,
 		issueCourseCredentialCmd,
-		approveCourseCredentialCmd,
+		approveCourseCredentialCmd(),
+		revokeCourseCredentialCmd(),
 		getCourseCmd,
 		getRootCmd,
 	)
+	addDryRunFlag(addStudentCmd, rmStudentCmd, renounceCourseCmd, issueCourseCredentialCmd)
+	addHashAlgorithmFlag(issueCourseCredentialCmd)
+	issueCourseCredentialCmd.Flags().StringVar(&saltedOutput, "salted", "",
+		"Issue the credential with salted claims, written to the given file for its holder to disclose them selectively")
+	// the digest of salted claims is their Merkle root, whatever the algorithm
+	issueCourseCredentialCmd.MarkFlagsMutuallyExclusive("salted", "hash-algorithm")
 	return courseCmd
 }
@@ -0,0 +1,446 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/relab/credbench/bench/datastore"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/schemes"
+
+	pb "github.com/relab/credbench/bench/proto"
+)
+
+// chainTime returns the timestamp of the latest block, the time at which
+// the validity of the credentials is checked.
+func chainTime(ctx context.Context) (time.Time, error) {
+	header, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
+
+// trackedStatus returns the status of a tracked credential at the given
+// time, updating it if it was revoked on-chain.
+func trackedStatus(ctx context.Context, cs *datastore.CredentialStore, c *pb.Credential, at time.Time) (pb.Status, error) {
+	if c.Status == pb.Status_ISSUED {
+		n, err := node.NewNode(common.BytesToAddress(c.Contract), backend)
+		if err != nil {
+			return 0, err
+		}
+		revoked, err := n.IsRevoked(ctx, nil, common.BytesToHash(c.Digest))
+		if err != nil {
+			return 0, err
+		}
+		if revoked {
+			c.Status = pb.Status_REVOKED
+			if err := cs.PutCredential(c); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return datastore.CredentialStatus(c, at), nil
+}
+
+func printTracked(c *pb.Credential, status pb.Status) {
+	s := Green(status.String())
+	if status != pb.Status_ISSUED {
+		s = Red(status.String())
+	}
+	fmt.Printf("Credential %s: %s\n", common.BytesToHash(c.Digest).Hex(), s)
+	fmt.Printf("  contract: %s subject: %s\n", common.BytesToAddress(c.Contract).Hex(), common.BytesToAddress(c.Subject).Hex())
+	if c.HashAlgorithm != "" {
+		fmt.Printf("  hash algorithm: %s\n", c.HashAlgorithm)
+	}
+	if c.ValidFrom != nil || c.ValidUntil != nil {
+		from, until := "-", "-"
+		if c.ValidFrom != nil {
+			from = c.ValidFrom.AsTime().UTC().Format(time.RFC3339)
+		}
+		if c.ValidUntil != nil {
+			until = c.ValidUntil.AsTime().UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("  valid from: %s until: %s\n", from, until)
+	}
+}
+
+func credentialStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status <digest>",
+		Short: "Shows the status of a tracked credential at the latest block",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cs := datastore.NewCredentialStore(db)
+			c, err := cs.GetCredential(common.HexToHash(args[0]))
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(c.Digest) == 0 {
+				log.Fatalf("credential %s is not tracked", args[0])
+			}
+			at, err := chainTime(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			status, err := trackedStatus(ctx, cs, c, at)
+			if err != nil {
+				log.Fatal(err)
+			}
+			printTracked(c, status)
+		},
+	}
+}
+
+func listCredentialsCmd() *cobra.Command {
+	var statuses []string
+
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the tracked credentials and their status at the latest block",
+		Long: `Lists the credentials tracked by the bench, e.g. the exams generated with a
+validity period. Issued credentials whose validity period ended before the
+latest block are expired.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			filter := make(map[pb.Status]bool)
+			for _, s := range statuses {
+				status, ok := pb.Status_value[strings.ToUpper(s)]
+				if !ok {
+					log.Fatalf("unknown status %q", s)
+				}
+				filter[pb.Status(status)] = true
+			}
+			at, err := chainTime(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cs := datastore.NewCredentialStore(db)
+			credentials, err := cs.GetCredentials(at)
+			if err != nil {
+				log.Fatal(err)
+			}
+			count := make(map[pb.Status]int)
+			for _, c := range credentials {
+				status, err := trackedStatus(ctx, cs, c, at)
+				if err != nil {
+					log.Fatal(err)
+				}
+				count[status]++
+				if len(filter) == 0 || filter[status] {
+					printTracked(c, status)
+				}
+			}
+			fmt.Printf("%d credentials at %s: %d issued, %d expired, %d revoked\n", len(credentials),
+				at.UTC().Format(time.RFC3339), count[pb.Status_ISSUED], count[pb.Status_EXPIRED], count[pb.Status_REVOKED])
+		},
+	}
+
+	c.Flags().StringSliceVar(&statuses, "status", nil, "Only list the credentials with the status (issued|expired|revoked)")
+	return c
+}
+
+var hashAlgorithm string
+
+// addHashAlgorithmFlag adds the --hash-algorithm flag to commands hashing
+// credential documents.
+func addHashAlgorithmFlag(cmds ...*cobra.Command) {
+	algorithms := make([]string, 0, len(schemes.HashAlgorithms()))
+	for _, a := range schemes.HashAlgorithms() {
+		algorithms = append(algorithms, string(a))
+	}
+	for _, c := range cmds {
+		c.Flags().StringVar(&hashAlgorithm, "hash-algorithm", string(schemes.DefaultHashAlgorithm),
+			fmt.Sprintf("Algorithm of the digest of the credential (%s)", strings.Join(algorithms, "|")))
+	}
+}
+
+// digestOf returns the digest of the credential document with the hash
+// algorithm of the command.
+func digestOf(doc schemes.Credential) (schemes.HashAlgorithm, [32]byte) {
+	alg, err := schemes.ParseHashAlgorithm(hashAlgorithm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	digest, err := alg.Hash(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return alg, digest
+}
+
+// saltDocument splits the credential document issued by the contract in
+// salted claims, returning them and their digest.
+func saltDocument(doc schemes.Credential, contract common.Address) (*schemes.SaltedCredential, [32]byte) {
+	salted, err := schemes.NewSaltedCredential(doc, rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	salted.Contract = contract.Hex()
+	digest, err := salted.Digest()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return salted, digest
+}
+
+// writeOutput writes the data to the file, or to the standard output if
+// no file is given.
+func writeOutput(output string, data []byte) error {
+	if output == "" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(output, data, 0o644)
+}
+
+func exportCredentialCmd() *cobra.Command {
+	var contract, output string
+
+	c := &cobra.Command{
+		Use:   "export <document>",
+		Short: "Exports a JSON credential document as a W3C verifiable credential",
+		Long: `Exports an assignment, course or diploma credential document as a W3C
+verifiable credential in JSON-LD. With --contract, the credential status
+points at the credential proof of the document in the contract, whose
+digest is computed with --hash-algorithm. With urdna2015-sha256-v1, the
+credential uses the JSON-LD context of the bench and its digest is the one
+computed by JSON-LD verifiers.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			doc, err := schemes.ParseCredential(data)
+			if err != nil {
+				log.Fatal(err)
+			}
+			var addr common.Address
+			if contract != "" {
+				if !common.IsHexAddress(contract) {
+					log.Fatalf("invalid contract address %q", contract)
+				}
+				addr = common.HexToAddress(contract)
+			}
+			alg, digest := digestOf(doc)
+			vc, err := schemes.ToVC(doc, addr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if alg == schemes.URDNA2015SHA256 {
+				// processed as JSON-LD by the verifiers
+				vc = vc.LinkedData()
+			}
+			if addr != (common.Address{}) {
+				vc.SetStatus(addr, alg, digest)
+			}
+			data, err = json.MarshalIndent(vc, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := writeOutput(output, data); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	c.Flags().StringVar(&contract, "contract", "", "Contract issuing the credential")
+	c.Flags().StringVarP(&output, "output", "o", "", "File of the verifiable credential (standard output if not given)")
+	addHashAlgorithmFlag(c)
+	return c
+}
+
+func importCredentialCmd() *cobra.Command {
+	var output string
+
+	c := &cobra.Command{
+		Use:   "import <vc>",
+		Short: "Imports a W3C verifiable credential as a JSON credential document",
+		Long: `Imports a W3C verifiable credential in JSON-LD as an assignment, course or
+diploma credential document. If the credential status points at a credential
+proof, the digest of the document must be the digest of the proof.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			vc, err := schemes.ParseVC(data)
+			if err != nil {
+				log.Fatal(err)
+			}
+			doc, err := schemes.FromVC(vc)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if vc.CredentialStatus != nil {
+				contract, digest, err := vc.Status()
+				if err != nil {
+					log.Fatal(err)
+				}
+				alg, err := schemes.ParseHashAlgorithm(vc.CredentialStatus.DigestAlgorithm)
+				if err != nil {
+					log.Fatal(err)
+				}
+				var d [32]byte
+				if alg == schemes.URDNA2015SHA256 {
+					// the digest computed by JSON-LD verifiers
+					d, err = schemes.HashVC(vc)
+				} else {
+					d, err = alg.Hash(doc)
+				}
+				if err != nil {
+					log.Fatal(err)
+				}
+				if d != digest {
+					log.Fatalf("document digest %s does not match the status digest %s", common.Hash(d).Hex(), digest.Hex())
+				}
+				log.Infof("Credential %s (%s) issued by %s", digest.Hex(), alg, contract.Hex())
+			}
+			data, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(doc)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := writeOutput(output, data); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&output, "output", "o", "", "File of the credential document (standard output if not given)")
+	return c
+}
+
+// validateDocument validates a JSON credential document, or verifiable
+// credential, failing with the list of invalid fields.
+func validateDocument(path string, subject common.Address) schemes.Credential {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc schemes.Credential
+	if _, vcErr := schemes.ParseVC(data); vcErr == nil {
+		_, doc, err = schemes.ValidateVC(data)
+		if err == nil && subject != (common.Address{}) {
+			err = schemes.Validate(doc, subject)
+		}
+	} else {
+		doc, err = schemes.ValidateJSON(data, subject)
+	}
+	var verr *schemes.ValidationError
+	if errors.As(err, &verr) {
+		fmt.Printf("%s: %s\n", Red("Invalid credential"), path)
+		for _, f := range verr.Fields {
+			fmt.Printf("  %s\n", f)
+		}
+		log.Fatal(schemes.ErrInvalidDocument)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return doc
+}
+
+func validateCredentialCmd() *cobra.Command {
+	var subject string
+
+	c := &cobra.Command{
+		Use:   "validate <document>",
+		Short: "Validates a JSON credential document or verifiable credential",
+		Long: `Validates an assignment, course or diploma credential document, or a W3C
+verifiable credential holding one, against the JSON Schema of its type, and
+checks that the grades are within the grading system, the credits are
+positive, the entities are identified by their addresses and the student is
+the subject of the credential. Course credentials are validated before
+being issued.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var addr common.Address
+			if subject != "" {
+				if !common.IsHexAddress(subject) {
+					log.Fatalf("invalid subject address %q", subject)
+				}
+				addr = common.HexToAddress(subject)
+			}
+			doc := validateDocument(args[0], addr)
+			alg, digest := digestOf(doc)
+			fmt.Printf("%s: %s %s (%s)\n", Green("Valid credential"), doc.ProtoReflect().Descriptor().Name(), common.Hash(digest).Hex(), alg)
+		},
+	}
+
+	c.Flags().StringVar(&subject, "subject", "", "Address of the student the credential is issued to")
+	addHashAlgorithmFlag(c)
+	return c
+}
+
+func discloseCredentialCmd() *cobra.Command {
+	var output string
+
+	c := &cobra.Command{
+		Use:   "disclose <salted> <path>...",
+		Short: "Derives a presentation disclosing some claims of a salted credential",
+		Long: `Derives a presentation disclosing only the claims of a credential issued with
+salted claims (course issue --salted) at the given JSON pointers, e.g.
+/course/finalGrade and /course/totalCredits, or nested in them. Verifiers
+check the presentation against the credential proof with verify disclosure.`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			salted := &schemes.SaltedCredential{}
+			if err := json.Unmarshal(data, salted); err != nil {
+				log.Fatal(err)
+			}
+			p, err := salted.Disclose(args[1:]...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			data, err = json.MarshalIndent(p, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := writeOutput(output, data); err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Disclosed %d of %d claims of credential %s", len(p.Claims), len(salted.Claims), p.Digest.Hex())
+		},
+	}
+
+	c.Flags().StringVarP(&output, "output", "o", "", "File of the presentation (standard output if not given)")
+	return c
+}
+
+func newCredentialCmd() *cobra.Command {
+	credentialCmd := &cobra.Command{
+		Use:   "credential",
+		Short: "Track, validate and convert the credentials to verifiable credentials",
+	}
+	credentialCmd.AddCommand(
+		credentialStatusCmd(),
+		listCredentialsCmd(),
+		exportCredentialCmd(),
+		importCredentialCmd(),
+		validateCredentialCmd(),
+		discloseCredentialCmd(),
+	)
+	return credentialCmd
+}
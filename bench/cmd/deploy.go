@@ -26,10 +26,13 @@ import (
 )
 
 func deployNotaryCmd() *cobra.Command {
-	return &cobra.Command{
+	c := &cobra.Command{
 		Use:   "notary",
 		Short: "Deploy notary library",
 		Run: func(cmd *cobra.Command, args []string) {
+			if preflightDeploy(defaultSender, "notary", notaryBinding.NotaryABI, notaryBinding.NotaryBin) {
+				return
+			}
 			opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 			if err != nil {
 				log.Fatal(err)
@@ -41,6 +44,8 @@ func deployNotaryCmd() *cobra.Command {
 			}
 		},
 	}
+	addDryRunFlag(c)
+	return c
 }
 
 func deployNotary(opts *bind.TransactOpts, backend *ethclient.Client) error {
@@ -59,10 +64,13 @@ func deployNotary(opts *bind.TransactOpts, backend *ethclient.Client) error {
 }
 
 func deployAggregatorCmd() *cobra.Command {
-	return &cobra.Command{
+	c := &cobra.Command{
 		Use:   "aggregator",
 		Short: "Deploy aggregator library",
 		Run: func(cmd *cobra.Command, args []string) {
+			if preflightDeploy(defaultSender, "aggregator", aggregator.CredentialSumABI, aggregator.CredentialSumBin) {
+				return
+			}
 			opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 			if err != nil {
 				log.Fatal(err)
@@ -74,6 +82,8 @@ func deployAggregatorCmd() *cobra.Command {
 			}
 		},
 	}
+	addDryRunFlag(c)
+	return c
 }
 
 func deployAggregator(opts *bind.TransactOpts, backend *ethclient.Client) error {
@@ -95,6 +105,11 @@ var deployAllLibsCmd = &cobra.Command{
 	Use:   "libs",
 	Short: "Deploy all libraries",
 	Run: func(cmd *cobra.Command, args []string) {
+		notaryDryRun := preflightDeploy(defaultSender, "notary", notaryBinding.NotaryABI, notaryBinding.NotaryBin)
+		if preflightDeploy(defaultSender, "aggregator", aggregator.CredentialSumABI, aggregator.CredentialSumBin) || notaryDryRun {
+			return
+		}
+
 		opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 		if err != nil {
 			log.Fatal(err)
@@ -129,6 +144,15 @@ func deployCourseCmd() *cobra.Command {
 				ownersAddr = append(ownersAddr, common.HexToAddress(addr))
 			}
 
+			if dryRun {
+				bin, err := linkContract(courseBinding.CourseBin, deployedLibs())
+				if err != nil {
+					log.Fatal(err)
+				}
+				preflightDeploy(defaultSender, "course", courseBinding.CourseABI, bin, ownersAddr, quorum)
+				return
+			}
+
 			opts, err := accountStore.GetTxOpts(defaultSender.Bytes(), backend)
 			if err != nil {
 				log.Fatal(err)
@@ -147,25 +171,14 @@ func deployCourseCmd() *cobra.Command {
 
 	c.MarkFlagRequired("owners")
 	c.MarkFlagRequired("quorum")
+	addDryRunFlag(c)
 
 	return c
 }
 
 func DeployCourse(opts *bind.TransactOpts, backend *ethclient.Client, owners []common.Address, quorum uint8) (common.Address, *types.Transaction, error) {
 	log.Infoln("Deploying Course...")
-	aggregatorAddr := viper.GetString("deployed_libs.aggregator")
-	if aggregatorAddr == "" {
-		log.Fatal(fmt.Errorf("Aggregator contract not deployed. Please, deploy it first"))
-	}
-	notaryAddr := viper.GetString("deployed_libs.notary")
-	if notaryAddr == "" {
-		log.Fatal(fmt.Errorf("Notary contract not deployed. Please, deploy it first"))
-	}
-	libs := map[string]string{
-		"CredentialSum": aggregatorAddr,
-		"Notary":        notaryAddr,
-	}
-
+	libs := deployedLibs()
 	cAddr, tx, _, err := LinkAndDeploy(opts, backend, courseBinding.CourseABI, courseBinding.CourseBin, libs, false, owners, quorum)
 	if err != nil {
 		return common.Address{}, nil, err
@@ -178,6 +191,19 @@ func DeployCourse(opts *bind.TransactOpts, backend *ethclient.Client, owners []c
 
 func DeployFaculty(opts *bind.TransactOpts, backend *ethclient.Client, owners []common.Address, quorum uint8) (common.Address, *types.Transaction, error) {
 	log.Infoln("Deploying Faculty...")
+	libs := deployedLibs()
+	cAddr, tx, _, err := LinkAndDeploy(opts, backend, faculty.FacultyABI, faculty.FacultyBin, libs, false, owners, quorum)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if accounts.IsZeroAddress(cAddr) {
+		return common.Address{}, nil, errors.New("zero address")
+	}
+	return cAddr, tx, nil
+}
+
+// deployedLibs returns the addresses of the libraries linked by the node contracts
+func deployedLibs() map[string]string {
 	aggregatorAddr := viper.GetString("deployed_libs.aggregator")
 	if aggregatorAddr == "" {
 		log.Fatal(fmt.Errorf("Aggregator contract not deployed. Please, deploy it first"))
@@ -186,19 +212,23 @@ func DeployFaculty(opts *bind.TransactOpts, backend *ethclient.Client, owners []
 	if notaryAddr == "" {
 		log.Fatal(fmt.Errorf("Notary contract not deployed. Please, deploy it first"))
 	}
-	libs := map[string]string{
+	return map[string]string{
 		"CredentialSum": aggregatorAddr,
 		"Notary":        notaryAddr,
 	}
+}
 
-	cAddr, tx, _, err := LinkAndDeploy(opts, backend, faculty.FacultyABI, faculty.FacultyBin, libs, false, owners, quorum)
-	if err != nil {
-		return common.Address{}, nil, err
+// linkContract links a contract with the given libraries
+func linkContract(contractBin string, deployedLibs map[string]string) (string, error) {
+	if len(deployedLibs) == 0 {
+		return contractBin, nil
 	}
-	if accounts.IsZeroAddress(cAddr) {
-		return common.Address{}, nil, errors.New("zero address")
+	// FIXME: refactor this
+	libs, err := deployer.FindLinkReferences(deployedLibs, nodeBinding.NodeLinkReferences)
+	if err != nil {
+		return "", err
 	}
-	return cAddr, tx, nil
+	return deployer.LinkContract(contractBin, libs), nil
 }
 
 // LinkAndDeploy links a contract with the given libraries and deploy it
@@ -210,13 +240,9 @@ func LinkAndDeploy(opts *bind.TransactOpts, backend *ethclient.Client, contractA
 	}
 	log.Infof("Deployer: %s balance: %v\n", opts.From.Hex(), balance)
 
-	if len(deployedLibs) > 0 {
-		// FIXME: refactor this
-		libs, err := deployer.FindLinkReferences(deployedLibs, nodeBinding.NodeLinkReferences)
-		if err != nil {
-			return common.Address{}, nil, nil, err
-		}
-		contractBin = deployer.LinkContract(contractBin, libs)
+	contractBin, err = linkContract(contractBin, deployedLibs)
+	if err != nil {
+		return common.Address{}, nil, nil, err
 	}
 
 	address, tx, contract, err := deployer.DeployContract(opts, backend, contractABI, contractBin, params...)
@@ -247,6 +273,7 @@ func newDeployCmd() *cobra.Command {
 		},
 	}
 
+	addDryRunFlag(deployAllLibsCmd)
 	deployCmd.AddCommand(
 		deployAllLibsCmd,
 		deployNotaryCmd(),
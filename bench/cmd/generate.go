@@ -9,14 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/relab/credbench/bench/datastore"
@@ -56,6 +57,7 @@ var generateTestCaseCmd = &cobra.Command{
 func generateTestConfigCmd() *cobra.Command {
 	var accountDistribution string
 	var totalAccounts, faculties, adms, semesters, courses, evaluators, exams, students int
+	var examValidity time.Duration
 
 	c := &cobra.Command{
 		Use:   "case",
@@ -70,7 +72,7 @@ func generateTestConfigCmd() *cobra.Command {
 			testCaseFileName := args[0]
 			log.Infoln("Generating test case configuration at:", testCaseFileName)
 			var err error
-			err = testconfig.GenConfigFile(testCaseFileName, accountDistribution, totalAccounts, faculties, adms, semesters, courses, evaluators, exams, students)
+			err = testconfig.GenConfigFile(testCaseFileName, accountDistribution, totalAccounts, faculties, adms, semesters, courses, evaluators, exams, students, examValidity)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -86,6 +88,7 @@ func generateTestConfigCmd() *cobra.Command {
 	c.Flags().IntVarP(&evaluators, "evaluators", "e", 1, "Number of evaluators per course")
 	c.Flags().IntVarP(&exams, "exams", "x", 2, "Number of exams per student per course")
 	c.Flags().IntVarP(&students, "students", "s", 20, "Number of students per course")
+	c.Flags().DurationVar(&examValidity, "examValidity", 0, "Validity period of the exam credentials (never expire if zero)")
 	return c
 }
 
@@ -104,6 +107,7 @@ func generateTestCmd() *cobra.Command {
 
 	c.AddCommand(
 		generateTestCaseCmd,
+		generateTopologyCmd(),
 		// FIXME: remove dependency of config generation command from persistentPreRun setup
 		generateTestConfigCmd(),
 	)
@@ -426,20 +430,25 @@ func selectAccounts(method string, n int, selectType pb.Type) (datastore.Account
 
 // Run the test case by enrolling students and producing a credential tree
 // for them for the specified period.
-var runTestCmd = &cobra.Command{
-	Use:   "run",
-	Short: "Run test case",
-	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-		testConfig, err = testconfig.LoadConfig(testFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		err = runTestCase()
-		if err != nil {
-			log.Fatal(err)
-		}
-	},
+func runTestCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "run",
+		Short: "Run test case",
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			testConfig, err = testconfig.LoadConfig(testFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = runTestCase()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	c.Flags().StringVar(&relayerURL, "relayer", "", "URL of a relayer submitting the approvals of the students")
+	return c
 }
 
 // Running test case
@@ -523,7 +532,7 @@ func runSemester(runner *transactor.Transactor, semester []byte, faculty *pb.Fac
 		return []common.Address{}, err
 	}
 
-	courses, err := facultyContract.GetCoursesBySemester(&bind.CallOpts{Pending: false}, semester)
+	courses, err := facultyContract.GetCoursesBySemesterContext(context.Background(), nil, semester)
 	if err != nil {
 		return []common.Address{}, err
 	}
@@ -566,7 +575,7 @@ func issueSemesterCredential(runner *transactor.Transactor, contract *faculty.Fa
 		if err != nil {
 			return []common.Address{}, err
 		}
-		students, err := cc.GetStudents(nil)
+		students, err := cc.GetStudentsContext(context.Background(), nil)
 		if err != nil {
 			return []common.Address{}, err
 		}
@@ -605,12 +614,7 @@ func issueSemesterCredential(runner *transactor.Transactor, contract *faculty.Fa
 					log.Fatal(err)
 				}
 			}
-			opts, err := accountStore.GetTxOpts(student.Bytes(), backend)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			tx, err := approveSemesterCredential(runner, opts, contract, digest)
+			tx, err := approveSemester(runner, contract, student, digest)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -625,6 +629,19 @@ func issueSemesterCredential(runner *transactor.Transactor, contract *faculty.Fa
 	return students, nil
 }
 
+// approveSemester approves the credential as the student, through the
+// relayer if one is given.
+func approveSemester(runner *transactor.Transactor, contract *faculty.Faculty, student common.Address, digest [32]byte) (*types.Transaction, error) {
+	if relayerURL != "" {
+		return relayApproval(context.Background(), relayerURL, student, contract.Address(), digest)
+	}
+	opts, err := accountStore.GetTxOpts(student.Bytes(), backend)
+	if err != nil {
+		return nil, err
+	}
+	return approveSemesterCredential(runner, opts, contract, digest)
+}
+
 // FIXME: DRY (courses/faculties)
 func aggregateSemesters(runner *transactor.Transactor, contract *faculty.Faculty, adm []byte, students []common.Address) {
 	wgs := sync.WaitGroup{}
@@ -634,7 +651,7 @@ func aggregateSemesters(runner *transactor.Transactor, contract *faculty.Faculty
 		go func() {
 			defer wgs.Done()
 
-			digests, err := contract.GetDigests(nil, student)
+			digests, err := contract.GetDigests(context.Background(), nil, student)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -730,6 +747,10 @@ func enrollStudents(runner *transactor.Transactor, contract *course.Course, eval
 // }
 
 func issueExams(runner *transactor.Transactor, contract *course.Course, evaluators datastore.Accounts, students datastore.Accounts) {
+	validity, err := testConfig.ExamValidityPeriod()
+	if err != nil {
+		log.Fatal(err)
+	}
 	wgs := sync.WaitGroup{}
 	wgs.Add(len(students))
 	for _, s := range students {
@@ -738,11 +759,14 @@ func issueExams(runner *transactor.Transactor, contract *course.Course, evaluato
 			studentAddress := common.BytesToAddress(student.Address)
 			for e := 0; e < testConfig.Exams; e++ {
 				var digest [32]byte
+				var record *pb.Credential
+				var err error
 				for i, evaluator := range evaluators {
-					// eAddress := common.BytesToAddress(evaluator.Address)
 					if i == 0 {
-						digest = schemes.GenerateRandomDigest(studentAddress.Bytes(), 32)
-						// digest = generateExamCredential(eAddress, studentAddress, contract.Address())
+						digest, record, err = newExamCredential(common.BytesToAddress(evaluator.Address), studentAddress, contract.Address(), validity)
+						if err != nil {
+							log.Fatal(err)
+						}
 					}
 
 					opts, err := accountStore.GetTxOpts(evaluator.Address, backend)
@@ -761,19 +785,23 @@ func issueExams(runner *transactor.Transactor, contract *course.Course, evaluato
 					}
 				}
 
-				opts, err := accountStore.GetTxOpts(student.Address, backend)
+				tx, err := approveCourse(runner, contract, studentAddress, digest)
 				if err != nil {
 					log.Fatal(err)
 				}
 
-				tx, err := approveCourseCredential(runner, opts, contract, digest)
+				err = deployer.WaitTxConfirmation(context.TODO(), backend, tx, 0)
 				if err != nil {
 					log.Fatal(err)
 				}
 
-				err = deployer.WaitTxConfirmation(context.TODO(), backend, tx, 0)
-				if err != nil {
-					log.Fatal(err)
+				if record != nil {
+					record.IssuedOn = timestamppb.Now()
+					record.Status = pb.Status_ISSUED
+					err = datastore.NewCredentialStore(db).PutCredential(record)
+					if err != nil {
+						log.Fatal(err)
+					}
 				}
 			}
 		}(s)
@@ -781,6 +809,74 @@ func issueExams(runner *transactor.Transactor, contract *course.Course, evaluato
 	wgs.Wait()
 }
 
+// newExamCredential returns the digest of a new exam credential. Exams with
+// a validity period are generated as documents valid from the timestamp of
+// the latest block, and returned as a record to track their expiration. The
+// document is stored in the credentials directory of the datadir, at the
+// storage path of the record, so that it can be verified later.
+func newExamCredential(registrar, student, contract common.Address, validity time.Duration) ([32]byte, *pb.Credential, error) {
+	if validity == 0 {
+		return schemes.GenerateRandomDigest(student.Bytes(), 32), nil, nil
+	}
+	header, err := backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	from := time.Unix(int64(header.Time), 0)
+	until := from.Add(validity)
+
+	courseEntity := &schemes.Entity{Id: contract.Hex(), Name: "Course Test Contract"}
+	ag := schemes.NewFakeAssignmentGrade(registrar.Hex(), student.Hex())
+	doc := schemes.NewFakeAssignmentGradeCredential(registrar.Hex(), courseEntity, ag)
+	schemes.SetValidity(doc, from, until)
+	digest, err := schemes.DefaultHashAlgorithm.Hash(doc)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	path, err := storeDocument(doc, digest)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	return digest, &pb.Credential{
+		Digest:        digest[:],
+		StoragePath:   path,
+		Registrar:     registrar.Bytes(),
+		Subject:       student.Bytes(),
+		Contract:      contract.Bytes(),
+		ValidFrom:     timestamppb.New(from),
+		ValidUntil:    timestamppb.New(until),
+		HashAlgorithm: string(schemes.DefaultHashAlgorithm),
+	}, nil
+}
+
+// storeDocument writes the credential document as JSON to the credentials
+// directory of the datadir, returning its path.
+func storeDocument(doc schemes.Credential, digest [32]byte) (string, error) {
+	dir := filepath.Join(datadir, "credentials")
+	if err := fileutils.CreateDir(dir); err != nil {
+		return "", err
+	}
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%x.json", digest))
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// approveCourse approves the credential as the student, through the
+// relayer if one is given.
+func approveCourse(runner *transactor.Transactor, contract *course.Course, student common.Address, digest [32]byte) (*types.Transaction, error) {
+	if relayerURL != "" {
+		return relayApproval(context.Background(), relayerURL, student, contract.Address(), digest)
+	}
+	opts, err := accountStore.GetTxOpts(student.Bytes(), backend)
+	if err != nil {
+		return nil, err
+	}
+	return approveCourseCredential(runner, opts, contract, digest)
+}
+
 func aggregateExams(runner *transactor.Transactor, contract *course.Course, evaluator *pb.Account, students []common.Address) {
 	wgs := sync.WaitGroup{}
 	wgs.Add(len(students))
@@ -789,7 +885,7 @@ func aggregateExams(runner *transactor.Transactor, contract *course.Course, eval
 		go func() {
 			defer wgs.Done()
 
-			digests, err := contract.GetDigests(nil, student)
+			digests, err := contract.GetDigests(context.Background(), nil, student)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -828,7 +924,7 @@ func newTestCmd() *cobra.Command {
 
 	testCmd.AddCommand(
 		generateTestCmd(),
-		runTestCmd,
+		runTestCmd(),
 		newGenAccountsCmd(),
 	)
 	return testCmd
@@ -14,20 +14,27 @@ import (
 	keyutils "github.com/relab/credbench/pkg/accounts"
 )
 
-var genesisCmd = &cobra.Command{
-	Use:   "genesis",
-	Short: "Generate genesis file",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		n, err := strconv.Atoi(args[0])
-		if err != nil {
-			log.Fatal(err)
-		}
-		err = genesis.GenerateGenesis(datadir, consensus, accountStore, n)
-		if err != nil {
-			log.Fatal(err)
-		}
-	},
+func newGenesisCmd() *cobra.Command {
+	var unfunded int
+
+	c := &cobra.Command{
+		Use:   "genesis",
+		Short: "Generate genesis file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = genesis.GenerateGenesis(datadir, consensus, accountStore, n, unfunded)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	c.Flags().IntVar(&unfunded, "unfunded-students", 0, "Number of student accounts without balance, whose approvals are relayed")
+	return c
 }
 
 func getAccountAddresses() ([]string, error) {
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/bench/datastore"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+// printPending writes the signing and approval state of a pending credential.
+func printPending(w io.Writer, p *ctree.PendingCredential, account common.Address) {
+	state := Yellow("waiting for signatures")
+	if p.QuorumSigned {
+		state = Yellow("waiting for approval")
+	}
+	action := ""
+	if account != (common.Address{}) && p.IsWaitingOn(account) {
+		action = fmt.Sprintf(" [%s]", Red("action required"))
+	}
+	fmt.Fprintf(w, "Credential %s: %s%s\n", p.Digest.Hex(), state, action)
+	fmt.Fprintf(w, "  contract: %s subject: %s\n", p.Contract.Hex(), p.Subject.Hex())
+	fmt.Fprintf(w, "  signatures: %d/%d\n", p.Signatures, p.Quorum)
+	for _, s := range p.Signers {
+		signed := Red("missing")
+		if s.Signed {
+			signed = Green("signed")
+		}
+		fmt.Fprintf(w, "    %s %s\n", s.Owner.Hex(), signed)
+	}
+}
+
+// knownContracts returns the nodes in the datastore and the contracts of the account.
+func knownContracts(account common.Address) ([]common.Address, error) {
+	contracts, err := datastore.NodeAddresses(db)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := accountStore.GetAccount(account.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(datastore.ToETHAddress(acc.Contracts), contracts...), nil
+}
+
+func newInboxCmd() *cobra.Command {
+	var all, asJSON bool
+
+	c := &cobra.Command{
+		Use:   "inbox <contract|account>",
+		Short: "Lists the pending credentials of a contract or waiting on an account",
+		Long: `Lists the credentials that were neither approved nor revoked, with the
+owners that signed them and their approval state. Given an account, only the
+credentials waiting on its signature, as owner, or approval, as subject, are
+listed, unless --all is given.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			addr := common.HexToAddress(args[0])
+			code, err := backend.CodeAt(ctx, addr, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var pending []*ctree.PendingCredential
+			var account common.Address
+			if len(code) > 0 {
+				n, err := node.NewNode(addr, backend)
+				if err != nil {
+					log.Fatal(err)
+				}
+				pending, err = n.PendingCredentials(ctx, nil)
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				account = addr
+				contracts, err := knownContracts(account)
+				if err != nil {
+					log.Fatal(err)
+				}
+				inbox, err := node.Inbox(ctx, nil, backend, account, contracts)
+				if err != nil {
+					log.Fatal(err)
+				}
+				for _, p := range inbox {
+					if all || p.IsWaitingOn(account) {
+						pending = append(pending, p)
+					}
+				}
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(pending); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if len(pending) == 0 {
+				fmt.Printf("%s: no pending credentials\n", Green("Inbox empty"))
+				return
+			}
+			for _, p := range pending {
+				printPending(os.Stdout, p, account)
+			}
+			fmt.Printf("%d pending credentials\n", len(pending))
+		},
+	}
+
+	c.Flags().BoolVar(&all, "all", false, "List all pending credentials of the contracts of the account")
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the pending credentials as JSON")
+	return c
+}
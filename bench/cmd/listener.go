@@ -2,35 +2,94 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"os/signal"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 
-	"github.com/relab/credbench/pkg/course"
+	"github.com/relab/credbench/pkg/ctree/node"
 	"github.com/spf13/cobra"
 )
 
-var listenCmd = &cobra.Command{
-	Use:   "listen",
-	Short: "Listen to blockchain events",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		c, err := getCourseContract(common.HexToAddress(args[0]))
-		if err != nil {
-			log.Fatal(err)
-		}
+func listenCmd() *cobra.Command {
+	var wsURL string
+	var from int64
+	var kinds, subjects, digests []string
 
-		wsbackend, err := ethclient.Dial("ws://127.0.0.1:8546")
-		if err != nil {
-			log.Fatal(err)
-		}
+	c := &cobra.Command{
+		Use:   "listen <contract>...",
+		Short: "Listen to the events of node contracts",
+		Long: `Print the decoded events of the node contracts as JSON lines: registered,
+signed, approved, revoked, aggregated, childAdded, studentEnrolled and
+ownerChanged. The events since --from are replayed before the new ones, and
+the subscription is resumed without losing events when the connection drops.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			q := node.EventQuery{}
+			for _, a := range args {
+				q.Contracts = append(q.Contracts, common.HexToAddress(a))
+			}
+			for _, k := range kinds {
+				kind, err := node.ParseEventKind(k)
+				if err != nil {
+					log.Fatal(err)
+				}
+				q.Kinds = append(q.Kinds, kind)
+			}
+			for _, s := range subjects {
+				q.Subjects = append(q.Subjects, common.HexToAddress(s))
+			}
+			for _, d := range digests {
+				q.Digests = append(q.Digests, common.HexToHash(d))
+			}
+			if from >= 0 {
+				q.FromBlock = big.NewInt(from)
+			}
+
+			wsbackend, err := ethclient.Dial(wsURL)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer wsbackend.Close()
+
+			events := make(chan node.Event)
+			sub, err := node.SubscribeEvents(ctx, wsbackend, q, events)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer sub.Unsubscribe()
 
-		listenCourse(wsbackend, c)
-	},
+			enc := json.NewEncoder(os.Stdout)
+			for {
+				select {
+				case e := <-events:
+					if err := enc.Encode(e); err != nil {
+						log.Fatal(err)
+					}
+				case err := <-sub.Err():
+					log.Fatal(err)
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	c.Flags().StringVar(&wsURL, "ws", "ws://127.0.0.1:8546", "Websocket endpoint of the blockchain backend")
+	c.Flags().Int64Var(&from, "from", -1, "First block replayed (only new events if negative)")
+	c.Flags().StringSliceVar(&kinds, "event", nil, "Only print the events of the kind")
+	c.Flags().StringSliceVar(&subjects, "subject", nil, "Only print the events of the subject")
+	c.Flags().StringSliceVar(&digests, "digest", nil, "Only print the events of the credential digest")
+	return c
 }
 
 func listen(backend *ethclient.Client) {
@@ -61,25 +120,3 @@ func listen(backend *ethclient.Client) {
 		}
 	}
 }
-
-// TODO: pass query
-func listenCourse(backend *ethclient.Client, course *course.Course) {
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{course.Address()},
-	}
-
-	events := make(chan types.Log)
-	sub, err := backend.SubscribeFilterLogs(context.Background(), query, events)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Fatal(err)
-		case e := <-events:
-			log.Infof("%+v\n", e)
-		}
-	}
-}
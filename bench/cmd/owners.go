@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/bench/datastore"
+	"github.com/relab/credbench/pkg/ctree/owners"
+	ownersBinding "github.com/relab/go-credbindings/owners"
+)
+
+func getOwnersContract(address common.Address) (*owners.Owners, error) {
+	o, err := owners.NewOwners(address, backend)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get owners: %v", err)
+	}
+	return o, nil
+}
+
+var listOwnersCmd = &cobra.Command{
+	Use:   "list <contract>",
+	Short: "Return the owners of a contract",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		o, err := getOwnersContract(common.HexToAddress(args[0]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		list, err := o.GetOwnersContext(context.Background(), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Owners:\n")
+		for _, addr := range list {
+			fmt.Printf("%s\n", addr.Hex())
+		}
+	},
+}
+
+var quorumCmd = &cobra.Command{
+	Use:   "quorum <contract>",
+	Short: "Return the number of owners required to sign a credential",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		o, err := getOwnersContract(common.HexToAddress(args[0]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx := context.Background()
+		quorum, err := o.QuorumContext(ctx, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		list, err := o.GetOwnersContext(ctx, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Quorum: %d of %d owners\n", quorum, len(list))
+	},
+}
+
+func changeOwnerCmd() *cobra.Command {
+	var from string
+
+	c := &cobra.Command{
+		Use:   "change <contract> <newOwner>",
+		Short: "Replace an owner of a contract",
+		Long: `Replace the sender, one of the owners of the contract, by a new owner.
+Once the change is confirmed, the course, faculty, node and account records
+in the datastore are updated.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			contract := common.HexToAddress(args[0])
+			newOwner := common.HexToAddress(args[1])
+			sender := defaultSender
+			if from != "" {
+				sender = common.HexToAddress(from)
+			}
+
+			o, err := getOwnersContract(contract)
+			if err != nil {
+				log.Fatal(err)
+			}
+			check := o.CheckChangeOwner(context.Background(), nil, sender, newOwner)
+			if preflight(check, sender, contract, ownersBinding.OwnersABI, "changeOwner", newOwner) {
+				return
+			}
+			opts, err := accountStore.GetTxOpts(sender.Bytes(), backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			r, err := o.Rotate(context.Background(), opts, newOwner, owners.WaitMined(backend))
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Transaction ID: %x\n", r.Tx.Hash())
+
+			err = datastore.ReplaceOwner(db, accountStore, contract, r.OldOwner, r.NewOwner)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s: %s replaced by %s in %s\n", Green("Owner changed"), r.OldOwner.Hex(), r.NewOwner.Hex(), contract.Hex())
+		},
+	}
+
+	c.Flags().StringVar(&from, "from", "", "Owner to be replaced (default account if not given)")
+	addDryRunFlag(c)
+	return c
+}
+
+func newOwnersCmd() *cobra.Command {
+	ownersCmd := &cobra.Command{
+		Use:   "owners",
+		Short: "Manage the owners of a contract",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			rootCmd.PersistentPreRun(cmd, args)
+			err := loadDefaultAccount()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	ownersCmd.AddCommand(
+		listOwnersCmd,
+		quorumCmd,
+		changeOwnerCmd(),
+	)
+	return ownersCmd
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var dryRun bool
+
+// addDryRunFlag adds the --dry-run flag to commands sending transactions.
+func addDryRunFlag(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().BoolVar(&dryRun, "dry-run", false, "Only run the preflight checks and estimate the gas of the transaction")
+	}
+}
+
+// preflight fails if the preflight check of a transaction failed. On a dry
+// run, it estimates the gas of the transaction sent by from and returns
+// true, telling the command not to send it. The checks only read the
+// chain, so commands take the nonce of the transaction after them.
+func preflight(check error, from common.Address, contract common.Address, contractABI string, method string, params ...interface{}) bool {
+	if check != nil {
+		log.Fatal(check)
+	}
+	if !dryRun {
+		return false
+	}
+	gas, err := executor.EstimateTX(from, contract, contractABI, method, params...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %s of %s by %s (gas %d)\n", Green("Preflight passed"), method, contract.Hex(), from.Hex(), gas)
+	return true
+}
+
+// preflightDeploy returns true on a dry run, after estimating the gas of the
+// deployment of a contract by from.
+func preflightDeploy(from common.Address, name, contractABI, contractBin string, params ...interface{}) bool {
+	if !dryRun {
+		return false
+	}
+	gas, err := executor.EstimateDeploy(from, contractABI, contractBin, params...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: deploy %s by %s (gas %d)\n", Green("Preflight passed"), name, from.Hex(), gas)
+	return true
+}
@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/accounts"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/quorum"
+)
+
+// accountKey returns the private key of an account of the datastore.
+func accountKey(address common.Address) (*ecdsa.PrivateKey, error) {
+	acc, err := accountStore.GetAccount(address.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(acc.Address) == 0 {
+		return nil, fmt.Errorf("account %s not found", address.Hex())
+	}
+	return accounts.HexToKey(acc.HexKey), nil
+}
+
+// newRegistration creates the registration of args <contract> <subject> <digest>.
+func newRegistration(args []string, witnesses []string) (*quorum.Registration, error) {
+	chainID, err := backend.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	w := make([]common.Address, len(witnesses))
+	for i, a := range witnesses {
+		w[i] = common.HexToAddress(a)
+	}
+	return quorum.NewRegistration(chainID, common.HexToAddress(args[0]), common.HexToAddress(args[1]), common.HexToHash(args[2]), w), nil
+}
+
+// printRegistration prints the fields of the typed data of a registration.
+func printRegistration(title string, r *quorum.Registration) {
+	fmt.Printf("%s registration on chain %s:\n", title, r.ChainID)
+	fmt.Printf("  contract:  %s\n", r.Contract.Hex())
+	fmt.Printf("  subject:   %s\n", r.Subject.Hex())
+	fmt.Printf("  digest:    %s\n", r.Digest.Hex())
+	fmt.Printf("  witnesses: %s\n", addressList(r.Witnesses))
+}
+
+func signRegistrationCmd() *cobra.Command {
+	var from, output, collector string
+	var witnesses []string
+
+	c := &cobra.Command{
+		Use:   "sign <contract> <subject> <digest>",
+		Short: "Sign a credential registration off-chain",
+		Long: `Sign the EIP-712 registration of a credential by the node contract with
+the key of an owner. The signature is written to a file, to be merged with
+the signatures of the other owners, or sent to a collector, in which case
+the registration served by the collector is signed only if it is the one
+given by the arguments, on the chain of the backend.`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			signer := defaultSender
+			if from != "" {
+				signer = common.HexToAddress(from)
+			}
+			key, err := accountKey(signer)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r, err := newRegistration(args, witnesses)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if collector != "" {
+				served, err := quorum.FetchRegistration(ctx, collector)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if !served.Equal(r) {
+					printRegistration("Expected", r)
+					printRegistration("Served", served)
+					log.Fatalf("collector %s serves a different registration, not signing it", collector)
+				}
+			}
+			printRegistration("Signing", r)
+			sig, err := r.Sign(key)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if collector != "" {
+				status, err := quorum.PostSignature(ctx, collector, sig)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("%s: %d of %d signatures\n", Green("Signature collected"), len(status.Signers), status.Quorum)
+				return
+			}
+			s := quorum.NewSignedRegistration(r)
+			if err := s.Add(sig); err != nil {
+				log.Fatal(err)
+			}
+			if output == "" {
+				output = fmt.Sprintf("%s-%s.json", r.Digest.Hex(), signer.Hex())
+			}
+			if err := s.WriteFile(output); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s by %s written to %s\n", Green("Registration signed"), signer.Hex(), output)
+		},
+	}
+
+	c.Flags().StringVar(&from, "from", "", "Owner signing the registration (default account if not given)")
+	c.Flags().StringVarP(&output, "output", "o", "", "File of the signed registration")
+	c.Flags().StringVar(&collector, "collector", "", "URL of the collector of the signatures")
+	c.Flags().StringSliceVar(&witnesses, "witnesses", nil, "Witnesses of the credential")
+	return c
+}
+
+func mergeSignaturesCmd() *cobra.Command {
+	var output string
+
+	c := &cobra.Command{
+		Use:   "merge <file>...",
+		Short: "Merge the signatures of a registration signed by different owners",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := quorum.MergeFiles(args...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := s.WriteFile(output); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s: %d signatures written to %s\n", Green("Signatures merged"), len(s.Signatures), output)
+		},
+	}
+
+	c.Flags().StringVarP(&output, "output", "o", "signed.json", "File of the merged registration")
+	return c
+}
+
+func collectSignaturesCmd() *cobra.Command {
+	var listen, output string
+	var witnesses []string
+
+	c := &cobra.Command{
+		Use:   "collect <contract> <subject> <digest>",
+		Short: "Collect the signatures of the owners over HTTP",
+		Long: `Serve the registration of a credential until it is signed by a quorum of
+the owners of the node contract, and write the signed registration to a file.
+Owners sign with 'quorum sign --collector <url> <contract> <subject> <digest>'
+or with eth_signTypedData_v4 on the typed data served at <url>/typeddata,
+posting their signature to <url>/signatures.`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			r, err := newRegistration(args, witnesses)
+			if err != nil {
+				log.Fatal(err)
+			}
+			n, err := node.NewNode(r.Contract, backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			owners, err := n.GetOwnersContext(ctx, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			q, err := n.QuorumContext(ctx, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			collector := quorum.NewCollector(r, owners, q)
+			ln, err := net.Listen("tcp", listen)
+			if err != nil {
+				log.Fatal(err)
+			}
+			srv := &http.Server{Handler: collector}
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					log.Fatal(err)
+				}
+			}()
+			fmt.Printf("Collecting %d of %d signatures at http://%s\n", q, len(owners), ln.Addr())
+
+			s, err := collector.Wait(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Fatal(err)
+			}
+			if err := s.WriteFile(output); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s: signed by %s, written to %s\n", Green("Quorum reached"), signerList(s), output)
+		},
+	}
+
+	c.Flags().StringVar(&listen, "listen", "127.0.0.1:8600", "Address of the collector")
+	c.Flags().StringVarP(&output, "output", "o", "signed.json", "File of the signed registration")
+	c.Flags().StringSliceVar(&witnesses, "witnesses", nil, "Witnesses of the credential")
+	return c
+}
+
+func signerList(s *quorum.SignedRegistration) string {
+	return addressList(s.Signers())
+}
+
+func addressList(addresses []common.Address) string {
+	hexes := make([]string, len(addresses))
+	for i, a := range addresses {
+		hexes[i] = a.Hex()
+	}
+	return strings.Join(hexes, ", ")
+}
+
+func submitRegistrationCmd() *cobra.Command {
+	var from string
+
+	c := &cobra.Command{
+		Use:   "submit <file>",
+		Short: "Submit a quorum signed registration in a single transaction",
+		Long: `Verify the signatures of the registration against the current owners and
+quorum of the node contract and relay it with registerCredentialWithSignatures.
+Any account can submit it. Node contracts without this entry point refuse the
+submission; --dry-run only verifies the registration and reports the size of
+the submission and whether the node supports it.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			s, err := quorum.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			sender := defaultSender
+			if from != "" {
+				sender = common.HexToAddress(from)
+			}
+			n, err := node.NewNode(s.Contract, backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			submitter := quorum.NewSubmitter(backend)
+
+			if dryRun {
+				if err := s.VerifyNode(ctx, nil, n); err != nil {
+					log.Fatal(err)
+				}
+				input, err := s.Calldata()
+				if err != nil {
+					log.Fatal(err)
+				}
+				supported, err := submitter.Supported(ctx, s.Contract)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("%s: %s signed by %s (%d bytes of calldata)\n", Green("Registration verified"), s.Digest.Hex(), signerList(s), len(input))
+				if !supported {
+					fmt.Printf("%s: %v\n", Red("Not submitted"), quorum.ErrUnsupported)
+				}
+				return
+			}
+			opts, err := accountStore.GetTxOpts(sender.Bytes(), backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tx, err := submitter.Submit(ctx, opts, s, n)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Transaction ID: %x\n", tx.Hash())
+			fmt.Printf("%s: %s signed by %s\n", Green("Registration submitted"), s.Digest.Hex(), signerList(s))
+		},
+	}
+
+	c.Flags().StringVar(&from, "from", "", "Account submitting the registration (default account if not given)")
+	addDryRunFlag(c)
+	return c
+}
+
+func newQuorumCmd() *cobra.Command {
+	signingCmd := &cobra.Command{
+		Use:   "quorum",
+		Short: "Sign credential registrations off-chain and submit them at once",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			rootCmd.PersistentPreRun(cmd, args)
+			err := loadDefaultAccount()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	signingCmd.AddCommand(
+		signRegistrationCmd(),
+		mergeSignaturesCmd(),
+		collectSignaturesCmd(),
+		submitRegistrationCmd(),
+	)
+	return signingCmd
+}
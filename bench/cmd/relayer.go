@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/relab/credbench/pkg/ctree/relay"
+)
+
+// relayerURL is the relayer submitting the approvals of the students, who
+// send them directly if it is empty.
+var relayerURL string
+
+// relayApproval signs the approval of the credential by the student and
+// sends it to the relayer, returning the transaction to wait for.
+func relayApproval(ctx context.Context, url string, student, contract common.Address, digest [32]byte) (*types.Transaction, error) {
+	key, err := accountKey(student)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the account store counts the approvals queued by the relayer, which
+	// the pending nonce of the student does not
+	opts, err := accountStore.GetTxOpts(student.Bytes(), backend)
+	if err != nil {
+		return nil, err
+	}
+	a, err := relay.PrepareApproval(ctx, backend, key, types.LatestSignerForChainID(chainID), opts.Nonce.Uint64(), contract, digest, gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := relay.PostApproval(ctx, url, a)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("approval %s of %s queued by the relayer", resp.Hash.Hex(), student.Hex())
+	return a.Tx, nil
+}
+
+func serveRelayerCmd() *cobra.Command {
+	var from, listen string
+	var batch int
+	var interval time.Duration
+	var maxGas uint64
+	var minGasPrice int64
+
+	c := &cobra.Command{
+		Use:   "serve",
+		Short: "Submit the credential approvals of students from a funded account",
+		Long: `Serve a relayer accepting approveCredential transactions signed by students
+without ether. The relayer funds their exact cost from the sponsor account and
+broadcasts them in batches. Students send them with 'course approve --relayer'
+and the test runner with 'test run --relayer'. The throughput of the relayer
+is served at /stats and printed on exit.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			sponsor := defaultSender
+			if from != "" {
+				sponsor = common.HexToAddress(from)
+			}
+			chainID, err := backend.ChainID(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r := relay.NewRelayer(backend, types.LatestSignerForChainID(chainID), func() (*bind.TransactOpts, error) {
+				return accountStore.GetTxOpts(sponsor.Bytes(), backend)
+			})
+			r.BatchSize = batch
+			r.Interval = interval
+			r.MaxGas = maxGas
+			if minGasPrice > 0 {
+				r.GasPrice = big.NewInt(minGasPrice)
+			}
+
+			ln, err := net.Listen("tcp", listen)
+			if err != nil {
+				log.Fatal(err)
+			}
+			srv := &http.Server{Handler: relay.Handler(r)}
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					log.Fatal(err)
+				}
+			}()
+			go func() {
+				for res := range r.Results() {
+					if res.Err != nil {
+						log.Errorf("approval %s of %s failed: %v", res.Approval.Hash().Hex(), res.Approval.Subject.Hex(), res.Err)
+					}
+				}
+			}()
+			fmt.Printf("Relaying approvals funded by %s at http://%s\n", sponsor.Hex(), ln.Addr())
+
+			if err := r.Run(ctx); err != nil && err != context.Canceled {
+				log.Fatal(err)
+			}
+			_ = srv.Shutdown(context.Background())
+			fmt.Printf("%s: %s\n", Green("Relayer stopped"), r.Stats())
+		},
+	}
+
+	c.Flags().StringVar(&from, "from", "", "Sponsor account funding the approvals (default account if not given)")
+	c.Flags().StringVar(&listen, "listen", "127.0.0.1:8700", "Address of the relayer")
+	c.Flags().IntVar(&batch, "batch", 32, "Maximum number of approvals per batch")
+	c.Flags().DurationVar(&interval, "interval", time.Second, "Longest wait for a batch to fill")
+	c.Flags().Uint64Var(&maxGas, "max-gas", 500000, "Maximum gas limit funded per approval")
+	c.Flags().Int64Var(&minGasPrice, "min-gas-price", 0, "Minimum gas price of the approvals in wei")
+	return c
+}
+
+func newRelayerCmd() *cobra.Command {
+	relayerCmd := &cobra.Command{
+		Use:   "relayer",
+		Short: "Relay the credential approvals of students",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			rootCmd.PersistentPreRun(cmd, args)
+			err := loadDefaultAccount()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	relayerCmd.AddCommand(serveRelayerCmd())
+	return relayerCmd
+}
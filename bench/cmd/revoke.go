@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/logrusorgru/aurora"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/bench/datastore"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+// printImpact writes the dependent credentials as an indented tree.
+func printImpact(w io.Writer, r *ctree.ImpactReport, indent string) {
+	revoked := ""
+	if r.Revoked {
+		revoked = fmt.Sprintf(" [%s]", Red("revoked"))
+	}
+	fmt.Fprintf(w, "%sCredential %s%s\n", indent, r.Digest.Hex(), revoked)
+	fmt.Fprintf(w, "%s  contract: %s subject: %s\n", indent, r.Contract.Hex(), r.Subject.Hex())
+	for _, d := range r.Dependents {
+		printImpact(w, d, indent+"    ")
+	}
+}
+
+func revocationImpactCmd() *cobra.Command {
+	var plan, asJSON bool
+
+	c := &cobra.Command{
+		Use:   "impact",
+		Short: "Lists the credentials that depend on a credential to be revoked",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			cAddr := common.HexToAddress(args[0])
+			digest := common.HexToHash(args[1])
+
+			known, err := datastore.NodeAddresses(db)
+			if err != nil {
+				log.Fatal(err)
+			}
+			report, err := node.RevocationImpact(context.Background(), nil, backend, cAddr, digest, known)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if asJSON {
+				out := struct {
+					*ctree.ImpactReport
+					Plan []ctree.Step `json:"plan,omitempty"`
+				}{ImpactReport: report}
+				if plan {
+					out.Plan = report.Plan()
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(out); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			printImpact(os.Stdout, report, "")
+			affected := report.Affected()
+			if len(affected) == 0 {
+				fmt.Printf("%s: no credential depends on %s\n", Green("No impact"), digest.Hex())
+				return
+			}
+			subjects := report.Subjects()
+			fmt.Printf("%s: %d dependent credentials of %d subjects\n", Yellow("Impact"), len(affected), len(subjects))
+			for _, s := range subjects {
+				fmt.Printf("  subject %s\n", s.Hex())
+			}
+			if plan {
+				fmt.Println("Plan:")
+				for i, s := range report.Plan() {
+					fmt.Printf("  %d. %s %s for subject %s", i+1, s.Action, s.Contract.Hex(), s.Subject.Hex())
+					if s.Digest != (common.Hash{}) {
+						fmt.Printf(" credential %s", s.Digest.Hex())
+					}
+					if s.Action == ctree.ActionReissue {
+						fmt.Printf(" with witnesses [%s]", addressList(s.Witnesses))
+					}
+					fmt.Println()
+				}
+			}
+		},
+	}
+
+	c.Flags().BoolVar(&plan, "plan", false, "Print the follow-up revocations and re-issuances")
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the impact as JSON")
+	return c
+}
+
+func newRevokeCmd() *cobra.Command {
+	revokeCmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Analyze credential revocations",
+	}
+	revokeCmd.AddCommand(
+		revocationImpactCmd(),
+	)
+	return revokeCmd
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/relab/credbench/bench/genesis"
 	"github.com/relab/credbench/bench/transactor"
 	"github.com/relab/credbench/pkg/client"
+	"github.com/relab/credbench/pkg/ctree/notary"
 	"github.com/relab/credbench/pkg/fileutils"
 
 	pb "github.com/relab/credbench/bench/proto"
@@ -81,8 +82,8 @@ var rootCmd = &cobra.Command{
 
 func Execute() {
 	rootCmd.AddCommand(
-		listenCmd,
-		genesisCmd,
+		listenCmd(),
+		newGenesisCmd(),
 		exportHelmCmd,
 		newTestCmd(),
 		newAccountCmd(),
@@ -90,6 +91,14 @@ func Execute() {
 		newCourseCmd(),
 		newFacultyCmd(),
 		newVerifyCmd(),
+		newBundleCmd(),
+		newRevokeCmd(),
+		newInboxCmd(),
+		newOwnersCmd(),
+		newQuorumCmd(),
+		newRelayerCmd(),
+		newCredentialCmd(),
+		newTreeCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -203,7 +212,16 @@ func setupDB(dbpath, dbfile string) (err error) {
 	if err != nil {
 		return err
 	}
-	// TODO: initialize credential store
+
+	err = datastore.CreateNodeStore(db)
+	if err != nil {
+		return err
+	}
+
+	err = datastore.CreateCredentialStore(db)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -217,6 +235,11 @@ func parseConfigFile() {
 	consensus = viper.GetString("chain.consensus")
 	dbPath = viper.GetString("database.path")
 	dbFile = viper.GetString("database.filename")
+	for _, name := range viper.GetStringSlice("revocation_reasons") {
+		if _, err := notary.DefaultReasons.Register(name); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
 func defaultConfigPath() string {
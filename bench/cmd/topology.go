@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/relab/credbench/bench/datastore"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/topology"
+
+	pb "github.com/relab/credbench/bench/proto"
+	nodeBinding "github.com/relab/go-credbindings/node"
+)
+
+// Deploy the credential tree described by a topology file.
+func generateTopologyCmd() *cobra.Command {
+	var output string
+
+	c := &cobra.Command{
+		Use:   "topology <spec.json|spec.yaml>",
+		Short: "Deploy the credential tree described by a topology file",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("please specify the topology file")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			log.Infoln("Reading topology at:", args[0])
+			spec, err := topology.Load(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if dryRun {
+				libs, err := topologyLibs()
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := preflightTopology(spec, libs); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			deployment, err := deployTopology(spec)
+			if deployment != nil {
+				if rerr := recordTopology(deployment); rerr != nil {
+					log.Error(rerr)
+				}
+				if output != "" {
+					if werr := writeDeployment(output, deployment); werr != nil {
+						log.Error(werr)
+					}
+				}
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, n := range deployment.Nodes {
+				log.Infof("%s deployed at %s", n.Path, n.Address.Hex())
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&output, "output", "o", "", "Write the deployed topology to this JSON file")
+	addDryRunFlag(c)
+	return c
+}
+
+// topologyLibs returns the deployed libraries linked by the node contracts.
+func topologyLibs() (map[string]string, error) {
+	aggregatorAddr := viper.GetString("deployed_libs.aggregator")
+	if aggregatorAddr == "" {
+		return nil, errors.New("Aggregator contract not deployed. Please, deploy it first")
+	}
+	notaryAddr := viper.GetString("deployed_libs.notary")
+	if notaryAddr == "" {
+		return nil, errors.New("Notary contract not deployed. Please, deploy it first")
+	}
+	return map[string]string{
+		"CredentialSum": aggregatorAddr,
+		"Notary":        notaryAddr,
+	}, nil
+}
+
+// preflightTopology estimates the gas of deploying each node of the
+// topology. The children are added once their parent is deployed, so
+// those transactions are only counted.
+func preflightTopology(spec *topology.Spec, libs map[string]string) error {
+	plan, err := spec.Plan()
+	if err != nil {
+		return err
+	}
+	bin, err := linkContract(nodeBinding.NodeBin, libs)
+	if err != nil {
+		return err
+	}
+	children := 0
+	for _, n := range plan.Nodes {
+		role := node.InnerRole
+		if n.Leaf {
+			role = node.LeafRole
+		}
+		preflightDeploy(defaultSender, n.Path, nodeBinding.NodeABI, bin, role, n.Owners, n.Quorum)
+		if n.Parent != "" {
+			children++
+		}
+	}
+	fmt.Printf("%d nodes to deploy and %d children to add\n", len(plan.Nodes), children)
+	return nil
+}
+
+func deployTopology(spec *topology.Spec) (*topology.Deployment, error) {
+	libs, err := topologyLibs()
+	if err != nil {
+		return nil, err
+	}
+	txOpts := func(account common.Address) (*bind.TransactOpts, error) {
+		return accountStore.GetTxOpts(account.Bytes(), backend)
+	}
+	d := topology.NewDeployer(backend, libs, defaultSender, txOpts)
+	return d.Deploy(context.Background(), spec)
+}
+
+// recordTopology stores the deployed nodes and adds them to the contracts of their owners.
+func recordTopology(deployment *topology.Deployment) error {
+	for _, n := range deployment.Nodes {
+		if n.Address == (common.Address{}) {
+			continue
+		}
+		var parent []byte
+		if p := deployment.Node(n.Parent); p != nil {
+			parent = p.Address.Bytes()
+		}
+		ns := datastore.NewNodeStore(db, n.Address)
+		err := ns.PutNode(&pb.Node{
+			Address:   n.Address.Bytes(),
+			Path:      n.Path,
+			Leaf:      n.Leaf,
+			Owners:    datastore.AddressToBytes(n.Owners),
+			Quorum:    uint32(n.Quorum),
+			Parent:    parent,
+			Children:  datastore.AddressToBytes(n.Children),
+			CreatedOn: timestamppb.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, o := range n.Owners {
+			account, err := accountStore.GetAccount(o.Bytes())
+			if err != nil {
+				return err
+			}
+			if len(account.Address) == 0 {
+				continue
+			}
+			account.Contracts = append(account.Contracts, n.Address.Bytes())
+			err = accountStore.PutAccount(account)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeDeployment(filename string, deployment *topology.Deployment) error {
+	data, err := json.MarshalIndent(deployment, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+func showTreeCmd() *cobra.Command {
+	var format string
+	var workers int
+	var block uint64
+
+	c := &cobra.Command{
+		Use:   "show <root-contract> <subject>",
+		Short: "Shows the credential tree of a subject",
+		Long: `Shows the credential tree of a subject as stored on-chain: the contracts,
+the credentials they issued to the subject, their roots and their signer,
+approval and revocation state. The tree is printed as an indented text tree,
+a Graphviz graph (e.g. 'tree show --format dot ... | dot -Tsvg > tree.svg')
+or JSON.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			f, err := ctree.ParseFormat(format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			opts := &ctree.CallOpts{}
+			if block > 0 {
+				opts.BlockNumber = new(big.Int).SetUint64(block)
+			}
+			report, err := node.CredentialTree(ctx, opts, backend, common.HexToAddress(args[0]), common.HexToAddress(args[1]), workers)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if f == ctree.FormatText {
+				printReport(os.Stdout, report)
+				return
+			}
+			if err := report.Render(os.Stdout, f); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	c.Flags().StringVar(&format, "format", "text", "Output format (text|dot|json)")
+	c.Flags().IntVar(&workers, "workers", node.DefaultTreeWorkers, "Maximum number of nodes walked concurrently")
+	c.Flags().Uint64Var(&block, "block", 0, "Show the tree at the given block number (requires an archive node)")
+	return c
+}
+
+func newTreeCmd() *cobra.Command {
+	treeCmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Explore credential trees",
+	}
+	treeCmd.AddCommand(showTreeCmd())
+	return treeCmd
+}
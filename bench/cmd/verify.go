@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -10,30 +17,89 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/relab/credbench/pkg/ctree"
 	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/encode"
+
+	pb "github.com/relab/credbench/pkg/schemes"
 )
 
-var onChain bool
+var (
+	onChain bool
+	atBlock uint64
+	atTime  string
+)
 
-var verifyCredentialTreeCmd = &cobra.Command{
-	Use:   "tree",
-	Short: "Verifies a credential tree",
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		cAddr := common.HexToAddress(args[0])
-		c, err := node.NewNode(cAddr, backend)
+// parseTime parses a unix timestamp or a RFC3339 date.
+func parseTime(s string) (uint64, error) {
+	if ts, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(t.Unix()), nil
+}
+
+// verifyCallOpts returns the call options used to query the contracts
+// at the block given by --block or --at, or at the latest block.
+func verifyCallOpts(ctx context.Context) *ctree.CallOpts {
+	opts := &ctree.CallOpts{}
+	switch {
+	case atBlock > 0:
+		opts.BlockNumber = new(big.Int).SetUint64(atBlock)
+	case atTime != "":
+		ts, err := parseTime(atTime)
 		if err != nil {
 			log.Fatal(err)
 		}
-		sAddr := common.HexToAddress(args[1])
-		start := time.Now()
-		if err = c.VerifyCredentialTree(onChain, nil, sAddr); err != nil {
-			elapsed := time.Since(start)
-			log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
+		opts.BlockNumber, err = node.BlockAt(ctx, backend, ts)
+		if err != nil {
+			log.Fatal(err)
 		}
-		elapsed := time.Since(start)
-		fmt.Printf("%s credential tree! Verified in %v\n", Green("Valid"), elapsed)
-	},
+		fmt.Printf("Verifying at block %v\n", opts.BlockNumber)
+	}
+	return opts
+}
+
+func verifyCredentialTreeCmd() *cobra.Command {
+	var workers int
+
+	c := &cobra.Command{
+		Use:   "tree",
+		Short: "Verifies a credential tree",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			cAddr := common.HexToAddress(args[0])
+			c, err := node.NewNode(cAddr, backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sAddr := common.HexToAddress(args[1])
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			opts := verifyCallOpts(ctx)
+			start := time.Now()
+			if onChain {
+				err = c.VerifyCredentialTree(ctx, true, opts, sAddr)
+			} else {
+				err = c.VerifyCredentialTreeContext(ctx, opts, sAddr, workers)
+			}
+			if err != nil {
+				elapsed := time.Since(start)
+				log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("%s credential tree! Verified in %v\n", Green("Valid"), elapsed)
+		},
+	}
+
+	c.Flags().IntVar(&workers, "workers", node.DefaultTreeWorkers, "Maximum number of nodes verified concurrently (off-chain only)")
+	return c
 }
 
 var verifyCredentialRootCmd = &cobra.Command{
@@ -48,8 +114,10 @@ var verifyCredentialRootCmd = &cobra.Command{
 		}
 		sAddr := common.HexToAddress(args[1])
 		root := common.HexToHash(args[2])
+		ctx := context.Background()
+		opts := verifyCallOpts(ctx)
 		start := time.Now()
-		if err = c.VerifyCredentialRoot(onChain, nil, sAddr, root); err != nil {
+		if err = c.VerifyCredentialRoot(ctx, onChain, opts, sAddr, root); err != nil {
 			elapsed := time.Since(start)
 			log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
 		}
@@ -70,13 +138,30 @@ var verifyCredentialCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
+		ctx := context.Background()
+		opts := verifyCallOpts(ctx)
 		start := time.Now()
-		if err := c.VerifyCredential(onChain, nil, sAddr, digest); err != nil {
+		if onChain || opts.BlockNumber == nil {
+			err = c.VerifyCredential(ctx, onChain, opts, sAddr, digest)
+		} else {
+			err = c.VerifyCredentialAt(ctx, sAddr, digest, opts.BlockNumber)
+		}
+		if err != nil {
 			elapsed := time.Since(start)
 			log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
 		}
 		elapsed := time.Since(start)
 		fmt.Printf("%s credential! Verified in %v\n", Green("Valid"), elapsed)
+		if opts.BlockNumber == nil {
+			return
+		}
+		if revoked, _ := c.IsRevoked(ctx, nil, digest); revoked {
+			rp, err := c.GetRevokedProof(ctx, nil, digest)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s: credential was revoked later at block %v (reason %s)\n", Yellow("Warning"), rp.RevokedBlock, notary.Reason(rp.Reason))
+		}
 	},
 }
 
@@ -91,8 +176,10 @@ var verifyIssuedCredentialsCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		sAddr := common.HexToAddress(args[1])
+		ctx := context.Background()
+		opts := verifyCallOpts(ctx)
 		start := time.Now()
-		if err := c.VerifyIssuedCredentials(onChain, nil, sAddr); err != nil {
+		if err := c.VerifyIssuedCredentials(ctx, onChain, opts, sAddr); err != nil {
 			elapsed := time.Since(start)
 			log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
 		}
@@ -101,6 +188,281 @@ var verifyIssuedCredentialsCmd = &cobra.Command{
 	},
 }
 
+// inclusionProof is the file format of the credential inclusion proofs.
+type inclusionProof struct {
+	Contract common.Address      `json:"contract"`
+	Subject  common.Address      `json:"subject"`
+	Root     common.Hash         `json:"root"`
+	Proof    *encode.MerkleProof `json:"proof"`
+}
+
+func verifyInclusionCmd() *cobra.Command {
+	var prove string
+
+	c := &cobra.Command{
+		Use:   "inclusion",
+		Short: "Verifies that a credential belongs to the Merkle root of a subject",
+		Long: `Verifies that a credential belongs to the Merkle root of the credentials
+of a subject. The node contract only stores the aggregated root, so all the
+credentials of the subject are read from the contract to recompute the Merkle
+root and to check that they are the aggregated ones.
+If --prove is given, the inclusion proof of the given digest is written
+to the proof file instead.`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			cAddr := common.HexToAddress(args[0])
+			c, err := node.NewNode(cAddr, backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sAddr := common.HexToAddress(args[1])
+			ctx := context.Background()
+			opts := verifyCallOpts(ctx)
+
+			if prove != "" {
+				root, proof, err := c.CredentialInclusionProof(ctx, opts, sAddr, common.HexToHash(prove))
+				if err != nil {
+					log.Fatal(err)
+				}
+				data, err := json.MarshalIndent(&inclusionProof{cAddr, sAddr, root, proof}, "", "  ")
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := os.WriteFile(args[2], data, 0o644); err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("Inclusion proof written to %s\n", args[2])
+				return
+			}
+
+			data, err := os.ReadFile(args[2])
+			if err != nil {
+				log.Fatal(err)
+			}
+			var p inclusionProof
+			if err := json.Unmarshal(data, &p); err != nil {
+				log.Fatal(err)
+			}
+			if p.Proof == nil {
+				log.Fatal("missing inclusion proof")
+			}
+			if p.Contract != cAddr || p.Subject != sAddr {
+				log.Fatalf("proof issued by %s to %s", p.Contract.Hex(), p.Subject.Hex())
+			}
+			start := time.Now()
+			if err := c.VerifyCredentialInclusionFromDigests(ctx, opts, sAddr, p.Root, p.Proof); err != nil {
+				elapsed := time.Since(start)
+				log.Fatalf("Verification failed in %v with error: %v\n", elapsed, err)
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("%s credential %s! Verified in %v\n", Green("Valid"), p.Proof.Leaf.Hex(), elapsed)
+		},
+	}
+
+	c.Flags().StringVar(&prove, "prove", "", "Write the inclusion proof of the given credential digest")
+	return c
+}
+
+// formatTimestamp formats a block timestamp.
+func formatTimestamp(ts *big.Int) string {
+	return time.Unix(ts.Int64(), 0).UTC().Format(time.RFC3339)
+}
+
+func verifyDocumentCmd() *cobra.Command {
+	var asJSON bool
+
+	c := &cobra.Command{
+		Use:   "document",
+		Short: "Verifies a JSON credential document against its issuing contract",
+		Long: `Verifies a JSON credential document, or verifiable credential, against its
+issuing contract. The digest of the credential proof is computed with
+--hash-algorithm, or the algorithm of the status of a verifiable credential.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			var doc pb.Credential
+			if vc, err := pb.ParseVC(data); err == nil {
+				if doc, err = pb.FromVC(vc); err != nil {
+					log.Fatal(err)
+				}
+				if s := vc.CredentialStatus; s != nil && !cmd.Flags().Changed("hash-algorithm") {
+					hashAlgorithm = s.DigestAlgorithm
+				}
+			} else if doc, err = pb.ParseCredential(data); err != nil {
+				log.Fatal(err)
+			}
+			alg, err := pb.ParseHashAlgorithm(hashAlgorithm)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			start := time.Now()
+			ctx := context.Background()
+			report, err := node.VerifyDocumentWith(ctx, verifyCallOpts(ctx), backend, doc, alg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			elapsed := time.Since(start)
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			fmt.Printf("Credential %s issued by %s\n", report.Credential.Digest.Hex(), report.Contract.Hex())
+			for _, err := range report.Credential.Errors {
+				fmt.Printf("  %s %v\n", Red("error:"), err)
+			}
+			printValidity(report.Validity)
+			for _, m := range report.Mismatches {
+				fmt.Printf("  %s %v\n", Red("mismatch:"), m)
+			}
+			if !report.Valid() {
+				fmt.Printf("%s credential document!\n", Red("Invalid"))
+				return
+			}
+			fmt.Printf("%s credential document! Verified in %v\n", Green("Valid"), elapsed)
+		},
+	}
+
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	addHashAlgorithmFlag(c)
+	return c
+}
+
+// printValidity prints the validity period check of a document, if any.
+func printValidity(v *ctree.ValidityReport) {
+	if v == nil {
+		return
+	}
+	status := Green(v.Status())
+	if v.Err != nil {
+		status = Red(v.Status())
+	}
+	fmt.Printf("  validity: %s at block %s (%s)\n", status, v.BlockNumber, formatTimestamp(v.BlockTimestamp))
+}
+
+func verifyDisclosureCmd() *cobra.Command {
+	var asJSON bool
+
+	c := &cobra.Command{
+		Use:   "disclosure <presentation>",
+		Short: "Verifies the claims disclosed by a presentation against the issuing contract",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			p := &pb.Presentation{}
+			if err := json.Unmarshal(data, p); err != nil {
+				log.Fatal(err)
+			}
+
+			ctx := context.Background()
+			report, err := node.VerifyDisclosure(ctx, verifyCallOpts(ctx), backend, p)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			fmt.Printf("%s %s issued by %s to %s\n", p.Type, report.Credential.Digest.Hex(), report.Contract.Hex(), report.Credential.Subject.Hex())
+			for _, d := range p.Claims {
+				fmt.Printf("  %s: %s\n", d.Path, d.Value)
+			}
+			for _, err := range report.Credential.Errors {
+				fmt.Printf("  %s %v\n", Red("error:"), err)
+			}
+			printValidity(report.Validity)
+			if !report.Valid() {
+				fmt.Printf("%s disclosure!\n", Red("Invalid"))
+				return
+			}
+			fmt.Printf("%s disclosure of %d claims!\n", Green("Valid"), len(p.Claims))
+		},
+	}
+
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return c
+}
+
+func verifyReportCmd() *cobra.Command {
+	var workers int
+	var asJSON bool
+
+	c := &cobra.Command{
+		Use:   "report",
+		Short: "Reports the verification of all credentials in a credential tree",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			cAddr := common.HexToAddress(args[0])
+			c, err := node.NewNode(cAddr, backend)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sAddr := common.HexToAddress(args[1])
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			report, err := c.VerifyCredentialTreeReport(ctx, verifyCallOpts(ctx), sAddr, workers)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			printReport(os.Stdout, report)
+			if errs := report.AllErrors(); len(errs) > 0 {
+				fmt.Printf("%s credential tree! Found %d problem(s):\n", Red("Invalid"), len(errs))
+				for _, err := range errs {
+					fmt.Printf("  - %v\n", err)
+				}
+				return
+			}
+			fmt.Printf("%s credential tree!\n", Green("Valid"))
+		},
+	}
+
+	c.Flags().IntVar(&workers, "workers", node.DefaultTreeWorkers, "Maximum number of nodes verified concurrently")
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return c
+}
+
+// colored highlights the failed checks and the errors of the reports.
+var colored = &ctree.TextStyle{
+	Status: func(ok bool) string {
+		if ok {
+			return Green("ok").String()
+		}
+		return Red("failed").String()
+	},
+	Error: func(label string) string { return Red(label).String() },
+}
+
+// printReport writes the verification report as an indented tree.
+func printReport(w io.Writer, r *ctree.VerificationReport) {
+	if err := ctree.WriteText(w, r, colored); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func newVerifyCmd() *cobra.Command {
 	verifyCmd := &cobra.Command{
 		Use:   "verify",
@@ -115,12 +477,19 @@ func newVerifyCmd() *cobra.Command {
 	}
 
 	verifyCmd.PersistentFlags().BoolVar(&onChain, "onchain", false, "perform an on-chain/off-chain verification")
+	verifyCmd.PersistentFlags().Uint64Var(&atBlock, "block", 0, "verify the state at the given block number (requires an archive node)")
+	verifyCmd.PersistentFlags().StringVar(&atTime, "at", "", "verify the state at the given time, as unix timestamp or RFC3339 date (requires an archive node)")
+	verifyCmd.MarkFlagsMutuallyExclusive("block", "at")
 
 	verifyCmd.AddCommand(
 		verifyCredentialCmd,
 		verifyIssuedCredentialsCmd,
-		verifyCredentialTreeCmd,
+		verifyCredentialTreeCmd(),
 		verifyCredentialRootCmd,
+		verifyReportCmd(),
+		verifyInclusionCmd(),
+		verifyDocumentCmd(),
+		verifyDisclosureCmd(),
 	)
 	return verifyCmd
 }
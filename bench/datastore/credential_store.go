@@ -0,0 +1,94 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	proto "google.golang.org/protobuf/proto"
+
+	"github.com/relab/credbench/bench/database"
+	pb "github.com/relab/credbench/bench/proto"
+)
+
+// Bucket("credentials")
+// kv: credential_digest -> CredentialProto
+var (
+	credentialBucket = "credentials"
+)
+
+type CredentialStore struct {
+	store *DataStore
+}
+
+func CreateCredentialStore(db *database.BoltDB) error {
+	return db.CreateBucketPath(credentialBucket)
+}
+
+func NewCredentialStore(db *database.BoltDB) *CredentialStore {
+	return &CredentialStore{
+		store: &DataStore{db: db, path: credentialBucket},
+	}
+}
+
+func (cs *CredentialStore) PutCredential(credential *pb.Credential) error {
+	if credential == nil || len(credential.Digest) == 0 {
+		return ErrEmptyData
+	}
+	value, err := proto.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	return cs.store.db.Put(cs.store.path, credential.Digest, value)
+}
+
+func (cs CredentialStore) GetCredential(digest common.Hash) (*pb.Credential, error) {
+	credential := &pb.Credential{}
+	buf, err := cs.store.db.Get(cs.store.path, digest.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if buf != nil {
+		err := proto.Unmarshal(buf, credential)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return credential, err
+}
+
+// GetCredentials returns the stored credentials whose status at the given
+// time is one of the statuses, or all of them if none is given.
+func (cs CredentialStore) GetCredentials(at time.Time, statuses ...pb.Status) ([]*pb.Credential, error) {
+	var credentials []*pb.Credential
+	err := cs.store.db.IterValues(cs.store.path, func(value []byte) error {
+		credential := &pb.Credential{}
+		if err := proto.Unmarshal(value, credential); err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			credentials = append(credentials, credential)
+			return nil
+		}
+		status := CredentialStatus(credential, at)
+		for _, s := range statuses {
+			if s == status {
+				credentials = append(credentials, credential)
+				break
+			}
+		}
+		return nil
+	})
+	return credentials, err
+}
+
+// CredentialStatus returns the status of the credential at the given time:
+// an issued credential whose validity period ended is expired.
+func CredentialStatus(credential *pb.Credential, at time.Time) pb.Status {
+	if credential.Status != pb.Status_ISSUED {
+		return credential.Status
+	}
+	if until := credential.ValidUntil; until != nil && !at.Before(until.AsTime()) {
+		return pb.Status_EXPIRED
+	}
+	return pb.Status_ISSUED
+}
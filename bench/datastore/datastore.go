@@ -1,6 +1,8 @@
 package datastore
 
 import (
+	"github.com/ethereum/go-ethereum/common"
+
 	"github.com/relab/credbench/bench/database"
 )
 
@@ -8,3 +10,20 @@ type DataStore struct {
 	db   *database.BoltDB
 	path string
 }
+
+// NodeAddresses returns the addresses of the faculties, courses and topology
+// nodes in the datastore.
+func NodeAddresses(db *database.BoltDB) ([]common.Address, error) {
+	var addresses []common.Address
+	for _, bucket := range []string{facultyBucket, courseBucket, nodeBucket} {
+		if !db.BucketExists(bucket) {
+			continue
+		}
+		keys, err := db.Keys(bucket)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, ToETHAddress(keys)...)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,61 @@
+package datastore
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	proto "google.golang.org/protobuf/proto"
+
+	"github.com/relab/credbench/bench/database"
+	pb "github.com/relab/credbench/bench/proto"
+)
+
+// Bucket("nodes")
+// kv: node_address -> NodeProto
+var (
+	nodeBucket = "nodes"
+)
+
+type NodeStore struct {
+	store   *DataStore
+	address common.Address
+}
+
+func CreateNodeStore(db *database.BoltDB) error {
+	return db.CreateBucketPath(nodeBucket)
+}
+
+func NewNodeStore(db *database.BoltDB, nodeAddress common.Address) *NodeStore {
+	return &NodeStore{
+		store:   &DataStore{db: db, path: nodeBucket},
+		address: nodeAddress,
+	}
+}
+
+func (ns *NodeStore) PutNode(node *pb.Node) error {
+	if node == nil {
+		return ErrEmptyData
+	}
+	address := common.BytesToAddress(node.Address)
+	if address == (common.Address{}) {
+		return ErrZeroAddress
+	}
+	value, err := proto.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return ns.store.db.Put(ns.store.path, address.Bytes(), value)
+}
+
+func (ns NodeStore) GetNode() (*pb.Node, error) {
+	node := &pb.Node{}
+	buf, err := ns.store.db.Get(ns.store.path, ns.address.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if buf != nil {
+		err := proto.Unmarshal(buf, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, err
+}
@@ -0,0 +1,94 @@
+package datastore
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/bench/database"
+)
+
+func replaceAddress(addresses [][]byte, oldAddr, newAddr common.Address) bool {
+	replaced := false
+	for i, a := range addresses {
+		if bytes.Equal(a, oldAddr.Bytes()) {
+			addresses[i] = newAddr.Bytes()
+			replaced = true
+		}
+	}
+	return replaced
+}
+
+func removeAddress(addresses [][]byte, addr common.Address) [][]byte {
+	kept := addresses[:0]
+	for _, a := range addresses {
+		if !bytes.Equal(a, addr.Bytes()) {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// ReplaceOwner updates the records of a contract after one of its owners
+// was changed: the evaluators of a course, the adms of a faculty, the owners
+// of a topology node and the contracts of both accounts.
+func ReplaceOwner(db *database.BoltDB, as *EthAccountStore, contract, oldOwner, newOwner common.Address) error {
+	if db.BucketExists(courseBucket) {
+		cs := NewCourseStore(db, contract)
+		course, err := cs.GetCourse()
+		if err != nil {
+			return err
+		}
+		if replaceAddress(course.Evaluators, oldOwner, newOwner) {
+			if err := cs.PutCourse(course); err != nil {
+				return err
+			}
+		}
+	}
+	if db.BucketExists(facultyBucket) {
+		fs := NewFacultyStore(db, contract)
+		faculty, err := fs.GetFaculty()
+		if err != nil {
+			return err
+		}
+		if replaceAddress(faculty.Adms, oldOwner, newOwner) {
+			if err := fs.PutFaculty(faculty); err != nil {
+				return err
+			}
+		}
+	}
+	if db.BucketExists(nodeBucket) {
+		ns := NewNodeStore(db, contract)
+		node, err := ns.GetNode()
+		if err != nil {
+			return err
+		}
+		if replaceAddress(node.Owners, oldOwner, newOwner) {
+			if err := ns.PutNode(node); err != nil {
+				return err
+			}
+		}
+	}
+
+	old, err := as.GetAccount(oldOwner.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(old.Address) > 0 {
+		old.Contracts = removeAddress(old.Contracts, contract)
+		if err := as.PutAccount(old); err != nil {
+			return err
+		}
+	}
+	acc, err := as.GetAccount(newOwner.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(acc.Address) > 0 {
+		acc.Contracts = append(removeAddress(acc.Contracts, contract), contract.Bytes())
+		if err := as.PutAccount(acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/bench/database"
+	pb "github.com/relab/credbench/bench/proto"
+)
+
+var (
+	testCourse   = common.HexToAddress("0xc0")
+	testFaculty  = common.HexToAddress("0xf0")
+	testOldOwner = common.HexToAddress("0x01")
+	testOwner    = common.HexToAddress("0x02")
+	testNewOwner = common.HexToAddress("0x03")
+)
+
+func newOwnersDB(t *testing.T) (*database.BoltDB, *EthAccountStore) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, CreateCourseStore(db))
+	require.NoError(t, CreateFacultyStore(db))
+	require.NoError(t, CreateNodeStore(db))
+	require.NoError(t, CreateEthAccountStore(db))
+
+	require.NoError(t, NewCourseStore(db, testCourse).PutCourse(&pb.Course{
+		Address:    testCourse.Bytes(),
+		Evaluators: [][]byte{testOldOwner.Bytes(), testOwner.Bytes()},
+	}))
+	require.NoError(t, NewFacultyStore(db, testFaculty).PutFaculty(&pb.Faculty{
+		Address: testFaculty.Bytes(),
+		Adms:    [][]byte{testOldOwner.Bytes()},
+	}))
+	as := NewEthAccountStore(db, big.NewInt(1337))
+	require.NoError(t, as.PutAccount(
+		&pb.Account{Address: testOldOwner.Bytes(), Contracts: [][]byte{testCourse.Bytes(), testFaculty.Bytes()}},
+		&pb.Account{Address: testOwner.Bytes(), Contracts: [][]byte{testCourse.Bytes()}},
+		&pb.Account{Address: testNewOwner.Bytes()},
+	))
+	return db, as
+}
+
+func accountContracts(t *testing.T, as *EthAccountStore, addr common.Address) [][]byte {
+	acc, err := as.GetAccount(addr.Bytes())
+	require.NoError(t, err)
+	return acc.Contracts
+}
+
+func TestReplaceOwner(t *testing.T) {
+	db, as := newOwnersDB(t)
+	require.NoError(t, ReplaceOwner(db, as, testCourse, testOldOwner, testNewOwner))
+
+	c, err := NewCourseStore(db, testCourse).GetCourse()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testNewOwner.Bytes(), testOwner.Bytes()}, c.Evaluators)
+	assert.Equal(t, [][]byte{testFaculty.Bytes()}, accountContracts(t, as, testOldOwner))
+	assert.Equal(t, [][]byte{testCourse.Bytes()}, accountContracts(t, as, testNewOwner))
+	assert.Equal(t, [][]byte{testCourse.Bytes()}, accountContracts(t, as, testOwner))
+
+	// the other contracts of the old owner are left unchanged
+	f, err := NewFacultyStore(db, testFaculty).GetFaculty()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testOldOwner.Bytes()}, f.Adms)
+}
+
+func TestReplaceOwnerNotFound(t *testing.T) {
+	db, as := newOwnersDB(t)
+	unknown := common.HexToAddress("0x0a")
+	require.NoError(t, ReplaceOwner(db, as, testCourse, unknown, testNewOwner))
+
+	c, err := NewCourseStore(db, testCourse).GetCourse()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testOldOwner.Bytes(), testOwner.Bytes()}, c.Evaluators)
+	acc, err := as.GetAccount(unknown.Bytes())
+	require.NoError(t, err)
+	assert.Empty(t, acc.Address, "no account is created for the unknown owner")
+	assert.Equal(t, [][]byte{testCourse.Bytes(), testFaculty.Bytes()}, accountContracts(t, as, testOldOwner))
+
+	// nor records for an unknown contract
+	contract := common.HexToAddress("0x0b")
+	require.NoError(t, ReplaceOwner(db, as, contract, testOldOwner, testNewOwner))
+	keys, err := db.Keys(courseBucket)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testCourse.Bytes()}, keys)
+	f, err := NewFacultyStore(db, testFaculty).GetFaculty()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testOldOwner.Bytes()}, f.Adms)
+}
@@ -1,6 +1,7 @@
 package genesis
 
 import (
+	"fmt"
 	"html/template"
 	"os"
 	"path/filepath"
@@ -31,19 +32,37 @@ type GenesisData struct {
 	Difficulty     string
 	GasLimit       string
 	DefaultBalance string
-	N              int      // len(Accounts) - 1
-	Accounts       []string // Account addresses
+	N              int             // len(Accounts) - 1
+	Accounts       []string        // Account addresses
+	Unfunded       map[string]bool // Accounts without balance
 	ExtraData      hexutil.Bytes
 	Clique         bool
 	CliquePeriod   int
 	CliqueEpoch    int
 }
 
-func GenerateGenesis(datadirPath string, consensus string, accountStore *datastore.EthAccountStore, n int) error {
+// GenerateGenesis creates n accounts funded in the genesis file, except for
+// the last unfunded ones, which are selected as students whose credential
+// approvals are submitted by a relayer.
+func GenerateGenesis(datadirPath string, consensus string, accountStore *datastore.EthAccountStore, n int, unfunded int) error {
+	if unfunded < 0 || unfunded >= n {
+		return fmt.Errorf("invalid number of unfunded accounts: %d of %d", unfunded, n)
+	}
 	accounts, err := CreateAccounts(accountStore, n)
 	if err != nil {
 		return err
 	}
+	students := make([][]byte, 0, unfunded)
+	for _, acc := range accounts[n-unfunded:] {
+		students = append(students, acc.Address)
+	}
+	if len(students) > 0 {
+		_, err = accountStore.SelectAccount(pb.Type_STUDENT, students...)
+		if err != nil {
+			return err
+		}
+		log.Infof("%d student accounts without balance\n", len(students))
+	}
 	// Select one deployer (first account on the genesis)
 	// We are also using it as a signer on the clique
 	deployer := accounts[0].Address
@@ -54,10 +73,10 @@ func GenerateGenesis(datadirPath string, consensus string, accountStore *datasto
 
 	log.Infof("Configured Clique Sealer/Validator: %s\n", common.BytesToAddress(accounts[0].Address).Hex())
 	genesisFile := filepath.Join(datadirPath, "genesis.json")
-	return createGenesisFile(genesisFile, newGenesisData(datadirPath, consensus, accounts))
+	return createGenesisFile(genesisFile, newGenesisData(datadirPath, consensus, accounts, unfunded))
 }
 
-func newGenesisData(datadirPath string, consensus string, accounts datastore.Accounts) *GenesisData {
+func newGenesisData(datadirPath string, consensus string, accounts datastore.Accounts, unfunded int) *GenesisData {
 	if len(accounts) == 0 {
 		log.Fatal("Attempt to create genesis without accounts")
 		return nil
@@ -70,6 +89,10 @@ func newGenesisData(datadirPath string, consensus string, accounts datastore.Acc
 		DefaultBalance: DefaultBalance,
 		N:              len(accounts) - 1,
 		Accounts:       accounts.ToHex(),
+		Unfunded:       make(map[string]bool),
+	}
+	for _, addr := range accounts[len(accounts)-unfunded:].ToHex() {
+		genesis.Unfunded[addr] = true
 	}
 
 	// TODO select n signers accounts instead of only one
@@ -183,7 +206,7 @@ const genesisTmpl = `
     "gasLimit": "{{ .GasLimit }}",
     "timestamp": "0x0",
     "alloc": { {{range $i, $address := .Accounts}}
-        "{{ $address }}": { "balance": "{{ $.DefaultBalance }}" }{{if lt $i $.N}}, {{end}}{{ end }}
+        "{{ $address }}": { "balance": "{{if index $.Unfunded $address}}0{{else}}{{ $.DefaultBalance }}{{end}}" }{{if lt $i $.N}}, {{end}}{{ end }}
     }
 }
 `
@@ -3,6 +3,7 @@ package testconfig
 import (
 	"encoding/json"
 	"os"
+	"time"
 )
 
 type TestConfig struct {
@@ -15,6 +16,16 @@ type TestConfig struct {
 	Evaluators          int    `json:"evaluators"`
 	Exams               int    `json:"exams"`
 	Students            int    `json:"students"`
+	ExamValidity        string `json:"exam_validity,omitempty"` // e.g. 8760h, exams never expire if empty
+}
+
+// ExamValidityPeriod returns how long exam credentials are valid after
+// being issued, zero if they never expire.
+func (c TestConfig) ExamValidityPeriod() (time.Duration, error) {
+	if c.ExamValidity == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.ExamValidity)
 }
 
 func LoadConfig(filename string) (config TestConfig, err error) {
@@ -27,7 +38,7 @@ func LoadConfig(filename string) (config TestConfig, err error) {
 	return
 }
 
-func GenConfigFile(filename string, accountDistribution string, totalAccounts, faculties, adms, semesters, courses, evaluators, exams, students int) error {
+func GenConfigFile(filename string, accountDistribution string, totalAccounts, faculties, adms, semesters, courses, evaluators, exams, students int, examValidity time.Duration) error {
 	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, os.ModePerm)
 	defer file.Close()
 	if err != nil {
@@ -45,6 +56,9 @@ func GenConfigFile(filename string, accountDistribution string, totalAccounts, f
 		Exams:               exams,
 		Students:            students,
 	}
+	if examValidity > 0 {
+		config.ExamValidity = examValidity.String()
+	}
 
 	return json.NewEncoder(file).Encode(config)
 }
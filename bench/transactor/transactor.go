@@ -33,17 +33,20 @@ func NewTransactor(backend *ethclient.Client, gasLimit, gasPrice *big.Int) *Tran
 	}
 }
 
-// SendTX performs a raw transaction and collect gas metrics
-func (t *Transactor) SendTX(contractName string, opts *bind.TransactOpts, contractAddress common.Address, contractABI string, method string, params ...interface{}) (*types.Transaction, error) {
-	sendTime := time.Now().UnixNano()
-
+// EstimateTX estimates the gas of a transaction sent by the given account,
+// without sending it
+func (t *Transactor) EstimateTX(from common.Address, contractAddress common.Address, contractABI string, method string, params ...interface{}) (uint64, error) {
 	parsedABI, err := abi.JSON(strings.NewReader(contractABI))
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	return t.estimate(&bind.TransactOpts{From: from}, contractAddress, parsedABI, method, params...)
+}
+
+func (t *Transactor) estimate(opts *bind.TransactOpts, contractAddress common.Address, parsedABI abi.ABI, method string, params ...interface{}) (uint64, error) {
 	input, err := parsedABI.Pack(method, params...)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	msg := ethereum.CallMsg{
 		From:     opts.From,
@@ -54,7 +57,22 @@ func (t *Transactor) SendTX(contractName string, opts *bind.TransactOpts, contra
 	}
 	gasLimit, err := t.backend.EstimateGas(context.TODO(), msg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		return 0, fmt.Errorf("failed to estimate gas needed: %v", err)
+	}
+	return gasLimit, nil
+}
+
+// SendTX performs a raw transaction and collect gas metrics
+func (t *Transactor) SendTX(contractName string, opts *bind.TransactOpts, contractAddress common.Address, contractABI string, method string, params ...interface{}) (*types.Transaction, error) {
+	sendTime := time.Now().UnixNano()
+
+	parsedABI, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return nil, err
+	}
+	gasLimit, err := t.estimate(opts, contractAddress, parsedABI, method, params...)
+	if err != nil {
+		return nil, err
 	}
 
 	contract := bind.NewBoundContract(contractAddress, parsedABI, t.backend, t.backend, t.backend)
@@ -111,26 +129,40 @@ func (t *Transactor) SendTX(contractName string, opts *bind.TransactOpts, contra
 	return tx, nil
 }
 
-// Deploy performs a raw transaction to deploy a contract and collect gas metrics
-func (t *Transactor) Deploy(opts *bind.TransactOpts, backend bind.ContractBackend, contractABI string, contractCode string, params ...interface{}) (common.Address, *types.Transaction, *bind.BoundContract, error) {
-	sendTime := time.Now().UnixNano()
-
+// EstimateDeploy estimates the gas of a contract deployment by the given
+// account, without sending it
+func (t *Transactor) EstimateDeploy(from common.Address, contractABI string, contractCode string, params ...interface{}) (uint64, error) {
 	parsed, err := abi.JSON(strings.NewReader(contractABI))
 	if err != nil {
-		return common.Address{}, nil, nil, err
+		return 0, err
 	}
+	return t.estimateDeploy(&bind.TransactOpts{From: from}, parsed, contractCode, params...)
+}
 
+func (t *Transactor) estimateDeploy(opts *bind.TransactOpts, parsed abi.ABI, contractCode string, params ...interface{}) (uint64, error) {
 	input, err := parsed.Pack("", params...)
 	if err != nil {
-		return common.Address{}, nil, nil, err
+		return 0, err
 	}
 	msg := ethereum.CallMsg{
 		From:     opts.From,
 		GasPrice: opts.GasPrice,
 		Value:    opts.Value,
-		Data:     input,
+		Data:     append(common.FromHex(contractCode), input...),
 	}
-	gasLimit, err := t.backend.EstimateGas(context.TODO(), msg)
+	return t.backend.EstimateGas(context.TODO(), msg)
+}
+
+// Deploy performs a raw transaction to deploy a contract and collect gas metrics
+func (t *Transactor) Deploy(opts *bind.TransactOpts, backend bind.ContractBackend, contractABI string, contractCode string, params ...interface{}) (common.Address, *types.Transaction, *bind.BoundContract, error) {
+	sendTime := time.Now().UnixNano()
+
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	gasLimit, err := t.estimateDeploy(opts, parsed, contractCode, params...)
 	if err != nil {
 		return common.Address{}, nil, nil, err
 	}
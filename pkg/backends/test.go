@@ -3,6 +3,8 @@ package backends
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
+	"math"
 	"math/big"
 	"time"
 
@@ -10,8 +12,12 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/params"
 
 	ctaccounts "github.com/relab/credbench/pkg/accounts"
 	aggregator "github.com/relab/go-credbindings/aggregator"
@@ -69,12 +75,120 @@ func init() {
 func NewTestBackend() *TestBackend {
 	ethAccounts := make(core.GenesisAlloc)
 	for _, acc := range TestAccounts {
-		ethAccounts[acc.Address] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+		ethAccounts[acc.Address] = core.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))}
 	}
 	backend := backends.NewSimulatedBackend(ethAccounts, 10000000)
 	return &TestBackend{backend, make(map[string]common.Address)}
 }
 
+// TransactOpts returns the transaction options to sign
+// transactions to the simulated chain with the given key.
+func (b *TestBackend) TransactOpts(key *ecdsa.PrivateKey) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(key, b.Blockchain().Config().ChainID)
+	if err != nil {
+		panic(err)
+	}
+	return opts
+}
+
+// CallContract executes a contract call at the given block. Unlike the
+// SimulatedBackend, past blocks are also supported, as in an archive node.
+func (b *TestBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	chain := b.Blockchain()
+	if blockNumber == nil || blockNumber.Cmp(chain.CurrentBlock().Number) == 0 {
+		return b.SimulatedBackend.CallContract(ctx, call, blockNumber)
+	}
+	header := chain.GetHeaderByNumber(blockNumber.Uint64())
+	if header == nil {
+		return nil, errors.New("block does not exist")
+	}
+	stateDB, err := chain.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &core.Message{
+		From:              call.From,
+		To:                call.To,
+		Value:             new(big.Int),
+		GasLimit:          header.GasLimit,
+		GasPrice:          new(big.Int),
+		GasFeeCap:         new(big.Int),
+		GasTipCap:         new(big.Int),
+		Data:              call.Data,
+		SkipAccountChecks: true,
+	}
+	if call.Value != nil {
+		msg.Value = call.Value
+	}
+	if call.Gas != 0 {
+		msg.GasLimit = call.Gas
+	}
+	evm := vm.NewEVM(core.NewEVMBlockContext(header, chain, nil), core.NewEVMTxContext(msg), stateDB, chain.Config(), vm.Config{NoBaseFee: true})
+	res, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return nil, err
+	}
+	return res.Return(), res.Err
+}
+
+func encodeProof(proof [][]byte) []string {
+	nodes := make([]string, len(proof))
+	for i, n := range proof {
+		nodes[i] = hexutil.Encode(n)
+	}
+	return nodes
+}
+
+// GetProof returns the account and storage values of an account with their
+// Merkle proofs at the given block, as the eth_getProof RPC method.
+func (b *TestBackend) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error) {
+	chain := b.Blockchain()
+	header := chain.CurrentHeader()
+	if blockNumber != nil {
+		header = chain.GetHeaderByNumber(blockNumber.Uint64())
+		if header == nil {
+			return nil, errors.New("block does not exist")
+		}
+	}
+	stateDB, err := chain.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := stateDB.GetProof(account)
+	if err != nil {
+		return nil, err
+	}
+	storageHash := types.EmptyRootHash
+	if tr, err := stateDB.StorageTrie(account); err != nil {
+		return nil, err
+	} else if tr != nil {
+		storageHash = tr.Hash()
+	}
+
+	res := &gethclient.AccountResult{
+		Address:      account,
+		AccountProof: encodeProof(proof),
+		Balance:      stateDB.GetBalance(account),
+		CodeHash:     stateDB.GetCodeHash(account),
+		Nonce:        stateDB.GetNonce(account),
+		StorageHash:  storageHash,
+	}
+	for _, k := range keys {
+		key := common.HexToHash(k)
+		p, err := stateDB.GetStorageProof(account, key)
+		if err != nil {
+			return nil, err
+		}
+		res.StorageProof = append(res.StorageProof, gethclient.StorageResult{
+			Key:   k,
+			Value: stateDB.GetState(account, key).Big(),
+			Proof: encodeProof(p),
+		})
+	}
+	return res, nil
+}
+
 // duration in seconds
 func (b *TestBackend) GetPeriod(duration uint64) (*big.Int, *big.Int) {
 	header, _ := b.HeaderByNumber(context.Background(), nil)
@@ -1,15 +1,24 @@
 package course
 
 import (
+	"context"
+	"errors"
+
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 
+	"github.com/relab/credbench/pkg/ctree"
 	"github.com/relab/credbench/pkg/ctree/node"
 	"github.com/relab/credbench/pkg/deployer"
 	bindings "github.com/relab/go-credbindings/course"
 )
 
+var (
+	ErrNotEnrolled     = errors.New("student not enrolled in the course")
+	ErrAlreadyEnrolled = errors.New("student already enrolled in the course")
+)
+
 // Course is a Go wrapper around an on-chain course contract.
 type Course struct {
 	*node.Node
@@ -71,19 +80,99 @@ func (c *Course) RenounceCourse(opts *bind.TransactOpts) (*types.Transaction, er
 }
 
 // GetStudents
+//
+// Deprecated: Use GetStudentsContext.
 func (c Course) GetStudents(opts *bind.CallOpts) ([]common.Address, error) {
 	return c.contract.GetStudents(opts)
 }
 
 // IsEnrolled
+//
+// Deprecated: Use IsEnrolledContext.
 func (c *Course) IsEnrolled(opts *bind.CallOpts, student common.Address) (bool, error) {
 	return c.contract.IsEnrolled(opts, student)
 }
 
+// GetStudentsContext returns the students enrolled at the block of the call
+// options.
+func (c Course) GetStudentsContext(ctx context.Context, opts *ctree.CallOpts) ([]common.Address, error) {
+	students, err := c.contract.GetStudents(opts.Bind(ctx))
+	if err != nil {
+		return nil, &ctree.CallError{Contract: c.address, Method: "getStudents", Err: err}
+	}
+	return students, nil
+}
+
+// IsEnrolledContext returns whether the student is enrolled at the block of
+// the call options.
+func (c *Course) IsEnrolledContext(ctx context.Context, opts *ctree.CallOpts, student common.Address) (bool, error) {
+	ok, err := c.contract.IsEnrolled(opts.Bind(ctx), student)
+	if err != nil {
+		return false, &ctree.CallError{Contract: c.address, Method: "isEnrolled", Subject: student, Err: err}
+	}
+	return ok, nil
+}
+
 func (c *Course) RegisterExam(opts *bind.TransactOpts, student common.Address, examDigest [32]byte) (*types.Transaction, error) {
 	return c.contract.RegisterExam(opts, student, examDigest)
 }
 
+// GetCredentialProof returns the credential proof of the digest.
+//
+// Deprecated: Use the GetCredentialProof method of the embedded node.Node,
+// which takes a context and reports the call errors as ctree.CallError.
 func (c *Course) GetCredentialProof(opts *bind.CallOpts, digest [32]byte) (bindings.NotaryCredentialProof, error) {
 	return c.contract.GetCredentialProof(opts, digest)
 }
+
+// preflightError wraps the reason a transaction would be reverted.
+func (c *Course) preflightError(method string, sender, student common.Address, err error) error {
+	return &ctree.PreflightError{Contract: c.address, Method: method, Sender: sender, Subject: student, Err: err}
+}
+
+// checkEnrolled checks whether a student is enrolled in the course.
+func (c *Course) checkEnrolled(ctx context.Context, opts *ctree.CallOpts, method string, sender, student common.Address, want bool) error {
+	ok, err := c.IsEnrolledContext(ctx, opts, student)
+	if err != nil {
+		return err
+	}
+	switch {
+	case want && !ok:
+		return c.preflightError(method, sender, student, ErrNotEnrolled)
+	case !want && ok:
+		return c.preflightError(method, sender, student, ErrAlreadyEnrolled)
+	}
+	return nil
+}
+
+// CheckAddStudent checks that the sender can enroll the student.
+func (c *Course) CheckAddStudent(ctx context.Context, opts *ctree.CallOpts, sender, student common.Address) error {
+	if err := c.CheckOwner(ctx, opts, "addStudent", sender); err != nil {
+		return err
+	}
+	return c.checkEnrolled(ctx, opts, "addStudent", sender, student, false)
+}
+
+// CheckRemoveStudent checks that the sender can remove an enrolled student.
+func (c *Course) CheckRemoveStudent(ctx context.Context, opts *ctree.CallOpts, sender, student common.Address) error {
+	if err := c.CheckOwner(ctx, opts, "removeStudent", sender); err != nil {
+		return err
+	}
+	return c.checkEnrolled(ctx, opts, "removeStudent", sender, student, true)
+}
+
+// CheckRenounceCourse checks that the sender is a student of the course.
+func (c *Course) CheckRenounceCourse(ctx context.Context, opts *ctree.CallOpts, sender common.Address) error {
+	return c.checkEnrolled(ctx, opts, "renounceCourse", sender, sender, true)
+}
+
+// CheckRegisterCredential checks that the contract would accept the
+// registration of a credential by the sender, and that the subject is
+// enrolled in the course. The contract does not require the enrollment,
+// but course credentials are only issued to its students.
+func (c *Course) CheckRegisterCredential(ctx context.Context, opts *ctree.CallOpts, sender, student common.Address, digest [32]byte, witnesses []common.Address) error {
+	if err := c.Node.CheckRegisterCredential(ctx, opts, sender, student, digest, witnesses); err != nil {
+		return err
+	}
+	return c.checkEnrolled(ctx, opts, "registerCredential", sender, student, true)
+}
@@ -1,12 +1,21 @@
 package course
 
 import (
+	"context"
 	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/relab/go-credbindings/aggregator"
 	bindings "github.com/relab/go-credbindings/course"
@@ -14,6 +23,9 @@ import (
 
 	"github.com/relab/credbench/pkg/accounts"
 	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/owners"
 	"github.com/relab/credbench/pkg/encode"
 
 	pb "github.com/relab/credbench/pkg/schemes"
@@ -46,7 +58,7 @@ func NewTestCourse(t *testing.T, evaluators backends.Accounts) *TestCourse {
 }
 
 func (tc *TestCourse) AddStudents(t *testing.T, students backends.Accounts) {
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	for _, addr := range students.Addresses() {
 		_, err := tc.Course.AddStudent(opts, addr)
 		if err != nil {
@@ -57,23 +69,42 @@ func (tc *TestCourse) AddStudents(t *testing.T, students backends.Accounts) {
 	tc.Students = students
 }
 
-func (tc *TestCourse) RegisterTestCredential(t *testing.T, to common.Address) [32]byte {
+func (tc *TestCourse) NewTestDocument(to common.Address) *pb.AssignmentGradeCredential {
 	evaluatorAddr := tc.Evaluators[0].Address.Hex()
 	courseEntity := &pb.Entity{
 		Id:   tc.Course.Address().Hex(),
 		Name: "Course Test Contract",
 	}
 	ag := pb.NewFakeAssignmentGrade(evaluatorAddr, to.Hex())
-	credential := pb.NewFakeAssignmentGradeCredential(evaluatorAddr, courseEntity, ag)
-	digest := pb.Hash(credential)
+	return pb.NewFakeAssignmentGradeCredential(evaluatorAddr, courseEntity, ag)
+}
+
+func (tc *TestCourse) RegisterTestCredential(t *testing.T, to common.Address) [32]byte {
+	return tc.RegisterTestDocument(t, to, tc.NewTestDocument(to))
+}
+
+func (tc *TestCourse) RegisterTestDocument(t *testing.T, to common.Address, credential *pb.AssignmentGradeCredential) [32]byte {
+	return tc.RegisterTestDigest(t, to, hashCredential(t, credential))
+}
+
+// hashCredential returns the digest of the credential with the default algorithm.
+func hashCredential(t *testing.T, c pb.Credential) [32]byte {
+	t.Helper()
+	digest, err := pb.DefaultHashAlgorithm.Hash(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
 
+func (tc *TestCourse) RegisterTestDigest(t *testing.T, to common.Address, digest [32]byte) [32]byte {
 	ch := make(chan *bindings.CourseCredentialIssued)
 	sub, _ := tc.Course.contract.WatchCredentialIssued(nil, ch, [][32]byte{digest}, []common.Address{to}, nil)
 	defer func() {
 		sub.Unsubscribe()
 	}()
 
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	_, err := tc.Course.RegisterCredential(opts, to, digest, []common.Address{})
 	if err != nil {
 		t.Fatalf("RegisterCredential expected no error, got: %v", err)
@@ -94,12 +125,12 @@ func (tc *TestCourse) RegisterTestCredential(t *testing.T, to common.Address) [3
 
 func (tc *TestCourse) ConfirmTestCredential(t *testing.T, from *ecdsa.PrivateKey, digest [32]byte) {
 	ch := make(chan *bindings.CourseCredentialSigned)
-	sub, _ := tc.Course.contract.WatchCredentialSigned(nil, ch, nil, [][32]byte{digest})
+	sub, _ := tc.Course.contract.WatchCredentialSigned(nil, ch, []common.Address{accounts.GetAddress(from)}, [][32]byte{digest})
 	defer func() {
 		sub.Unsubscribe()
 	}()
 
-	opts := bind.NewKeyedTransactor(from)
+	opts := tc.Backend.TransactOpts(from)
 	_, err := tc.Course.ApproveCredential(opts, digest)
 	if err != nil {
 		t.Fatalf("ApproveCredential expected no error, got: %v", err)
@@ -129,7 +160,7 @@ func deployLibs(opts *bind.TransactOpts, backend *backends.TestBackend) (map[str
 }
 
 func deployCourse(backend *backends.TestBackend, prvKey *ecdsa.PrivateKey, evaluators []common.Address, quorum uint8) (common.Address, *Course, error) {
-	opts := bind.NewKeyedTransactor(prvKey)
+	opts := backend.TransactOpts(prvKey)
 
 	libs, err := deployLibs(opts, backend)
 	if err != nil {
@@ -152,11 +183,11 @@ func TestNewCourse(t *testing.T) {
 	defer tc.Backend.Close()
 
 	// Calling Owners contract methods
-	if ok, err := tc.Course.IsOwner(&bind.CallOpts{Pending: true}, tc.Evaluators[0].Address); !ok {
+	if ok, err := tc.Course.IsOwnerContext(context.Background(), &ctree.CallOpts{Pending: true}, tc.Evaluators[0].Address); !ok {
 		t.Fatalf("IsOwner expected to be true but return: %t, %v", ok, err)
 	}
 
-	owners, err := tc.Course.GetOwners(&bind.CallOpts{Pending: true})
+	owners, err := tc.Course.GetOwnersContext(context.Background(), &ctree.CallOpts{Pending: true})
 	if err != nil {
 		t.Fatalf("OwnersList expected no errors but got: %v", err)
 	}
@@ -169,7 +200,7 @@ func TestAddStudent(t *testing.T) {
 
 	// Add a student
 	studentAddress := backends.TestAccounts[2].Address
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	if _, err := tc.Course.AddStudent(opts, studentAddress); err != nil {
 		t.Fatalf("AddStudent expected to add a student but return: %v", err)
 	}
@@ -181,7 +212,7 @@ func TestAddStudent(t *testing.T) {
 	}
 
 	// Verify if a student was added
-	if ok, err := tc.Course.IsEnrolled(&bind.CallOpts{Pending: true}, studentAddress); err != nil || !ok {
+	if ok, err := tc.Course.IsEnrolledContext(context.Background(), &ctree.CallOpts{Pending: true}, studentAddress); err != nil || !ok {
 		t.Fatalf("IsEnrolled expected student %v to be enrolled but return: %t, %v", studentAddress.Hex(), ok, err)
 	}
 }
@@ -195,7 +226,7 @@ func TestRemoveStudent(t *testing.T) {
 	tc.AddStudents(t, backends.Accounts{student})
 
 	// Remove a student
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	if _, err := tc.Course.RemoveStudent(opts, studentAddress); err != nil {
 		t.Fatalf("RemoveStudent expected to remove a student but return: %v", err)
 	}
@@ -207,7 +238,7 @@ func TestRemoveStudent(t *testing.T) {
 	}
 
 	// Verify if a student was removed
-	if ok, err := tc.Course.IsEnrolled(&bind.CallOpts{Pending: true}, studentAddress); err != nil || ok {
+	if ok, err := tc.Course.IsEnrolledContext(context.Background(), &ctree.CallOpts{Pending: true}, studentAddress); err != nil || ok {
 		t.Fatalf("IsEnrolled expected student %v to NOT be enrolled but return: %t, %v", studentAddress.Hex(), ok, err)
 	}
 }
@@ -221,7 +252,7 @@ func TestRenounceCourse(t *testing.T) {
 	studentAddress := student.Address
 	tc.AddStudents(t, backends.Accounts{student})
 
-	opts := bind.NewKeyedTransactor(studentKey)
+	opts := tc.Backend.TransactOpts(studentKey)
 	if _, err := tc.Course.RenounceCourse(opts); err != nil {
 		t.Fatalf("RenounceCourse expected to remove the sender (student) but return: %v", err)
 	}
@@ -233,7 +264,7 @@ func TestRenounceCourse(t *testing.T) {
 	}
 
 	// Verify if a student was removed
-	if ok, err := tc.Course.IsEnrolled(&bind.CallOpts{Pending: true}, studentAddress); err != nil || ok {
+	if ok, err := tc.Course.IsEnrolledContext(context.Background(), &ctree.CallOpts{Pending: true}, studentAddress); err != nil || ok {
 		t.Fatalf("IsEnrolled expected student %v to NOT be enrolled but return: %t, %v", studentAddress.Hex(), ok, err)
 	}
 }
@@ -301,14 +332,14 @@ func TestIssuerAggregateCredential(t *testing.T) {
 		tc.ConfirmTestCredential(t, studentKey, d)
 	}
 
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	_, err := tc.Course.AggregateCredentials(opts, studentAddress, digests)
 	if err != nil {
 		t.Fatalf("AggregateCredentials expected no error, got: %v", err)
 	}
 	tc.Backend.Commit()
 
-	aggregatedDigest, err := tc.Course.GetRoot(nil, studentAddress)
+	aggregatedDigest, err := tc.Course.GetRoot(context.Background(), nil, studentAddress)
 	if err != nil {
 		t.Error(err)
 	}
@@ -336,7 +367,7 @@ func VerifyCredentialTree(t *testing.T) {
 		tc.ConfirmTestCredential(t, studentKey, d)
 	}
 
-	opts := bind.NewKeyedTransactor(tc.Evaluators[0].Key)
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
 	_, err := tc.Course.AggregateCredentials(opts, studentAddress, digests)
 	if err != nil {
 		t.Fatalf("AggregateCredentials expected no error, got: %v", err)
@@ -354,8 +385,589 @@ func VerifyCredentialTree(t *testing.T) {
 	}
 	assert.True(t, b)
 
-	err = tc.Course.VerifyCredentialTree(true, nil, studentAddress)
+	err = tc.Course.VerifyCredentialTree(context.Background(), true, nil, studentAddress)
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+func TestVerifyCredentialAt(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+	beforeIssued := tc.Backend.Blockchain().CurrentBlock().Number
+
+	digest := tc.RegisterTestCredential(t, student.Address)
+	issued := tc.Backend.Blockchain().CurrentBlock().Number
+
+	tc.ConfirmTestCredential(t, student.Key, digest)
+	approved := tc.Backend.Blockchain().CurrentBlock()
+
+	if err := tc.Backend.AdjustTime(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	tc.Backend.Commit()
+
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
+	_, err := tc.Course.Revoke(opts, digest, [32]byte{})
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tc.Backend.Commit()
+	revoked := tc.Backend.Blockchain().CurrentBlock()
+
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		block *big.Int
+		err   error
+	}{
+		{"before issued", beforeIssued, node.ErrCredentialNotFound},
+		{"not approved", issued, node.ErrCredentialNotApproved},
+		{"approved", approved.Number, nil},
+		{"revoked", revoked.Number, node.ErrCredentialRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tc.Course.VerifyCredentialAt(ctx, student.Address, digest, tt.block)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	err = tc.Course.VerifyCredential(context.Background(), false, nil, student.Address, digest)
+	assert.ErrorIs(t, err, node.ErrCredentialRevoked)
+
+	block, err := node.BlockAt(ctx, tc.Backend, approved.Time+1)
+	assert.NoError(t, err)
+	assert.Equal(t, approved.Number, block)
+	err = tc.Course.VerifyCredentialAtTime(ctx, student.Address, digest, approved.Time+1)
+	assert.NoError(t, err)
+	err = tc.Course.VerifyCredentialAtTime(ctx, student.Address, digest, revoked.Time)
+	assert.ErrorIs(t, err, node.ErrCredentialRevoked)
+}
+
+func TestVerifyCredentialInclusionFromDigests(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+
+	var digests [][32]byte
+	for i := 0; i < 3; i++ {
+		d := tc.RegisterTestCredential(t, student.Address)
+		digests = append(digests, d)
+		tc.ConfirmTestCredential(t, student.Key, d)
+	}
+
+	root, proof, err := tc.Course.CredentialInclusionProof(context.Background(), nil, student.Address, digests[1])
+	assert.NoError(t, err)
+	err = tc.Course.VerifyCredentialInclusionFromDigests(context.Background(), nil, student.Address, root, proof)
+	assert.ErrorIs(t, err, node.ErrRootNotFound)
+
+	opts := tc.Backend.TransactOpts(tc.Evaluators[0].Key)
+	_, err = tc.Course.AggregateCredentials(opts, student.Address, digests)
+	if err != nil {
+		t.Fatalf("AggregateCredentials expected no error, got: %v", err)
+	}
+	tc.Backend.Commit()
+
+	// the Merkle root is not the aggregated root of the student
+	err = tc.Course.VerifyCredentialRoot(context.Background(), false, nil, student.Address, root)
+	assert.ErrorIs(t, err, node.ErrWrongRoot)
+	err = tc.Course.VerifyCredentialInclusionFromDigests(context.Background(), nil, student.Address, root, proof)
+	assert.NoError(t, err)
+
+	other, err := encode.NewMerkleProof(digests, 0)
+	assert.NoError(t, err)
+	other.Leaf = proof.Leaf
+	err = tc.Course.VerifyCredentialInclusionFromDigests(context.Background(), nil, student.Address, root, other)
+	assert.ErrorIs(t, err, node.ErrInvalidInclusionProof)
+
+	err = tc.Course.VerifyCredentialRoot(context.Background(), false, nil, student.Address, [32]byte{1})
+	assert.ErrorIs(t, err, node.ErrWrongRoot)
+
+	_, _, err = tc.Course.CredentialInclusionProof(context.Background(), nil, student.Address, [32]byte{1})
+	assert.ErrorIs(t, err, node.ErrCredentialNotFound)
+}
+
+func TestVerifyDocument(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	other := backends.TestAccounts[3]
+	tc.AddStudents(t, backends.Accounts{student, other})
+
+	// the simulated chain time starts at the unix epoch
+	header, err := tc.Backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := &timestamppb.Timestamp{Seconds: int64(header.Time)}
+
+	doc := tc.NewTestDocument(student.Address)
+	doc.CreatedAt = createdAt
+	doc.Assignment.Student.Id = "did:eth-uis:" + student.Address.Hex()
+	tc.RegisterTestDocument(t, student.Address, doc)
+
+	data, err := protojson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := pb.ParseCredential(data)
+	if err != nil {
+		t.Fatalf("ParseCredential expected no error, got: %v", err)
+	}
+
+	report, err := node.VerifyDocument(context.Background(), nil, tc.Backend, parsed)
+	if err != nil {
+		t.Fatalf("VerifyDocument expected no error, got: %v", err)
+	}
+	assert.Equal(t, tc.Course.Address(), report.Contract)
+	assert.Empty(t, report.Mismatches)
+	assert.ErrorIs(t, report.Err(), node.ErrCredentialNotApproved)
+
+	tc.ConfirmTestCredential(t, student.Key, hashCredential(t, doc))
+	report, err = node.VerifyDocument(context.Background(), nil, tc.Backend, parsed)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+
+	// document issued on-chain to another subject by another registrar
+	wrong := tc.NewTestDocument(student.Address)
+	wrong.CreatedAt = createdAt
+	wrong.CreatedBy = other.Address.Hex()
+	wrong.Assignment.Evaluators = append(wrong.Assignment.Evaluators, &pb.Entity{Id: other.Address.Hex()})
+	tc.RegisterTestDocument(t, other.Address, wrong)
+	report, err = tc.Course.VerifyDocument(context.Background(), nil, wrong)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Err(), node.ErrWrongSubject)
+	fields := make(map[string]string)
+	for _, m := range report.Mismatches {
+		fields[m.Field] = m.Chain
+	}
+	assert.Equal(t, map[string]string{
+		"student":       other.Address.Hex(),
+		"created_by":    tc.Evaluators[0].Address.Hex(),
+		"evaluators[1]": "not an owner",
+	}, fields)
+
+	// any change to the document changes its digest
+	parsed.(*pb.AssignmentGradeCredential).Assignment.Grade++
+	report, err = node.VerifyDocument(context.Background(), nil, tc.Backend, parsed)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Err(), node.ErrCredentialNotFound)
+
+	_, err = pb.ParseCredential([]byte(`{"name": "unknown"}`))
+	assert.ErrorIs(t, err, pb.ErrUnknownCredential)
+}
+
+func TestVerifyDocumentOfferedBy(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+	otherAddr, _, err := deployCourse(tc.Backend, tc.Evaluators[0].Key, backends.Accounts(tc.Evaluators).Addresses(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Backend.Commit()
+
+	header, err := tc.Backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := tc.NewTestDocument(student.Address)
+	doc.CreatedAt = &timestamppb.Timestamp{Seconds: int64(header.Time)}
+	doc.Assignment.Student.Id = "did:eth-uis:" + student.Address.Hex()
+	doc.OfferedBy = []*pb.Entity{
+		{Id: "University of Stavanger"},
+		{Id: student.Address.Hex()},
+		{Id: "did:eth-uis:" + otherAddr.Hex()},
+		{Id: tc.Course.Address().Hex()},
+	}
+	digest := tc.RegisterTestDocument(t, student.Address, doc)
+	tc.ConfirmTestCredential(t, student.Key, digest)
+
+	// the course is found after an account and a course that did not issue it
+	report, err := node.VerifyDocument(context.Background(), nil, tc.Backend, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, tc.Course.Address(), report.Contract)
+	assert.True(t, report.Valid())
+
+	// unregistered documents are reported by the first course
+	doc.Assignment.Grade++
+	report, err = node.VerifyDocument(context.Background(), nil, tc.Backend, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, otherAddr, report.Contract)
+	assert.ErrorIs(t, report.Err(), node.ErrCredentialNotFound)
+
+	doc.OfferedBy = doc.OfferedBy[:2]
+	_, err = node.VerifyDocument(context.Background(), nil, tc.Backend, doc)
+	assert.ErrorIs(t, err, node.ErrIssuerNotFound)
+}
+
+func TestVerifyDocumentValidity(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+	ctx := context.Background()
+
+	header, err := tc.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(int64(header.Time), 0)
+
+	doc := tc.NewTestDocument(student.Address)
+	doc.CreatedAt = timestamppb.New(now)
+	doc.Assignment.Student.Id = "did:eth-uis:" + student.Address.Hex()
+	pb.SetValidity(doc, now, now.Add(100*time.Second))
+	digest := tc.RegisterTestDocument(t, student.Address, doc)
+	tc.ConfirmTestCredential(t, student.Key, digest)
+
+	report, err := node.VerifyDocument(ctx, nil, tc.Backend, doc)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+	if assert.NotNil(t, report.Validity) {
+		assert.Equal(t, "valid", report.Validity.Status())
+	}
+	validAt := report.Validity.BlockNumber
+
+	if err := tc.Backend.AdjustTime(200 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	tc.Backend.Commit()
+	report, err = node.VerifyDocument(ctx, nil, tc.Backend, doc)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Err(), pb.ErrExpired)
+	assert.Equal(t, "expired", report.Validity.Status())
+
+	// still valid at the block in which it was verified before
+	report, err = node.VerifyDocument(ctx, &ctree.CallOpts{BlockNumber: validAt}, tc.Backend, doc)
+	assert.NoError(t, err)
+	assert.NoError(t, report.Err())
+
+	header, err = tc.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = time.Unix(int64(header.Time), 0)
+	future := tc.NewTestDocument(student.Address)
+	future.CreatedAt = timestamppb.New(now)
+	future.Assignment.Student.Id = "did:eth-uis:" + student.Address.Hex()
+	pb.SetValidity(future, now.Add(time.Hour), time.Time{})
+	digest = tc.RegisterTestDocument(t, student.Address, future)
+	tc.ConfirmTestCredential(t, student.Key, digest)
+	report, err = node.VerifyDocument(ctx, nil, tc.Backend, future)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Err(), pb.ErrNotYetValid)
+	assert.Equal(t, "not yet valid", report.Validity.Status())
+
+	pb.SetValidity(future, now, now.Add(-time.Hour))
+	assert.ErrorIs(t, pb.CheckValidity(future, now), pb.ErrInvalidValidity)
+}
+
+func TestVerifyDisclosureValidity(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+	ctx := context.Background()
+
+	header, err := tc.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(int64(header.Time), 0)
+
+	doc := tc.NewTestDocument(student.Address)
+	doc.Assignment.Student.Id = "did:eth-uis:" + student.Address.Hex()
+	pb.SetValidity(doc, now, now.Add(100*time.Second))
+	salted, err := pb.NewSaltedCredential(doc, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salted.Contract = tc.Course.Address().Hex()
+	digest, err := salted.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.RegisterTestDigest(t, student.Address, digest)
+	tc.ConfirmTestCredential(t, student.Key, digest)
+
+	// the validity period is disclosed with any claim
+	p, err := salted.Disclose("/assignment/student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := p.Claim("/validUntil")
+	assert.True(t, ok)
+
+	report, err := node.VerifyDisclosure(ctx, nil, tc.Backend, p)
+	assert.NoError(t, err)
+	assert.NoError(t, report.Err())
+	if assert.NotNil(t, report.Validity) {
+		assert.Equal(t, "valid", report.Validity.Status())
+	}
+
+	if err := tc.Backend.AdjustTime(200 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	tc.Backend.Commit()
+	report, err = node.VerifyDisclosure(ctx, nil, tc.Backend, p)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Err(), pb.ErrExpired)
+	assert.Equal(t, "expired", report.Validity.Status())
+}
+
+// unavailableBackend fails all contract calls, as a node during an outage.
+type unavailableBackend struct {
+	bind.ContractBackend
+}
+
+var errUnavailable = errors.New("backend unavailable")
+
+func (unavailableBackend) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	return nil, errUnavailable
+}
+
+func TestTypedErrors(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	tc.AddStudents(t, backends.Accounts{student})
+	digest := tc.RegisterTestCredential(t, student.Address)
+	ctx := context.Background()
+
+	err := tc.Course.VerifyCredential(ctx, false, nil, backends.TestAccounts[3].Address, digest)
+	assert.ErrorIs(t, err, node.ErrWrongSubject)
+	var verr *ctree.VerificationError
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Equal(t, tc.Course.Address(), verr.Contract)
+		assert.Equal(t, common.Hash(digest), verr.Digest)
+	}
+
+	n, err := node.NewNode(tc.Course.Address(), unavailableBackend{tc.Backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = n.GetCredentialProof(ctx, nil, digest)
+	assert.ErrorIs(t, err, errUnavailable)
+
+	// an outage is not a verification failure
+	err = n.VerifyCredential(ctx, false, nil, student.Address, digest)
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.False(t, errors.As(err, &verr))
+	var cerr *ctree.CallError
+	if assert.ErrorAs(t, err, &cerr) {
+		assert.Equal(t, tc.Course.Address(), cerr.Contract)
+		assert.Equal(t, "getCredentialProof", cerr.Method)
+		assert.Equal(t, common.Hash(digest), cerr.Digest)
+	}
+
+	_, err = n.GetOwnersContext(ctx, nil)
+	assert.ErrorAs(t, err, &cerr)
+}
+
+func TestPendingCredentials(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:2])
+	defer tc.Backend.Close()
+
+	student := backends.TestAccounts[2]
+	other := backends.TestAccounts[3]
+	tc.AddStudents(t, backends.Accounts{student, other})
+	ctx := context.Background()
+	evaluator := tc.Evaluators[1]
+
+	digest := tc.RegisterTestCredential(t, student.Address)
+	otherDigest := tc.RegisterTestCredential(t, other.Address)
+
+	pending, err := tc.Course.PendingCredentials(ctx, nil, student.Address)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		p := pending[0]
+		assert.Equal(t, common.Hash(digest), p.Digest)
+		assert.Equal(t, uint64(1), p.Signatures)
+		assert.Equal(t, uint8(2), p.Quorum)
+		assert.False(t, p.QuorumSigned)
+		assert.Equal(t, []common.Address{evaluator.Address}, p.Missing())
+		assert.True(t, p.IsWaitingOn(evaluator.Address))
+		assert.False(t, p.IsWaitingOn(student.Address))
+	}
+
+	// the inbox of an owner has the credentials of all subjects
+	inbox, err := node.Inbox(ctx, nil, tc.Backend, evaluator.Address, []common.Address{tc.Course.Address()})
+	assert.NoError(t, err)
+	assert.Len(t, inbox, 2)
+	inbox, err = node.Inbox(ctx, nil, tc.Backend, student.Address, []common.Address{tc.Course.Address()})
+	assert.NoError(t, err)
+	assert.Len(t, inbox, 1)
+
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(evaluator.Key), student.Address, digest, []common.Address{})
+	assert.NoError(t, err)
+	tc.Backend.Commit()
+
+	pending, err = tc.Course.PendingCredentials(ctx, nil, student.Address)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		p := pending[0]
+		assert.True(t, p.QuorumSigned)
+		assert.Empty(t, p.Missing())
+		assert.Equal(t, []common.Address{student.Address}, p.WaitingOn())
+	}
+
+	// approved and revoked credentials are not pending
+	tc.ConfirmTestCredential(t, student.Key, digest)
+	_, err = tc.Course.Revoke(tc.Backend.TransactOpts(tc.Evaluators[0].Key), otherDigest, [32]byte{})
+	assert.NoError(t, err)
+	tc.Backend.Commit()
+
+	pending, err = tc.Course.PendingCredentials(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// the state at a past block
+	block := tc.Backend.Blockchain().CurrentBlock().Number
+	pending, err = tc.Course.PendingCredentials(ctx, &ctree.CallOpts{BlockNumber: new(big.Int).Sub(block, big.NewInt(2))})
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestRotateOwner(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:1])
+	defer tc.Backend.Close()
+
+	ctx := context.Background()
+	oldOwner := tc.Evaluators[0]
+	newOwner := backends.TestAccounts[4]
+	wait := func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		tc.Backend.Commit()
+		return tc.Backend.TransactionReceipt(ctx, tx.Hash())
+	}
+
+	_, err := tc.Course.Rotate(ctx, tc.Backend.TransactOpts(newOwner.Key), backends.TestAccounts[5].Address, wait)
+	assert.ErrorIs(t, err, owners.ErrNotOwner)
+	_, err = tc.Course.Rotate(ctx, tc.Backend.TransactOpts(oldOwner.Key), oldOwner.Address, wait)
+	assert.ErrorIs(t, err, owners.ErrAlreadyOwner)
+	_, err = tc.Course.Rotate(ctx, tc.Backend.TransactOpts(oldOwner.Key), common.Address{}, wait)
+	assert.ErrorIs(t, err, owners.ErrInvalidOwner)
+
+	r, err := tc.Course.Rotate(ctx, tc.Backend.TransactOpts(oldOwner.Key), newOwner.Address, wait)
+	if err != nil {
+		t.Fatalf("Rotate expected no error, got: %v", err)
+	}
+	assert.Equal(t, oldOwner.Address, r.OldOwner)
+	assert.Equal(t, newOwner.Address, r.NewOwner)
+	assert.Equal(t, types.ReceiptStatusSuccessful, r.Receipt.Status)
+
+	list, err := tc.Course.GetOwnersContext(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{newOwner.Address}, list)
+
+	// the new owner issues credentials
+	student := backends.TestAccounts[2]
+	digest := [32]byte{1}
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(oldOwner.Key), student.Address, digest, []common.Address{})
+	assert.Error(t, err)
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(newOwner.Key), student.Address, digest, []common.Address{})
+	assert.NoError(t, err)
+}
+
+func TestPreflightChecks(t *testing.T) {
+	tc := NewTestCourse(t, backends.TestAccounts[:2])
+	defer tc.Backend.Close()
+
+	ctx := context.Background()
+	evaluator, other := tc.Evaluators[0], tc.Evaluators[1]
+	student, stranger := backends.TestAccounts[2], backends.TestAccounts[3]
+	tc.AddStudents(t, backends.Accounts{student})
+
+	// each failed check is also reverted by the contract
+	err := tc.Course.CheckAddStudent(ctx, nil, student.Address, stranger.Address)
+	assert.ErrorIs(t, err, node.ErrNotOwner)
+	var pe *ctree.PreflightError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, "addStudent", pe.Method)
+		assert.Equal(t, student.Address, pe.Sender)
+	}
+	_, err = tc.Course.AddStudent(tc.Backend.TransactOpts(student.Key), stranger.Address)
+	assert.Error(t, err)
+
+	err = tc.Course.CheckAddStudent(ctx, nil, evaluator.Address, student.Address)
+	assert.ErrorIs(t, err, ErrAlreadyEnrolled)
+	_, err = tc.Course.AddStudent(tc.Backend.TransactOpts(evaluator.Key), student.Address)
+	assert.Error(t, err)
+
+	err = tc.Course.CheckRemoveStudent(ctx, nil, evaluator.Address, stranger.Address)
+	assert.ErrorIs(t, err, ErrNotEnrolled)
+	_, err = tc.Course.RemoveStudent(tc.Backend.TransactOpts(evaluator.Key), stranger.Address)
+	assert.Error(t, err)
+	assert.ErrorIs(t, tc.Course.CheckRenounceCourse(ctx, nil, stranger.Address), ErrNotEnrolled)
+
+	doc := tc.NewTestDocument(student.Address)
+	digest := hashCredential(t, doc)
+	err = tc.Course.CheckRegisterCredential(ctx, nil, stranger.Address, student.Address, digest, nil)
+	assert.ErrorIs(t, err, node.ErrNotOwner)
+	err = tc.Course.CheckRegisterCredential(ctx, nil, evaluator.Address, student.Address, digest, []common.Address{tc.Course.Address()})
+	assert.ErrorIs(t, err, node.ErrLeafWitnesses)
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(evaluator.Key), student.Address, digest, []common.Address{tc.Course.Address()})
+	assert.Error(t, err)
+	// the contract issues credentials to students not enrolled
+	assert.ErrorIs(t, tc.Course.CheckRegisterCredential(ctx, nil, evaluator.Address, stranger.Address, digest, nil), ErrNotEnrolled)
+
+	assert.NoError(t, tc.Course.CheckRegisterCredential(ctx, nil, evaluator.Address, student.Address, digest, nil))
+	tc.RegisterTestDocument(t, student.Address, doc)
+
+	err = tc.Course.CheckRegisterCredential(ctx, nil, evaluator.Address, student.Address, digest, nil)
+	assert.ErrorIs(t, err, node.ErrAlreadySigned)
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(evaluator.Key), student.Address, digest, nil)
+	assert.Error(t, err)
+	tc.AddStudents(t, backends.Accounts{stranger})
+	err = tc.Course.CheckRegisterCredential(ctx, nil, other.Address, stranger.Address, digest, nil)
+	assert.ErrorIs(t, err, node.ErrDigestRegistered)
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(other.Key), stranger.Address, digest, nil)
+	assert.Error(t, err)
+
+	err = tc.Course.CheckApproveCredential(ctx, nil, stranger.Address, digest)
+	assert.ErrorIs(t, err, node.ErrNotSubject)
+	err = tc.Course.CheckApproveCredential(ctx, nil, student.Address, digest)
+	assert.ErrorIs(t, err, node.ErrNotQuorumSigned)
+	_, err = tc.Course.ApproveCredential(tc.Backend.TransactOpts(student.Key), digest)
+	assert.Error(t, err)
+	err = tc.Course.CheckAggregateCredentials(ctx, nil, evaluator.Address, student.Address, [][32]byte{digest})
+	assert.ErrorIs(t, err, node.ErrNoCredentials)
+
+	assert.NoError(t, tc.Course.CheckRegisterCredential(ctx, nil, other.Address, student.Address, digest, nil))
+	_, err = tc.Course.RegisterCredential(tc.Backend.TransactOpts(other.Key), student.Address, digest, nil)
+	assert.NoError(t, err)
+	tc.Backend.Commit()
+	assert.NoError(t, tc.Course.CheckApproveCredential(ctx, nil, student.Address, digest))
+	tc.ConfirmTestCredential(t, student.Key, digest)
+	assert.ErrorIs(t, tc.Course.CheckApproveCredential(ctx, nil, student.Address, digest), node.ErrAlreadyApproved)
+	assert.NoError(t, tc.Course.CheckAggregateCredentials(ctx, nil, evaluator.Address, student.Address, [][32]byte{digest}))
+	err = tc.Course.CheckAggregateCredentials(ctx, nil, evaluator.Address, student.Address, [][32]byte{{1}})
+	assert.ErrorIs(t, err, node.ErrCredentialNotFound)
+
+	err = tc.Course.CheckRevoke(ctx, nil, student.Address, digest)
+	assert.ErrorIs(t, err, node.ErrNotOwner)
+	assert.ErrorIs(t, tc.Course.CheckRevoke(ctx, nil, evaluator.Address, [32]byte{1}), node.ErrCredentialNotFound)
+	assert.NoError(t, tc.Course.CheckRevoke(ctx, nil, evaluator.Address, digest))
+	_, err = tc.Course.Revoke(tc.Backend.TransactOpts(evaluator.Key), digest, [32]byte{})
+	assert.NoError(t, err)
+	tc.Backend.Commit()
+
+	assert.ErrorIs(t, tc.Course.CheckRevoke(ctx, nil, evaluator.Address, digest), node.ErrCredentialRevoked)
+	assert.ErrorIs(t, tc.Course.CheckRegisterCredential(ctx, nil, other.Address, student.Address, digest, nil), node.ErrCredentialRevoked)
+	err = tc.Course.CheckAggregateCredentials(ctx, nil, evaluator.Address, student.Address, [][32]byte{digest})
+	assert.ErrorIs(t, err, node.ErrCredentialRevoked)
+	_, err = tc.Course.AggregateCredentials(tc.Backend.TransactOpts(evaluator.Key), student.Address, [][32]byte{digest})
+	assert.Error(t, err)
+}
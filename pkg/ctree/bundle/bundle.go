@@ -0,0 +1,201 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+// Version is the version of the bundle format.
+const Version = 1
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported bundle version")
+	ErrUntrustedHeader    = errors.New("bundle header does not match the trusted block hash")
+	ErrInvalidProof       = errors.New("invalid state proof")
+	ErrIncompleteBundle   = errors.New("state not included in the bundle")
+	ErrReportMismatch     = errors.New("bundle report does not match the proven state")
+	ErrOffline            = errors.New("operation not supported offline")
+)
+
+// ProofBackend is a contract backend that can prove its state (eth_getProof).
+type ProofBackend interface {
+	bind.ContractBackend
+	GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error)
+}
+
+// StorageProof proves the value of a storage slot of an account.
+type StorageProof struct {
+	Key   common.Hash     `json:"key"`
+	Value common.Hash     `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// AccountProof proves the state of an account, its code and the
+// storage slots read when verifying the credential tree.
+type AccountProof struct {
+	Address     common.Address  `json:"address"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Balance     *hexutil.Big    `json:"balance"`
+	CodeHash    common.Hash     `json:"codeHash"`
+	StorageHash common.Hash     `json:"storageHash"`
+	Proof       []hexutil.Bytes `json:"proof"`
+	Code        hexutil.Bytes   `json:"code"`
+	Storage     []*StorageProof `json:"storage"`
+}
+
+// Bundle is a self-contained proof of the credential tree of a subject
+// at a pinned block. It contains the state of all contracts read by the
+// verification, proved against the state root of the block header, and
+// the verification report of the tree.
+type Bundle struct {
+	Version  int             `json:"version"`
+	Contract common.Address  `json:"contract"`
+	Subject  common.Address  `json:"subject"`
+	Header   *types.Header   `json:"header"`
+	Accounts []*AccountProof `json:"accounts"`
+	Report   json.RawMessage `json:"report"`
+}
+
+func toBytes(proof []string) ([]hexutil.Bytes, error) {
+	nodes := make([]hexutil.Bytes, len(proof))
+	for i, p := range proof {
+		n, err := hexutil.Decode(p)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+func newAccountProof(res *gethclient.AccountResult, code []byte) (*AccountProof, error) {
+	proof, err := toBytes(res.AccountProof)
+	if err != nil {
+		return nil, err
+	}
+	balance := res.Balance
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	return &AccountProof{
+		Address:     res.Address,
+		Nonce:       hexutil.Uint64(res.Nonce),
+		Balance:     (*hexutil.Big)(balance),
+		CodeHash:    res.CodeHash,
+		StorageHash: res.StorageHash,
+		Proof:       proof,
+		Code:        code,
+	}, nil
+}
+
+func (a *AccountProof) addStorage(res []gethclient.StorageResult) error {
+	for _, s := range res {
+		proof, err := toBytes(s.Proof)
+		if err != nil {
+			return err
+		}
+		var value common.Hash
+		if s.Value != nil {
+			value = common.BigToHash(s.Value)
+		}
+		a.Storage = append(a.Storage, &StorageProof{
+			Key:   common.HexToHash(s.Key),
+			Value: value,
+			Proof: proof,
+		})
+	}
+	return nil
+}
+
+// reportTree verifies the credential tree of the subject using the
+// state of the offline backend.
+func reportTree(ctx context.Context, backend *offlineBackend, contract, subject common.Address) (*ctree.VerificationReport, error) {
+	n, err := node.NewNode(contract, backend)
+	if err != nil {
+		return nil, err
+	}
+	opts := &ctree.CallOpts{BlockNumber: backend.header.Number}
+	return n.VerifyCredentialTreeReport(ctx, opts, subject, 1)
+}
+
+// Export builds the verification bundle of the credential tree of a subject
+// at the given block, or at the latest block if block is nil. The tree is
+// verified on the state proved so far, fetching the proofs of the accounts
+// and storage slots read by the verification until all of them are proved.
+func Export(ctx context.Context, backend ProofBackend, contract, subject common.Address, block *big.Int) (*Bundle, error) {
+	header, err := backend.HeaderByNumber(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		Version:  Version,
+		Contract: contract,
+		Subject:  subject,
+		Header:   header,
+	}
+	accounts := make(map[common.Address]*AccountProof)
+	for {
+		ob := newOfflineBackend(header, b.Accounts, false)
+		report, err := reportTree(ctx, ob, contract, subject)
+		if len(ob.missing) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			b.Report, err = json.Marshal(report)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		for addr, slots := range ob.missing {
+			keys := make([]string, 0, len(slots))
+			for k := range slots {
+				keys = append(keys, k.Hex())
+			}
+			sort.Strings(keys)
+			res, err := backend.GetProof(ctx, addr, keys, header.Number)
+			if err != nil {
+				return nil, err
+			}
+			a, ok := accounts[addr]
+			if !ok {
+				code, err := backend.CodeAt(ctx, addr, header.Number)
+				if err != nil {
+					return nil, err
+				}
+				a, err = newAccountProof(res, code)
+				if err != nil {
+					return nil, err
+				}
+				accounts[addr] = a
+				b.Accounts = append(b.Accounts, a)
+			}
+			if err := a.addStorage(res.StorageProof); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(b.Accounts, func(i, j int) bool {
+		return bytes.Compare(b.Accounts[i].Address[:], b.Accounts[j].Address[:]) < 0
+	})
+	for _, a := range b.Accounts {
+		sort.Slice(a.Storage, func(i, j int) bool {
+			return bytes.Compare(a.Storage[i].Key[:], a.Storage[j].Key[:]) < 0
+		})
+	}
+	return b, nil
+}
@@ -0,0 +1,186 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+var (
+	owner   = backends.TestAccounts[0]
+	subject = backends.TestAccounts[1]
+)
+
+// testTree is an inner node witnessed by a leaf, both issuing an
+// approved credential to the subject.
+type testTree struct {
+	backend *backends.TestBackend
+	inner   *node.Node
+	leaf    *node.Node
+	digest  [32]byte
+}
+
+func newTestTree(t *testing.T) *testTree {
+	t.Helper()
+	backend := backends.NewTestBackend()
+	t.Cleanup(func() { backend.Close() })
+
+	libs, err := backend.DeployLibs(backend.TransactOpts(owner.Key))
+	require.NoError(t, err)
+	backend.Commit()
+	owners := []common.Address{owner.Address}
+	_, _, inner, err := node.Deploy(backend.TransactOpts(owner.Key), backend, libs, node.InnerRole, owners, uint8(1))
+	require.NoError(t, err)
+	backend.Commit()
+	_, _, leaf, err := node.Deploy(backend.TransactOpts(owner.Key), backend, libs, node.LeafRole, owners, uint8(1))
+	require.NoError(t, err)
+	backend.Commit()
+	_, err = inner.AddNode(backend.TransactOpts(owner.Key), leaf.Address())
+	require.NoError(t, err)
+	backend.Commit()
+
+	issue := func(n *node.Node, digest [32]byte, witnesses []common.Address) {
+		_, err := n.RegisterCredential(backend.TransactOpts(owner.Key), subject.Address, digest, witnesses)
+		require.NoError(t, err)
+		backend.Commit()
+		_, err = n.ApproveCredential(backend.TransactOpts(subject.Key), digest)
+		require.NoError(t, err)
+		backend.Commit()
+	}
+	issue(leaf, [32]byte{1}, nil)
+	_, err = leaf.AggregateCredentials(backend.TransactOpts(owner.Key), subject.Address, [][32]byte{{1}})
+	require.NoError(t, err)
+	backend.Commit()
+	issue(inner, [32]byte{2}, []common.Address{leaf.Address()})
+	return &testTree{backend, inner, leaf, [32]byte{2}}
+}
+
+// export returns the encoded bundle of the tree and the trusted hash of
+// its pinned block.
+func (tt *testTree) export(t *testing.T) ([]byte, common.Hash) {
+	t.Helper()
+	ctx := context.Background()
+	header, err := tt.backend.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	b, err := Export(ctx, tt.backend, tt.inner.Address(), subject.Address, nil)
+	require.NoError(t, err)
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	return data, header.Hash()
+}
+
+func decode(t *testing.T, data []byte) *Bundle {
+	t.Helper()
+	b := &Bundle{}
+	require.NoError(t, json.Unmarshal(data, b))
+	return b
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	tt := newTestTree(t)
+	data, _ := tt.export(t)
+
+	b := decode(t, data)
+	assert.Equal(t, Version, b.Version)
+	assert.Equal(t, tt.inner.Address(), b.Contract)
+	assert.Equal(t, subject.Address, b.Subject)
+	// the accounts read by the verification, with the storage of the nodes
+	proved := make(map[common.Address]bool)
+	for _, a := range b.Accounts {
+		proved[a.Address] = len(a.Storage) > 0
+	}
+	assert.True(t, proved[tt.inner.Address()])
+	assert.True(t, proved[tt.leaf.Address()])
+
+	encoded, err := json.Marshal(b)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(encoded))
+}
+
+func TestVerifyBundle(t *testing.T) {
+	tt := newTestTree(t)
+	data, trusted := tt.export(t)
+
+	// the state proved at the pinned block does not change afterwards
+	_, err := tt.inner.Revoke(tt.backend.TransactOpts(owner.Key), tt.digest, [32]byte{1})
+	require.NoError(t, err)
+	tt.backend.Commit()
+
+	report, err := Verify(context.Background(), decode(t, data), trusted)
+	require.NoError(t, err)
+	assert.True(t, report.Valid())
+	require.Len(t, report.Credentials, 1)
+	assert.Equal(t, common.Hash(tt.digest), report.Credentials[0].Digest)
+	assert.False(t, report.Credentials[0].Revoked)
+	require.Len(t, report.Credentials[0].Witnesses, 1)
+	assert.Equal(t, tt.leaf.Address(), report.Credentials[0].Witnesses[0].Contract)
+}
+
+func TestVerifyTamperedBundle(t *testing.T) {
+	tt := newTestTree(t)
+	data, trusted := tt.export(t)
+
+	account := func(b *Bundle, addr common.Address) *AccountProof {
+		for _, a := range b.Accounts {
+			if a.Address == addr {
+				return a
+			}
+		}
+		t.Fatalf("account %s not in the bundle", addr.Hex())
+		return nil
+	}
+	tests := []struct {
+		name   string
+		tamper func(b *Bundle)
+		err    error
+	}{
+		{"storage value", func(b *Bundle) { account(b, tt.inner.Address()).Storage[0].Value[31] ^= 1 }, ErrInvalidProof},
+		{"storage proof", func(b *Bundle) { account(b, tt.leaf.Address()).Storage[0].Proof[0][4] ^= 1 }, ErrInvalidProof},
+		{"account proof", func(b *Bundle) { account(b, tt.inner.Address()).Proof[0][4] ^= 1 }, ErrInvalidProof},
+		{"code", func(b *Bundle) { account(b, tt.leaf.Address()).Code[0] ^= 1 }, ErrInvalidProof},
+		{"missing slot", func(b *Bundle) {
+			a := account(b, tt.inner.Address())
+			a.Storage = a.Storage[1:]
+		}, ErrIncompleteBundle},
+		{"report", func(b *Bundle) { b.Report = json.RawMessage(`{}`) }, ErrReportMismatch},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := decode(t, data)
+			tc.tamper(b)
+			_, err := Verify(context.Background(), b, trusted)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestVerifyBundleHeader(t *testing.T) {
+	tt := newTestTree(t)
+	data, trusted := tt.export(t)
+
+	_, err := Verify(context.Background(), decode(t, data), common.Hash{1})
+	assert.ErrorIs(t, err, ErrUntrustedHeader)
+
+	// a header forged with another state root is not the trusted one
+	b := decode(t, data)
+	b.Header.Root = common.Hash{1}
+	_, err = Verify(context.Background(), b, trusted)
+	assert.ErrorIs(t, err, ErrUntrustedHeader)
+
+	b = decode(t, data)
+	b.Header = nil
+	_, err = Verify(context.Background(), b, trusted)
+	assert.ErrorIs(t, err, ErrUntrustedHeader)
+
+	b = decode(t, data)
+	b.Version++
+	_, err = Verify(context.Background(), b, trusted)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
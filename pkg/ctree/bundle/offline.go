@@ -0,0 +1,233 @@
+package bundle
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// chainConfig enables all supported forks, so that contracts compiled
+// for any of them can be executed offline.
+var chainConfig = func() *params.ChainConfig {
+	c := *params.AllEthashProtocolChanges
+	c.ShanghaiTime = new(uint64)
+	return &c
+}()
+
+// accessTracer reports the accounts and storage slots read by a call.
+type accessTracer struct {
+	access func(addr common.Address, slot *common.Hash)
+}
+
+func (t *accessTracer) CaptureTxStart(uint64) {}
+func (t *accessTracer) CaptureTxEnd(uint64)   {}
+func (t *accessTracer) CaptureStart(_ *vm.EVM, _, to common.Address, _ bool, _ []byte, _ uint64, _ *big.Int) {
+	t.access(to, nil)
+}
+func (t *accessTracer) CaptureEnd([]byte, uint64, error) {}
+func (t *accessTracer) CaptureEnter(_ vm.OpCode, _, to common.Address, _ []byte, _ uint64, _ *big.Int) {
+	t.access(to, nil)
+}
+func (t *accessTracer) CaptureExit([]byte, uint64, error) {}
+func (t *accessTracer) CaptureFault(uint64, vm.OpCode, uint64, uint64, *vm.ScopeContext, int, error) {
+}
+
+func (t *accessTracer) CaptureState(_ uint64, op vm.OpCode, _, _ uint64, scope *vm.ScopeContext, _ []byte, _ int, _ error) {
+	switch op {
+	case vm.SLOAD:
+		slot := common.Hash(scope.Stack.Back(0).Bytes32())
+		t.access(scope.Contract.Address(), &slot)
+	case vm.SELFBALANCE:
+		t.access(scope.Contract.Address(), nil)
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.EXTCODEHASH:
+		t.access(common.Address(scope.Stack.Back(0).Bytes20()), nil)
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.access(common.Address(scope.Stack.Back(1).Bytes20()), nil)
+	}
+}
+
+// offlineBackend executes contract calls on the state of the accounts of a
+// bundle at its pinned block. The accounts and storage slots read by the
+// calls that are not in the bundle are kept as missing; in strict mode
+// these calls fail instead.
+type offlineBackend struct {
+	header *types.Header
+	strict bool
+	state  *state.StateDB
+	proven map[common.Address]map[common.Hash]bool
+
+	mu      sync.Mutex
+	missing map[common.Address]map[common.Hash]bool
+}
+
+func newOfflineBackend(header *types.Header, accounts []*AccountProof, strict bool) *offlineBackend {
+	// an empty in-memory state never fails to be created
+	st, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	proven := make(map[common.Address]map[common.Hash]bool, len(accounts))
+	for _, a := range accounts {
+		st.SetNonce(a.Address, uint64(a.Nonce))
+		st.SetBalance(a.Address, a.Balance.ToInt())
+		st.SetCode(a.Address, a.Code)
+		slots := make(map[common.Hash]bool, len(a.Storage))
+		for _, s := range a.Storage {
+			st.SetState(a.Address, s.Key, s.Value)
+			slots[s.Key] = true
+		}
+		proven[a.Address] = slots
+	}
+	st.Finalise(true)
+	return &offlineBackend{
+		header:  header,
+		strict:  strict,
+		state:   st,
+		proven:  proven,
+		missing: make(map[common.Address]map[common.Hash]bool),
+	}
+}
+
+// access records the account or storage slot as missing if it was
+// not proved, returning whether it was proved.
+func (b *offlineBackend) access(addr common.Address, slot *common.Hash) bool {
+	slots, ok := b.proven[addr]
+	if ok && (slot == nil || slots[*slot]) {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.missing[addr]
+	if !ok {
+		m = make(map[common.Hash]bool)
+		b.missing[addr] = m
+	}
+	if slot != nil {
+		m[*slot] = true
+	}
+	return false
+}
+
+func (b *offlineBackend) checkBlock(blockNumber *big.Int) error {
+	if blockNumber != nil && blockNumber.Cmp(b.header.Number) != 0 {
+		return ErrOffline
+	}
+	return nil
+}
+
+func (b *offlineBackend) blockContext() vm.BlockContext {
+	h := b.header
+	ctx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Coinbase:    h.Coinbase,
+		GasLimit:    h.GasLimit,
+		BlockNumber: new(big.Int).Set(h.Number),
+		Time:        h.Time,
+		Difficulty:  h.Difficulty,
+		BaseFee:     h.BaseFee,
+	}
+	if h.Difficulty == nil || h.Difficulty.Sign() == 0 {
+		random := h.MixDigest
+		ctx.Random = &random
+	}
+	return ctx
+}
+
+func (b *offlineBackend) CodeAt(_ context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := b.checkBlock(blockNumber); err != nil {
+		return nil, err
+	}
+	if !b.access(contract, nil) && b.strict {
+		return nil, ErrIncompleteBundle
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.GetCode(contract), nil
+}
+
+func (b *offlineBackend) CallContract(_ context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := b.checkBlock(blockNumber); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	st := b.state.Copy()
+	b.mu.Unlock()
+
+	complete := true
+	tracer := &accessTracer{access: func(addr common.Address, slot *common.Hash) {
+		if !b.access(addr, slot) {
+			complete = false
+		}
+	}}
+	msg := &core.Message{
+		From:              call.From,
+		To:                call.To,
+		Value:             new(big.Int),
+		GasLimit:          b.header.GasLimit,
+		GasPrice:          new(big.Int),
+		GasFeeCap:         new(big.Int),
+		GasTipCap:         new(big.Int),
+		Data:              call.Data,
+		SkipAccountChecks: true,
+	}
+	if call.Gas != 0 {
+		msg.GasLimit = call.Gas
+	}
+	evm := vm.NewEVM(b.blockContext(), core.NewEVMTxContext(msg), st, chainConfig, vm.Config{NoBaseFee: true, Tracer: tracer})
+	res, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if !complete && b.strict {
+		return nil, ErrIncompleteBundle
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Return(), res.Err
+}
+
+func (b *offlineBackend) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if err := b.checkBlock(number); err != nil {
+		return nil, err
+	}
+	return b.header, nil
+}
+
+func (b *offlineBackend) PendingCodeAt(context.Context, common.Address) ([]byte, error) {
+	return nil, ErrOffline
+}
+
+func (b *offlineBackend) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return 0, ErrOffline
+}
+
+func (b *offlineBackend) SuggestGasPrice(context.Context) (*big.Int, error) {
+	return nil, ErrOffline
+}
+
+func (b *offlineBackend) SuggestGasTipCap(context.Context) (*big.Int, error) {
+	return nil, ErrOffline
+}
+
+func (b *offlineBackend) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 0, ErrOffline
+}
+
+func (b *offlineBackend) SendTransaction(context.Context, *types.Transaction) error {
+	return ErrOffline
+}
+
+func (b *offlineBackend) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, ErrOffline
+}
+
+func (b *offlineBackend) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, ErrOffline
+}
@@ -0,0 +1,122 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+// proofDB returns a database with the trie nodes of a Merkle proof.
+func proofDB(proof []hexutil.Bytes) *memorydb.Database {
+	db := memorydb.New()
+	for _, n := range proof {
+		// writes to an open memory database do not fail
+		_ = db.Put(crypto.Keccak256(n), n)
+	}
+	return db
+}
+
+// verifyStorage checks the storage proofs against the storage root of the account.
+func (a *AccountProof) verifyStorage() error {
+	for _, s := range a.Storage {
+		var value common.Hash
+		if a.StorageHash != types.EmptyRootHash {
+			v, err := trie.VerifyProof(a.StorageHash, crypto.Keccak256(s.Key[:]), proofDB(s.Proof))
+			if err != nil {
+				return fmt.Errorf("%w: slot %s: %v", ErrInvalidProof, s.Key.Hex(), err)
+			}
+			if len(v) > 0 {
+				_, content, _, err := rlp.Split(v)
+				if err != nil {
+					return fmt.Errorf("%w: slot %s: %v", ErrInvalidProof, s.Key.Hex(), err)
+				}
+				value = common.BytesToHash(content)
+			}
+		}
+		if value != s.Value {
+			return fmt.Errorf("%w: slot %s: wrong value", ErrInvalidProof, s.Key.Hex())
+		}
+	}
+	return nil
+}
+
+// verify checks the account proof against the state root of a block.
+func (a *AccountProof) verify(root common.Hash) error {
+	if a.Balance == nil {
+		return fmt.Errorf("%w: missing balance", ErrInvalidProof)
+	}
+	v, err := trie.VerifyProof(root, crypto.Keccak256(a.Address[:]), proofDB(a.Proof))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	acc := types.StateAccount{
+		Balance:  new(big.Int),
+		Root:     types.EmptyRootHash,
+		CodeHash: types.EmptyCodeHash[:],
+	}
+	if len(v) > 0 {
+		if err := rlp.DecodeBytes(v, &acc); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+	} else if a.CodeHash == (common.Hash{}) {
+		// the code hash of missing accounts is empty
+		acc.CodeHash = a.CodeHash[:]
+	}
+	if acc.Nonce != uint64(a.Nonce) || acc.Balance.Cmp(a.Balance.ToInt()) != 0 ||
+		acc.Root != a.StorageHash || !bytes.Equal(acc.CodeHash, a.CodeHash[:]) {
+		return fmt.Errorf("%w: wrong account state", ErrInvalidProof)
+	}
+	if len(a.Code) > 0 && crypto.Keccak256Hash(a.Code) != a.CodeHash {
+		return fmt.Errorf("%w: wrong code", ErrInvalidProof)
+	}
+	if len(a.Code) == 0 && len(v) > 0 && a.CodeHash != types.EmptyCodeHash {
+		return fmt.Errorf("%w: missing code", ErrInvalidProof)
+	}
+	return a.verifyStorage()
+}
+
+// Verify checks the bundle against the hash of a trusted block, and verifies
+// the credential tree of the subject offline on the proved state, returning
+// its verification report. The report must match the one in the bundle.
+func Verify(ctx context.Context, b *Bundle, trusted common.Hash) (*ctree.VerificationReport, error) {
+	if b.Version != Version {
+		return nil, ErrUnsupportedVersion
+	}
+	if b.Header == nil || b.Header.Hash() != trusted {
+		return nil, ErrUntrustedHeader
+	}
+	for _, a := range b.Accounts {
+		if err := a.verify(b.Header.Root); err != nil {
+			return nil, fmt.Errorf("account %s: %w", a.Address.Hex(), err)
+		}
+	}
+
+	report, err := reportTree(ctx, newOfflineBackend(b.Header, b.Accounts, true), b.Contract, b.Subject)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var expected bytes.Buffer
+	if err := json.Compact(&expected, b.Report); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReportMismatch, err)
+	}
+	if !bytes.Equal(data, expected.Bytes()) {
+		return nil, ErrReportMismatch
+	}
+	return report, nil
+}
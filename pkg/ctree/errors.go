@@ -0,0 +1,85 @@
+package ctree
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CallError is returned when the state of a node contract could not be read,
+// wrapping the error of the backend. Unlike a VerificationError, it says
+// nothing about the validity of the credentials of the node.
+type CallError struct {
+	Contract common.Address
+	Method   string
+	Subject  common.Address
+	Digest   common.Hash
+	Err      error
+}
+
+func (e *CallError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s of %s", e.Method, e.Contract.Hex())
+	writeTarget(&b, e.Subject, e.Digest)
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// VerificationError is returned when a credential or the root of a subject
+// issued by a node contract fails the verification, wrapping the reason.
+type VerificationError struct {
+	Contract common.Address
+	Subject  common.Address
+	Digest   common.Hash
+	Err      error
+}
+
+func (e *VerificationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "node %s", e.Contract.Hex())
+	writeTarget(&b, e.Subject, e.Digest)
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+func writeTarget(b *strings.Builder, subject common.Address, digest common.Hash) {
+	if subject != (common.Address{}) {
+		fmt.Fprintf(b, " subject %s", subject.Hex())
+	}
+	if digest != (common.Hash{}) {
+		fmt.Fprintf(b, " credential %s", digest.Hex())
+	}
+}
+
+// PreflightError is returned when a transaction to a node contract would be
+// reverted, as found by checking the state of the contract before sending
+// it, wrapping the reason.
+type PreflightError struct {
+	Contract common.Address
+	Method   string
+	Sender   common.Address
+	Subject  common.Address
+	Digest   common.Hash
+	Err      error
+}
+
+func (e *PreflightError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s of %s by %s", e.Method, e.Contract.Hex(), e.Sender.Hex())
+	writeTarget(&b, e.Subject, e.Digest)
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
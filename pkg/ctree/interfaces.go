@@ -1,6 +1,9 @@
 package ctree
 
 import (
+	"context"
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -13,6 +16,10 @@ type Issuer interface {
 	Verifier
 }
 
+// NodeInterface is the interface of a node of the credential tree.
+//
+// Deprecated: NodeInterface discards the errors of some reads and relies
+// on the context of the call options. Use NodeInterfaceV2 instead.
 type NodeInterface interface {
 	Address() common.Address
 
@@ -59,6 +66,10 @@ type NodeInterface interface {
 	GetRoot(opts *bind.CallOpts, subject common.Address) ([32]byte, error)
 }
 
+// Verifier verifies the credentials issued by a node.
+//
+// Deprecated: Verifier relies on the context of the call options. Use
+// VerifierV2 instead.
 type Verifier interface {
 	VerifyCredential(onchain bool, opts *bind.CallOpts, subject common.Address, digest [32]byte) error
 
@@ -67,4 +78,105 @@ type Verifier interface {
 	VerifyCredentialRoot(onchain bool, opts *bind.CallOpts, subject common.Address, root [32]byte) error
 
 	VerifyCredentialTree(onchain bool, opts *bind.CallOpts, subject common.Address) error
+
+	// VerifyCredentialTreeContext performs an off-chain verification of the credential
+	// tree of a subject, verifying up to workers sub-trees concurrently.
+	VerifyCredentialTreeContext(ctx context.Context, opts *bind.CallOpts, subject common.Address, workers int) error
+
+	// VerifyCredentialTreeReport verifies the credential tree of a subject off-chain
+	// and reports the state and verification failures of every credential in the tree.
+	VerifyCredentialTreeReport(ctx context.Context, opts *bind.CallOpts, subject common.Address, workers int) (*VerificationReport, error)
+}
+
+type IssuerV2 interface {
+	NodeInterfaceV2
+	VerifierV2
+}
+
+// CallOpts selects the state read by a call: the pending state, or the
+// state at a block number, the latest block if nil. The context of the call
+// is its first argument.
+type CallOpts struct {
+	Pending     bool
+	BlockNumber *big.Int
+}
+
+// Bind returns the options of a contract call using the given context.
+func (opts *CallOpts) Bind(ctx context.Context) *bind.CallOpts {
+	callOpts := &bind.CallOpts{Context: ctx}
+	if opts != nil {
+		callOpts.Pending = opts.Pending
+		callOpts.BlockNumber = opts.BlockNumber
+	}
+	return callOpts
+}
+
+// NodeInterfaceV2 is the interface of a node of the credential tree.
+// Reads take a context and failures to read the contract are returned as
+// CallError. Transactions use the context of the transaction options.
+type NodeInterfaceV2 interface {
+	Address() common.Address
+
+	// RegisterCredential issues a new credential proof ensuring append-only property.
+	RegisterCredential(opts *bind.TransactOpts, subject common.Address, digest [32]byte, witnesses []common.Address) (*types.Transaction, error)
+
+	// Revoke revokes an issued credential proof
+	Revoke(opts *bind.TransactOpts, digest [32]byte, reason [32]byte) (*types.Transaction, error)
+
+	// ApproveCredential approves the emission of a quorum signed credential proof
+	ApproveCredential(opts *bind.TransactOpts, digest [32]byte) (*types.Transaction, error)
+
+	// AggregateCredentials aggregateCredentials aggregates the digests of a given subject.
+	AggregateCredentials(opts *bind.TransactOpts, subject common.Address, digests [][32]byte) (*types.Transaction, error)
+
+	// GetCredentialProof maps document digest to issued credential proof
+	GetCredentialProof(ctx context.Context, opts *CallOpts, digest [32]byte) (*notary.NotaryCredentialProof, error)
+
+	// GetRevokedProof maps document digest to revoked proof
+	GetRevokedProof(ctx context.Context, opts *CallOpts, digest [32]byte) (*notary.NotaryRevocationProof, error)
+
+	// GetRevoked returns a list of revoked credentials
+	GetRevoked(ctx context.Context, opts *CallOpts, subject common.Address) ([][32]byte, error)
+
+	// IsRevoked verifies if a credential proof was revoked
+	IsRevoked(ctx context.Context, opts *CallOpts, digest [32]byte) (bool, error)
+
+	// IsSigned returns whether an owner already signed a digest
+	IsSigned(ctx context.Context, opts *CallOpts, digest [32]byte, owner common.Address) (bool, error)
+
+	// IsQuorumSigned verify if a credential proof was signed by a quorum
+	IsQuorumSigned(ctx context.Context, opts *CallOpts, digest [32]byte) (bool, error)
+
+	// GetDigests returns the list of the issued credentials' digests of a subject
+	GetDigests(ctx context.Context, opts *CallOpts, subject common.Address) ([][32]byte, error)
+
+	// GetWitnesses returns the witnesses of a proof
+	GetWitnesses(ctx context.Context, opts *CallOpts, digest [32]byte) ([]common.Address, error)
+
+	// GetEvidenceRoot returns the root of the evidences of an issued credential proof
+	GetEvidenceRoot(ctx context.Context, opts *CallOpts, digest [32]byte) ([32]byte, error)
+
+	// GetRoot returns the aggregated proof of a subject
+	GetRoot(ctx context.Context, opts *CallOpts, subject common.Address) ([32]byte, error)
+}
+
+// VerifierV2 verifies the credentials issued by a node. Verification
+// failures are returned as VerificationError, wrapping the reason of the
+// failure.
+type VerifierV2 interface {
+	VerifyCredential(ctx context.Context, onchain bool, opts *CallOpts, subject common.Address, digest [32]byte) error
+
+	VerifyIssuedCredentials(ctx context.Context, onchain bool, opts *CallOpts, subject common.Address) error
+
+	VerifyCredentialRoot(ctx context.Context, onchain bool, opts *CallOpts, subject common.Address, root [32]byte) error
+
+	VerifyCredentialTree(ctx context.Context, onchain bool, opts *CallOpts, subject common.Address) error
+
+	// VerifyCredentialTreeContext performs an off-chain verification of the credential
+	// tree of a subject, verifying up to workers sub-trees concurrently.
+	VerifyCredentialTreeContext(ctx context.Context, opts *CallOpts, subject common.Address, workers int) error
+
+	// VerifyCredentialTreeReport verifies the credential tree of a subject off-chain
+	// and reports the state and verification failures of every credential in the tree.
+	VerifyCredentialTreeReport(ctx context.Context, opts *CallOpts, subject common.Address, workers int) (*VerificationReport, error)
 }
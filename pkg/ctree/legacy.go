@@ -0,0 +1,124 @@
+package ctree
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree/notary"
+)
+
+// legacyIssuer implements the Issuer interface on top of an IssuerV2.
+type legacyIssuer struct {
+	IssuerV2
+}
+
+// Legacy returns the Issuer interface of the node, for the callers not
+// migrated to IssuerV2. Its reads use the context of the call options and,
+// as before, the credential and revocation proofs are empty if they could
+// not be read.
+func Legacy(n IssuerV2) Issuer {
+	return &legacyIssuer{n}
+}
+
+// split returns the context and the state selected by the call options.
+func split(opts *bind.CallOpts) (context.Context, *CallOpts) {
+	if opts == nil {
+		return context.Background(), nil
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx, &CallOpts{Pending: opts.Pending, BlockNumber: opts.BlockNumber}
+}
+
+func (l *legacyIssuer) GetCredentialProof(opts *bind.CallOpts, digest [32]byte) *notary.NotaryCredentialProof {
+	ctx, o := split(opts)
+	proof, err := l.IssuerV2.GetCredentialProof(ctx, o, digest)
+	if err != nil {
+		return &notary.NotaryCredentialProof{}
+	}
+	return proof
+}
+
+func (l *legacyIssuer) GetRevokedProof(opts *bind.CallOpts, digest [32]byte) *notary.NotaryRevocationProof {
+	ctx, o := split(opts)
+	proof, err := l.IssuerV2.GetRevokedProof(ctx, o, digest)
+	if err != nil {
+		return &notary.NotaryRevocationProof{}
+	}
+	return proof
+}
+
+func (l *legacyIssuer) GetRevoked(opts *bind.CallOpts, subject common.Address) ([][32]byte, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.GetRevoked(ctx, o, subject)
+}
+
+func (l *legacyIssuer) IsRevoked(opts *bind.CallOpts, digest [32]byte) (bool, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.IsRevoked(ctx, o, digest)
+}
+
+func (l *legacyIssuer) IsSigned(opts *bind.CallOpts, digest [32]byte, owner common.Address) (bool, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.IsSigned(ctx, o, digest, owner)
+}
+
+func (l *legacyIssuer) IsQuorumSigned(opts *bind.CallOpts, digest [32]byte) (bool, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.IsQuorumSigned(ctx, o, digest)
+}
+
+func (l *legacyIssuer) GetDigests(opts *bind.CallOpts, subject common.Address) ([][32]byte, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.GetDigests(ctx, o, subject)
+}
+
+func (l *legacyIssuer) GetWitnesses(opts *bind.CallOpts, digest [32]byte) ([]common.Address, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.GetWitnesses(ctx, o, digest)
+}
+
+func (l *legacyIssuer) GetEvidenceRoot(opts *bind.CallOpts, digest [32]byte) ([32]byte, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.GetEvidenceRoot(ctx, o, digest)
+}
+
+func (l *legacyIssuer) GetRoot(opts *bind.CallOpts, subject common.Address) ([32]byte, error) {
+	ctx, o := split(opts)
+	return l.IssuerV2.GetRoot(ctx, o, subject)
+}
+
+func (l *legacyIssuer) VerifyCredential(onchain bool, opts *bind.CallOpts, subject common.Address, digest [32]byte) error {
+	ctx, o := split(opts)
+	return l.IssuerV2.VerifyCredential(ctx, onchain, o, subject, digest)
+}
+
+func (l *legacyIssuer) VerifyIssuedCredentials(onchain bool, opts *bind.CallOpts, subject common.Address) error {
+	ctx, o := split(opts)
+	return l.IssuerV2.VerifyIssuedCredentials(ctx, onchain, o, subject)
+}
+
+func (l *legacyIssuer) VerifyCredentialRoot(onchain bool, opts *bind.CallOpts, subject common.Address, root [32]byte) error {
+	ctx, o := split(opts)
+	return l.IssuerV2.VerifyCredentialRoot(ctx, onchain, o, subject, root)
+}
+
+func (l *legacyIssuer) VerifyCredentialTree(onchain bool, opts *bind.CallOpts, subject common.Address) error {
+	ctx, o := split(opts)
+	return l.IssuerV2.VerifyCredentialTree(ctx, onchain, o, subject)
+}
+
+// VerifyCredentialTreeContext uses the given context, as it always did.
+func (l *legacyIssuer) VerifyCredentialTreeContext(ctx context.Context, opts *bind.CallOpts, subject common.Address, workers int) error {
+	_, o := split(opts)
+	return l.IssuerV2.VerifyCredentialTreeContext(ctx, o, subject, workers)
+}
+
+func (l *legacyIssuer) VerifyCredentialTreeReport(ctx context.Context, opts *bind.CallOpts, subject common.Address, workers int) (*VerificationReport, error) {
+	_, o := split(opts)
+	return l.IssuerV2.VerifyCredentialTreeReport(ctx, o, subject, workers)
+}
@@ -0,0 +1,200 @@
+// Package memory implements the node contract of the credential tree in Go,
+// keeping the state of the nodes in memory. It follows the semantics of the
+// contract, reverting the same operations with the same reasons, and can be
+// used in place of the node contracts where a chain is not needed.
+package memory
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+
+	bindings "github.com/relab/go-credbindings/node"
+)
+
+// BlockPeriod is the number of seconds between blocks, as in the simulated backend.
+const BlockPeriod = 10
+
+var (
+	ErrNoSender  = errors.New("transaction options without sender")
+	ErrNoHistory = errors.New("in-memory nodes only keep the latest state")
+)
+
+var nodeABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(bindings.NodeABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Revert is returned when a transaction is rejected by a node, with
+// the revert reason that the contract gives for the same transaction.
+type Revert struct {
+	Reason string
+}
+
+func (e *Revert) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+func revert(reason string) error {
+	return &Revert{Reason: reason}
+}
+
+// Chain keeps the in-memory nodes and the block that the transactions are
+// included in. Transactions are applied to the state as soon as they are
+// sent and are included in the pending block until Commit is called.
+type Chain struct {
+	mu     sync.RWMutex
+	number uint64
+	time   uint64
+	nonces map[common.Address]uint64
+	nodes  map[common.Address]*state
+}
+
+// NewChain creates a chain whose head is the given block.
+func NewChain(number, time uint64) *Chain {
+	return &Chain{
+		number: number,
+		time:   time,
+		nonces: make(map[common.Address]uint64),
+		nodes:  make(map[common.Address]*state),
+	}
+}
+
+// Commit seals the pending block.
+func (c *Chain) Commit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.number++
+	c.time += BlockPeriod
+}
+
+// Head returns the number and the time of the last committed block.
+func (c *Chain) Head() (number, time uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.number, c.time
+}
+
+// pending returns the number and time of the block being built.
+func (c *Chain) pending() (*big.Int, *big.Int) {
+	return new(big.Int).SetUint64(c.number + 1), new(big.Int).SetUint64(c.time + BlockPeriod)
+}
+
+// Deploy creates a node at the address of a contract created by the sender.
+func (c *Chain) Deploy(opts *bind.TransactOpts, role uint8, owners []common.Address, quorum uint8) (common.Address, *Node, error) {
+	if opts == nil || opts.From == (common.Address{}) {
+		return common.Address{}, nil, ErrNoSender
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addr := crypto.CreateAddress(opts.From, c.nonces[opts.From])
+	n, err := c.deploy(addr, role, owners, quorum)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	c.nonces[opts.From]++
+	return addr, n, nil
+}
+
+// DeployAt creates a node at the given address, which allows the nodes
+// to mirror contracts deployed elsewhere.
+func (c *Chain) DeployAt(addr common.Address, role uint8, owners []common.Address, quorum uint8) (*Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deploy(addr, role, owners, quorum)
+}
+
+// deploy checks the arguments of the constructor of the contract.
+func (c *Chain) deploy(addr common.Address, role uint8, owners []common.Address, quorum uint8) (*Node, error) {
+	if len(owners) == 0 {
+		return nil, revert("Owners/not enough owners")
+	}
+	if quorum == 0 || int(quorum) > len(owners) {
+		return nil, revert("Owners/quorum out of range")
+	}
+	if role != node.LeafRole && role != node.InnerRole {
+		return nil, revert("")
+	}
+	seen := make(map[common.Address]bool, len(owners))
+	for _, o := range owners {
+		if o == (common.Address{}) || seen[o] {
+			return nil, revert("")
+		}
+		seen[o] = true
+	}
+	if _, ok := c.nodes[addr]; ok {
+		return nil, revert("")
+	}
+	c.nodes[addr] = newState(role, owners, quorum)
+	return &Node{chain: c, address: addr}, nil
+}
+
+// NewNode returns the node deployed at the given address.
+func (c *Chain) NewNode(addr common.Address) (*Node, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.nodes[addr]; !ok {
+		return nil, bind.ErrNoCode
+	}
+	return &Node{chain: c, address: addr}, nil
+}
+
+// transact applies a transaction of the sender to a node, returning the
+// transaction calling the same method of the contract.
+func (c *Chain) transact(opts *bind.TransactOpts, to common.Address, method string, apply func(s *state, sender common.Address) error, args ...interface{}) (*types.Transaction, error) {
+	if opts == nil || opts.From == (common.Address{}) {
+		return nil, ErrNoSender
+	}
+	data, err := nodeABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.nodes[to]
+	if !ok {
+		return nil, bind.ErrNoCode
+	}
+	tx := types.NewTx(&types.LegacyTx{Nonce: c.nonces[opts.From], To: &to, Data: data})
+	if opts.Signer != nil {
+		if tx, err = opts.Signer(opts.From, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := apply(s, opts.From); err != nil {
+		return nil, err
+	}
+	c.nonces[opts.From]++
+	return tx, nil
+}
+
+// read runs a read of a node on the latest state.
+func (c *Chain) read(opts *ctree.CallOpts, addr common.Address, f func(s *state) error) error {
+	if opts != nil && opts.BlockNumber != nil {
+		return ErrNoHistory
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.nodes[addr]
+	if !ok {
+		return bind.ErrNoCode
+	}
+	return f(s)
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/notary"
+)
+
+func TestLegacy(t *testing.T) {
+	owner := backends.TestAccounts[0].Address
+	subject := backends.TestAccounts[5].Address
+	chain := NewChain(1, 1000)
+	_, n, err := chain.Deploy(&bind.TransactOpts{From: owner}, node.LeafRole, []common.Address{owner}, 1)
+	require.NoError(t, err)
+	chain.Commit()
+	_, err = n.RegisterCredential(&bind.TransactOpts{From: owner}, subject, digest(1), nil)
+	require.NoError(t, err)
+	chain.Commit()
+	_, err = n.ApproveCredential(&bind.TransactOpts{From: subject}, digest(1))
+	require.NoError(t, err)
+	chain.Commit()
+
+	l := ctree.Legacy(n)
+	assert.Equal(t, subject, l.GetCredentialProof(nil, digest(1)).Subject)
+	assert.NoError(t, l.VerifyCredential(false, nil, subject, digest(1)))
+	// the block of the call options is read
+	number, _ := chain.Head()
+	_, err = l.IsQuorumSigned(&bind.CallOpts{BlockNumber: new(big.Int).SetUint64(number - 1)}, digest(1))
+	assert.ErrorIs(t, err, ErrNoHistory)
+
+	// the reads use the context of the call options
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &bind.CallOpts{Context: ctx}
+	_, err = l.IsRevoked(canceled, digest(1))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, &notary.NotaryCredentialProof{}, l.GetCredentialProof(canceled, digest(1)))
+	assert.ErrorIs(t, l.VerifyCredentialTreeContext(ctx, nil, subject, 1), context.Canceled)
+}
@@ -0,0 +1,587 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/go-credbindings/aggregator"
+	"github.com/relab/go-credbindings/notary"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// Accounts used by the tests: owners are chosen from the first accounts,
+// the next ones are not owners of any node and the last ones are subjects.
+const (
+	numOwners   = 3
+	numAccounts = 5
+	numDigests  = 4
+)
+
+var subjects = []int{5, 6}
+
+// issuer is the common interface of the node contracts and in-memory nodes.
+type issuer interface {
+	ctree.IssuerV2
+	IsLeaf(ctx context.Context, opts *ctree.CallOpts) (bool, error)
+	GetOwnersContext(ctx context.Context, opts *ctree.CallOpts) ([]common.Address, error)
+	QuorumContext(ctx context.Context, opts *ctree.CallOpts) (uint8, error)
+	AddNode(opts *bind.TransactOpts, node common.Address) (*types.Transaction, error)
+	ChangeOwner(opts *bind.TransactOpts, newOwner common.Address) (*types.Transaction, error)
+}
+
+type nodeSpec struct {
+	role   uint8
+	owners []int
+	quorum uint8
+}
+
+type opKind int
+
+const (
+	opRegister opKind = iota
+	opApprove
+	opRevoke
+	opAggregate
+	opAddChild
+	opChangeOwner
+)
+
+// op is an operation sent by an account to one of the nodes. Nodes,
+// accounts and digests are referenced by their index.
+type op struct {
+	kind      opKind
+	node      int
+	sender    int
+	subject   int
+	digest    byte
+	witnesses []int
+	digests   []byte
+	target    int
+}
+
+func (o op) String() string {
+	names := []string{"register", "approve", "revoke", "aggregate", "addChild", "changeOwner"}
+	return fmt.Sprintf("%s{node: %d sender: %d subject: %d digest: %d witnesses: %v digests: %v target: %d}",
+		names[o.kind], o.node, o.sender, o.subject, o.digest, o.witnesses, o.digests, o.target)
+}
+
+func digest(i byte) [32]byte {
+	return [32]byte{i}
+}
+
+// harness sends the same operations to node contracts deployed on a
+// simulated chain and to in-memory nodes at the same addresses, checking
+// that both accept or reject every operation and end in the same state.
+type harness struct {
+	t         *testing.T
+	ctx       context.Context
+	backend   *backends.TestBackend
+	chain     *Chain
+	libs      map[string]string
+	contracts []issuer
+	nodes     []issuer
+}
+
+func newHarness(t *testing.T, specs []nodeSpec) *harness {
+	backend := backends.NewTestBackend()
+	t.Cleanup(func() { backend.Close() })
+	opts := backend.TransactOpts(backends.TestAccounts[0].Key)
+
+	libs := make(map[string]string)
+	aggregatorAddr, _, _, err := aggregator.DeployCredentialSum(opts, backend)
+	require.NoError(t, err)
+	libs["CredentialSum"] = aggregatorAddr.Hex()
+	notaryAddr, _, _, err := notary.DeployNotary(opts, backend)
+	require.NoError(t, err)
+	libs["Notary"] = notaryAddr.Hex()
+	backend.Commit()
+
+	h := &harness{t: t, ctx: context.Background(), backend: backend, libs: libs}
+	for _, s := range specs {
+		owners := make([]common.Address, len(s.owners))
+		for i, o := range s.owners {
+			owners[i] = backends.TestAccounts[o].Address
+		}
+		_, _, c, err := node.Deploy(opts, backend, libs, s.role, owners, s.quorum)
+		require.NoError(t, err)
+		backend.Commit()
+		h.contracts = append(h.contracts, c)
+	}
+
+	head := backend.Blockchain().CurrentBlock()
+	h.chain = NewChain(head.Number.Uint64(), head.Time)
+	for i, s := range specs {
+		owners, err := h.contracts[i].GetOwnersContext(h.ctx, nil)
+		require.NoError(t, err)
+		n, err := h.chain.DeployAt(h.contracts[i].Address(), s.role, owners, s.quorum)
+		require.NoError(t, err)
+		h.nodes = append(h.nodes, n)
+	}
+	return h
+}
+
+func (h *harness) run(n issuer, o op) error {
+	opts := h.backend.TransactOpts(backends.TestAccounts[o.sender].Key)
+	subject := backends.TestAccounts[o.subject].Address
+	var err error
+	switch o.kind {
+	case opRegister:
+		witnesses := make([]common.Address, len(o.witnesses))
+		for i, w := range o.witnesses {
+			witnesses[i] = h.nodes[w].Address()
+		}
+		_, err = n.RegisterCredential(opts, subject, digest(o.digest), witnesses)
+	case opApprove:
+		_, err = n.ApproveCredential(opts, digest(o.digest))
+	case opRevoke:
+		_, err = n.Revoke(opts, digest(o.digest), digest(o.digest))
+	case opAggregate:
+		digests := make([][32]byte, len(o.digests))
+		for i, d := range o.digests {
+			digests[i] = digest(d)
+		}
+		_, err = n.AggregateCredentials(opts, subject, digests)
+	case opAddChild:
+		_, err = n.AddNode(opts, h.nodes[o.target].Address())
+	case opChangeOwner:
+		_, err = n.ChangeOwner(opts, backends.TestAccounts[o.target].Address)
+	}
+	return err
+}
+
+// apply sends the operation to both nodes and commits the block.
+// apply sends the operation to both implementations, returning whether
+// it was accepted. Rejected operations leave the state unchanged.
+func (h *harness) apply(o op) bool {
+	h.t.Helper()
+	want := h.run(h.contracts[o.node], o)
+	got := h.run(h.nodes[o.node], o)
+	h.backend.Commit()
+	h.chain.Commit()
+	if want == nil {
+		require.NoError(h.t, got, o.String())
+		return true
+	}
+	require.Error(h.t, got, "%s: contract: %v", o, want)
+	require.Equal(h.t, want.Error(), got.Error(), o.String())
+	return false
+}
+
+// check compares the state read from the contracts and the in-memory nodes.
+func (h *harness) check(step string) {
+	h.t.Helper()
+	ctx := h.ctx
+	equal := func(want, got result, msg string, args ...interface{}) {
+		h.t.Helper()
+		require.Equal(h.t, want.String(), got.String(), "%s: %s", step, fmt.Sprintf(msg, args...))
+	}
+	sameResult := func(want, got error, msg string, args ...interface{}) {
+		h.t.Helper()
+		require.Equal(h.t, want == nil, got == nil, "%s: %s: contract: %v memory: %v", step, fmt.Sprintf(msg, args...), want, got)
+	}
+	// off-chain verifications return the same typed errors
+	sameError := func(want, got error, msg string, args ...interface{}) {
+		h.t.Helper()
+		equal(res(nil, want), res(nil, got), msg, args...)
+	}
+
+	for i, c := range h.contracts {
+		n := h.nodes[i]
+		equal(res(c.IsLeaf(ctx, nil)), res(n.IsLeaf(ctx, nil)), "node %d isLeaf", i)
+		equal(res(c.GetOwnersContext(ctx, nil)), res(n.GetOwnersContext(ctx, nil)), "node %d owners", i)
+		equal(res(c.QuorumContext(ctx, nil)), res(n.QuorumContext(ctx, nil)), "node %d quorum", i)
+
+		for _, sIdx := range subjects {
+			s := backends.TestAccounts[sIdx].Address
+			digests, err := c.GetDigests(ctx, nil, s)
+			require.NoError(h.t, err, step)
+			equal(res(digests, err), res(n.GetDigests(ctx, nil, s)), "node %d digests of %d", i, sIdx)
+			equal(res(c.GetRevoked(ctx, nil, s)), res(n.GetRevoked(ctx, nil, s)), "node %d revoked of %d", i, sIdx)
+			root, err := c.GetRoot(ctx, nil, s)
+			require.NoError(h.t, err, step)
+			equal(res(root, err), res(n.GetRoot(ctx, nil, s)), "node %d root of %d", i, sIdx)
+
+			sameResult(c.VerifyIssuedCredentials(ctx, true, nil, s), n.VerifyIssuedCredentials(ctx, true, nil, s), "node %d verifyIssuedCredentials of %d", i, sIdx)
+			sameResult(c.VerifyCredentialTree(ctx, true, nil, s), n.VerifyCredentialTree(ctx, true, nil, s), "node %d verifyCredentialTree of %d", i, sIdx)
+			sameResult(c.VerifyCredentialRoot(ctx, true, nil, s, root), n.VerifyCredentialRoot(ctx, true, nil, s, root), "node %d verifyCredentialRoot of %d", i, sIdx)
+			listRoot, err := encode.EncodeByteArray(digests)
+			require.NoError(h.t, err)
+			sameResult(c.VerifyCredentialRoot(ctx, true, nil, s, listRoot), n.VerifyCredentialRoot(ctx, true, nil, s, listRoot), "node %d verifyCredentialRoot of digests of %d", i, sIdx)
+
+			sameError(c.VerifyIssuedCredentials(ctx, false, nil, s), n.VerifyIssuedCredentials(ctx, false, nil, s), "node %d off-chain verifyIssuedCredentials of %d", i, sIdx)
+			sameError(c.VerifyCredentialTree(ctx, false, nil, s), n.VerifyCredentialTree(ctx, false, nil, s), "node %d off-chain verifyCredentialTree of %d", i, sIdx)
+			sameError(c.VerifyCredentialTreeContext(ctx, nil, s, 2), n.VerifyCredentialTreeContext(ctx, nil, s, 2), "node %d verifyCredentialTreeContext of %d", i, sIdx)
+			sameError(c.VerifyCredentialRoot(ctx, false, nil, s, root), n.VerifyCredentialRoot(ctx, false, nil, s, root), "node %d off-chain verifyCredentialRoot of %d", i, sIdx)
+			sameError(c.VerifyCredentialRoot(ctx, false, nil, s, listRoot), n.VerifyCredentialRoot(ctx, false, nil, s, listRoot), "node %d off-chain verifyCredentialRoot of digests of %d", i, sIdx)
+
+			want, err := c.VerifyCredentialTreeReport(ctx, nil, s, 1)
+			require.NoError(h.t, err, step)
+			got, err := n.VerifyCredentialTreeReport(ctx, nil, s, 1)
+			require.NoError(h.t, err, step)
+			require.JSONEq(h.t, reportJSON(h.t, want), reportJSON(h.t, got), "%s: node %d report of %d", step, i, sIdx)
+		}
+
+		for d := byte(1); d <= numDigests; d++ {
+			equal(res(c.GetCredentialProof(ctx, nil, digest(d))), res(n.GetCredentialProof(ctx, nil, digest(d))), "node %d proof of %d", i, d)
+			equal(res(c.GetRevokedProof(ctx, nil, digest(d))), res(n.GetRevokedProof(ctx, nil, digest(d))), "node %d revocation of %d", i, d)
+			equal(res(c.IsRevoked(ctx, nil, digest(d))), res(n.IsRevoked(ctx, nil, digest(d))), "node %d isRevoked %d", i, d)
+			equal(res(c.IsQuorumSigned(ctx, nil, digest(d))), res(n.IsQuorumSigned(ctx, nil, digest(d))), "node %d isQuorumSigned %d", i, d)
+			equal(res(c.GetWitnesses(ctx, nil, digest(d))), res(n.GetWitnesses(ctx, nil, digest(d))), "node %d witnesses of %d", i, d)
+			equal(res(c.GetEvidenceRoot(ctx, nil, digest(d))), res(n.GetEvidenceRoot(ctx, nil, digest(d))), "node %d evidence root of %d", i, d)
+			for a := 0; a < numAccounts; a++ {
+				owner := backends.TestAccounts[a].Address
+				equal(res(c.IsSigned(ctx, nil, digest(d), owner)), res(n.IsSigned(ctx, nil, digest(d), owner)), "node %d isSigned %d by %d", i, d, a)
+			}
+			for _, sIdx := range subjects {
+				s := backends.TestAccounts[sIdx].Address
+				sameResult(c.VerifyCredential(ctx, true, nil, s, digest(d)), n.VerifyCredential(ctx, true, nil, s, digest(d)), "node %d verifyCredential %d of %d", i, d, sIdx)
+				sameError(c.VerifyCredential(ctx, false, nil, s, digest(d)), n.VerifyCredential(ctx, false, nil, s, digest(d)), "node %d off-chain verifyCredential %d of %d", i, d, sIdx)
+			}
+		}
+	}
+}
+
+// result is the value and error of a read, compared by their text.
+type result struct {
+	v   interface{}
+	err error
+}
+
+func res(v interface{}, err error) result {
+	return result{v, err}
+}
+
+func (r result) String() string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	return fmt.Sprintf("%+v", r.v)
+}
+
+func reportJSON(t *testing.T, r *ctree.VerificationReport) string {
+	b, err := json.Marshal(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// tree deploys two leaves with different quorums and two inner nodes,
+// the second one having the first as a child.
+var tree = []nodeSpec{
+	{role: node.LeafRole, owners: []int{0, 1}, quorum: 2},
+	{role: node.LeafRole, owners: []int{0}, quorum: 1},
+	{role: node.InnerRole, owners: []int{0, 2}, quorum: 1},
+	{role: node.InnerRole, owners: []int{0}, quorum: 1},
+}
+
+var treeChildren = []op{
+	{kind: opAddChild, node: 2, target: 0},
+	{kind: opAddChild, node: 2, target: 1},
+	{kind: opAddChild, node: 3, target: 2},
+	{kind: opAddChild, node: 3, target: 1},
+}
+
+func TestDifferentialDeploy(t *testing.T) {
+	h := newHarness(t, nil)
+	opts := h.backend.TransactOpts(backends.TestAccounts[0].Key)
+	tests := []nodeSpec{
+		{role: node.LeafRole, owners: []int{0, 1}, quorum: 2},
+		{role: node.InnerRole, owners: []int{0}, quorum: 1},
+		{role: node.LeafRole, quorum: 0},
+		{role: node.LeafRole, owners: []int{0}, quorum: 0},
+		{role: node.LeafRole, owners: []int{0}, quorum: 2},
+		{role: node.LeafRole, owners: []int{0, 0}, quorum: 1},
+		{role: node.InnerRole + 1, owners: []int{0}, quorum: 1},
+	}
+	for _, tt := range tests {
+		owners := make([]common.Address, len(tt.owners))
+		for i, o := range tt.owners {
+			owners[i] = backends.TestAccounts[o].Address
+		}
+		_, _, _, want := node.Deploy(opts, h.backend, h.libs, tt.role, owners, tt.quorum)
+		h.backend.Commit()
+		_, _, got := h.chain.Deploy(opts, tt.role, owners, tt.quorum)
+		if want == nil {
+			require.NoError(t, got, "%+v", tt)
+			continue
+		}
+		require.Error(t, got, "%+v", tt)
+		require.Equal(t, want.Error(), got.Error(), "%+v", tt)
+	}
+}
+
+func TestDifferentialScenario(t *testing.T) {
+	h := newHarness(t, tree)
+	h.check("deploy")
+
+	s, other := subjects[0], subjects[1]
+	ops := append(append([]op{}, treeChildren...), []op{
+		{kind: opAddChild, node: 0, target: 1},
+		{kind: opAddChild, node: 2, target: 2},
+		{kind: opAddChild, node: 2, target: 0},
+		// quorum signing in a leaf
+		{kind: opRegister, node: 0, sender: 0, subject: s, digest: 1},
+		{kind: opRegister, node: 0, sender: 0, subject: s, digest: 1},
+		{kind: opRegister, node: 0, sender: 3, subject: s, digest: 1},
+		{kind: opRegister, node: 0, sender: 1, subject: other, digest: 1},
+		{kind: opRegister, node: 0, sender: 0, subject: s, digest: 2, witnesses: []int{1}},
+		{kind: opApprove, node: 0, sender: s, digest: 1},
+		{kind: opRegister, node: 0, sender: 1, subject: s, digest: 1},
+		{kind: opApprove, node: 0, sender: other, digest: 1},
+		{kind: opApprove, node: 0, sender: s, digest: 1},
+		{kind: opApprove, node: 0, sender: s, digest: 1},
+		{kind: opApprove, node: 0, sender: s, digest: 3},
+		// aggregation
+		{kind: opAggregate, node: 0, sender: 0, subject: s},
+		{kind: opAggregate, node: 0, sender: 0, subject: s, digests: []byte{3}},
+		{kind: opAggregate, node: 0, sender: 0, subject: other, digests: []byte{1}},
+		{kind: opAggregate, node: 0, sender: 2, subject: s, digests: []byte{1}},
+		{kind: opAggregate, node: 0, sender: 0, subject: s, digests: []byte{1, 1}},
+		{kind: opAggregate, node: 0, sender: 0, subject: s, digests: []byte{1}},
+		{kind: opRegister, node: 1, sender: 0, subject: s, digest: 1},
+		{kind: opApprove, node: 1, sender: s, digest: 1},
+		{kind: opAggregate, node: 1, sender: 0, subject: s, digests: []byte{1}},
+		// witnesses of inner nodes
+		{kind: opRegister, node: 2, sender: 0, subject: s, digest: 1},
+		{kind: opRegister, node: 2, sender: 0, subject: s, digest: 1, witnesses: []int{3}},
+		{kind: opRegister, node: 2, sender: 0, subject: other, digest: 1, witnesses: []int{0}},
+		{kind: opRegister, node: 2, sender: 0, subject: s, digest: 1, witnesses: []int{0, 1}},
+		{kind: opRegister, node: 2, sender: 2, subject: s, digest: 1, witnesses: []int{1}},
+		{kind: opRegister, node: 2, sender: 2, subject: s, digest: 1, witnesses: []int{0, 1}},
+		{kind: opApprove, node: 2, sender: s, digest: 1},
+		{kind: opRegister, node: 2, sender: 0, subject: s, digest: 2, witnesses: []int{1, 1}},
+		{kind: opApprove, node: 2, sender: s, digest: 2},
+		{kind: opAggregate, node: 2, sender: 0, subject: s, digests: []byte{1, 2}},
+		{kind: opRegister, node: 3, sender: 0, subject: s, digest: 1, witnesses: []int{2}},
+		{kind: opApprove, node: 3, sender: s, digest: 1},
+		{kind: opRegister, node: 3, sender: 0, subject: s, digest: 2, witnesses: []int{1}},
+		{kind: opApprove, node: 3, sender: s, digest: 2},
+		// a new credential in a leaf makes the evidence of the inner nodes stale
+		{kind: opRegister, node: 1, sender: 0, subject: s, digest: 2},
+		{kind: opApprove, node: 1, sender: s, digest: 2},
+		{kind: opAggregate, node: 1, sender: 0, subject: s, digests: []byte{1, 2}},
+		{kind: opRegister, node: 2, sender: 0, subject: s, digest: 3, witnesses: []int{1}},
+		// revocation
+		{kind: opRevoke, node: 0, sender: 3, digest: 1},
+		{kind: opRevoke, node: 0, sender: 0, digest: 4},
+		{kind: opRegister, node: 0, sender: 0, subject: s, digest: 4},
+		{kind: opRevoke, node: 0, sender: 1, digest: 4},
+		{kind: opRevoke, node: 0, sender: 0, digest: 4},
+		{kind: opRegister, node: 0, sender: 1, subject: s, digest: 4},
+		{kind: opApprove, node: 0, sender: s, digest: 4},
+		{kind: opRevoke, node: 1, sender: 0, digest: 1},
+		{kind: opAggregate, node: 1, sender: 0, subject: s, digests: []byte{2}},
+		// owners
+		{kind: opChangeOwner, node: 0, sender: 1, target: 0},
+		{kind: opChangeOwner, node: 0, sender: 3, target: 4},
+		{kind: opChangeOwner, node: 0, sender: 1, target: 3},
+		{kind: opRegister, node: 0, sender: 3, subject: s, digest: 2},
+		{kind: opRegister, node: 0, sender: 1, subject: s, digest: 2},
+	}...)
+	checkEvery, accepted := 1, 0
+	if testing.Short() {
+		checkEvery = 5
+	}
+	for i, o := range ops {
+		if h.apply(o) {
+			accepted++
+			if accepted%checkEvery == 0 {
+				h.check(fmt.Sprintf("op %d %s", i, o))
+			}
+		}
+	}
+	h.check("end")
+}
+
+// TestDifferentialDeepTree issues credentials in a tree of four levels,
+// where the contracts only check the witnesses that are leaves, and
+// compares the off-chain verifications of the whole tree.
+func TestDifferentialDeepTree(t *testing.T) {
+	h := newHarness(t, []nodeSpec{
+		{role: node.LeafRole, owners: []int{0}, quorum: 1},
+		{role: node.LeafRole, owners: []int{1}, quorum: 1},
+		{role: node.InnerRole, owners: []int{0}, quorum: 1},
+		{role: node.InnerRole, owners: []int{0, 1}, quorum: 2},
+		{role: node.InnerRole, owners: []int{2}, quorum: 1},
+	})
+	s := subjects[0]
+	issue := func(n, owner int, d byte, witnesses ...int) []op {
+		return []op{
+			{kind: opRegister, node: n, sender: owner, subject: s, digest: d, witnesses: witnesses},
+			{kind: opApprove, node: n, sender: s, digest: d},
+			{kind: opAggregate, node: n, sender: owner, subject: s, digests: []byte{d}},
+		}
+	}
+	ops := []op{
+		{kind: opAddChild, node: 2, target: 0},
+		{kind: opAddChild, node: 2, target: 1},
+		{kind: opAddChild, node: 3, target: 2},
+		{kind: opAddChild, node: 3, target: 1},
+		{kind: opAddChild, node: 4, sender: 2, target: 3},
+	}
+	ops = append(ops, issue(0, 0, 1)...)
+	ops = append(ops, issue(1, 1, 2)...)
+	ops = append(ops, issue(2, 0, 3, 0, 1)...)
+	ops = append(ops, []op{
+		{kind: opRegister, node: 3, sender: 0, subject: s, digest: 4, witnesses: []int{2, 1}},
+		{kind: opRegister, node: 3, sender: 1, subject: s, digest: 4, witnesses: []int{2, 1}},
+		{kind: opApprove, node: 3, sender: s, digest: 4},
+		{kind: opAggregate, node: 3, sender: 0, subject: s, digests: []byte{4}},
+	}...)
+	ops = append(ops, issue(4, 2, 1, 3)...)
+	ops = append(ops, []op{
+		// a revocation two levels below the root
+		{kind: opRevoke, node: 2, sender: 0, digest: 3},
+		// a leaf credential issued after the evidence was registered
+		{kind: opRegister, node: 0, sender: 0, subject: s, digest: 2},
+		{kind: opApprove, node: 0, sender: s, digest: 2},
+		{kind: opAggregate, node: 0, sender: 0, subject: s, digests: []byte{1, 2}},
+	}...)
+	for i, o := range ops {
+		require.True(t, h.apply(o), o.String())
+		h.check(fmt.Sprintf("op %d %s", i, o))
+	}
+	err := h.nodes[4].VerifyCredentialTree(h.ctx, false, nil, backends.TestAccounts[s].Address)
+	var verr *ctree.VerificationError
+	require.ErrorAs(t, err, &verr)
+	require.ErrorIs(t, err, node.ErrCredentialRevoked)
+	require.Equal(t, h.nodes[2].Address(), verr.Contract)
+}
+
+// randomOp returns an operation that is likely, but not certain, to be
+// accepted by the node, choosing its arguments from the in-memory state.
+func (h *harness) randomOp(rnd *rand.Rand) op {
+	o := op{
+		node:    rnd.Intn(len(h.nodes)),
+		sender:  rnd.Intn(numAccounts),
+		subject: subjects[rnd.Intn(len(subjects))],
+		digest:  byte(1 + rnd.Intn(numDigests)),
+	}
+	s := h.chain.nodes[h.nodes[o.node].Address()]
+	if rnd.Intn(10) > 0 {
+		o.sender = h.account(s.owners[rnd.Intn(len(s.owners))])
+	}
+	// pending picks a credential that is not approved yet, if any
+	pending := func() *record {
+		var p *record
+		for _, r := range s.records {
+			if !r.proof.Approved && (p == nil || rnd.Intn(2) == 0) {
+				p = r
+			}
+		}
+		if p != nil {
+			o.digest = p.proof.Digest[0]
+			o.subject = h.account(p.proof.Subject)
+		}
+		return p
+	}
+
+	switch k := rnd.Intn(100); {
+	case k < 40:
+		o.kind = opRegister
+		if rnd.Intn(2) == 0 {
+			pending()
+		}
+		if !s.isLeaf() || rnd.Intn(10) == 0 {
+			for i := rnd.Intn(3); i >= 0; i-- {
+				w := rnd.Intn(len(h.nodes))
+				if len(s.children) > 0 && rnd.Intn(10) > 0 {
+					w = h.index(s.children[rnd.Intn(len(s.children))])
+				}
+				o.witnesses = append(o.witnesses, w)
+			}
+		}
+	case k < 65:
+		o.kind = opApprove
+		if p := pending(); p != nil && rnd.Intn(10) > 0 {
+			o.sender = o.subject
+		}
+	case k < 85:
+		o.kind = opAggregate
+		for _, subject := range subjects {
+			if len(s.digests[backends.TestAccounts[subject].Address]) > 0 && rnd.Intn(2) == 0 {
+				o.subject = subject
+			}
+		}
+		for _, d := range s.digests[backends.TestAccounts[o.subject].Address] {
+			if rnd.Intn(5) > 0 {
+				o.digests = append(o.digests, d[0])
+			}
+		}
+		if rnd.Intn(5) == 0 {
+			rnd.Shuffle(len(o.digests), func(i, j int) { o.digests[i], o.digests[j] = o.digests[j], o.digests[i] })
+		}
+	case k < 93:
+		o.kind = opRevoke
+	case k < 98:
+		o.kind = opAddChild
+		o.target = rnd.Intn(len(h.nodes))
+	default:
+		o.kind = opChangeOwner
+		o.target = rnd.Intn(numAccounts)
+	}
+	return o
+}
+
+// account returns the index of a test account.
+func (h *harness) account(addr common.Address) int {
+	for i, a := range backends.TestAccounts {
+		if a.Address == addr {
+			return i
+		}
+	}
+	h.t.Fatalf("unknown account %s", addr.Hex())
+	return -1
+}
+
+// index returns the index of a node.
+func (h *harness) index(addr common.Address) int {
+	for i, n := range h.nodes {
+		if n.Address() == addr {
+			return i
+		}
+	}
+	h.t.Fatalf("unknown node %s", addr.Hex())
+	return -1
+}
+
+func TestDifferentialRandom(t *testing.T) {
+	// the state is compared every few accepted operations, since reading
+	// it from the contracts is much slower than running the operations
+	const numOps, checkEvery = 100, 5
+	seeds := int64(3)
+	if testing.Short() {
+		seeds = 1
+	}
+	for seed := int64(1); seed <= seeds; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			h := newHarness(t, tree)
+			for _, o := range treeChildren {
+				h.apply(o)
+			}
+			rnd := rand.New(rand.NewSource(seed))
+			accepted := 0
+			for i := 0; i < numOps; i++ {
+				o := h.randomOp(rnd)
+				if h.apply(o) {
+					accepted++
+					if accepted%checkEvery == 0 {
+						h.check(fmt.Sprintf("op %d %s", i, o))
+					}
+				}
+			}
+			h.check("end")
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/notary"
+)
+
+// Node is an in-memory node of the credential tree.
+type Node struct {
+	chain   *Chain
+	address common.Address
+}
+
+var _ ctree.IssuerV2 = (*Node)(nil)
+
+// Address returns the address of the node.
+func (n *Node) Address() common.Address {
+	return n.address
+}
+
+// read runs a read of the node state, returning failures as CallError.
+func (n *Node) read(ctx context.Context, opts *ctree.CallOpts, method string, subject common.Address, digest [32]byte, f func(s *state) error) error {
+	err := ctx.Err()
+	if err == nil {
+		err = n.chain.read(opts, n.address, f)
+	}
+	if err != nil {
+		return &ctree.CallError{Contract: n.address, Method: method, Subject: subject, Digest: digest, Err: err}
+	}
+	return nil
+}
+
+// IsLeaf returns whether the node is a leaf of the credential tree
+func (n *Node) IsLeaf(ctx context.Context, opts *ctree.CallOpts) (leaf bool, err error) {
+	err = n.read(ctx, opts, "isLeaf", common.Address{}, [32]byte{}, func(s *state) error {
+		leaf = s.isLeaf()
+		return nil
+	})
+	return leaf, err
+}
+
+// IsOwnerContext check if a given address is an Owner
+func (n *Node) IsOwnerContext(ctx context.Context, opts *ctree.CallOpts, address common.Address) (owner bool, err error) {
+	err = n.read(ctx, opts, "isOwner", common.Address{}, [32]byte{}, func(s *state) error {
+		owner = s.isOwner(address)
+		return nil
+	})
+	return owner, err
+}
+
+// GetOwnersContext returns the list of owners
+func (n *Node) GetOwnersContext(ctx context.Context, opts *ctree.CallOpts) (owners []common.Address, err error) {
+	err = n.read(ctx, opts, "owners", common.Address{}, [32]byte{}, func(s *state) error {
+		owners = append([]common.Address{}, s.owners...)
+		return nil
+	})
+	return owners, err
+}
+
+// QuorumContext returns the number of signatures required to approve a credential
+func (n *Node) QuorumContext(ctx context.Context, opts *ctree.CallOpts) (quorum uint8, err error) {
+	err = n.read(ctx, opts, "quorum", common.Address{}, [32]byte{}, func(s *state) error {
+		quorum = s.quorum
+		return nil
+	})
+	return quorum, err
+}
+
+// ChangeOwner one of the owners. Sender should be the old owner.
+func (n *Node) ChangeOwner(opts *bind.TransactOpts, newOwner common.Address) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "changeOwner", func(s *state, sender common.Address) error {
+		return n.chain.changeOwner(s, sender, newOwner)
+	}, newOwner)
+}
+
+// AddNode registers a new child node
+func (n *Node) AddNode(opts *bind.TransactOpts, child common.Address) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "addChild", func(s *state, sender common.Address) error {
+		return n.chain.addChild(s, n.address, sender, child)
+	}, child)
+}
+
+// RegisterCredential issues a new credential proof ensuring append-only property.
+// If the credential already exist, the sender signs the existing credential.
+func (n *Node) RegisterCredential(opts *bind.TransactOpts, subject common.Address, digest [32]byte, witnesses []common.Address) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "registerCredential", func(s *state, sender common.Address) error {
+		return n.chain.registerCredential(s, sender, subject, digest, witnesses)
+	}, subject, digest, witnesses)
+}
+
+// ApproveCredential approves the emission of a quorum signed credential proof
+func (n *Node) ApproveCredential(opts *bind.TransactOpts, digest [32]byte) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "approveCredential", func(s *state, sender common.Address) error {
+		return n.chain.approveCredential(s, sender, digest)
+	}, digest)
+}
+
+// Revoke revokes an issued credential proof
+func (n *Node) Revoke(opts *bind.TransactOpts, digest [32]byte, reason [32]byte) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "revokeCredential", func(s *state, sender common.Address) error {
+		return n.chain.revokeCredential(s, sender, digest, reason)
+	}, digest, reason)
+}
+
+// AggregateCredentials aggregates the digests of a given subject.
+func (n *Node) AggregateCredentials(opts *bind.TransactOpts, subject common.Address, digests [][32]byte) (*types.Transaction, error) {
+	return n.chain.transact(opts, n.address, "aggregateCredentials", func(s *state, sender common.Address) error {
+		return n.chain.aggregateCredentials(s, sender, subject, digests)
+	}, subject, digests)
+}
+
+// GetCredentialProof returns the credential proof of a digest
+func (n *Node) GetCredentialProof(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (cp *notary.NotaryCredentialProof, err error) {
+	err = n.read(ctx, opts, "getCredentialProof", common.Address{}, digest, func(s *state) error {
+		r, ok := s.records[digest]
+		if !ok {
+			cp = &notary.NotaryCredentialProof{
+				Signed:         new(big.Int),
+				InsertedBlock:  new(big.Int),
+				BlockTimestamp: new(big.Int),
+				Nonce:          new(big.Int),
+				Witnesses:      []common.Address{},
+			}
+			return nil
+		}
+		p := r.proof
+		p.Signed = new(big.Int).Set(p.Signed)
+		p.Witnesses = append([]common.Address{}, p.Witnesses...)
+		cp = &p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// GetRevokedProof returns the revocation proof of a digest
+func (n *Node) GetRevokedProof(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (rp *notary.NotaryRevocationProof, err error) {
+	err = n.read(ctx, opts, "getRevokedProof", common.Address{}, digest, func(s *state) error {
+		p, ok := s.revoked[digest]
+		if !ok {
+			rp = &notary.NotaryRevocationProof{RevokedBlock: new(big.Int)}
+			return nil
+		}
+		c := *p
+		rp = &c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+// GetRevoked returns the revoked credentials of a subject. As in the
+// contract, the list has an entry per revocation but only includes the
+// revoked credentials that were approved, the others being left empty.
+func (n *Node) GetRevoked(ctx context.Context, opts *ctree.CallOpts, subject common.Address) (revoked [][32]byte, err error) {
+	err = n.read(ctx, opts, "getRevoked", subject, [32]byte{}, func(s *state) error {
+		if len(s.digests[subject]) == 0 {
+			return revert("Issuer/there are no credentials")
+		}
+		revoked = s.revokedDigests(subject)
+		return nil
+	})
+	return revoked, err
+}
+
+// IsRevoked verifies if a credential proof was revoked
+func (n *Node) IsRevoked(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (revoked bool, err error) {
+	err = n.read(ctx, opts, "isRevoked", common.Address{}, digest, func(s *state) error {
+		_, revoked = s.revoked[digest]
+		return nil
+	})
+	return revoked, err
+}
+
+// IsSigned returns whether an owner already signed a digest
+func (n *Node) IsSigned(ctx context.Context, opts *ctree.CallOpts, digest [32]byte, owner common.Address) (signed bool, err error) {
+	err = n.read(ctx, opts, "isSigned", common.Address{}, digest, func(s *state) error {
+		if r, ok := s.records[digest]; ok {
+			signed = r.signers[owner]
+		}
+		return nil
+	})
+	return signed, err
+}
+
+// IsQuorumSigned verify if a credential proof was signed by a quorum
+func (n *Node) IsQuorumSigned(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (signed bool, err error) {
+	err = n.read(ctx, opts, "isQuorumSigned", common.Address{}, digest, func(s *state) error {
+		signed = s.isQuorumSigned(digest)
+		return nil
+	})
+	return signed, err
+}
+
+// GetDigests returns the approved credentials of a subject, in approval order
+func (n *Node) GetDigests(ctx context.Context, opts *ctree.CallOpts, subject common.Address) (digests [][32]byte, err error) {
+	err = n.read(ctx, opts, "getDigests", subject, [32]byte{}, func(s *state) error {
+		digests = append([][32]byte{}, s.digests[subject]...)
+		return nil
+	})
+	return digests, err
+}
+
+// GetWitnesses returns the witnesses of a proof
+func (n *Node) GetWitnesses(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (witnesses []common.Address, err error) {
+	err = n.read(ctx, opts, "getWitnesses", common.Address{}, digest, func(s *state) error {
+		witnesses = []common.Address{}
+		if r, ok := s.records[digest]; ok {
+			witnesses = append(witnesses, r.proof.Witnesses...)
+		}
+		return nil
+	})
+	return witnesses, err
+}
+
+// GetEvidenceRoot returns the root of the evidences of an issued credential proof
+func (n *Node) GetEvidenceRoot(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (root [32]byte, err error) {
+	err = n.read(ctx, opts, "getEvidenceRoot", common.Address{}, digest, func(s *state) error {
+		if r, ok := s.records[digest]; ok {
+			root = r.proof.EvidenceRoot
+		}
+		return nil
+	})
+	return root, err
+}
+
+// GetRoot returns the aggregated root of a subject
+func (n *Node) GetRoot(ctx context.Context, opts *ctree.CallOpts, subject common.Address) (root [32]byte, err error) {
+	err = n.read(ctx, opts, "getRoot", subject, [32]byte{}, func(s *state) error {
+		root = s.root(subject)
+		return nil
+	})
+	return root, err
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// The off-chain verifications follow node.Node instead of the contract,
+// checking each condition in the same order and wrapping the reason of the
+// first failure in a VerificationError.
+
+func verificationError(addr, subject common.Address, digest [32]byte, err error) error {
+	return &ctree.VerificationError{Contract: addr, Subject: subject, Digest: digest, Err: err}
+}
+
+// checkCredential verifies an issued credential of the node off-chain.
+func (c *Chain) checkCredential(addr, subject common.Address, digest [32]byte) error {
+	s := c.nodes[addr]
+	r, ok := s.records[digest]
+	var err error
+	switch _, revoked := s.revoked[digest]; {
+	case !ok:
+		err = node.ErrCredentialNotFound
+	case r.proof.Subject != subject:
+		err = node.ErrWrongSubject
+	case !r.proof.Approved:
+		err = node.ErrCredentialNotApproved
+	case !s.isQuorumSigned(digest):
+		err = node.ErrNotQuorumSigned
+	case revoked:
+		err = node.ErrCredentialRevoked
+	case c.evidenceRoot(subject, r.proof.Witnesses) != r.proof.EvidenceRoot:
+		err = node.ErrWrongEvidenceRoot
+	default:
+		return nil
+	}
+	return verificationError(addr, subject, digest, err)
+}
+
+// checkIssuedCredentials verifies all the credentials of a subject off-chain.
+func (c *Chain) checkIssuedCredentials(addr, subject common.Address) error {
+	digests := c.nodes[addr].digests[subject]
+	if len(digests) == 0 {
+		return verificationError(addr, subject, [32]byte{}, node.ErrNoCredentials)
+	}
+	for _, d := range digests {
+		if err := c.checkCredential(addr, subject, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCredentialRoot verifies off-chain that the root is the aggregated
+// root of the subject and aggregates all the credentials of the subject.
+func (c *Chain) checkCredentialRoot(addr, subject common.Address, root [32]byte) error {
+	s := c.nodes[addr]
+	digests := s.digests[subject]
+	if len(digests) == 0 {
+		return verificationError(addr, subject, [32]byte{}, node.ErrNoCredentials)
+	}
+	stored := s.root(subject)
+	if stored == ([32]byte{}) {
+		return verificationError(addr, subject, [32]byte{}, node.ErrRootNotFound)
+	}
+	if stored != root {
+		return verificationError(addr, subject, [32]byte{}, node.ErrWrongRoot)
+	}
+	if r, err := encode.EncodeByteArray(digests); err != nil || r != stored {
+		return verificationError(addr, subject, [32]byte{}, node.ErrVerificationFailed)
+	}
+	return nil
+}
+
+// digestResult keeps the verification of an issued credential
+// and the witnesses referenced by its proof.
+type digestResult struct {
+	err       error
+	digest    [32]byte
+	witnesses []common.Address
+}
+
+// nodeResult is the verification result of a node of the tree.
+type nodeResult struct {
+	err     error
+	digests []digestResult
+}
+
+// treeVerifier verifies the credential tree of a subject off-chain. Each
+// node is verified once and the tree is walked in pre-order, returning the
+// first error found, as the concurrent verification of node.Node.
+type treeVerifier struct {
+	chain   *Chain
+	root    common.Address
+	subject common.Address
+	visited map[common.Address]*nodeResult
+}
+
+// verifyNode verifies the issued credentials of the root and inner nodes
+// and the root of the subject in leaves.
+func (tv *treeVerifier) verifyNode(addr common.Address) *nodeResult {
+	if r, ok := tv.visited[addr]; ok {
+		return r
+	}
+	r := &nodeResult{}
+	tv.visited[addr] = r
+	s := tv.chain.nodes[addr]
+	if addr != tv.root && s.isLeaf() {
+		r.err = tv.chain.checkCredentialRoot(addr, tv.subject, s.root(tv.subject))
+		return r
+	}
+	digests := s.digests[tv.subject]
+	if len(digests) == 0 {
+		r.err = verificationError(addr, tv.subject, [32]byte{}, node.ErrNoCredentials)
+		return r
+	}
+	for _, d := range digests {
+		if err := tv.chain.checkCredential(addr, tv.subject, d); err != nil {
+			r.digests = append(r.digests, digestResult{err: err})
+			return r
+		}
+		r.digests = append(r.digests, digestResult{digest: d, witnesses: s.records[d].proof.Witnesses})
+	}
+	return r
+}
+
+func (tv *treeVerifier) walk(addr common.Address, path map[common.Address]bool) error {
+	r := tv.verifyNode(addr)
+	if r.err != nil {
+		return r.err
+	}
+	for _, d := range r.digests {
+		if d.err != nil {
+			return d.err
+		}
+		seen := make(map[common.Address]bool, len(d.witnesses))
+		for _, w := range d.witnesses {
+			if seen[w] {
+				return verificationError(addr, tv.subject, d.digest, node.ErrRepeatedWitness)
+			}
+			seen[w] = true
+			if path[w] {
+				return verificationError(addr, tv.subject, d.digest, node.ErrCyclicTree)
+			}
+			path[w] = true
+			err := tv.walk(w, path)
+			delete(path, w)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
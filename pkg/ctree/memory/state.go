@@ -0,0 +1,365 @@
+package memory
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// record is an issued credential proof and the owners that signed it.
+type record struct {
+	proof   notary.NotaryCredentialProof
+	signers map[common.Address]bool
+}
+
+// state is the storage of a node contract.
+type state struct {
+	role     uint8
+	owners   []common.Address
+	quorum   uint8
+	children []common.Address
+	isChild  map[common.Address]bool
+
+	records map[[32]byte]*record
+	revoked map[[32]byte]*notary.NotaryRevocationProof
+	nonces  map[common.Address]int64
+	digests map[common.Address][][32]byte
+	revokes map[common.Address]int
+	roots   map[common.Address][32]byte
+}
+
+func newState(role uint8, owners []common.Address, quorum uint8) *state {
+	return &state{
+		role:    role,
+		owners:  append([]common.Address(nil), owners...),
+		quorum:  quorum,
+		isChild: make(map[common.Address]bool),
+		records: make(map[[32]byte]*record),
+		revoked: make(map[[32]byte]*notary.NotaryRevocationProof),
+		nonces:  make(map[common.Address]int64),
+		digests: make(map[common.Address][][32]byte),
+		revokes: make(map[common.Address]int),
+		roots:   make(map[common.Address][32]byte),
+	}
+}
+
+func (s *state) isLeaf() bool {
+	return s.role == node.LeafRole
+}
+
+func (s *state) isOwner(addr common.Address) bool {
+	for _, o := range s.owners {
+		if o == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) onlyOwner(sender common.Address) error {
+	if !s.isOwner(sender) {
+		return revert("Owners/sender is not an owner")
+	}
+	return nil
+}
+
+func (s *state) notRevoked(digest [32]byte) error {
+	if _, ok := s.revoked[digest]; ok {
+		return revert("Issuer/credential revoked")
+	}
+	return nil
+}
+
+func (s *state) isQuorumSigned(digest [32]byte) bool {
+	r, ok := s.records[digest]
+	return ok && r.proof.Signed.Cmp(big.NewInt(int64(s.quorum))) >= 0
+}
+
+// revokedDigests lists the approved credentials of a subject that were
+// revoked, in a list with an entry per revoked credential of the subject.
+func (s *state) revokedDigests(subject common.Address) [][32]byte {
+	revoked := make([][32]byte, s.revokes[subject])
+	i := 0
+	for _, d := range s.digests[subject] {
+		if _, ok := s.revoked[d]; ok {
+			revoked[i] = d
+			i++
+		}
+	}
+	return revoked
+}
+
+func (s *state) root(subject common.Address) [32]byte {
+	return s.roots[subject]
+}
+
+func (c *Chain) changeOwner(s *state, sender, newOwner common.Address) error {
+	if err := s.onlyOwner(sender); err != nil {
+		return err
+	}
+	if newOwner == (common.Address{}) || s.isOwner(newOwner) {
+		return revert("Owners/invalid address given")
+	}
+	// the contract only changes the owners of nodes where all owners sign
+	if int(s.quorum) != len(s.owners) {
+		return revert("")
+	}
+	for i, o := range s.owners {
+		if o == sender {
+			s.owners[i] = newOwner
+		}
+	}
+	return nil
+}
+
+func (c *Chain) addChild(s *state, addr, sender, child common.Address) error {
+	if err := s.onlyOwner(sender); err != nil {
+		return err
+	}
+	if s.isLeaf() {
+		return revert("Node/node must be Inner")
+	}
+	if child == addr {
+		return revert("Node/cannot add itself")
+	}
+	if _, ok := c.nodes[child]; !ok {
+		return revert("")
+	}
+	if s.isChild[child] {
+		return revert("Node/node already added")
+	}
+	s.children = append(s.children, child)
+	s.isChild[child] = true
+	return nil
+}
+
+// evidenceRoot computes the root of the current roots of the witnesses of
+// a subject. Credentials without witnesses have no evidence.
+func (c *Chain) evidenceRoot(subject common.Address, witnesses []common.Address) [32]byte {
+	if len(witnesses) == 0 {
+		return [32]byte{}
+	}
+	roots := make([][32]byte, len(witnesses))
+	for i, w := range witnesses {
+		roots[i] = c.nodes[w].root(subject)
+	}
+	r, _ := encode.EncodeByteArray(roots)
+	return r
+}
+
+func (c *Chain) registerCredential(s *state, sender, subject common.Address, digest [32]byte, witnesses []common.Address) error {
+	if err := s.onlyOwner(sender); err != nil {
+		return err
+	}
+	if s.isLeaf() {
+		if len(witnesses) > 0 {
+			return revert("Node/Leaf cannot have witnesses")
+		}
+	} else {
+		if len(witnesses) == 0 {
+			return revert("Node/witness not found")
+		}
+		for _, w := range witnesses {
+			if !s.isChild[w] {
+				return revert("Node/address not authorized")
+			}
+			if _, ok := c.nodes[w].roots[subject]; !ok {
+				return revert("Node/root not found")
+			}
+		}
+	}
+	if err := s.notRevoked(digest); err != nil {
+		return err
+	}
+	evidenceRoot := c.evidenceRoot(subject, witnesses)
+
+	if r, ok := s.records[digest]; ok {
+		if r.signers[sender] {
+			return revert("Notary/sender already signed")
+		}
+		if r.proof.Subject != subject {
+			return revert("Notary/digest already registered")
+		}
+		if r.proof.EvidenceRoot != evidenceRoot {
+			return revert("Notary/mismatched evidence root")
+		}
+		r.proof.Signed = new(big.Int).Add(r.proof.Signed, big.NewInt(1))
+		r.signers[sender] = true
+		return nil
+	}
+
+	block, timestamp := c.pending()
+	s.nonces[subject]++
+	s.records[digest] = &record{
+		proof: notary.NotaryCredentialProof{
+			Signed:         big.NewInt(1),
+			InsertedBlock:  block,
+			BlockTimestamp: timestamp,
+			Nonce:          big.NewInt(s.nonces[subject]),
+			Digest:         digest,
+			Registrar:      sender,
+			Subject:        subject,
+			Witnesses:      append([]common.Address{}, witnesses...),
+			EvidenceRoot:   evidenceRoot,
+		},
+		signers: map[common.Address]bool{sender: true},
+	}
+	return nil
+}
+
+func (c *Chain) approveCredential(s *state, sender common.Address, digest [32]byte) error {
+	if err := s.notRevoked(digest); err != nil {
+		return err
+	}
+	r, ok := s.records[digest]
+	if !ok || r.proof.Subject != sender {
+		return revert("Notary/wrong subject")
+	}
+	if r.proof.Approved {
+		return revert("Notary/credential already signed")
+	}
+	if !s.isQuorumSigned(digest) {
+		return revert("Notary/no quorum of signatures")
+	}
+	r.proof.Approved = true
+	s.digests[sender] = append(s.digests[sender], digest)
+	return nil
+}
+
+func (c *Chain) revokeCredential(s *state, sender common.Address, digest, reason [32]byte) error {
+	if err := s.onlyOwner(sender); err != nil {
+		return err
+	}
+	if err := s.notRevoked(digest); err != nil {
+		return err
+	}
+	r, ok := s.records[digest]
+	if !ok {
+		return revert("Notary/credential not found")
+	}
+	block, _ := c.pending()
+	s.revoked[digest] = &notary.NotaryRevocationProof{
+		Registrar:    sender,
+		Subject:      r.proof.Subject,
+		RevokedBlock: block,
+		Reason:       reason,
+	}
+	s.revokes[r.proof.Subject]++
+	return nil
+}
+
+func (c *Chain) aggregateCredentials(s *state, sender, subject common.Address, digests [][32]byte) error {
+	if err := s.onlyOwner(sender); err != nil {
+		return err
+	}
+	if len(s.digests[subject]) == 0 {
+		return revert("Issuer/there are no credentials")
+	}
+	if len(digests) == 0 {
+		return revert("CredentialSum/empty list")
+	}
+	for _, d := range digests {
+		if ok, err := s.verifyCredential(subject, d); err != nil {
+			return revert(revertReasons[err])
+		} else if !ok {
+			return revert("Issuer/has invalid credentials")
+		}
+	}
+	root, err := encode.EncodeByteArray(digests)
+	if err != nil {
+		return err
+	}
+	s.roots[subject] = root
+	return nil
+}
+
+// revertReasons are the reasons of the contract for the errors of verifyCredential.
+var revertReasons = map[error]string{
+	node.ErrCredentialNotFound: "Notary/credential not found",
+	node.ErrWrongSubject:       "Notary/not owned by subject",
+}
+
+// isValid reports whether an issued credential is approved,
+// signed by a quorum of owners and not revoked.
+func (s *state) isValid(digest [32]byte) bool {
+	r := s.records[digest]
+	_, revoked := s.revoked[digest]
+	return r.proof.Approved && s.isQuorumSigned(digest) && !revoked
+}
+
+// verifyCredential checks a credential as the verifyCredential method of
+// the contract. The returned error is set where the contract reverts.
+func (s *state) verifyCredential(subject common.Address, digest [32]byte) (bool, error) {
+	r, ok := s.records[digest]
+	if !ok {
+		return false, node.ErrCredentialNotFound
+	}
+	if r.proof.Subject != subject {
+		return false, node.ErrWrongSubject
+	}
+	return s.isValid(digest), nil
+}
+
+func (s *state) verifyIssuedCredentials(subject common.Address) (bool, error) {
+	digests := s.digests[subject]
+	if len(digests) == 0 {
+		return false, node.ErrNoCredentials
+	}
+	for _, d := range digests {
+		if ok, err := s.verifyCredential(subject, d); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// verifyCredentialRoot reports whether the root is the aggregated root of
+// the subject and aggregates all the credentials issued to the subject.
+func (s *state) verifyCredentialRoot(subject common.Address, root [32]byte) bool {
+	if stored, ok := s.roots[subject]; !ok || stored != root {
+		return false
+	}
+	r, err := encode.EncodeByteArray(s.digests[subject])
+	return err == nil && r == root
+}
+
+// verifyCredentialTree checks the credentials of a subject as the
+// verifyCredentialTree method of the contract. Inner nodes also check that
+// the witnesses are leaves whose roots aggregate all their credentials and
+// that the evidence roots match the roots of the witnesses, but not the
+// credentials issued by the leaves.
+func (c *Chain) verifyCredentialTree(s *state, subject common.Address) (bool, error) {
+	digests := s.digests[subject]
+	if len(digests) == 0 {
+		return false, node.ErrCredentialNotFound
+	}
+	if ok, err := s.verifyIssuedCredentials(subject); !ok || err != nil {
+		return false, err
+	}
+	if r, ok := s.roots[subject]; ok && !s.verifyCredentialRoot(subject, r) {
+		return false, nil
+	}
+	if s.isLeaf() {
+		return true, nil
+	}
+	for _, d := range digests {
+		proof := s.records[d].proof
+		for _, w := range proof.Witnesses {
+			ws := c.nodes[w]
+			if !ws.isLeaf() {
+				return false, nil
+			}
+			if !ws.verifyCredentialRoot(subject, ws.root(subject)) {
+				return false, nil
+			}
+		}
+		if c.evidenceRoot(subject, proof.Witnesses) != proof.EvidenceRoot {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// verify runs a verification of the contract on the node state. Where the
+// contract reverts, the reason is wrapped in a VerificationError, as is
+// ErrVerificationFailed where it returns false.
+func (n *Node) verify(ctx context.Context, opts *ctree.CallOpts, method string, subject common.Address, digest [32]byte, f func(s *state) (bool, error)) error {
+	var (
+		ok  bool
+		err error
+	)
+	if rerr := n.read(ctx, opts, method, subject, digest, func(s *state) error {
+		ok, err = f(s)
+		return nil
+	}); rerr != nil {
+		return rerr
+	}
+	if err == nil && !ok {
+		err = node.ErrVerificationFailed
+	}
+	if err != nil {
+		return &ctree.VerificationError{Contract: n.address, Subject: subject, Digest: digest, Err: err}
+	}
+	return nil
+}
+
+// verifyOffchain runs an off-chain verification on the state of the
+// nodes, returning the VerificationError of the first failure as node.Node.
+func (n *Node) verifyOffchain(ctx context.Context, opts *ctree.CallOpts, method string, subject common.Address, digest [32]byte, f func() error) error {
+	var verr error
+	if err := n.read(ctx, opts, method, subject, digest, func(*state) error {
+		verr = f()
+		return nil
+	}); err != nil {
+		return err
+	}
+	return verr
+}
+
+// VerifyCredential checks whether the credential is valid
+func (n *Node) VerifyCredential(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address, digest [32]byte) error {
+	if !onchain {
+		return n.verifyOffchain(ctx, opts, "getCredentialProof", subject, digest, func() error {
+			return n.chain.checkCredential(n.address, subject, digest)
+		})
+	}
+	return n.verify(ctx, opts, "verifyCredential", subject, digest, func(s *state) (bool, error) {
+		return s.verifyCredential(subject, digest)
+	})
+}
+
+// VerifyIssuedCredentials checks whether all credentials of a given subject are valid
+func (n *Node) VerifyIssuedCredentials(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address) error {
+	if !onchain {
+		return n.verifyOffchain(ctx, opts, "getDigests", subject, [32]byte{}, func() error {
+			return n.chain.checkIssuedCredentials(n.address, subject)
+		})
+	}
+	return n.verify(ctx, opts, "verifyIssuedCredentials", subject, [32]byte{}, func(s *state) (bool, error) {
+		return s.verifyIssuedCredentials(subject)
+	})
+}
+
+// VerifyCredentialRoot checks whether the root is the aggregated root of
+// the subject and aggregates all the credentials of the subject.
+func (n *Node) VerifyCredentialRoot(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address, root [32]byte) error {
+	if !onchain {
+		return n.verifyOffchain(ctx, opts, "getDigests", subject, [32]byte{}, func() error {
+			return n.chain.checkCredentialRoot(n.address, subject, root)
+		})
+	}
+	return n.verify(ctx, opts, "verifyCredentialRoot", subject, [32]byte{}, func(s *state) (bool, error) {
+		return s.verifyCredentialRoot(subject, root), nil
+	})
+}
+
+// VerifyCredentialTree checks the credentials of a subject and, in inner
+// nodes, the leaves of the credential tree and the evidence roots. Off-chain,
+// the whole credential tree is verified as by node.Node.
+func (n *Node) VerifyCredentialTree(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address) error {
+	if !onchain {
+		return n.VerifyCredentialTreeContext(ctx, opts, subject, node.DefaultTreeWorkers)
+	}
+	return n.verify(ctx, opts, "verifyCredentialTree", subject, [32]byte{}, func(s *state) (bool, error) {
+		return n.chain.verifyCredentialTree(s, subject)
+	})
+}
+
+// VerifyCredentialTreeContext performs an off-chain verification of the
+// credential tree of a subject, returning the same error as node.Node. The
+// nodes are in memory and verified in a single pass, so workers is not used.
+func (n *Node) VerifyCredentialTreeContext(ctx context.Context, opts *ctree.CallOpts, subject common.Address, workers int) error {
+	return n.verifyOffchain(ctx, opts, "getDigests", subject, [32]byte{}, func() error {
+		tv := &treeVerifier{chain: n.chain, root: n.address, subject: subject, visited: make(map[common.Address]*nodeResult)}
+		return tv.walk(n.address, map[common.Address]bool{n.address: true})
+	})
+}
+
+// VerifyCredentialTreeReport reports the state and verification failures of
+// every credential in the credential tree of a subject, with the same checks
+// as the report of the node contracts.
+func (n *Node) VerifyCredentialTreeReport(ctx context.Context, opts *ctree.CallOpts, subject common.Address, workers int) (r *ctree.VerificationReport, err error) {
+	err = n.read(ctx, opts, "verifyCredentialTree", subject, [32]byte{}, func(s *state) error {
+		rb := &reportBuilder{chain: n.chain, subject: subject, reports: make(map[common.Address]*ctree.VerificationReport)}
+		r = rb.reportNode(n.address, map[common.Address]bool{n.address: true})
+		return nil
+	})
+	return r, err
+}
+
+// reportBuilder reports the nodes of a credential tree once, linking the
+// reports of the witnesses in a depth-first traversal.
+type reportBuilder struct {
+	chain   *Chain
+	subject common.Address
+	reports map[common.Address]*ctree.VerificationReport
+}
+
+func (rb *reportBuilder) reportNode(addr common.Address, path map[common.Address]bool) *ctree.VerificationReport {
+	if r, ok := rb.reports[addr]; ok {
+		return r
+	}
+	s := rb.chain.nodes[addr]
+	digests := s.digests[rb.subject]
+	r := &ctree.VerificationReport{
+		Contract: addr,
+		Subject:  rb.subject,
+		Leaf:     s.isLeaf(),
+		Owners:   append([]common.Address{}, s.owners...),
+		Quorum:   s.quorum,
+	}
+	rb.reports[addr] = r
+	if len(digests) == 0 {
+		r.Errors = append(r.Errors, node.ErrNoCredentials)
+	}
+
+	for _, d := range digests {
+		c := rb.reportCredential(s, d)
+		r.Credentials = append(r.Credentials, c)
+		seen := make(map[common.Address]bool)
+		for _, w := range s.records[d].proof.Witnesses {
+			if seen[w] {
+				c.Errors = append(c.Errors, node.ErrRepeatedWitness)
+				continue
+			}
+			seen[w] = true
+			if path[w] {
+				c.Witnesses = append(c.Witnesses, &ctree.VerificationReport{
+					Contract: w,
+					Subject:  rb.subject,
+					Errors:   ctree.Errors{node.ErrCyclicTree},
+				})
+				continue
+			}
+			path[w] = true
+			c.Witnesses = append(c.Witnesses, rb.reportNode(w, path))
+			delete(path, w)
+		}
+	}
+
+	root, ok := s.roots[rb.subject]
+	if !ok {
+		if s.isLeaf() {
+			r.Errors = append(r.Errors, node.ErrRootNotFound)
+		}
+		return r
+	}
+	r.Root = root
+	if len(digests) > 0 {
+		digestsRoot, _ := encode.EncodeByteArray(digests)
+		r.RootMatch = digestsRoot == root
+	}
+	if !r.RootMatch {
+		r.Errors = append(r.Errors, node.ErrWrongRoot)
+	}
+	return r
+}
+
+func (rb *reportBuilder) reportCredential(s *state, digest [32]byte) *ctree.CredentialReport {
+	cp := s.records[digest].proof
+	c := &ctree.CredentialReport{
+		Digest:         digest,
+		Registrar:      cp.Registrar,
+		Subject:        cp.Subject,
+		InsertedBlock:  cp.InsertedBlock,
+		BlockTimestamp: cp.BlockTimestamp,
+		Approved:       cp.Approved,
+		EvidenceRoot:   cp.EvidenceRoot,
+	}
+	if cp.Subject != rb.subject {
+		c.Errors = append(c.Errors, node.ErrWrongSubject)
+	}
+	if !cp.Approved {
+		c.Errors = append(c.Errors, node.ErrCredentialNotApproved)
+	}
+	for _, o := range s.owners {
+		c.Signers = append(c.Signers, ctree.SignerStatus{Owner: o, Signed: s.records[digest].signers[o]})
+	}
+	c.QuorumSigned = s.isQuorumSigned(digest)
+	if !c.QuorumSigned {
+		c.Errors = append(c.Errors, node.ErrNotQuorumSigned)
+	}
+	if rp, ok := s.revoked[digest]; ok {
+		c.Revoked = true
+		c.Revocation = &ctree.RevocationReport{
+			Revoker:      rp.Registrar,
+			RevokedBlock: rp.RevokedBlock,
+			Reason:       rp.Reason,
+			ReasonName:   notary.Reason(rp.Reason).String(),
+		}
+		c.Errors = append(c.Errors, node.ErrCredentialRevoked)
+	}
+	if rb.chain.evidenceRoot(cp.Subject, cp.Witnesses) != cp.EvidenceRoot {
+		c.Errors = append(c.Errors, node.ErrWrongEvidenceRoot)
+	}
+	return c
+}
@@ -0,0 +1,252 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/schemes"
+)
+
+var ErrIssuerNotFound = errors.New("document does not name an issuer contract")
+
+func entityIDs(entities []*schemes.Entity) string {
+	ids := make([]string, len(entities))
+	for i, e := range entities {
+		ids[i] = e.GetId()
+	}
+	return strings.Join(ids, ",")
+}
+
+func formatTime(ts *big.Int) string {
+	return time.Unix(ts.Int64(), 0).UTC().Format(time.RFC3339)
+}
+
+// VerifyDocument cross-checks a credential document with the credential
+// proof of its digest issued by the node, reporting the verification of
+// the proof and the fields of the document that do not match it.
+func (n *Node) VerifyDocument(ctx context.Context, opts *ctree.CallOpts, doc schemes.Credential) (*ctree.DocumentReport, error) {
+	return n.VerifyDocumentWith(ctx, opts, doc, schemes.DefaultHashAlgorithm)
+}
+
+// VerifyDocumentWith is VerifyDocument for a credential proof of the
+// digest computed with the hash algorithm.
+func (n *Node) VerifyDocumentWith(ctx context.Context, opts *ctree.CallOpts, doc schemes.Credential, alg schemes.HashAlgorithm) (*ctree.DocumentReport, error) {
+	digest, err := alg.Hash(doc)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := schemes.Address(schemes.Subject(doc).GetId())
+	if err != nil {
+		return nil, fmt.Errorf("student %q: %w", schemes.Subject(doc).GetId(), err)
+	}
+	owners, err := n.GetOwnersContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	rb := &reportBuilder{ctx: ctx, opts: opts, subject: subject}
+	c, _, err := rb.reportCredential(n, owners, digest)
+	if err != nil {
+		return nil, err
+	}
+	r := &ctree.DocumentReport{Contract: n.Address(), Credential: c}
+	if c.InsertedBlock == nil || c.InsertedBlock.Sign() == 0 {
+		c.Errors = ctree.Errors{ErrCredentialNotFound}
+		return r, nil
+	}
+	if doc.GetValidFrom() != nil || doc.GetValidUntil() != nil {
+		r.Validity, err = ValidityAt(ctx, opts, n.backend, doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mismatch := func(field, document, chain string) {
+		r.Mismatches = append(r.Mismatches, ctree.FieldMismatch{Field: field, Document: document, Chain: chain})
+	}
+	offeredBy := false
+	for _, e := range doc.GetOfferedBy() {
+		if addr, err := schemes.Address(e.GetId()); err == nil && addr == n.Address() {
+			offeredBy = true
+		}
+	}
+	if !offeredBy {
+		mismatch("offered_by", entityIDs(doc.GetOfferedBy()), n.Address().Hex())
+	}
+	if subject != c.Subject {
+		mismatch("student", schemes.Subject(doc).GetId(), c.Subject.Hex())
+	}
+	if registrar, err := schemes.Address(doc.GetCreatedBy()); err != nil || registrar != c.Registrar {
+		mismatch("created_by", doc.GetCreatedBy(), c.Registrar.Hex())
+	}
+	if createdAt := doc.GetCreatedAt(); createdAt != nil && c.BlockTimestamp != nil && createdAt.GetSeconds() > c.BlockTimestamp.Int64() {
+		mismatch("created_at", createdAt.AsTime().UTC().Format(time.RFC3339), formatTime(c.BlockTimestamp))
+	}
+
+	signed := make(map[common.Address]bool, len(c.Signers))
+	for _, s := range c.Signers {
+		signed[s.Owner] = s.Signed
+	}
+	evaluators := make(map[common.Address]bool)
+	for i, e := range schemes.Evaluators(doc) {
+		field := fmt.Sprintf("evaluators[%d]", i)
+		addr, err := schemes.Address(e.GetId())
+		if err != nil {
+			mismatch(field, e.GetId(), "not an address")
+			continue
+		}
+		evaluators[addr] = true
+		s, owner := signed[addr]
+		switch {
+		case !owner:
+			mismatch(field, e.GetId(), "not an owner")
+		case !s:
+			mismatch(field, e.GetId(), "not signed")
+		}
+	}
+	for _, s := range c.Signers {
+		if s.Signed && !evaluators[s.Owner] {
+			mismatch("evaluators", "", "signed by "+s.Owner.Hex())
+		}
+	}
+	return r, nil
+}
+
+// VerifyDisclosure checks that the claims disclosed by a presentation
+// belong to its digest, and cross-checks the digest with the credential
+// proof issued by the node. The disclosed student, if any, must be the
+// subject of the proof, and the disclosed validity period, if any, is
+// checked at the timestamp of the block.
+func (n *Node) VerifyDisclosure(ctx context.Context, opts *ctree.CallOpts, p *schemes.Presentation) (*ctree.DocumentReport, error) {
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	from, until, err := p.Validity()
+	if err != nil {
+		return nil, err
+	}
+	cp, err := n.GetCredentialProof(ctx, opts, p.Digest)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := n.GetOwnersContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	subject, disclosed := p.Subject()
+	if !disclosed {
+		subject = cp.Subject
+	}
+	rb := &reportBuilder{ctx: ctx, opts: opts, subject: subject}
+	c, _, err := rb.reportCredential(n, owners, p.Digest)
+	if err != nil {
+		return nil, err
+	}
+	r := &ctree.DocumentReport{Contract: n.Address(), Credential: c}
+	if c.InsertedBlock == nil || c.InsertedBlock.Sign() == 0 {
+		c.Errors = ctree.Errors{ErrCredentialNotFound}
+		return r, nil
+	}
+	if from != nil || until != nil {
+		r.Validity, err = validityAt(ctx, opts, n.backend, from, until)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ValidityAt checks the validity period of a credential document at the
+// timestamp of the block queried by opts, the latest block by default.
+func ValidityAt(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, doc schemes.Credential) (*ctree.ValidityReport, error) {
+	return validityAt(ctx, opts, backend, doc.GetValidFrom(), doc.GetValidUntil())
+}
+
+func validityAt(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, from, until *timestamppb.Timestamp) (*ctree.ValidityReport, error) {
+	var number *big.Int
+	if opts != nil {
+		number = opts.BlockNumber
+	}
+	header, err := backend.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	v := &ctree.ValidityReport{
+		BlockNumber:    header.Number,
+		BlockTimestamp: new(big.Int).SetUint64(header.Time),
+	}
+	if from != nil {
+		t := from.AsTime().UTC()
+		v.ValidFrom = &t
+	}
+	if until != nil {
+		t := until.AsTime().UTC()
+		v.ValidUntil = &t
+	}
+	v.Err = schemes.CheckValidityPeriod(from, until, time.Unix(int64(header.Time), 0))
+	return v, nil
+}
+
+// VerifyDocument locates the contract that issued a credential document
+// from its offered_by entities and cross-checks the document with it.
+func VerifyDocument(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, doc schemes.Credential) (*ctree.DocumentReport, error) {
+	return VerifyDocumentWith(ctx, opts, backend, doc, schemes.DefaultHashAlgorithm)
+}
+
+// VerifyDocumentWith is VerifyDocument for a credential proof of the
+// digest computed with the hash algorithm. Every offered_by entity that is
+// a node contract is tried in order, and the report of the first one that
+// issued the credential proof is returned. If none did, the report of the
+// first node contract is returned, and ErrIssuerNotFound if there is none.
+func VerifyDocumentWith(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, doc schemes.Credential, alg schemes.HashAlgorithm) (*ctree.DocumentReport, error) {
+	var first *ctree.DocumentReport
+	for _, e := range doc.GetOfferedBy() {
+		addr, err := schemes.Address(e.GetId())
+		if err != nil {
+			continue
+		}
+		n, err := NewNode(addr, backend)
+		if err != nil {
+			return nil, err
+		}
+		r, err := n.VerifyDocumentWith(ctx, opts, doc, alg)
+		if err != nil {
+			if callFailed(err) {
+				// not a node contract
+				continue
+			}
+			return nil, err
+		}
+		if c := r.Credential; c.InsertedBlock != nil && c.InsertedBlock.Sign() > 0 {
+			return r, nil
+		}
+		if first == nil {
+			first = r
+		}
+	}
+	if first == nil {
+		return nil, ErrIssuerNotFound
+	}
+	return first, nil
+}
+
+// VerifyDisclosure cross-checks a presentation with the contract that
+// issued the credential.
+func VerifyDisclosure(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, p *schemes.Presentation) (*ctree.DocumentReport, error) {
+	if !common.IsHexAddress(p.Contract) {
+		return nil, ErrIssuerNotFound
+	}
+	n, err := NewNode(common.HexToAddress(p.Contract), backend)
+	if err != nil {
+		return nil, err
+	}
+	return n.VerifyDisclosure(ctx, opts, p)
+}
@@ -0,0 +1,532 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/notary"
+
+	coursebindings "github.com/relab/go-credbindings/course"
+	bindings "github.com/relab/go-credbindings/node"
+)
+
+// EventKind is the kind of a decoded event of a node contract. Some kinds
+// are not events of the contracts but are inferred from them, as noted.
+type EventKind string
+
+const (
+	// EventRegistered is a CredentialIssued event of a credential
+	// without witnesses.
+	EventRegistered EventKind = "registered"
+	// EventSigned is a CredentialSigned event signed by an owner.
+	EventSigned EventKind = "signed"
+	// EventApproved is a CredentialSigned event signed by the subject
+	// of the credential, i.e. its approval.
+	EventApproved EventKind = "approved"
+	// EventRevoked is a CredentialRevoked event.
+	EventRevoked EventKind = "revoked"
+	// EventAggregated is a CredentialIssued event of a credential with
+	// witnesses, read at the block of the event.
+	EventAggregated      EventKind = "aggregated"
+	EventChildAdded      EventKind = "childAdded"
+	EventStudentEnrolled EventKind = "studentEnrolled"
+	EventOwnerChanged    EventKind = "ownerChanged"
+)
+
+// Event is a decoded event of a node contract.
+type Event interface {
+	Meta() *EventMeta
+}
+
+// EventMeta locates an event in the chain.
+type EventMeta struct {
+	Kind        EventKind      `json:"event"`
+	Contract    common.Address `json:"contract"`
+	BlockNumber uint64         `json:"blockNumber"`
+	TxHash      common.Hash    `json:"txHash"`
+	LogIndex    uint           `json:"logIndex"`
+	// Removed is set when the event was reverted by a chain reorganisation.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// Meta returns the location of the event.
+func (m *EventMeta) Meta() *EventMeta { return m }
+
+// CredentialRegistered is the registration of a credential proof.
+type CredentialRegistered struct {
+	EventMeta
+	Digest    common.Hash    `json:"digest"`
+	Subject   common.Address `json:"subject"`
+	Registrar common.Address `json:"registrar"`
+}
+
+// CredentialAggregated is the registration of a credential proof by an
+// inner node, aggregating the credentials of the witnesses. The root
+// aggregation of a leaf does not emit events.
+type CredentialAggregated struct {
+	EventMeta
+	Digest    common.Hash      `json:"digest"`
+	Subject   common.Address   `json:"subject"`
+	Registrar common.Address   `json:"registrar"`
+	Witnesses []common.Address `json:"witnesses"`
+}
+
+// CredentialSigned is the signature of a credential proof by an owner.
+type CredentialSigned struct {
+	EventMeta
+	Digest  common.Hash    `json:"digest"`
+	Subject common.Address `json:"subject"`
+	Signer  common.Address `json:"signer"`
+}
+
+// CredentialApproved is the approval of a credential proof by its subject.
+type CredentialApproved struct {
+	EventMeta
+	Digest  common.Hash    `json:"digest"`
+	Subject common.Address `json:"subject"`
+}
+
+// CredentialRevoked is the revocation of a credential proof.
+type CredentialRevoked struct {
+	EventMeta
+	Digest     common.Hash    `json:"digest"`
+	Subject    common.Address `json:"subject"`
+	Revoker    common.Address `json:"revoker"`
+	Reason     common.Hash    `json:"reason"`
+	ReasonName string         `json:"reasonName"`
+}
+
+// ChildAdded is the addition of a child node to an inner node.
+type ChildAdded struct {
+	EventMeta
+	Child     common.Address `json:"child"`
+	CreatedBy common.Address `json:"createdBy"`
+	Role      uint8          `json:"role"`
+}
+
+// StudentEnrolled is the enrolment of a student in a course.
+type StudentEnrolled struct {
+	EventMeta
+	Student   common.Address `json:"student"`
+	CreatedBy common.Address `json:"createdBy"`
+}
+
+// OwnerChanged is the replacement of an owner of a node.
+type OwnerChanged struct {
+	EventMeta
+	OldOwner common.Address `json:"oldOwner"`
+	NewOwner common.Address `json:"newOwner"`
+}
+
+// EventQuery selects the events of a set of node contracts. Empty fields
+// select all the events. Events without subject or digest, such as the
+// addition of children, are not selected when filtering by them.
+type EventQuery struct {
+	Contracts []common.Address
+	Kinds     []EventKind
+	Subjects  []common.Address
+	Digests   []common.Hash
+	// FromBlock is the first block replayed by a subscription, which only
+	// receives new events if it is nil.
+	FromBlock *big.Int
+}
+
+var (
+	nodeABI   = mustParseABI(bindings.NodeABI)
+	courseABI = mustParseABI(coursebindings.CourseABI)
+	eventIDs  = map[common.Hash]string{
+		nodeABI.Events["CredentialIssued"].ID:  "CredentialIssued",
+		nodeABI.Events["CredentialSigned"].ID:  "CredentialSigned",
+		nodeABI.Events["CredentialRevoked"].ID: "CredentialRevoked",
+		nodeABI.Events["NodeAdded"].ID:         "NodeAdded",
+		nodeABI.Events["OwnerChanged"].ID:      "OwnerChanged",
+		courseABI.Events["StudentAdded"].ID:    "StudentAdded",
+	}
+)
+
+func mustParseABI(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// EventDecoder decodes the logs of node contracts, looking up the subjects
+// and witnesses of the credentials that are not in the logs.
+type EventDecoder struct {
+	backend bind.ContractBackend
+	node    *bind.BoundContract
+	course  *bind.BoundContract
+
+	mu       sync.Mutex
+	subjects map[common.Address]map[common.Hash]common.Address
+}
+
+// NewEventDecoder returns a decoder of the logs of node contracts.
+func NewEventDecoder(backend bind.ContractBackend) *EventDecoder {
+	return &EventDecoder{
+		backend:  backend,
+		node:     bind.NewBoundContract(common.Address{}, nodeABI, nil, nil, nil),
+		course:   bind.NewBoundContract(common.Address{}, courseABI, nil, nil, nil),
+		subjects: make(map[common.Address]map[common.Hash]common.Address),
+	}
+}
+
+func (d *EventDecoder) cacheSubject(contract common.Address, digest common.Hash, subject common.Address) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.subjects[contract] == nil {
+		d.subjects[contract] = make(map[common.Hash]common.Address)
+	}
+	d.subjects[contract][digest] = subject
+}
+
+func (d *EventDecoder) subject(ctx context.Context, opts *ctree.CallOpts, contract common.Address, digest common.Hash) (common.Address, error) {
+	d.mu.Lock()
+	subject, ok := d.subjects[contract][digest]
+	d.mu.Unlock()
+	if ok {
+		return subject, nil
+	}
+	n, err := NewNode(contract, d.backend)
+	if err != nil {
+		return common.Address{}, err
+	}
+	proof, err := n.GetCredentialProof(ctx, opts, digest)
+	if err != nil {
+		return common.Address{}, err
+	}
+	d.cacheSubject(contract, digest, proof.Subject)
+	return proof.Subject, nil
+}
+
+// Decode decodes a log of a node contract. Logs of other events are
+// ignored, returning a nil event.
+func (d *EventDecoder) Decode(ctx context.Context, log types.Log) (Event, error) {
+	if len(log.Topics) == 0 {
+		return nil, nil
+	}
+	name, ok := eventIDs[log.Topics[0]]
+	if !ok {
+		return nil, nil
+	}
+	meta := EventMeta{
+		Contract:    log.Address,
+		BlockNumber: log.BlockNumber,
+		TxHash:      log.TxHash,
+		LogIndex:    log.Index,
+		Removed:     log.Removed,
+	}
+	// the state read to decode the event is the one of its block
+	opts := &ctree.CallOpts{BlockNumber: new(big.Int).SetUint64(log.BlockNumber)}
+	switch name {
+	case "CredentialIssued":
+		ev := new(bindings.NodeCredentialIssued)
+		if err := d.node.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		d.cacheSubject(log.Address, ev.Digest, ev.Subject)
+		n, err := NewNode(log.Address, d.backend)
+		if err != nil {
+			return nil, err
+		}
+		witnesses, err := n.GetWitnesses(ctx, opts, ev.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if len(witnesses) > 0 {
+			meta.Kind = EventAggregated
+			return &CredentialAggregated{meta, ev.Digest, ev.Subject, ev.Registrar, witnesses}, nil
+		}
+		meta.Kind = EventRegistered
+		return &CredentialRegistered{meta, ev.Digest, ev.Subject, ev.Registrar}, nil
+	case "CredentialSigned":
+		ev := new(bindings.NodeCredentialSigned)
+		if err := d.node.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		subject, err := d.subject(ctx, opts, log.Address, ev.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if ev.Signer == subject {
+			meta.Kind = EventApproved
+			return &CredentialApproved{meta, ev.Digest, subject}, nil
+		}
+		meta.Kind = EventSigned
+		return &CredentialSigned{meta, ev.Digest, subject, ev.Signer}, nil
+	case "CredentialRevoked":
+		ev := new(bindings.NodeCredentialRevoked)
+		if err := d.node.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		meta.Kind = EventRevoked
+		return &CredentialRevoked{meta, ev.Digest, ev.Subject, ev.Revoker, ev.Reason, notary.Reason(ev.Reason).String()}, nil
+	case "NodeAdded":
+		ev := new(bindings.NodeNodeAdded)
+		if err := d.node.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		meta.Kind = EventChildAdded
+		return &ChildAdded{meta, ev.NodeAddress, ev.CreatedBy, ev.Role}, nil
+	case "OwnerChanged":
+		ev := new(bindings.NodeOwnerChanged)
+		if err := d.node.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		meta.Kind = EventOwnerChanged
+		return &OwnerChanged{meta, ev.OldOwner, ev.NewOwner}, nil
+	case "StudentAdded":
+		ev := new(coursebindings.CourseStudentAdded)
+		if err := d.course.UnpackLog(ev, name, log); err != nil {
+			return nil, err
+		}
+		meta.Kind = EventStudentEnrolled
+		return &StudentEnrolled{meta, ev.Student, ev.CreatedBy}, nil
+	}
+	return nil, nil
+}
+
+// eventSubject returns the subject and digest of an event, if any.
+func eventSubject(e Event) (subject *common.Address, digest *common.Hash) {
+	switch e := e.(type) {
+	case *CredentialRegistered:
+		return &e.Subject, &e.Digest
+	case *CredentialAggregated:
+		return &e.Subject, &e.Digest
+	case *CredentialSigned:
+		return &e.Subject, &e.Digest
+	case *CredentialApproved:
+		return &e.Subject, &e.Digest
+	case *CredentialRevoked:
+		return &e.Subject, &e.Digest
+	case *StudentEnrolled:
+		return &e.Student, nil
+	}
+	return nil, nil
+}
+
+// Match reports whether the query selects the event.
+func (q *EventQuery) Match(e Event) bool {
+	if len(q.Kinds) > 0 && !containsKind(q.Kinds, e.Meta().Kind) {
+		return false
+	}
+	subject, digest := eventSubject(e)
+	if len(q.Subjects) > 0 && (subject == nil || !containsAddress(q.Subjects, *subject)) {
+		return false
+	}
+	if len(q.Digests) > 0 && (digest == nil || !containsHash(q.Digests, *digest)) {
+		return false
+	}
+	return true
+}
+
+func containsKind(kinds []EventKind, k EventKind) bool {
+	for _, kind := range kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddress(addrs []common.Address, a common.Address) bool {
+	for _, addr := range addrs {
+		if addr == a {
+			return true
+		}
+	}
+	return false
+}
+
+func containsHash(hashes []common.Hash, h common.Hash) bool {
+	for _, hash := range hashes {
+		if hash == h {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEventKind returns the kind of events with the given name.
+func ParseEventKind(s string) (EventKind, error) {
+	for _, k := range []EventKind{EventRegistered, EventSigned, EventApproved, EventRevoked,
+		EventAggregated, EventChildAdded, EventStudentEnrolled, EventOwnerChanged} {
+		if strings.EqualFold(s, string(k)) {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event %q", s)
+}
+
+func (q *EventQuery) filter(from, to *big.Int) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		FromBlock: from,
+		ToBlock:   to,
+		Addresses: q.Contracts,
+	}
+}
+
+func (q *EventQuery) decode(ctx context.Context, d *EventDecoder, logs []types.Log) ([]Event, error) {
+	var events []Event
+	for _, log := range logs {
+		e, err := d.Decode(ctx, log)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil && q.Match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// ReplayEvents returns the events selected by the query from its first
+// block, or the genesis, up to the given block, or the latest if nil.
+func ReplayEvents(ctx context.Context, backend bind.ContractBackend, q EventQuery, to *big.Int) ([]Event, error) {
+	from := q.FromBlock
+	if from == nil {
+		from = new(big.Int)
+	}
+	logs, err := backend.FilterLogs(ctx, q.filter(from, to))
+	if err != nil {
+		return nil, err
+	}
+	return q.decode(ctx, NewEventDecoder(backend), logs)
+}
+
+// ResubscribeBackoff bounds the wait between the attempts to resubscribe to
+// the events after the subscription fails, e.g. when a websocket drops.
+var ResubscribeBackoff = 10 * time.Second
+
+// SubscribeEvents delivers the events selected by the query to the sink,
+// in chain order. Events since the first block of the query are replayed
+// before the new events. When the subscription fails, it resubscribes
+// replaying the events missed in the meantime, so that each event is
+// delivered once. Events that cannot be decoded, e.g. when the lookup of
+// their subject fails, are retried in the same way.
+func SubscribeEvents(ctx context.Context, backend bind.ContractBackend, q EventQuery, sink chan<- Event) (event.Subscription, error) {
+	s := &eventStream{
+		backend: backend,
+		decoder: NewEventDecoder(backend),
+		query:   q,
+		sink:    sink,
+	}
+	if q.FromBlock != nil {
+		s.next = q.FromBlock.Uint64()
+	} else {
+		head, err := backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.next = head.Number.Uint64() + 1
+	}
+	return event.ResubscribeErr(ResubscribeBackoff, s.subscribe), nil
+}
+
+// SubscribeEvents delivers the events of the node selected by the query.
+func (n *Node) SubscribeEvents(ctx context.Context, q EventQuery, sink chan<- Event) (event.Subscription, error) {
+	q.Contracts = []common.Address{n.address}
+	return SubscribeEvents(ctx, n.backend, q, sink)
+}
+
+// ReplayEvents returns the events of the node selected by the query.
+func (n *Node) ReplayEvents(ctx context.Context, q EventQuery, to *big.Int) ([]Event, error) {
+	q.Contracts = []common.Address{n.address}
+	return ReplayEvents(ctx, n.backend, q, to)
+}
+
+// eventStream is a subscription to the events of node contracts. It keeps
+// the position of the next event to deliver across resubscriptions.
+type eventStream struct {
+	backend bind.ContractBackend
+	decoder *EventDecoder
+	query   EventQuery
+	sink    chan<- Event
+
+	// next is the position of the next event, at block next and log
+	// index index.
+	next  uint64
+	index uint
+}
+
+func (s *eventStream) subscribe(ctx context.Context, lastErr error) (event.Subscription, error) {
+	// subscribe before replaying, to not miss the events in between
+	logs := make(chan types.Log, 128)
+	sub, err := s.backend.SubscribeFilterLogs(ctx, s.query.filter(nil, nil), logs)
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	var replayed []types.Log
+	if head.Number.Uint64() >= s.next {
+		replayed, err = s.backend.FilterLogs(ctx, s.query.filter(new(big.Int).SetUint64(s.next), head.Number))
+		if err != nil {
+			sub.Unsubscribe()
+			return nil, err
+		}
+	}
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for _, log := range replayed {
+			if err := s.deliver(ctx, log, quit); err != nil {
+				return err
+			}
+		}
+		for {
+			select {
+			case log := <-logs:
+				if err := s.deliver(ctx, log, quit); err != nil {
+					return err
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// deliver sends the event of the log to the sink, skipping the events
+// already delivered.
+func (s *eventStream) deliver(ctx context.Context, log types.Log, quit <-chan struct{}) error {
+	if !log.Removed {
+		if log.BlockNumber < s.next || (log.BlockNumber == s.next && log.Index < s.index) {
+			return nil
+		}
+	}
+	e, err := s.decoder.Decode(ctx, log)
+	if err != nil {
+		return err
+	}
+	if !log.Removed {
+		s.next, s.index = log.BlockNumber, log.Index+1
+	}
+	if e == nil || !s.query.Match(e) {
+		return nil
+	}
+	select {
+	case s.sink <- e:
+	case <-quit:
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+var ErrBlockNotFound = errors.New("no block mined at the given time")
+
+// issuedAt reports whether a credential proof inserted at the given block
+// exists in the state queried by opts. A proof that was never inserted has
+// no inserted block.
+func issuedAt(insertedBlock *big.Int, opts *ctree.CallOpts) bool {
+	if insertedBlock == nil || insertedBlock.Sign() == 0 {
+		return false
+	}
+	return opts == nil || opts.BlockNumber == nil || insertedBlock.Cmp(opts.BlockNumber) <= 0
+}
+
+// BlockAt returns the number of the last block mined at or before
+// the given unix timestamp.
+func BlockAt(ctx context.Context, backend bind.ContractBackend, timestamp uint64) (*big.Int, error) {
+	latest, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if latest.Time <= timestamp {
+		return latest.Number, nil
+	}
+	genesis, err := backend.HeaderByNumber(ctx, common.Big0)
+	if err != nil {
+		return nil, err
+	}
+	if genesis.Time > timestamp {
+		return nil, ErrBlockNotFound
+	}
+	// lo is always mined at or before the timestamp and hi after it
+	lo, hi := uint64(0), latest.Number.Uint64()
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		h, err := backend.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return nil, err
+		}
+		if h.Time <= timestamp {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return new(big.Int).SetUint64(lo), nil
+}
+
+// VerifyCredentialAt checks whether the credential was valid at the given
+// block, i.e. it was issued to the subject, signed by a quorum, approved and
+// not revoked yet. The contract state is queried at that block, so the
+// backend must be able to serve historical state (e.g. an archive node).
+// A credential revoked after the block is still valid at the block.
+func (n *Node) VerifyCredentialAt(ctx context.Context, subject common.Address, digest [32]byte, block *big.Int) error {
+	return n.VerifyCredential(ctx, false, &ctree.CallOpts{BlockNumber: block}, subject, digest)
+}
+
+// VerifyCredentialAtTime checks whether the credential was valid at the
+// last block mined at or before the given unix timestamp.
+func (n *Node) VerifyCredentialAtTime(ctx context.Context, subject common.Address, digest [32]byte, timestamp uint64) error {
+	block, err := BlockAt(ctx, n.backend, timestamp)
+	if err != nil {
+		return err
+	}
+	return n.VerifyCredentialAt(ctx, subject, digest, block)
+}
@@ -0,0 +1,196 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+type impactKey struct {
+	contract common.Address
+	digest   [32]byte
+}
+
+// impactFinder walks the credential tree upward from a credential,
+// looking for credentials that use its node as witness.
+type impactFinder struct {
+	ctx     context.Context
+	backend bind.ContractBackend
+	opts    *ctree.CallOpts
+	known   []common.Address
+	visited map[impactKey]bool
+}
+
+// parents returns the nodes that may use the node as witness: its parent,
+// when it is a contract rather than the account that deployed the node,
+// and the known nodes.
+func (f *impactFinder) parents(n *Node) ([]common.Address, error) {
+	parent, err := n.contract.MyParent(f.opts.Bind(f.ctx))
+	if err != nil {
+		return nil, n.callError("myParent", common.Address{}, [32]byte{}, err)
+	}
+	candidates := f.known
+	if parent != (common.Address{}) {
+		var block *big.Int
+		if f.opts != nil {
+			block = f.opts.BlockNumber
+		}
+		code, err := f.backend.CodeAt(f.ctx, parent, block)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) > 0 {
+			candidates = append([]common.Address{parent}, candidates...)
+		}
+	}
+	seen := map[common.Address]bool{n.Address(): true}
+	var parents []common.Address
+	for _, p := range candidates {
+		if p != (common.Address{}) && !seen[p] {
+			seen[p] = true
+			parents = append(parents, p)
+		}
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return bytes.Compare(parents[i][:], parents[j][:]) < 0
+	})
+	return parents, nil
+}
+
+// includes reports whether the evidence root of the credential proof
+// includes the credential issued by the witness: whether the root of the
+// witness used for the evidence root, read at the block in which the proof
+// was inserted, aggregates the digest. Roots that do not aggregate a prefix
+// of the digests of the subject cannot be told apart from the ones that
+// include the digest, and are reported as including it.
+func (f *impactFinder) includes(cp *notary.NotaryCredentialProof, witness common.Address, digest [32]byte) (bool, error) {
+	opts := &ctree.CallOpts{BlockNumber: cp.InsertedBlock}
+	evidence, err := EvidenceRoot(f.ctx, opts, f.backend, cp.Subject, cp.Witnesses)
+	if err != nil {
+		return false, err
+	}
+	if evidence != cp.EvidenceRoot {
+		// the roots used for the evidence root are unknown
+		return true, nil
+	}
+	n, err := NewNode(witness, f.backend)
+	if err != nil {
+		return false, err
+	}
+	root, err := n.GetRoot(f.ctx, opts, cp.Subject)
+	if err != nil {
+		return false, err
+	}
+	if root == ([32]byte{}) {
+		return false, nil
+	}
+	digests, err := n.GetDigests(f.ctx, opts, cp.Subject)
+	if err != nil {
+		return false, err
+	}
+	included := false
+	for i, d := range digests {
+		included = included || d == digest
+		r, err := encode.EncodeByteArray(digests[:i+1])
+		if err != nil {
+			return false, err
+		}
+		if r == root {
+			return included, nil
+		}
+	}
+	return true, nil
+}
+
+func (f *impactFinder) find(addr common.Address, digest [32]byte) (*ctree.ImpactReport, error) {
+	f.visited[impactKey{addr, digest}] = true
+	n, err := NewNode(addr, f.backend)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := n.GetCredentialProof(f.ctx, f.opts, digest)
+	if err != nil {
+		return nil, err
+	}
+	if cp.InsertedBlock == nil || cp.InsertedBlock.Sign() == 0 {
+		return nil, n.verificationError(common.Address{}, digest, ErrCredentialNotFound)
+	}
+	revoked, err := n.IsRevoked(f.ctx, f.opts, digest)
+	if err != nil {
+		return nil, err
+	}
+	r := &ctree.ImpactReport{
+		Contract:  addr,
+		Digest:    digest,
+		Subject:   cp.Subject,
+		Witnesses: cp.Witnesses,
+		Revoked:   revoked,
+	}
+
+	parents, err := f.parents(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range parents {
+		pn, err := NewNode(p, f.backend)
+		if err != nil {
+			return nil, err
+		}
+		digests, err := pn.GetDigests(f.ctx, f.opts, cp.Subject)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range digests {
+			if f.visited[impactKey{p, d}] {
+				continue
+			}
+			pcp, err := pn.GetCredentialProof(f.ctx, f.opts, d)
+			if err != nil {
+				return nil, err
+			}
+			for _, w := range pcp.Witnesses {
+				if w != addr {
+					continue
+				}
+				included, err := f.includes(pcp, addr, digest)
+				if err != nil {
+					return nil, err
+				}
+				if !included {
+					break
+				}
+				dep, err := f.find(p, d)
+				if err != nil {
+					return nil, err
+				}
+				r.Dependents = append(r.Dependents, dep)
+				break
+			}
+		}
+	}
+	return r, nil
+}
+
+// RevocationImpact finds the credentials whose evidence depends on the given
+// credential, walking the credential tree upward through the parent of each
+// node and the given known nodes, which may also use the nodes as witnesses.
+// A credential depends on another one when its evidence root includes a root
+// of the witness that aggregates the other credential.
+func RevocationImpact(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, contract common.Address, digest [32]byte, known []common.Address) (*ctree.ImpactReport, error) {
+	f := &impactFinder{
+		ctx:     ctx,
+		backend: backend,
+		opts:    opts,
+		known:   known,
+		visited: make(map[impactKey]bool),
+	}
+	return f.find(contract, digest)
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+// filterOpts returns the options to filter the logs up to the block queried by opts.
+func filterOpts(ctx context.Context, opts *ctree.CallOpts) *bind.FilterOpts {
+	fopts := &bind.FilterOpts{Context: ctx}
+	if opts != nil && opts.BlockNumber != nil {
+		end := opts.BlockNumber.Uint64()
+		fopts.End = &end
+	}
+	return fopts
+}
+
+// issuedDigests returns the digests of the credentials issued to the
+// subjects, or to any subject if none is given, in issuing order.
+func (n *Node) issuedDigests(ctx context.Context, opts *ctree.CallOpts, subjects []common.Address) ([][32]byte, error) {
+	it, err := n.contract.FilterCredentialIssued(filterOpts(ctx, opts), nil, subjects, nil)
+	if err != nil {
+		return nil, n.callError("CredentialIssued", common.Address{}, [32]byte{}, err)
+	}
+	defer it.Close()
+
+	var digests [][32]byte
+	seen := make(map[[32]byte]bool)
+	for it.Next() {
+		if d := it.Event.Digest; !seen[d] {
+			seen[d] = true
+			digests = append(digests, d)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, n.callError("CredentialIssued", common.Address{}, [32]byte{}, err)
+	}
+	return digests, nil
+}
+
+// PendingCredentials returns the credentials issued by the node to the
+// subjects, or to any subject if none is given, that were neither approved
+// nor revoked, in issuing order. The credentials are found in the
+// CredentialIssued logs of the node, and their state is read from the node.
+func (n *Node) PendingCredentials(ctx context.Context, opts *ctree.CallOpts, subjects ...common.Address) ([]*ctree.PendingCredential, error) {
+	digests, err := n.issuedDigests(ctx, opts, subjects)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := n.GetOwnersContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	quorum, err := n.QuorumContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*ctree.PendingCredential
+	for _, d := range digests {
+		cp, err := n.GetCredentialProof(ctx, opts, d)
+		if err != nil {
+			return nil, err
+		}
+		if cp.Approved || !issuedAt(cp.InsertedBlock, opts) {
+			continue
+		}
+		revoked, err := n.IsRevoked(ctx, opts, d)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			continue
+		}
+
+		p := &ctree.PendingCredential{
+			Contract:      n.address,
+			Digest:        d,
+			Subject:       cp.Subject,
+			Registrar:     cp.Registrar,
+			InsertedBlock: cp.InsertedBlock,
+			Quorum:        quorum,
+		}
+		if cp.Signed != nil {
+			p.Signatures = cp.Signed.Uint64()
+		}
+		for _, o := range owners {
+			signed, err := n.IsSigned(ctx, opts, d, o)
+			if err != nil {
+				return nil, err
+			}
+			p.Signers = append(p.Signers, ctree.SignerStatus{Owner: o, Signed: signed})
+		}
+		p.QuorumSigned = p.Signatures >= uint64(quorum)
+		pending = append(pending, p)
+	}
+	return pending, nil
+}
+
+// Inbox returns the pending credentials of the given nodes that involve the
+// account: all pending credentials of the nodes it owns, and the pending
+// credentials issued to it by the other nodes. Whether a credential is
+// waiting on the account is given by its IsWaitingOn method.
+func Inbox(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, account common.Address, contracts []common.Address) ([]*ctree.PendingCredential, error) {
+	var inbox []*ctree.PendingCredential
+	seen := make(map[common.Address]bool)
+	for _, c := range contracts {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		n, err := NewNode(c, backend)
+		if err != nil {
+			return nil, err
+		}
+		owner, err := n.IsOwnerContext(ctx, opts, account)
+		if err != nil {
+			return nil, err
+		}
+		var subjects []common.Address
+		if !owner {
+			subjects = []common.Address{account}
+		}
+		pending, err := n.PendingCredentials(ctx, opts, subjects...)
+		if err != nil {
+			return nil, err
+		}
+		inbox = append(inbox, pending...)
+	}
+	return inbox, nil
+}
@@ -1,9 +1,8 @@
 package node
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"math/big"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -34,6 +33,8 @@ var (
 	ErrCredentialRevoked     = errors.New("credential revoked")
 	ErrWrongRoot             = errors.New("root does not match")
 	ErrRootNotFound          = errors.New("root not found")
+	ErrInvalidInclusionProof = errors.New("invalid inclusion proof")
+	ErrWrongEvidenceRoot     = errors.New("evidence root does not match the witnesses' roots")
 )
 
 // Node is a Go wrapper around an node contract.
@@ -44,6 +45,8 @@ type Node struct {
 	backend  bind.ContractBackend
 }
 
+var _ ctree.IssuerV2 = (*Node)(nil)
+
 // NewNode creates a struct exposing convenient operations to
 // interact with the Node contract.
 func NewNode(contractAddr common.Address, backend bind.ContractBackend) (*Node, error) {
@@ -65,7 +68,7 @@ func Deploy(auth *bind.TransactOpts, backend bind.ContractBackend, deployedLibs
 	}
 	contractBin := deployer.LinkContract(bindings.NodeBin, libs)
 
-	address, tx, _, err := deployer.DeployContract(auth, backend, bindings.NodeABI, contractBin, params)
+	address, tx, _, err := deployer.DeployContract(auth, backend, bindings.NodeABI, contractBin, params...)
 	if err != nil {
 		return common.Address{}, nil, nil, err
 	}
@@ -87,13 +90,41 @@ func (n *Node) AddNode(opts *bind.TransactOpts, node common.Address) (*types.Tra
 	return n.contract.AddChild(opts, node)
 }
 
-func (n *Node) IsLeaf(opts *bind.CallOpts) (bool, error) {
-	return n.contract.IsLeaf(opts)
+// GetChildren returns the child nodes, in the order they were added
+func (n *Node) GetChildren(ctx context.Context, opts *ctree.CallOpts) ([]common.Address, error) {
+	children, err := n.contract.GetChildren(opts.Bind(ctx))
+	return children, n.callError("getChildren", common.Address{}, [32]byte{}, err)
+}
+
+// callError wraps the error of a read of the node contract.
+func (n *Node) callError(method string, subject common.Address, digest [32]byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ctree.CallError{Contract: n.address, Method: method, Subject: subject, Digest: digest, Err: err}
+}
+
+// verificationError wraps the reason of a verification failure.
+func (n *Node) verificationError(subject common.Address, digest [32]byte, err error) error {
+	return &ctree.VerificationError{Contract: n.address, Subject: subject, Digest: digest, Err: err}
+}
+
+// IsLeaf returns whether the node is a leaf of the credential tree
+func (n *Node) IsLeaf(ctx context.Context, opts *ctree.CallOpts) (bool, error) {
+	leaf, err := n.contract.IsLeaf(opts.Bind(ctx))
+	return leaf, n.callError("isLeaf", common.Address{}, [32]byte{}, err)
 }
 
 // GetRoot returns the aggregated proof of a subject
-func (n *Node) GetRoot(opts *bind.CallOpts, subject common.Address) ([32]byte, error) {
-	return n.contract.GetRoot(opts, subject)
+func (n *Node) GetRoot(ctx context.Context, opts *ctree.CallOpts, subject common.Address) ([32]byte, error) {
+	root, err := n.contract.GetRoot(opts.Bind(ctx), subject)
+	return root, n.callError("getRoot", subject, [32]byte{}, err)
+}
+
+// getProof returns the root proof of a subject
+func (n *Node) getProof(ctx context.Context, opts *ctree.CallOpts, subject common.Address) (bindings.CredentialSumRoot, error) {
+	p, err := n.contract.GetProof(opts.Bind(ctx), subject)
+	return p, n.callError("getProof", subject, [32]byte{}, err)
 }
 
 // RegisterCredential issues a new credential proof ensuring append-only property.
@@ -117,118 +148,160 @@ func (n *Node) AggregateCredentials(opts *bind.TransactOpts, subject common.Addr
 	return n.contract.AggregateCredentials(opts, subject, digests)
 }
 
-// GetCredentialProof maps document digest to issued credential proof
-func (n *Node) GetCredentialProof(opts *bind.CallOpts, digest [32]byte) *notary.NotaryCredentialProof {
-	proof, _ := n.contract.GetCredentialProof(opts, digest)
+// GetCredentialProof maps document digest to issued credential proof.
+// The proof of a digest that was not issued has no inserted block.
+func (n *Node) GetCredentialProof(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (*notary.NotaryCredentialProof, error) {
+	proof, err := n.contract.GetCredentialProof(opts.Bind(ctx), digest)
+	if err != nil {
+		return nil, n.callError("getCredentialProof", common.Address{}, digest, err)
+	}
 	cp := notary.NotaryCredentialProof(proof)
-	return &cp
+	return &cp, nil
 }
 
 // GetRevokedProof maps document digest to revoked proof
-func (n *Node) GetRevokedProof(opts *bind.CallOpts, digest [32]byte) *notary.NotaryRevocationProof {
-	proof, _ := n.contract.GetRevokedProof(opts, digest)
+func (n *Node) GetRevokedProof(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (*notary.NotaryRevocationProof, error) {
+	proof, err := n.contract.GetRevokedProof(opts.Bind(ctx), digest)
+	if err != nil {
+		return nil, n.callError("getRevokedProof", common.Address{}, digest, err)
+	}
 	rp := notary.NotaryRevocationProof(proof)
-	return &rp
+	return &rp, nil
 }
 
 // IsSigned returns whether an owner already signed a digest
-func (n *Node) IsSigned(opts *bind.CallOpts, digest [32]byte, owner common.Address) (bool, error) {
-	return n.contract.IsSigned(opts, digest, owner)
+func (n *Node) IsSigned(ctx context.Context, opts *ctree.CallOpts, digest [32]byte, owner common.Address) (bool, error) {
+	signed, err := n.contract.IsSigned(opts.Bind(ctx), digest, owner)
+	return signed, n.callError("isSigned", common.Address{}, digest, err)
 }
 
 // IsQuorumSigned verify if a credential proof was signed by a quorum
-func (n *Node) IsQuorumSigned(opts *bind.CallOpts, digest [32]byte) (bool, error) {
-	return n.contract.IsQuorumSigned(opts, digest)
+func (n *Node) IsQuorumSigned(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (bool, error) {
+	signed, err := n.contract.IsQuorumSigned(opts.Bind(ctx), digest)
+	return signed, n.callError("isQuorumSigned", common.Address{}, digest, err)
 }
 
 // GetDigests returns the list of the issued credentials' digests of a subject
-func (n *Node) GetDigests(opts *bind.CallOpts, subject common.Address) ([][32]byte, error) {
-	return n.contract.GetDigests(opts, subject)
+func (n *Node) GetDigests(ctx context.Context, opts *ctree.CallOpts, subject common.Address) ([][32]byte, error) {
+	digests, err := n.contract.GetDigests(opts.Bind(ctx), subject)
+	return digests, n.callError("getDigests", subject, [32]byte{}, err)
 }
 
 // GetWitnesses returns the witnesses of a proof
-func (n *Node) GetWitnesses(opts *bind.CallOpts, digest [32]byte) ([]common.Address, error) {
-	return n.contract.GetWitnesses(opts, digest)
+func (n *Node) GetWitnesses(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) ([]common.Address, error) {
+	witnesses, err := n.contract.GetWitnesses(opts.Bind(ctx), digest)
+	return witnesses, n.callError("getWitnesses", common.Address{}, digest, err)
 }
 
 // GetEvidenceRoot returns the root of the evidences of an issued credential proof
-func (n *Node) GetEvidenceRoot(opts *bind.CallOpts, digest [32]byte) ([32]byte, error) {
-	return n.contract.GetEvidenceRoot(opts, digest)
+func (n *Node) GetEvidenceRoot(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) ([32]byte, error) {
+	root, err := n.contract.GetEvidenceRoot(opts.Bind(ctx), digest)
+	return root, n.callError("getEvidenceRoot", common.Address{}, digest, err)
 }
 
 // GetRevoked returns a list of revoked credentials
-func (n *Node) GetRevoked(opts *bind.CallOpts, subject common.Address) ([][32]byte, error) {
-	return n.contract.GetRevoked(opts, subject)
+func (n *Node) GetRevoked(ctx context.Context, opts *ctree.CallOpts, subject common.Address) ([][32]byte, error) {
+	revoked, err := n.contract.GetRevoked(opts.Bind(ctx), subject)
+	return revoked, n.callError("getRevoked", subject, [32]byte{}, err)
 }
 
 // IsRevoked verifies if a credential proof was revoked
-func (n *Node) IsRevoked(opts *bind.CallOpts, digest [32]byte) (bool, error) {
-	return n.contract.IsRevoked(opts, digest)
+func (n *Node) IsRevoked(ctx context.Context, opts *ctree.CallOpts, digest [32]byte) (bool, error) {
+	revoked, err := n.contract.IsRevoked(opts.Bind(ctx), digest)
+	return revoked, n.callError("isRevoked", common.Address{}, digest, err)
 }
 
 // VerifyCredential checks whether the credential is valid
-func (n *Node) VerifyCredential(onchain bool, opts *bind.CallOpts, subject common.Address, digest [32]byte) error {
+func (n *Node) VerifyCredential(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address, digest [32]byte) error {
 	if onchain {
-		ok, err := n.contract.VerifyCredential(opts, subject, digest)
+		ok, err := n.contract.VerifyCredential(opts.Bind(ctx), subject, digest)
 		if err != nil {
-			return err
+			return n.callError("verifyCredential", subject, digest, err)
 		}
 		if !ok {
-			return ErrVerificationFailed
+			return n.verificationError(subject, digest, ErrVerificationFailed)
 		}
 		return nil
 	}
-	cp, err := n.contract.GetCredentialProof(opts, digest)
+	cp, err := n.GetCredentialProof(ctx, opts, digest)
 	if err != nil {
 		return err
 	}
-	if cp.InsertedBlock == big.NewInt(0) {
-		return ErrCredentialNotFound
+	if !issuedAt(cp.InsertedBlock, opts) {
+		return n.verificationError(subject, digest, ErrCredentialNotFound)
 	}
 	if cp.Subject != subject {
-		return ErrWrongSubject
+		return n.verificationError(subject, digest, ErrWrongSubject)
 	}
 	if !cp.Approved {
-		return ErrCredentialNotApproved
+		return n.verificationError(subject, digest, ErrCredentialNotApproved)
 	}
-	signed, err := n.contract.IsQuorumSigned(opts, digest)
+	signed, err := n.IsQuorumSigned(ctx, opts, digest)
 	if err != nil {
 		return err
 	}
 	if !signed {
-		return ErrNotQuorumSigned
+		return n.verificationError(subject, digest, ErrNotQuorumSigned)
 	}
-	revoked, err := n.contract.IsRevoked(opts, digest)
+	revoked, err := n.IsRevoked(ctx, opts, digest)
 	if err != nil {
 		return err
 	}
 	if revoked {
-		return ErrCredentialRevoked
+		return n.verificationError(subject, digest, ErrCredentialRevoked)
+	}
+	evidenceRoot, err := EvidenceRoot(ctx, opts, n.backend, subject, cp.Witnesses)
+	if err != nil {
+		return err
+	}
+	if evidenceRoot != cp.EvidenceRoot {
+		return n.verificationError(subject, digest, ErrWrongEvidenceRoot)
 	}
 	return nil
 }
 
+// EvidenceRoot computes the evidence root of a credential from the current
+// roots of its witnesses for the subject, as done by the contract when the
+// credential is registered. Credentials without witnesses have no evidence.
+func EvidenceRoot(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, subject common.Address, witnesses []common.Address) ([32]byte, error) {
+	if len(witnesses) == 0 {
+		return [32]byte{}, nil
+	}
+	roots := make([][32]byte, len(witnesses))
+	for i, w := range witnesses {
+		n, err := bindings.NewNodeCaller(w, backend)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		roots[i], err = n.GetRoot(opts.Bind(ctx), subject)
+		if err != nil {
+			return [32]byte{}, &ctree.CallError{Contract: w, Method: "getRoot", Subject: subject, Err: err}
+		}
+	}
+	return encode.EncodeByteArray(roots)
+}
+
 // VerifyIssuedCredentials checks whether all credentials of a given subject are valid
-func (n *Node) VerifyIssuedCredentials(onchain bool, opts *bind.CallOpts, subject common.Address) error {
+func (n *Node) VerifyIssuedCredentials(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address) error {
 	if onchain {
-		ok, err := n.contract.VerifyIssuedCredentials(opts, subject)
+		ok, err := n.contract.VerifyIssuedCredentials(opts.Bind(ctx), subject)
 		if err != nil {
-			return err
+			return n.callError("verifyIssuedCredentials", subject, [32]byte{}, err)
 		}
 		if !ok {
-			return ErrVerificationFailed
+			return n.verificationError(subject, [32]byte{}, ErrVerificationFailed)
 		}
 		return nil
 	}
-	digests, err := n.contract.GetDigests(opts, subject)
+	digests, err := n.GetDigests(ctx, opts, subject)
 	if err != nil {
 		return err
 	}
 	if len(digests) == 0 {
-		return ErrNoCredentials
+		return n.verificationError(subject, [32]byte{}, ErrNoCredentials)
 	}
 	for _, d := range digests {
-		err := n.VerifyCredential(false, opts, subject, d)
+		err := n.VerifyCredential(ctx, false, opts, subject, d)
 		if err != nil {
 			return err
 		}
@@ -236,109 +309,129 @@ func (n *Node) VerifyIssuedCredentials(onchain bool, opts *bind.CallOpts, subjec
 	return nil
 }
 
-// VerifyCredentialRoot
-func (n *Node) VerifyCredentialRoot(onchain bool, opts *bind.CallOpts, subject common.Address, root [32]byte) error {
+// VerifyCredentialRoot checks whether the root is the aggregated root of a
+// given subject and aggregates all its credentials.
+func (n *Node) VerifyCredentialRoot(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address, root [32]byte) error {
 	if onchain {
-		ok, err := n.contract.VerifyCredentialRoot(opts, subject, root)
+		ok, err := n.contract.VerifyCredentialRoot(opts.Bind(ctx), subject, root)
 		if err != nil {
-			return err
+			return n.callError("verifyCredentialRoot", subject, [32]byte{}, err)
 		}
 		if !ok {
-			return ErrVerificationFailed
+			return n.verificationError(subject, [32]byte{}, ErrVerificationFailed)
 		}
 		return nil
 	}
-	digests, err := n.contract.GetDigests(opts, subject)
+	digests, err := n.GetDigests(ctx, opts, subject)
 	if err != nil {
 		return err
 	}
 	if len(digests) == 0 {
-		return ErrNoCredentials
+		return n.verificationError(subject, [32]byte{}, ErrNoCredentials)
 	}
 
-	p, err := n.contract.GetProof(opts, subject)
+	p, err := n.getProof(ctx, opts, subject)
 	if err != nil {
 		return err
 	}
-	if bytes.Equal(p.Proof[:], []byte{}) {
-		return ErrRootNotFound
+	if p.Proof == [32]byte{} {
+		return n.verificationError(subject, [32]byte{}, ErrRootNotFound)
 	}
 	if p.Proof != root {
-		return ErrWrongRoot
+		return n.verificationError(subject, [32]byte{}, ErrWrongRoot)
 	}
 
 	r, err := encode.EncodeByteArray(digests)
 	if err != nil {
 		return err
 	}
-	if bytes.Equal(r[:], root[:]) {
+	if r == p.Proof {
 		return nil
 	}
-	return ErrVerificationFailed
+	return n.verificationError(subject, [32]byte{}, ErrVerificationFailed)
 }
 
-// TODO: check proof: InsertedBlock and BlockTimestamp
+// CredentialInclusionProof returns the Merkle root of the credentials of a
+// subject and the inclusion proof of the given credential in it. The node
+// contract does not store the Merkle root, which is computed from all the
+// credentials of the subject.
+func (n *Node) CredentialInclusionProof(ctx context.Context, opts *ctree.CallOpts, subject common.Address, digest [32]byte) ([32]byte, *encode.MerkleProof, error) {
+	digests, err := n.GetDigests(ctx, opts, subject)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	if len(digests) == 0 {
+		return [32]byte{}, nil, n.verificationError(subject, [32]byte{}, ErrNoCredentials)
+	}
+	root, err := encode.MerkleRoot(digests)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	proof, err := encode.NewMerkleProofOf(digests, digest)
+	if errors.Is(err, encode.ErrLeafNotFound) {
+		return [32]byte{}, nil, n.verificationError(subject, digest, ErrCredentialNotFound)
+	}
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	return root, proof, nil
+}
 
-// VerifyCredentialTree performs a pre-order tree traversal over
-// the credential tree of a given subject and verifies if the given
-// root match with the current root on the root of the credential tree
-// and if all the sub-trees were correctly built.
-func verifyCredentialTree(n ctree.Issuer, backend bind.ContractBackend, opts *bind.CallOpts, subject common.Address) error {
-	digests, err := n.GetDigests(opts, subject)
+// VerifyCredentialInclusionFromDigests checks whether the credential of the
+// inclusion proof is valid and belongs to the Merkle root of the credentials
+// of a subject. It is a convenience check of a proof given by the subject:
+// since the contract only stores the aggregated root, all the credentials of
+// the subject are read to recompute the Merkle root and to check that they
+// are the ones aggregated on-chain.
+func (n *Node) VerifyCredentialInclusionFromDigests(ctx context.Context, opts *ctree.CallOpts, subject common.Address, root [32]byte, proof *encode.MerkleProof) error {
+	if !proof.Verify(root) {
+		return n.verificationError(subject, proof.Leaf, ErrInvalidInclusionProof)
+	}
+	digests, err := n.GetDigests(ctx, opts, subject)
 	if err != nil {
 		return err
 	}
 	if len(digests) == 0 {
-		return ErrNoCredentials
+		return n.verificationError(subject, [32]byte{}, ErrNoCredentials)
 	}
-	for _, d := range digests {
-		err := n.VerifyCredential(false, opts, subject, d)
-		if err != nil {
-			return err
-		}
-		c := n.GetCredentialProof(opts, d)
-		for _, w := range c.Witnesses {
-			node, err := NewNode(w, backend) // witnesses must be nodes
-			if err != nil {
-				return err
-			}
-			ok, err := node.IsLeaf(opts)
-			if ok && err == nil {
-				r, err := node.GetRoot(opts, subject)
-				if err != nil {
-					return err
-				}
-				err = node.VerifyCredentialRoot(false, opts, subject, r)
-				if err != nil {
-					return err
-				}
-			} else if err != nil {
-				return err
-			} else {
-				// check sub-tree
-				err := verifyCredentialTree(node, backend, opts, subject)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	m, err := encode.MerkleRoot(digests)
+	if err != nil {
+		return err
 	}
-	return nil
+	if m != root {
+		return n.verificationError(subject, [32]byte{}, ErrWrongRoot)
+	}
+	aggregated, err := n.GetRoot(ctx, opts, subject)
+	if err != nil {
+		return err
+	}
+	if err := n.VerifyCredentialRoot(ctx, false, opts, subject, aggregated); err != nil {
+		return err
+	}
+	return n.VerifyCredential(ctx, false, opts, subject, proof.Leaf)
 }
 
-func (n *Node) VerifyCredentialTree(onchain bool, opts *bind.CallOpts, subject common.Address) error {
+// VerifyCredentialTree performs a pre-order tree traversal over
+// the credential tree of a given subject and verifies if the given
+// root match with the current root on the root of the credential tree
+// and if all the sub-trees were correctly built.
+func (n *Node) VerifyCredentialTree(ctx context.Context, onchain bool, opts *ctree.CallOpts, subject common.Address) error {
 	if onchain {
-		ok, err := n.contract.VerifyCredentialTree(opts, subject)
+		ok, err := n.contract.VerifyCredentialTree(opts.Bind(ctx), subject)
 		if err != nil {
-			return err
+			return n.callError("verifyCredentialTree", subject, [32]byte{}, err)
 		}
 		if !ok {
-			return ErrVerificationFailed
+			return n.verificationError(subject, [32]byte{}, ErrVerificationFailed)
 		}
 		return nil
 	}
-	return verifyCredentialTree(n, n.backend, opts, subject)
+	return verifyCredentialTree(ctx, n, n.backend, opts, subject, DefaultTreeWorkers)
 }
 
-// TODO: add methods to verify json credential
-// given a credential, parse the json and query the contracts cross-checking the data.
+// VerifyCredentialTreeContext performs an off-chain verification of the
+// credential tree of a given subject, verifying up to workers sub-trees
+// concurrently. The verification stops if the context is canceled.
+func (n *Node) VerifyCredentialTreeContext(ctx context.Context, opts *ctree.CallOpts, subject common.Address, workers int) error {
+	return verifyCredentialTree(ctx, n, n.backend, opts, subject, workers)
+}
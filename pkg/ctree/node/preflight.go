@@ -0,0 +1,282 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/owners"
+)
+
+// Reasons of the preflight checks for the transactions the node contract
+// would revert. The checks also return ErrCredentialRevoked,
+// ErrCredentialNotFound, ErrNotQuorumSigned, ErrRootNotFound and
+// ErrWrongEvidenceRoot.
+var (
+	ErrNotOwner         = owners.ErrNotOwner
+	ErrAlreadySigned    = errors.New("credential already signed by the sender")
+	ErrDigestRegistered = errors.New("digest already registered for another subject")
+	ErrLeafWitnesses    = errors.New("leaf nodes cannot have witnesses")
+	ErrNoWitnesses      = errors.New("inner nodes require witnesses")
+	ErrNotChild         = errors.New("witness is not a child node")
+	ErrNotSubject       = errors.New("sender is not the subject of the credential")
+	ErrAlreadyApproved  = errors.New("credential already approved")
+	ErrNoDigests        = errors.New("empty list of credentials")
+	ErrLeafNode         = errors.New("leaf nodes cannot have children")
+	ErrSelfChild        = errors.New("node cannot be a child of itself")
+	ErrChildAdded       = errors.New("node already added")
+)
+
+// preflightError wraps the reason a transaction would be reverted.
+func (n *Node) preflightError(method string, sender, subject common.Address, digest [32]byte, err error) error {
+	return &ctree.PreflightError{Contract: n.address, Method: method, Sender: sender, Subject: subject, Digest: digest, Err: err}
+}
+
+// CheckOwner checks that the sender is an owner of the node, as required
+// by the given method of the contract.
+func (n *Node) CheckOwner(ctx context.Context, opts *ctree.CallOpts, method string, sender common.Address) error {
+	ok, err := n.IsOwnerContext(ctx, opts, sender)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return n.preflightError(method, sender, common.Address{}, [32]byte{}, ErrNotOwner)
+	}
+	return nil
+}
+
+// CheckRegisterCredential checks that the contract would accept the
+// registration of a credential by the sender, either issuing it or signing
+// a credential already issued by another owner.
+func (n *Node) CheckRegisterCredential(ctx context.Context, opts *ctree.CallOpts, sender, subject common.Address, digest [32]byte, witnesses []common.Address) error {
+	const method = "registerCredential"
+	if err := n.CheckOwner(ctx, opts, method, sender); err != nil {
+		return err
+	}
+	if err := n.checkWitnesses(ctx, opts, sender, subject, digest, witnesses); err != nil {
+		return err
+	}
+	revoked, err := n.IsRevoked(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return n.preflightError(method, sender, subject, digest, ErrCredentialRevoked)
+	}
+
+	cp, err := n.GetCredentialProof(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if !issuedAt(cp.InsertedBlock, opts) {
+		return nil
+	}
+	signed, err := n.IsSigned(ctx, opts, digest, sender)
+	if err != nil {
+		return err
+	}
+	if signed {
+		return n.preflightError(method, sender, subject, digest, ErrAlreadySigned)
+	}
+	if cp.Subject != subject {
+		return n.preflightError(method, sender, subject, digest, fmt.Errorf("%w: %s", ErrDigestRegistered, cp.Subject.Hex()))
+	}
+	evidenceRoot, err := EvidenceRoot(ctx, opts, n.backend, subject, witnesses)
+	if err != nil {
+		return err
+	}
+	if evidenceRoot != cp.EvidenceRoot {
+		return n.preflightError(method, sender, subject, digest, ErrWrongEvidenceRoot)
+	}
+	return nil
+}
+
+// checkWitnesses checks that leaves register credentials without witnesses,
+// and inner nodes with witnesses that are children holding a root of the
+// subject.
+func (n *Node) checkWitnesses(ctx context.Context, opts *ctree.CallOpts, sender, subject common.Address, digest [32]byte, witnesses []common.Address) error {
+	const method = "registerCredential"
+	leaf, err := n.IsLeaf(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if leaf {
+		if len(witnesses) > 0 {
+			return n.preflightError(method, sender, subject, digest, ErrLeafWitnesses)
+		}
+		return nil
+	}
+	if len(witnesses) == 0 {
+		return n.preflightError(method, sender, subject, digest, ErrNoWitnesses)
+	}
+	children, err := n.GetChildren(ctx, opts)
+	if err != nil {
+		return err
+	}
+	isChild := make(map[common.Address]bool, len(children))
+	for _, c := range children {
+		isChild[c] = true
+	}
+	for _, w := range witnesses {
+		if !isChild[w] {
+			return n.preflightError(method, sender, subject, digest, fmt.Errorf("%w: %s", ErrNotChild, w.Hex()))
+		}
+		wn, err := NewNode(w, n.backend)
+		if err != nil {
+			return err
+		}
+		root, err := wn.GetRoot(ctx, opts, subject)
+		if err != nil {
+			return err
+		}
+		if root == [32]byte{} {
+			return n.preflightError(method, sender, subject, digest, fmt.Errorf("%w in witness %s", ErrRootNotFound, w.Hex()))
+		}
+	}
+	return nil
+}
+
+// CheckApproveCredential checks that the contract would accept the approval
+// of a credential by the sender, its subject, once signed by a quorum.
+func (n *Node) CheckApproveCredential(ctx context.Context, opts *ctree.CallOpts, sender common.Address, digest [32]byte) error {
+	const method = "approveCredential"
+	revoked, err := n.IsRevoked(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return n.preflightError(method, sender, common.Address{}, digest, ErrCredentialRevoked)
+	}
+	cp, err := n.GetCredentialProof(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if !issuedAt(cp.InsertedBlock, opts) {
+		return n.preflightError(method, sender, common.Address{}, digest, ErrCredentialNotFound)
+	}
+	if cp.Subject != sender {
+		return n.preflightError(method, sender, cp.Subject, digest, ErrNotSubject)
+	}
+	if cp.Approved {
+		return n.preflightError(method, sender, cp.Subject, digest, ErrAlreadyApproved)
+	}
+	signed, err := n.IsQuorumSigned(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if !signed {
+		return n.preflightError(method, sender, cp.Subject, digest, ErrNotQuorumSigned)
+	}
+	return nil
+}
+
+// CheckRevoke checks that the contract would accept the revocation of an
+// issued credential by the sender.
+func (n *Node) CheckRevoke(ctx context.Context, opts *ctree.CallOpts, sender common.Address, digest [32]byte) error {
+	const method = "revokeCredential"
+	if err := n.CheckOwner(ctx, opts, method, sender); err != nil {
+		return err
+	}
+	revoked, err := n.IsRevoked(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return n.preflightError(method, sender, common.Address{}, digest, ErrCredentialRevoked)
+	}
+	cp, err := n.GetCredentialProof(ctx, opts, digest)
+	if err != nil {
+		return err
+	}
+	if !issuedAt(cp.InsertedBlock, opts) {
+		return n.preflightError(method, sender, common.Address{}, digest, ErrCredentialNotFound)
+	}
+	return nil
+}
+
+// CheckAggregateCredentials checks that the contract would accept the
+// aggregation of the given credentials of a subject by the sender. As the
+// contract, it requires the credentials to be approved, signed by a quorum
+// and not revoked, but does not check their evidence roots.
+func (n *Node) CheckAggregateCredentials(ctx context.Context, opts *ctree.CallOpts, sender, subject common.Address, digests [][32]byte) error {
+	const method = "aggregateCredentials"
+	if err := n.CheckOwner(ctx, opts, method, sender); err != nil {
+		return err
+	}
+	issued, err := n.GetDigests(ctx, opts, subject)
+	if err != nil {
+		return err
+	}
+	if len(issued) == 0 {
+		return n.preflightError(method, sender, subject, [32]byte{}, ErrNoCredentials)
+	}
+	if len(digests) == 0 {
+		return n.preflightError(method, sender, subject, [32]byte{}, ErrNoDigests)
+	}
+	for _, d := range digests {
+		cp, err := n.GetCredentialProof(ctx, opts, d)
+		if err != nil {
+			return err
+		}
+		var reason error
+		switch {
+		case !issuedAt(cp.InsertedBlock, opts):
+			reason = ErrCredentialNotFound
+		case cp.Subject != subject:
+			reason = ErrWrongSubject
+		case !cp.Approved:
+			reason = ErrCredentialNotApproved
+		}
+		if reason == nil {
+			signed, err := n.IsQuorumSigned(ctx, opts, d)
+			if err != nil {
+				return err
+			}
+			revoked, err := n.IsRevoked(ctx, opts, d)
+			if err != nil {
+				return err
+			}
+			if !signed {
+				reason = ErrNotQuorumSigned
+			} else if revoked {
+				reason = ErrCredentialRevoked
+			}
+		}
+		if reason != nil {
+			return n.preflightError(method, sender, subject, d, reason)
+		}
+	}
+	return nil
+}
+
+// CheckAddNode checks that the contract would accept a child node added by
+// the sender.
+func (n *Node) CheckAddNode(ctx context.Context, opts *ctree.CallOpts, sender, child common.Address) error {
+	const method = "addChild"
+	if err := n.CheckOwner(ctx, opts, method, sender); err != nil {
+		return err
+	}
+	leaf, err := n.IsLeaf(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if leaf {
+		return n.preflightError(method, sender, common.Address{}, [32]byte{}, ErrLeafNode)
+	}
+	if child == n.address {
+		return n.preflightError(method, sender, common.Address{}, [32]byte{}, ErrSelfChild)
+	}
+	children, err := n.GetChildren(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		if c == child {
+			return n.preflightError(method, sender, common.Address{}, [32]byte{}, fmt.Errorf("%w: %s", ErrChildAdded, child.Hex()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// ErrNotNodeContract is reported for a witness that is not a node contract.
+var ErrNotNodeContract = errors.New("witness is not a node contract")
+
+// callFailed reports whether a contract call failed in the contract, which
+// reverted or returned no output of the method, rather than in the backend.
+func callFailed(err error) bool {
+	if errors.Is(err, bind.ErrNoCode) || errors.Is(err, vm.ErrExecutionReverted) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, vm.ErrExecutionReverted.Error()) || strings.Contains(msg, "abi: ")
+}
+
+// isNodeContract reports whether the contract at addr is a node contract.
+// The error is only set if the backend could not be queried.
+func isNodeContract(ctx context.Context, opts *ctree.CallOpts, n *Node) (bool, error) {
+	var block *big.Int
+	if opts != nil {
+		block = opts.BlockNumber
+	}
+	code, err := n.backend.CodeAt(ctx, n.Address(), block)
+	if err != nil {
+		return false, err
+	}
+	if len(code) == 0 {
+		return false, nil
+	}
+	if _, err := n.IsLeaf(ctx, opts); err != nil {
+		if callFailed(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// reportBuilder collects the verification reports of the nodes of a
+// credential tree. Each node is reported once and linked to the
+// credentials that use it as witness after the traversal.
+type reportBuilder struct {
+	ctx     context.Context
+	backend bind.ContractBackend
+	opts    *ctree.CallOpts
+	subject common.Address
+	root    *Node
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	err       error
+	reports   map[common.Address]*ctree.VerificationReport
+	witnesses map[*ctree.CredentialReport][]common.Address
+}
+
+func (rb *reportBuilder) process(addr common.Address) []common.Address {
+	r, witnesses, err := rb.reportNode(addr)
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if err != nil {
+		if rb.err == nil {
+			rb.err = err
+			rb.cancel()
+		}
+		return nil
+	}
+	rb.reports[addr] = r
+	var next []common.Address
+	for c, w := range witnesses {
+		rb.witnesses[c] = w
+		next = append(next, w...)
+	}
+	return next
+}
+
+// reportNode verifies all credentials issued by the node to the subject.
+// The returned error is only set if the node state could not be retrieved.
+func (rb *reportBuilder) reportNode(addr common.Address) (*ctree.VerificationReport, map[*ctree.CredentialReport][]common.Address, error) {
+	n := rb.root
+	if addr != rb.root.Address() {
+		var err error
+		n, err = NewNode(addr, rb.backend)
+		if err != nil {
+			return nil, nil, err
+		}
+		ok, err := isNodeContract(rb.ctx, rb.opts, n)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return &ctree.VerificationReport{
+				Contract: addr,
+				Subject:  rb.subject,
+				Errors:   ctree.Errors{ErrNotNodeContract},
+			}, nil, nil
+		}
+	}
+	leaf, err := n.IsLeaf(rb.ctx, rb.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	owners, err := n.GetOwnersContext(rb.ctx, rb.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	quorum, err := n.QuorumContext(rb.ctx, rb.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	digests, err := n.GetDigests(rb.ctx, rb.opts, rb.subject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &ctree.VerificationReport{
+		Contract: addr,
+		Subject:  rb.subject,
+		Leaf:     leaf,
+		Owners:   owners,
+		Quorum:   quorum,
+	}
+	if len(digests) == 0 {
+		r.Errors = append(r.Errors, ErrNoCredentials)
+	}
+
+	witnesses := make(map[*ctree.CredentialReport][]common.Address, len(digests))
+	for _, d := range digests {
+		c, w, err := rb.reportCredential(n, owners, d)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.Credentials = append(r.Credentials, c)
+		witnesses[c] = w
+	}
+
+	p, err := n.getProof(rb.ctx, rb.opts, rb.subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.InsertedBlock == nil || p.InsertedBlock.Sign() == 0 {
+		if leaf {
+			r.Errors = append(r.Errors, ErrRootNotFound)
+		}
+		return r, witnesses, nil
+	}
+	r.Root = p.Proof
+	if len(digests) > 0 {
+		root, err := encode.EncodeByteArray(digests)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.RootMatch = root == p.Proof
+	}
+	if !r.RootMatch {
+		r.Errors = append(r.Errors, ErrWrongRoot)
+	}
+	return r, witnesses, nil
+}
+
+func (rb *reportBuilder) reportCredential(n *Node, owners []common.Address, digest [32]byte) (*ctree.CredentialReport, []common.Address, error) {
+	cp, err := n.GetCredentialProof(rb.ctx, rb.opts, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &ctree.CredentialReport{
+		Digest:         digest,
+		Registrar:      cp.Registrar,
+		Subject:        cp.Subject,
+		InsertedBlock:  cp.InsertedBlock,
+		BlockTimestamp: cp.BlockTimestamp,
+		Approved:       cp.Approved,
+		EvidenceRoot:   cp.EvidenceRoot,
+	}
+	if cp.InsertedBlock == nil || cp.InsertedBlock.Sign() == 0 {
+		c.Errors = append(c.Errors, ErrCredentialNotFound)
+	}
+	if cp.Subject != rb.subject {
+		c.Errors = append(c.Errors, ErrWrongSubject)
+	}
+	if !cp.Approved {
+		c.Errors = append(c.Errors, ErrCredentialNotApproved)
+	}
+
+	for _, o := range owners {
+		signed, err := n.IsSigned(rb.ctx, rb.opts, digest, o)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.Signers = append(c.Signers, ctree.SignerStatus{Owner: o, Signed: signed})
+	}
+	signed, err := n.IsQuorumSigned(rb.ctx, rb.opts, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.QuorumSigned = signed
+	if !signed {
+		c.Errors = append(c.Errors, ErrNotQuorumSigned)
+	}
+
+	revoked, err := n.IsRevoked(rb.ctx, rb.opts, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Revoked = revoked
+	if revoked {
+		rp, err := n.GetRevokedProof(rb.ctx, rb.opts, digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.Revocation = &ctree.RevocationReport{
+			Revoker:      rp.Registrar,
+			RevokedBlock: rp.RevokedBlock,
+			Reason:       rp.Reason,
+			ReasonName:   notary.Reason(rp.Reason).String(),
+		}
+		c.Errors = append(c.Errors, ErrCredentialRevoked)
+	}
+
+	// the roots of witnesses that are not node contracts are reported
+	// with the witnesses, and do not match the evidence root
+	evidenceRoot, err := EvidenceRoot(rb.ctx, rb.opts, n.backend, cp.Subject, cp.Witnesses)
+	if err != nil && !callFailed(err) {
+		return nil, nil, err
+	}
+	if err != nil || evidenceRoot != cp.EvidenceRoot {
+		c.Errors = append(c.Errors, ErrWrongEvidenceRoot)
+	}
+	return c, cp.Witnesses, nil
+}
+
+// link connects the credential reports to the reports of their witnesses
+// in a depth-first traversal. Repeated witnesses and witnesses that
+// would close a cycle are reported as errors instead of being linked.
+func (rb *reportBuilder) link(addr common.Address, path, linked map[common.Address]bool) *ctree.VerificationReport {
+	r := rb.reports[addr]
+	if linked[addr] {
+		return r
+	}
+	linked[addr] = true
+	for _, c := range r.Credentials {
+		seen := make(map[common.Address]bool)
+		for _, w := range rb.witnesses[c] {
+			if seen[w] {
+				c.Errors = append(c.Errors, ErrRepeatedWitness)
+				continue
+			}
+			seen[w] = true
+			if path[w] {
+				c.Witnesses = append(c.Witnesses, &ctree.VerificationReport{
+					Contract: w,
+					Subject:  rb.subject,
+					Errors:   ctree.Errors{ErrCyclicTree},
+				})
+				continue
+			}
+			path[w] = true
+			c.Witnesses = append(c.Witnesses, rb.link(w, path, linked))
+			delete(path, w)
+		}
+	}
+	return r
+}
+
+// reportCredentialTree verifies all the credentials in the credential tree
+// of a subject, reporting every verification failure found instead of
+// stopping at the first one, including witnesses that are not node
+// contracts. An error is only returned if the state of the tree could not
+// be retrieved from the backend.
+func reportCredentialTree(ctx context.Context, n *Node, opts *ctree.CallOpts, subject common.Address, workers int) (*ctree.VerificationReport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rb := &reportBuilder{
+		ctx:       ctx,
+		backend:   n.backend,
+		opts:      opts,
+		subject:   subject,
+		root:      n,
+		cancel:    cancel,
+		reports:   make(map[common.Address]*ctree.VerificationReport),
+		witnesses: make(map[*ctree.CredentialReport][]common.Address),
+	}
+	err := newTraversal(ctx, workers, rb.process).run(n.Address())
+	if rb.err != nil {
+		return nil, rb.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	path := map[common.Address]bool{n.Address(): true}
+	return rb.link(n.Address(), path, make(map[common.Address]bool)), nil
+}
+
+// VerifyCredentialTreeReport performs an off-chain verification of the
+// credential tree of a subject, verifying up to workers nodes concurrently,
+// and returns a report with the state of every credential in the tree.
+func (n *Node) VerifyCredentialTreeReport(ctx context.Context, opts *ctree.CallOpts, subject common.Address, workers int) (*ctree.VerificationReport, error) {
+	return reportCredentialTree(ctx, n, opts, subject, workers)
+}
+
+// CredentialTree returns the credential tree of a subject rooted at the
+// given contract, walking the digests, credential proofs and witnesses of
+// the nodes as the verification of the tree does. The returned report
+// holds the state of every node and credential, and can be rendered with
+// Render.
+func CredentialTree(ctx context.Context, opts *ctree.CallOpts, backend bind.ContractBackend, root, subject common.Address, workers int) (*ctree.VerificationReport, error) {
+	n, err := NewNode(root, backend)
+	if err != nil {
+		return nil, err
+	}
+	return reportCredentialTree(ctx, n, opts, subject, workers)
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// traversal visits each node of a credential tree once, processing up to
+// workers nodes concurrently. The process function is called for every
+// visited node and returns the witnesses that should be visited next.
+type traversal struct {
+	ctx     context.Context
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	process func(addr common.Address) []common.Address
+
+	mu      sync.Mutex
+	visited map[common.Address]bool
+}
+
+func newTraversal(ctx context.Context, workers int, process func(common.Address) []common.Address) *traversal {
+	if workers < 1 {
+		workers = DefaultTreeWorkers
+	}
+	return &traversal{
+		ctx:     ctx,
+		sem:     make(chan struct{}, workers),
+		process: process,
+		visited: make(map[common.Address]bool),
+	}
+}
+
+// claim reports whether the node was not visited yet, marking it as visited.
+func (t *traversal) claim(addr common.Address) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.visited[addr] {
+		return false
+	}
+	t.visited[addr] = true
+	return true
+}
+
+func (t *traversal) visit(addresses []common.Address) {
+	for _, addr := range addresses {
+		if !t.claim(addr) {
+			continue
+		}
+		t.wg.Add(1)
+		go func(addr common.Address) {
+			defer t.wg.Done()
+			select {
+			case t.sem <- struct{}{}:
+			case <-t.ctx.Done():
+				return
+			}
+			next := t.process(addr)
+			<-t.sem
+			t.visit(next)
+		}(addr)
+	}
+}
+
+// run visits all nodes reachable from the root and waits for them
+// to be processed, or until the context is done.
+func (t *traversal) run(root common.Address) error {
+	t.visit([]common.Address{root})
+	t.wg.Wait()
+	return t.ctx.Err()
+}
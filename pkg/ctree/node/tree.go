@@ -0,0 +1,183 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+// DefaultTreeWorkers is the default number of nodes of a credential tree
+// that are verified concurrently.
+const DefaultTreeWorkers = 8
+
+var (
+	ErrCyclicTree      = errors.New("credential tree contains a cycle")
+	ErrRepeatedWitness = errors.New("repeated witness in credential proof")
+)
+
+// digestResult keeps the verification of an issued credential
+// and the witnesses referenced by its proof.
+type digestResult struct {
+	err       error
+	digest    [32]byte
+	witnesses []common.Address
+}
+
+// nodeResult is the verification result of a node of the tree.
+// Leaf nodes only verify the root of the subject, while inner nodes
+// verify all issued credentials of the subject and its witnesses.
+type nodeResult struct {
+	err     error
+	digests []digestResult
+}
+
+func (r *nodeResult) witnesses() []common.Address {
+	var witnesses []common.Address
+	for _, d := range r.digests {
+		witnesses = append(witnesses, d.witnesses...)
+	}
+	return witnesses
+}
+
+// treeVerifier verifies the nodes of a credential tree concurrently,
+// visiting each node at most once. The results are kept per node so that
+// the tree can be walked afterwards in the same order as a sequential
+// pre-order traversal, returning the same error.
+type treeVerifier struct {
+	ctx     context.Context
+	backend bind.ContractBackend
+	opts    *ctree.CallOpts
+	subject common.Address
+	root    ctree.IssuerV2
+
+	mu      sync.Mutex
+	visited map[common.Address]*nodeResult
+}
+
+func newTreeVerifier(ctx context.Context, n ctree.IssuerV2, backend bind.ContractBackend, opts *ctree.CallOpts, subject common.Address) *treeVerifier {
+	return &treeVerifier{
+		ctx:     ctx,
+		backend: backend,
+		opts:    opts,
+		subject: subject,
+		root:    n,
+		visited: make(map[common.Address]*nodeResult),
+	}
+}
+
+func (tv *treeVerifier) result(addr common.Address) *nodeResult {
+	tv.mu.Lock()
+	defer tv.mu.Unlock()
+	return tv.visited[addr]
+}
+
+func (tv *treeVerifier) process(addr common.Address) []common.Address {
+	r := tv.verifyNode(addr)
+	tv.mu.Lock()
+	tv.visited[addr] = r
+	tv.mu.Unlock()
+	return r.witnesses()
+}
+
+func (tv *treeVerifier) verifyNode(addr common.Address) *nodeResult {
+	if addr == tv.root.Address() {
+		return tv.verifyInner(tv.root)
+	}
+	node, err := NewNode(addr, tv.backend) // witnesses must be nodes
+	if err != nil {
+		return &nodeResult{err: err}
+	}
+	leaf, err := node.IsLeaf(tv.ctx, tv.opts)
+	if err != nil {
+		return &nodeResult{err: err}
+	}
+	if leaf {
+		return &nodeResult{err: tv.verifyLeaf(node)}
+	}
+	return tv.verifyInner(node)
+}
+
+func (tv *treeVerifier) verifyLeaf(node *Node) error {
+	r, err := node.GetRoot(tv.ctx, tv.opts, tv.subject)
+	if err != nil {
+		return err
+	}
+	return node.VerifyCredentialRoot(tv.ctx, false, tv.opts, tv.subject, r)
+}
+
+// verifyInner verifies the issued credentials of an inner node, stopping
+// at the first invalid credential.
+func (tv *treeVerifier) verifyInner(n ctree.IssuerV2) *nodeResult {
+	digests, err := n.GetDigests(tv.ctx, tv.opts, tv.subject)
+	if err != nil {
+		return &nodeResult{err: err}
+	}
+	if len(digests) == 0 {
+		return &nodeResult{err: &ctree.VerificationError{Contract: n.Address(), Subject: tv.subject, Err: ErrNoCredentials}}
+	}
+	r := &nodeResult{}
+	for _, d := range digests {
+		err := n.VerifyCredential(tv.ctx, false, tv.opts, tv.subject, d)
+		if err != nil {
+			r.digests = append(r.digests, digestResult{err: err})
+			return r
+		}
+		c, err := n.GetCredentialProof(tv.ctx, tv.opts, d)
+		if err != nil {
+			r.digests = append(r.digests, digestResult{err: err})
+			return r
+		}
+		r.digests = append(r.digests, digestResult{digest: d, witnesses: c.Witnesses})
+	}
+	return r
+}
+
+// walk traverses the verified tree in pre-order, returning the first error
+// found. Repeated witnesses and cycles are reported on the credential whose
+// proof references them.
+func (tv *treeVerifier) walk(addr common.Address, path map[common.Address]bool) error {
+	r := tv.result(addr)
+	if r.err != nil {
+		return r.err
+	}
+	for _, d := range r.digests {
+		if d.err != nil {
+			return d.err
+		}
+		seen := make(map[common.Address]bool, len(d.witnesses))
+		for _, w := range d.witnesses {
+			if seen[w] {
+				return &ctree.VerificationError{Contract: addr, Subject: tv.subject, Digest: d.digest, Err: ErrRepeatedWitness}
+			}
+			seen[w] = true
+			if path[w] {
+				return &ctree.VerificationError{Contract: addr, Subject: tv.subject, Digest: d.digest, Err: ErrCyclicTree}
+			}
+			path[w] = true
+			err := tv.walk(w, path)
+			delete(path, w)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifyCredentialTree performs an off-chain verification of the credential
+// tree of a given subject, checking all issued credentials of the inner nodes
+// and the roots of the leaves. The sub-trees of the witnesses are verified
+// concurrently by at most workers goroutines, and each node is verified once
+// even if it is a witness of many credentials.
+func verifyCredentialTree(ctx context.Context, n ctree.IssuerV2, backend bind.ContractBackend, opts *ctree.CallOpts, subject common.Address, workers int) error {
+	tv := newTreeVerifier(ctx, n, backend, opts, subject)
+	if err := newTraversal(ctx, workers, tv.process).run(n.Address()); err != nil {
+		return err
+	}
+	return tv.walk(n.Address(), map[common.Address]bool{n.Address(): true})
+}
@@ -0,0 +1,172 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+func TestWalkVerifiedTree(t *testing.T) {
+	root := common.HexToAddress("0x01")
+	a := common.HexToAddress("0x0a")
+	b := common.HexToAddress("0x0b")
+	leaf := common.HexToAddress("0x0c")
+
+	tests := []struct {
+		name    string
+		visited map[common.Address]*nodeResult
+		err     error
+	}{
+		{
+			name: "shared leaf",
+			visited: map[common.Address]*nodeResult{
+				root: {digests: []digestResult{{witnesses: []common.Address{a, b}}}},
+				a:    {digests: []digestResult{{witnesses: []common.Address{leaf}}}},
+				b:    {digests: []digestResult{{witnesses: []common.Address{leaf}}}},
+				leaf: {},
+			},
+		},
+		{
+			name: "cycle",
+			visited: map[common.Address]*nodeResult{
+				root: {digests: []digestResult{{witnesses: []common.Address{a}}}},
+				a:    {digests: []digestResult{{witnesses: []common.Address{b}}}},
+				b:    {digests: []digestResult{{digest: [32]byte{1}, witnesses: []common.Address{a}}}},
+			},
+			err: &ctree.VerificationError{Contract: b, Digest: [32]byte{1}, Err: ErrCyclicTree},
+		},
+		{
+			name: "repeated witness",
+			visited: map[common.Address]*nodeResult{
+				root: {digests: []digestResult{{digest: [32]byte{2}, witnesses: []common.Address{leaf, leaf}}}},
+				leaf: {},
+			},
+			err: &ctree.VerificationError{Contract: root, Digest: [32]byte{2}, Err: ErrRepeatedWitness},
+		},
+		{
+			name: "first error in pre-order",
+			visited: map[common.Address]*nodeResult{
+				root: {digests: []digestResult{
+					{witnesses: []common.Address{a}},
+					{err: ErrCredentialNotApproved},
+				}},
+				a: {digests: []digestResult{{err: ErrCredentialRevoked}}},
+			},
+			err: ErrCredentialRevoked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tv := &treeVerifier{visited: tt.visited}
+			err := tv.walk(root, map[common.Address]bool{root: true})
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+// isLeafID is the selector of the isLeaf method, read once per verified node.
+var isLeafID = crypto.Keccak256([]byte("isLeaf()"))[:4]
+
+// countingBackend counts the nodes verified, from their calls to isLeaf.
+type countingBackend struct {
+	*backends.TestBackend
+	mu       sync.Mutex
+	verified map[common.Address]int
+}
+
+func (b *countingBackend) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	if bytes.HasPrefix(call.Data, isLeafID) {
+		b.mu.Lock()
+		b.verified[*call.To]++
+		b.mu.Unlock()
+	}
+	return b.TestBackend.CallContract(ctx, call, block)
+}
+
+// TestVerifyDeployedTree verifies the credential tree of a student over
+// a university with two departments, which both take a shared course as
+// a witness of their credentials.
+func TestVerifyDeployedTree(t *testing.T) {
+	backend := backends.NewTestBackend()
+	defer backend.Close()
+	ctx := context.Background()
+	owner := backends.TestAccounts[0]
+	student := backends.TestAccounts[1]
+
+	libs, err := backend.DeployLibs(backend.TransactOpts(owner.Key))
+	require.NoError(t, err)
+	backend.Commit()
+	deploy := func(role uint8, children ...*Node) *Node {
+		_, _, n, err := Deploy(backend.TransactOpts(owner.Key), backend, libs, role, []common.Address{owner.Address}, uint8(1))
+		require.NoError(t, err)
+		backend.Commit()
+		for _, c := range children {
+			_, err = n.AddNode(backend.TransactOpts(owner.Key), c.Address())
+			require.NoError(t, err)
+			backend.Commit()
+		}
+		return n
+	}
+	// issue registers and approves a credential of the student, witnessed
+	// by the given nodes, and aggregates it into the root of the student
+	issue := func(n *Node, digest [32]byte, witnesses ...*Node) {
+		var addrs []common.Address
+		for _, w := range witnesses {
+			addrs = append(addrs, w.Address())
+		}
+		_, err := n.RegisterCredential(backend.TransactOpts(owner.Key), student.Address, digest, addrs)
+		require.NoError(t, err)
+		backend.Commit()
+		_, err = n.ApproveCredential(backend.TransactOpts(student.Key), digest)
+		require.NoError(t, err)
+		backend.Commit()
+		_, err = n.AggregateCredentials(backend.TransactOpts(owner.Key), student.Address, [][32]byte{digest})
+		require.NoError(t, err)
+		backend.Commit()
+	}
+
+	shared := deploy(LeafRole)
+	course1 := deploy(LeafRole)
+	course2 := deploy(LeafRole)
+	dep1 := deploy(InnerRole, course1, shared)
+	dep2 := deploy(InnerRole, course2, shared)
+	university := deploy(InnerRole, dep1, dep2)
+	issue(shared, [32]byte{1})
+	issue(course1, [32]byte{2})
+	issue(course2, [32]byte{3})
+	issue(dep1, [32]byte{4}, course1, shared)
+	issue(dep2, [32]byte{5}, course2, shared)
+	issue(university, [32]byte{6}, dep1, dep2)
+
+	for _, workers := range []int{1, 4} {
+		counting := &countingBackend{TestBackend: backend, verified: make(map[common.Address]int)}
+		root, err := NewNode(university.Address(), counting)
+		require.NoError(t, err)
+		assert.NoError(t, root.VerifyCredentialTreeContext(ctx, nil, student.Address, workers), "%d workers", workers)
+		// the shared course is verified once
+		for _, n := range []*Node{shared, course1, course2, dep1, dep2} {
+			assert.Equal(t, 1, counting.verified[n.Address()], "%d workers", workers)
+		}
+	}
+
+	_, err = dep2.Revoke(backend.TransactOpts(owner.Key), [32]byte{5}, [32]byte{})
+	require.NoError(t, err)
+	backend.Commit()
+	err = university.VerifyCredentialTreeContext(ctx, nil, student.Address, 4)
+	assert.ErrorIs(t, err, ErrCredentialRevoked)
+	var verr *ctree.VerificationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, dep2.Address(), verr.Contract)
+	assert.Equal(t, common.Hash{5}, verr.Digest)
+}
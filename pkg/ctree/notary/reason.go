@@ -0,0 +1,188 @@
+package notary
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrUnknownReason    = errors.New("unknown revocation reason")
+	ErrReservedReason   = errors.New("reserved revocation reason")
+	ErrInvalidNamespace = errors.New("institution reasons must be namespaced as <institution>/<code>")
+)
+
+// Reason is the code of a revocation reason stored in the revocation proof
+// of a credential.
+//
+// Standard reasons are small integers encoded big-endian in the code, so
+// that they can be compared on-chain. Institution-defined reasons are
+// namespaced as <institution>/<code> and encoded as the keccak256 hash of
+// their name, which is deterministic and never collides with the
+// standard ones.
+type Reason [32]byte
+
+// Standard revocation reasons.
+var (
+	ReasonUnspecified         = Reason{}
+	ReasonAdministrativeError = standardReason(1)
+	ReasonPlagiarism          = standardReason(2)
+	ReasonRegrade             = standardReason(3)
+	ReasonMisconduct          = standardReason(4)
+	ReasonSuperseded          = standardReason(5)
+	ReasonWithdrawn           = standardReason(6)
+)
+
+var standardReasons = map[string]Reason{
+	"unspecified":          ReasonUnspecified,
+	"administrative-error": ReasonAdministrativeError,
+	"plagiarism":           ReasonPlagiarism,
+	"regrade":              ReasonRegrade,
+	"misconduct":           ReasonMisconduct,
+	"superseded":           ReasonSuperseded,
+	"withdrawn":            ReasonWithdrawn,
+}
+
+func standardReason(code uint8) Reason {
+	var r Reason
+	r[31] = code
+	return r
+}
+
+// InstitutionReason returns the code of an institution-defined reason.
+func InstitutionReason(name string) (Reason, error) {
+	institution, code, ok := strings.Cut(name, "/")
+	if !ok || institution == "" || code == "" {
+		return Reason{}, fmt.Errorf("%w: %q", ErrInvalidNamespace, name)
+	}
+	return Reason(crypto.Keccak256Hash([]byte(strings.ToLower(name)))), nil
+}
+
+// IsStandard reports whether the code is reserved for standard reasons.
+func (r Reason) IsStandard() bool {
+	for _, b := range r[:31] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the reason code as stored on-chain.
+func (r Reason) Hash() common.Hash {
+	return common.Hash(r)
+}
+
+// String returns the name of the reason in the default registry, or the
+// hex encoding of its code if it is unknown.
+func (r Reason) String() string {
+	return DefaultReasons.Name(r)
+}
+
+// Reasons is a registry of the names of the revocation reasons. It holds
+// the standard reasons and the institution-defined reasons registered.
+type Reasons struct {
+	mu    sync.RWMutex
+	names map[Reason]string
+	codes map[string]Reason
+}
+
+// DefaultReasons is the registry used to decode the reasons of the
+// verification reports.
+var DefaultReasons = NewReasons()
+
+// NewReasons returns a registry with the standard reasons.
+func NewReasons() *Reasons {
+	rs := &Reasons{
+		names: make(map[Reason]string),
+		codes: make(map[string]Reason),
+	}
+	for name, r := range standardReasons {
+		rs.names[r] = name
+		rs.codes[name] = r
+	}
+	return rs
+}
+
+// Register adds an institution-defined reason to the registry and returns
+// its code.
+func (rs *Reasons) Register(name string) (Reason, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := standardReasons[name]; ok {
+		return Reason{}, fmt.Errorf("%w: %q", ErrReservedReason, name)
+	}
+	r, err := InstitutionReason(name)
+	if err != nil {
+		return Reason{}, err
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.names[r] = name
+	rs.codes[name] = r
+	return r, nil
+}
+
+// Lookup returns the code of a registered reason.
+func (rs *Reasons) Lookup(name string) (Reason, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	r, ok := rs.codes[strings.ToLower(strings.TrimSpace(name))]
+	return r, ok
+}
+
+// Name returns the name of a reason, or the hex encoding of its code if it
+// is not registered.
+func (rs *Reasons) Name(r Reason) string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	if name, ok := rs.names[r]; ok {
+		return name
+	}
+	if r.IsStandard() {
+		return fmt.Sprintf("standard-%d", r[31])
+	}
+	return r.Hash().Hex()
+}
+
+// Names returns the names of the registered reasons, sorted.
+func (rs *Reasons) Names() []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	names := make([]string, 0, len(rs.codes))
+	for name := range rs.codes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse returns the code of a reason given by its name, an unregistered
+// institution-defined name or its hex encoded code.
+func (rs *Reasons) Parse(s string) (Reason, error) {
+	s = strings.TrimSpace(s)
+	if r, ok := rs.Lookup(s); ok {
+		return r, nil
+	}
+	if strings.HasPrefix(s, "0x") {
+		b, err := hexutil.Decode(s)
+		if err != nil || len(b) != len(Reason{}) {
+			return Reason{}, fmt.Errorf("%w: %q is not a 32 bytes code", ErrUnknownReason, s)
+		}
+		return Reason(common.BytesToHash(b)), nil
+	}
+	if strings.Contains(s, "/") {
+		return InstitutionReason(s)
+	}
+	return Reason{}, fmt.Errorf("%w: %q", ErrUnknownReason, s)
+}
+
+// ParseReason parses a reason with the default registry.
+func ParseReason(s string) (Reason, error) {
+	return DefaultReasons.Parse(s)
+}
@@ -0,0 +1,59 @@
+package notary
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReason(t *testing.T) {
+	rs := NewReasons()
+
+	r, err := rs.Parse("plagiarism")
+	require.NoError(t, err)
+	assert.Equal(t, ReasonPlagiarism, r)
+	assert.True(t, r.IsStandard())
+	assert.Equal(t, "plagiarism", rs.Name(r))
+
+	r, err = rs.Parse(" Regrade ")
+	require.NoError(t, err)
+	assert.Equal(t, ReasonRegrade, r)
+
+	r, err = rs.Parse(ReasonSuperseded.Hash().Hex())
+	require.NoError(t, err)
+	assert.Equal(t, ReasonSuperseded, r)
+
+	_, err = rs.Parse("plagiarsm")
+	assert.ErrorIs(t, err, ErrUnknownReason)
+	_, err = rs.Parse("0x01")
+	assert.ErrorIs(t, err, ErrUnknownReason)
+}
+
+func TestInstitutionReason(t *testing.T) {
+	rs := NewReasons()
+
+	// institution codes are deterministic and not standard
+	r, err := rs.Parse("uis/late-submission")
+	require.NoError(t, err)
+	assert.Equal(t, Reason(crypto.Keccak256Hash([]byte("uis/late-submission"))), r)
+	assert.False(t, r.IsStandard())
+	assert.Equal(t, r.Hash().Hex(), rs.Name(r))
+
+	registered, err := rs.Register("UiS/Late-Submission")
+	require.NoError(t, err)
+	assert.Equal(t, r, registered)
+	assert.Equal(t, "uis/late-submission", rs.Name(r))
+	assert.Contains(t, rs.Names(), "uis/late-submission")
+
+	_, err = rs.Register("plagiarism")
+	assert.ErrorIs(t, err, ErrReservedReason)
+	_, err = rs.Register("late-submission")
+	assert.ErrorIs(t, err, ErrInvalidNamespace)
+
+	// unknown standard codes are still decoded as standard
+	assert.Equal(t, "standard-42", rs.Name(standardReason(42)))
+	// the default registry is independent
+	assert.Equal(t, r.Hash().Hex(), r.String())
+}
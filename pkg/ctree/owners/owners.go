@@ -1,16 +1,31 @@
 package owners
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 
+	"github.com/relab/credbench/pkg/ctree"
 	bindings "github.com/relab/go-credbindings/owners"
 )
 
+var (
+	ErrInvalidOwner  = errors.New("invalid owner address")
+	ErrNotOwner      = errors.New("sender is not an owner")
+	ErrAlreadyOwner  = errors.New("new owner is already an owner")
+	ErrTxFailed      = errors.New("transaction failed")
+	ErrOwnerNotFound = errors.New("owner change not found in the contract state")
+	ErrPartialQuorum = errors.New("owners can only be changed when the quorum is all owners")
+)
+
 // Owners is a Go wrapper around an owners contract.
 type Owners struct {
 	contract *bindings.Owners
+	address  common.Address
 }
 
 // NewOwners creates a struct exposing convenient operations to
@@ -20,25 +35,151 @@ func NewOwners(contractAddr common.Address, backend bind.ContractBackend) (*Owne
 	if err != nil {
 		return nil, err
 	}
-	return &Owners{contract: c}, nil
+	return &Owners{contract: c, address: contractAddr}, nil
+}
+
+func (c Owners) callError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ctree.CallError{Contract: c.address, Method: method, Err: err}
 }
 
 // IsOwner check if a given address is an Owner
+//
+// Deprecated: Use IsOwnerContext.
 func (c Owners) IsOwner(opts *bind.CallOpts, address common.Address) (bool, error) {
 	return c.contract.IsOwner(opts, address)
 }
 
 // GetOwners returns the list of owners
+//
+// Deprecated: Use GetOwnersContext.
 func (c Owners) GetOwners(opts *bind.CallOpts) ([]common.Address, error) {
 	return c.contract.Owners(opts)
 }
 
 // Quorum returns the list of owners
+//
+// Deprecated: Use QuorumContext.
 func (c Owners) Quorum(opts *bind.CallOpts) (uint8, error) {
 	return c.contract.Quorum(opts)
 }
 
+// IsOwnerContext checks if a given address is an owner at the block of the
+// call options.
+func (c Owners) IsOwnerContext(ctx context.Context, opts *ctree.CallOpts, address common.Address) (bool, error) {
+	ok, err := c.contract.IsOwner(opts.Bind(ctx), address)
+	return ok, c.callError("isOwner", err)
+}
+
+// GetOwnersContext returns the list of owners at the block of the call
+// options.
+func (c Owners) GetOwnersContext(ctx context.Context, opts *ctree.CallOpts) ([]common.Address, error) {
+	owners, err := c.contract.Owners(opts.Bind(ctx))
+	return owners, c.callError("owners", err)
+}
+
+// QuorumContext returns the number of owners required to sign a credential
+// at the block of the call options.
+func (c Owners) QuorumContext(ctx context.Context, opts *ctree.CallOpts) (uint8, error) {
+	quorum, err := c.contract.Quorum(opts.Bind(ctx))
+	return quorum, c.callError("quorum", err)
+}
+
 // ChangeOwner one of the owners. Sender should be the old owner.
 func (c *Owners) ChangeOwner(opts *bind.TransactOpts, newOwner common.Address) (*types.Transaction, error) {
 	return c.contract.ChangeOwner(opts, newOwner)
 }
+
+// CheckChangeOwner checks that the contract would accept the replacement of
+// the sender by a new owner: the sender must be an owner, the new owner must
+// not, and the quorum must be all owners.
+func (c Owners) CheckChangeOwner(ctx context.Context, opts *ctree.CallOpts, sender, newOwner common.Address) error {
+	if newOwner == (common.Address{}) {
+		return ErrInvalidOwner
+	}
+	ok, err := c.IsOwnerContext(ctx, opts, sender)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotOwner, sender.Hex())
+	}
+	ok, err = c.IsOwnerContext(ctx, opts, newOwner)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyOwner, newOwner.Hex())
+	}
+	owners, err := c.GetOwnersContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+	quorum, err := c.QuorumContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if int(quorum) != len(owners) {
+		return fmt.Errorf("%w: quorum %d of %d owners", ErrPartialQuorum, quorum, len(owners))
+	}
+	return nil
+}
+
+// WaitFunc waits for a transaction to be included in a block.
+type WaitFunc func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error)
+
+// WaitMined returns a WaitFunc waiting until the transaction is mined.
+func WaitMined(backend bind.DeployBackend) WaitFunc {
+	return func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		return bind.WaitMined(ctx, backend, tx)
+	}
+}
+
+// Rotation is a confirmed change of an owner of a contract.
+type Rotation struct {
+	Contract common.Address
+	OldOwner common.Address
+	NewOwner common.Address
+	Tx       *types.Transaction
+	Receipt  *types.Receipt
+}
+
+// Rotate replaces the sender by a new owner. It checks the change with
+// CheckChangeOwner before sending the transaction, and that the owners were
+// changed once it is confirmed.
+func (c *Owners) Rotate(ctx context.Context, opts *bind.TransactOpts, newOwner common.Address, wait WaitFunc) (*Rotation, error) {
+	if err := c.CheckChangeOwner(ctx, nil, opts.From, newOwner); err != nil {
+		return nil, err
+	}
+
+	tx, err := c.ChangeOwner(opts, newOwner)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := wait(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("%w: %s", ErrTxFailed, tx.Hash().Hex())
+	}
+
+	r := &Rotation{Contract: c.address, OldOwner: opts.From, NewOwner: newOwner, Tx: tx, Receipt: receipt}
+	owners, err := c.GetOwnersContext(ctx, nil)
+	if err != nil {
+		return r, err
+	}
+	found := false
+	for _, o := range owners {
+		if o == opts.From {
+			return r, ErrOwnerNotFound
+		}
+		found = found || o == newOwner
+	}
+	if !found {
+		return r, ErrOwnerNotFound
+	}
+	return r, nil
+}
@@ -0,0 +1,224 @@
+package quorum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Status is the signing state of a registration served by a collector.
+type Status struct {
+	Hash    common.Hash      `json:"hash"`
+	Signers []common.Address `json:"signers"`
+	Quorum  uint8            `json:"quorum"`
+	Done    bool             `json:"done"`
+}
+
+// Collector is an HTTP handler collecting the signatures of the owners on a
+// registration. It serves:
+//
+//	GET  /typeddata  the EIP-712 typed data to sign, e.g. with eth_signTypedData_v4
+//	GET  /status     the signers so far and whether the quorum was reached
+//	GET  /           the signed registration
+//	POST /signatures a Signature of an owner
+type Collector struct {
+	signed *SignedRegistration
+	owners map[common.Address]bool
+	quorum uint8
+	done   chan struct{}
+	once   sync.Once
+	mux    *http.ServeMux
+}
+
+// NewCollector creates a collector of the signatures of the owners, done
+// once a quorum of them signed.
+func NewCollector(r *Registration, owners []common.Address, quorum uint8) *Collector {
+	c := &Collector{
+		signed: NewSignedRegistration(r),
+		owners: make(map[common.Address]bool, len(owners)),
+		quorum: quorum,
+		done:   make(chan struct{}),
+		mux:    http.NewServeMux(),
+	}
+	for _, o := range owners {
+		c.owners[o] = true
+	}
+	if quorum == 0 {
+		c.once.Do(func() { close(c.done) })
+	}
+	c.mux.HandleFunc("/typeddata", c.handleTypedData)
+	c.mux.HandleFunc("/status", c.handleStatus)
+	c.mux.HandleFunc("/signatures", c.handleSignatures)
+	c.mux.HandleFunc("/", c.handleSigned)
+	return c
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Add adds the signature of an owner.
+func (c *Collector) Add(sig *Signature) error {
+	if !c.owners[sig.Signer] {
+		return fmt.Errorf("%w: %s", ErrNotOwner, sig.Signer.Hex())
+	}
+	if err := c.signed.Add(sig); err != nil {
+		return err
+	}
+	if len(c.signed.Signers()) >= int(c.quorum) {
+		c.once.Do(func() { close(c.done) })
+	}
+	return nil
+}
+
+// Done is closed once a quorum of owners signed.
+func (c *Collector) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait waits until a quorum of owners signed and returns the signed registration.
+func (c *Collector) Wait(ctx context.Context) (*SignedRegistration, error) {
+	select {
+	case <-c.done:
+		return c.signed, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Status returns the signing state of the registration.
+func (c *Collector) Status() (*Status, error) {
+	hash, err := c.signed.Hash()
+	if err != nil {
+		return nil, err
+	}
+	signers := c.signed.Signers()
+	return &Status{
+		Hash:    hash,
+		Signers: signers,
+		Quorum:  c.quorum,
+		Done:    len(signers) >= int(c.quorum),
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (c *Collector) handleTypedData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, c.signed.TypedData())
+}
+
+func (c *Collector) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.writeStatus(w)
+}
+
+func (c *Collector) writeStatus(w http.ResponseWriter) {
+	status, err := c.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (c *Collector) handleSigned(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	c.signed.mu.Lock()
+	defer c.signed.mu.Unlock()
+	writeJSON(w, c.signed)
+}
+
+func (c *Collector) handleSignatures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sig := &Signature{}
+	if err := json.NewDecoder(r.Body).Decode(sig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := c.Add(sig)
+	switch {
+	case errors.Is(err, ErrNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrDuplicatedSigner):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.writeStatus(w)
+}
+
+// PostSignature sends the signature of an owner to the collector at url.
+func PostSignature(ctx context.Context, url string, sig *Signature) (*Status, error) {
+	body, err := json.Marshal(sig)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/signatures", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg := new(bytes.Buffer)
+		_, _ = msg.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("collector: %s: %s", resp.Status, bytes.TrimSpace(msg.Bytes()))
+	}
+	status := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// FetchRegistration gets the registration to sign from the collector at url.
+func FetchRegistration(ctx context.Context, url string) (*Registration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("collector: %s", resp.Status)
+	}
+	s := &SignedRegistration{}
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return nil, err
+	}
+	return &s.Registration, nil
+}
@@ -0,0 +1,230 @@
+package quorum
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+var (
+	alice   = backends.TestAccounts[0]
+	bob     = backends.TestAccounts[1]
+	charlie = backends.TestAccounts[2]
+	student = backends.TestAccounts[5]
+
+	owners   = []common.Address{alice.Address, bob.Address, charlie.Address}
+	contract = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+)
+
+func testRegistration() *Registration {
+	return NewRegistration(big.NewInt(1337), contract, student.Address, [32]byte{1}, []common.Address{bob.Address})
+}
+
+func TestSignRecover(t *testing.T) {
+	r := testRegistration()
+	sig, err := r.Sign(alice.Key)
+	require.NoError(t, err)
+	assert.Equal(t, alice.Address, sig.Signer)
+	assert.Contains(t, []byte{27, 28}, sig.Signature[crypto.RecoveryIDOffset])
+	assert.NoError(t, r.Verify(sig))
+
+	// the hash is the EIP-712 hash of the typed data given to wallets
+	hash, err := r.Hash()
+	require.NoError(t, err)
+	want, _, err := apitypes.TypedDataAndHash(r.TypedData())
+	require.NoError(t, err)
+	assert.Equal(t, common.BytesToHash(want), hash)
+
+	// any change of the registration or of its domain invalidates the signature
+	other := testRegistration()
+	other.Digest = common.Hash{2}
+	assert.ErrorIs(t, other.Verify(sig), ErrInvalidSignature)
+	other = testRegistration()
+	other.ChainID = (*hexutil.Big)(big.NewInt(1))
+	assert.ErrorIs(t, other.Verify(sig), ErrInvalidSignature)
+	other = testRegistration()
+	other.Contract = common.Address{1}
+	assert.ErrorIs(t, other.Verify(sig), ErrInvalidSignature)
+
+	forged := &Signature{Signer: bob.Address, Signature: sig.Signature}
+	assert.ErrorIs(t, r.Verify(forged), ErrInvalidSignature)
+	_, err = r.Recover(sig.Signature[:64])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func sign(t *testing.T, r *Registration, accounts ...backends.Account) *SignedRegistration {
+	t.Helper()
+	s := NewSignedRegistration(r)
+	for _, acc := range accounts {
+		sig, err := r.Sign(acc.Key)
+		require.NoError(t, err)
+		require.NoError(t, s.Add(sig))
+	}
+	return s
+}
+
+func TestVerifyQuorum(t *testing.T) {
+	r := testRegistration()
+	s := sign(t, r, charlie, alice)
+	// signatures are ordered by signer
+	assert.Equal(t, []common.Address{charlie.Address, alice.Address}, s.Signers())
+
+	assert.NoError(t, s.VerifyQuorum(owners, 2))
+	assert.ErrorIs(t, s.VerifyQuorum(owners, 3), ErrNoQuorum)
+	assert.ErrorIs(t, s.VerifyQuorum(owners[:2], 2), ErrNotOwner)
+
+	sig, err := r.Sign(alice.Key)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Add(sig), ErrDuplicatedSigner)
+	assert.ErrorIs(t, s.Add(&Signature{Signer: bob.Address, Signature: sig.Signature}), ErrInvalidSignature)
+
+	// signatures added without checks are still verified
+	s.Signatures = append(s.Signatures, &Signature{Signer: bob.Address, Signature: sig.Signature})
+	assert.ErrorIs(t, s.VerifyQuorum(owners, 2), ErrInvalidSignature)
+	s.Signatures[2] = sig
+	assert.ErrorIs(t, s.VerifyQuorum(owners, 2), ErrDuplicatedSigner)
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	r := testRegistration()
+	files := []string{filepath.Join(dir, "alice.json"), filepath.Join(dir, "bob.json"), filepath.Join(dir, "both.json")}
+	require.NoError(t, sign(t, r, alice).WriteFile(files[0]))
+	require.NoError(t, sign(t, r, bob).WriteFile(files[1]))
+	require.NoError(t, sign(t, r, alice, bob).WriteFile(files[2]))
+
+	merged, err := MergeFiles(files...)
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{alice.Address, bob.Address}, merged.Signers())
+	assert.Equal(t, r.Digest, merged.Digest)
+	assert.NoError(t, merged.VerifyQuorum(owners, 2))
+
+	other := testRegistration()
+	other.Subject = alice.Address
+	require.NoError(t, sign(t, other, charlie).WriteFile(filepath.Join(dir, "other.json")))
+	_, err = MergeFiles(files[0], filepath.Join(dir, "other.json"))
+	assert.ErrorIs(t, err, ErrDifferentRequests)
+}
+
+func TestRegistrationEqual(t *testing.T) {
+	r := testRegistration()
+	assert.True(t, r.Equal(testRegistration()))
+	tests := map[string]func(r *Registration){
+		"chain":     func(r *Registration) { r.ChainID = (*hexutil.Big)(big.NewInt(5)) },
+		"contract":  func(r *Registration) { r.Contract = alice.Address },
+		"subject":   func(r *Registration) { r.Subject = alice.Address },
+		"digest":    func(r *Registration) { r.Digest = common.Hash{2} },
+		"witnesses": func(r *Registration) { r.Witnesses = []common.Address{alice.Address} },
+	}
+	for name, change := range tests {
+		other := testRegistration()
+		change(other)
+		assert.False(t, r.Equal(other), name)
+	}
+}
+
+func TestMergeConcurrent(t *testing.T) {
+	r := testRegistration()
+	a, b := sign(t, r, alice), sign(t, r, bob)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, a.Merge(b))
+	}()
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, b.Merge(a))
+	}()
+	wg.Wait()
+	assert.Equal(t, []common.Address{alice.Address, bob.Address}, a.Signers())
+	assert.Equal(t, []common.Address{alice.Address, bob.Address}, b.Signers())
+}
+
+func TestCollector(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r := testRegistration()
+	c := NewCollector(r, owners, 2)
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	fetched, err := FetchRegistration(ctx, srv.URL)
+	require.NoError(t, err)
+	hash, err := fetched.Hash()
+	require.NoError(t, err)
+	want, err := r.Hash()
+	require.NoError(t, err)
+	require.Equal(t, want, hash)
+
+	sig, err := fetched.Sign(alice.Key)
+	require.NoError(t, err)
+	status, err := PostSignature(ctx, srv.URL, sig)
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{alice.Address}, status.Signers)
+	assert.False(t, status.Done)
+
+	_, err = PostSignature(ctx, srv.URL, sig)
+	assert.ErrorContains(t, err, "409")
+	sig, err = fetched.Sign(student.Key)
+	require.NoError(t, err)
+	_, err = PostSignature(ctx, srv.URL, sig)
+	assert.ErrorContains(t, err, "403")
+
+	sig, err = fetched.Sign(charlie.Key)
+	require.NoError(t, err)
+	status, err = PostSignature(ctx, srv.URL, sig)
+	require.NoError(t, err)
+	assert.True(t, status.Done)
+
+	signed, err := c.Wait(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, signed.VerifyQuorum(owners, 2))
+}
+
+func TestSubmit(t *testing.T) {
+	backend := backends.NewTestBackend()
+	defer backend.Close()
+	ctx := context.Background()
+
+	libs, err := backend.DeployLibs(backend.TransactOpts(alice.Key))
+	require.NoError(t, err)
+	backend.Commit()
+	addr, _, n, err := node.Deploy(backend.TransactOpts(alice.Key), backend, libs, node.LeafRole, owners, uint8(2))
+	require.NoError(t, err)
+	backend.Commit()
+
+	r := NewRegistration(backend.Blockchain().Config().ChainID, addr, student.Address, [32]byte{1}, nil)
+	s := sign(t, r, alice)
+	assert.ErrorIs(t, s.VerifyNode(ctx, nil, n), ErrNoQuorum)
+	s = sign(t, r, alice, bob)
+	require.NoError(t, s.VerifyNode(ctx, nil, n))
+
+	input, err := s.Calldata()
+	require.NoError(t, err)
+	assert.Equal(t, parsedSubmitABI.Methods[SubmitMethod].ID, input[:4])
+
+	// the deployed node contracts do not implement the entry point yet
+	sm := NewSubmitter(backend)
+	supported, err := sm.Supported(ctx, addr)
+	require.NoError(t, err)
+	assert.False(t, supported)
+	_, err = sm.Submit(ctx, backend.TransactOpts(student.Key), s, n)
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = sm.Submit(ctx, backend.TransactOpts(student.Key), sign(t, r, alice), n)
+	assert.ErrorIs(t, err, ErrNoQuorum)
+}
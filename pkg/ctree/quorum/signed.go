@@ -0,0 +1,186 @@
+package quorum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/ctree"
+)
+
+var (
+	ErrNotOwner          = errors.New("signer is not an owner")
+	ErrDuplicatedSigner  = errors.New("owner already signed")
+	ErrNoQuorum          = errors.New("not signed by a quorum of owners")
+	ErrDifferentRequests = errors.New("signatures of different registrations")
+)
+
+// SignedRegistration is a registration with the signatures of the owners
+// collected so far, sorted by signer.
+type SignedRegistration struct {
+	Registration
+	Signatures []*Signature `json:"signatures"`
+
+	mu sync.Mutex
+}
+
+// NewSignedRegistration creates a registration without signatures.
+func NewSignedRegistration(r *Registration) *SignedRegistration {
+	return &SignedRegistration{Registration: *r, Signatures: []*Signature{}}
+}
+
+// Add adds a valid signature of a new signer.
+func (s *SignedRegistration) Add(sig *Signature) error {
+	if err := s.Verify(sig); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.Signatures), func(i int) bool {
+		return bytes.Compare(s.Signatures[i].Signer[:], sig.Signer[:]) >= 0
+	})
+	if i < len(s.Signatures) && s.Signatures[i].Signer == sig.Signer {
+		return fmt.Errorf("%w: %s", ErrDuplicatedSigner, sig.Signer.Hex())
+	}
+	s.Signatures = append(s.Signatures, nil)
+	copy(s.Signatures[i+1:], s.Signatures[i:])
+	s.Signatures[i] = sig
+	return nil
+}
+
+// Signers returns the accounts that signed the registration.
+func (s *SignedRegistration) Signers() []common.Address {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	signers := make([]common.Address, len(s.Signatures))
+	for i, sig := range s.Signatures {
+		signers[i] = sig.Signer
+	}
+	return signers
+}
+
+// Merge adds the signatures of another copy of the same registration,
+// skipping the signers that already signed.
+func (s *SignedRegistration) Merge(other *SignedRegistration) error {
+	if !s.Registration.Equal(&other.Registration) {
+		return ErrDifferentRequests
+	}
+	other.mu.Lock()
+	signatures := append([]*Signature(nil), other.Signatures...)
+	other.mu.Unlock()
+	for _, sig := range signatures {
+		if err := s.Add(sig); err != nil && !errors.Is(err, ErrDuplicatedSigner) {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyQuorum checks that the registration is signed by a quorum of the
+// owners, and that all signatures are valid signatures of distinct owners.
+func (s *SignedRegistration) VerifyQuorum(owners []common.Address, quorum uint8) error {
+	isOwner := make(map[common.Address]bool, len(owners))
+	for _, o := range owners {
+		isOwner[o] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	signed := make(map[common.Address]bool, len(s.Signatures))
+	for _, sig := range s.Signatures {
+		if err := s.Verify(sig); err != nil {
+			return fmt.Errorf("%w of %s", err, sig.Signer.Hex())
+		}
+		if !isOwner[sig.Signer] {
+			return fmt.Errorf("%w: %s", ErrNotOwner, sig.Signer.Hex())
+		}
+		if signed[sig.Signer] {
+			return fmt.Errorf("%w: %s", ErrDuplicatedSigner, sig.Signer.Hex())
+		}
+		signed[sig.Signer] = true
+	}
+	if len(signed) < int(quorum) {
+		return fmt.Errorf("%w: %d of %d", ErrNoQuorum, len(signed), quorum)
+	}
+	return nil
+}
+
+// OwnersReader reads the owners of a node.
+type OwnersReader interface {
+	GetOwnersContext(ctx context.Context, opts *ctree.CallOpts) ([]common.Address, error)
+	QuorumContext(ctx context.Context, opts *ctree.CallOpts) (uint8, error)
+}
+
+// VerifyNode checks that the registration is signed by a quorum of the
+// current owners of the node.
+func (s *SignedRegistration) VerifyNode(ctx context.Context, opts *ctree.CallOpts, n OwnersReader) error {
+	owners, err := n.GetOwnersContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+	quorum, err := n.QuorumContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return s.VerifyQuorum(owners, quorum)
+}
+
+// ReadFile reads a signed registration and checks its signatures.
+func ReadFile(filename string) (*SignedRegistration, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &SignedRegistration{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	signatures := s.Signatures
+	s.Signatures = []*Signature{}
+	for _, sig := range signatures {
+		if err := s.Add(sig); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return s, nil
+}
+
+// WriteFile writes the signed registration as JSON.
+func (s *SignedRegistration) WriteFile(filename string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// MergeFiles merges the signatures of the registrations in the files,
+// e.g. each signed by a different owner.
+func MergeFiles(filenames ...string) (*SignedRegistration, error) {
+	var merged *SignedRegistration
+	for _, f := range filenames {
+		s, err := ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = s
+			continue
+		}
+		if err := merged.Merge(s); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+	}
+	if merged == nil {
+		return nil, errors.New("no files to merge")
+	}
+	return merged, nil
+}
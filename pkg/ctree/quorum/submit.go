@@ -0,0 +1,102 @@
+package quorum
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// SubmitMethod is the node entry point registering a credential signed
+// off-chain by a quorum of owners. The node contracts of go-credbindings
+// v1.0.0 do not implement it yet, so submissions to them are refused with
+// ErrUnsupported: the signed registration and its calldata can still be
+// used to compare the size of a single submission with one
+// registerCredential transaction per owner.
+const SubmitMethod = "registerCredentialWithSignatures"
+
+const submitABI = `[{"type":"function","name":"registerCredentialWithSignatures","stateMutability":"nonpayable","inputs":[{"name":"subject","type":"address"},{"name":"digest","type":"bytes32"},{"name":"witnesses","type":"address[]"},{"name":"signatures","type":"bytes[]"}],"outputs":[]}]`
+
+var (
+	ErrNoSignatures = errors.New("registration has no signatures")
+	ErrUnsupported  = errors.New("node contract does not implement " + SubmitMethod)
+)
+
+var parsedSubmitABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(submitABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Calldata returns the input of the submission of the signed registration,
+// with the signatures ordered by signer.
+func (s *SignedRegistration) Calldata() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Signatures) == 0 {
+		return nil, ErrNoSignatures
+	}
+	signatures := make([][]byte, len(s.Signatures))
+	for i, sig := range s.Signatures {
+		signatures[i] = sig.Signature
+	}
+	return parsedSubmitABI.Pack(SubmitMethod, s.Subject, s.Digest, s.Witnesses, signatures)
+}
+
+// Submitter relays signed registrations to their node contract. Any
+// account can submit them, not only the owners.
+type Submitter struct {
+	backend bind.ContractBackend
+}
+
+// NewSubmitter creates a submitter sending transactions through the backend.
+func NewSubmitter(backend bind.ContractBackend) *Submitter {
+	return &Submitter{backend: backend}
+}
+
+// Supported reports whether the contract implements SubmitMethod, that is
+// whether the dispatcher of its code pushes the selector of the method.
+func (sm *Submitter) Supported(ctx context.Context, contract common.Address) (bool, error) {
+	code, err := sm.backend.CodeAt(ctx, contract, nil)
+	if err != nil {
+		return false, err
+	}
+	selector := parsedSubmitABI.Methods[SubmitMethod].ID
+	return bytes.Contains(code, append([]byte{byte(vm.PUSH4)}, selector...)), nil
+}
+
+// Submit sends the signed registration to its node contract in a single
+// transaction, once verified against the current owners and quorum of the
+// node. It fails with ErrUnsupported if the node does not implement
+// SubmitMethod.
+func (sm *Submitter) Submit(ctx context.Context, opts *bind.TransactOpts, s *SignedRegistration, n OwnersReader) (*types.Transaction, error) {
+	if err := s.VerifyNode(ctx, nil, n); err != nil {
+		return nil, err
+	}
+	supported, err := sm.Supported(ctx, s.Contract)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return nil, ErrUnsupported
+	}
+	input, err := s.Calldata()
+	if err != nil {
+		return nil, err
+	}
+	c := bind.NewBoundContract(s.Contract, parsedSubmitABI, sm.backend, sm.backend, sm.backend)
+	if opts.Context == nil {
+		o := *opts
+		o.Context = ctx
+		opts = &o
+	}
+	return c.RawTransact(opts, input)
+}
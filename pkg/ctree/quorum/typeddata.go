@@ -0,0 +1,165 @@
+// Package quorum collects the signatures of the owners of a node on a
+// credential registration off-chain, as EIP-712 typed data, so that a quorum
+// signed registration can be submitted in a single transaction instead of a
+// registerCredential transaction per owner.
+package quorum
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	// DomainName and DomainVersion identify the signing domain of the node contracts.
+	DomainName    = "CredBench Node"
+	DomainVersion = "1"
+
+	// PrimaryType is the EIP-712 type of a credential registration.
+	PrimaryType = "CredentialRegistration"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+var registrationTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	PrimaryType: {
+		{Name: "subject", Type: "address"},
+		{Name: "digest", Type: "bytes32"},
+		{Name: "witnesses", Type: "address[]"},
+	},
+}
+
+// Registration is the registration of a credential by a node, as done by
+// the registerCredential method of the node contract.
+type Registration struct {
+	ChainID   *hexutil.Big     `json:"chainId"`
+	Contract  common.Address   `json:"contract"`
+	Subject   common.Address   `json:"subject"`
+	Digest    common.Hash      `json:"digest"`
+	Witnesses []common.Address `json:"witnesses"`
+}
+
+// NewRegistration creates the registration of a credential by the node
+// deployed at contract on the chain with the given id.
+func NewRegistration(chainID *big.Int, contract, subject common.Address, digest [32]byte, witnesses []common.Address) *Registration {
+	if witnesses == nil {
+		witnesses = []common.Address{}
+	}
+	return &Registration{
+		ChainID:   (*hexutil.Big)(chainID),
+		Contract:  contract,
+		Subject:   subject,
+		Digest:    digest,
+		Witnesses: witnesses,
+	}
+}
+
+// TypedData returns the registration as EIP-712 typed data, which can be
+// signed by wallets with eth_signTypedData_v4.
+func (r *Registration) TypedData() apitypes.TypedData {
+	witnesses := make([]interface{}, len(r.Witnesses))
+	for i, w := range r.Witnesses {
+		witnesses[i] = w.Hex()
+	}
+	return apitypes.TypedData{
+		Types:       registrationTypes,
+		PrimaryType: PrimaryType,
+		Domain: apitypes.TypedDataDomain{
+			Name:              DomainName,
+			Version:           DomainVersion,
+			ChainId:           (*math.HexOrDecimal256)(r.ChainID),
+			VerifyingContract: r.Contract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"subject":   r.Subject.Hex(),
+			"digest":    r.Digest.Hex(),
+			"witnesses": witnesses,
+		},
+	}
+}
+
+// Hash returns the EIP-712 hash of the registration signed by the owners.
+func (r *Registration) Hash() (common.Hash, error) {
+	hash, _, err := apitypes.TypedDataAndHash(r.TypedData())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// Equal reports whether both registrations have the same typed data hash,
+// i.e. whether a signature of one is a signature of the other.
+func (r *Registration) Equal(other *Registration) bool {
+	h, err := r.Hash()
+	if err != nil {
+		return false
+	}
+	o, err := other.Hash()
+	return err == nil && h == o
+}
+
+// Signature is the signature of a registration by an owner, in the
+// [R || S || V] format of eth_signTypedData, with V being 27 or 28.
+type Signature struct {
+	Signer    common.Address `json:"signer"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// Sign signs the registration with the key of an owner.
+func (r *Registration) Sign(key *ecdsa.PrivateKey) (*Signature, error) {
+	hash, err := r.Hash()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return &Signature{Signer: crypto.PubkeyToAddress(key.PublicKey), Signature: sig}, nil
+}
+
+// Recover returns the account that signed the registration.
+func (r *Registration) Recover(signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, ErrInvalidSignature
+	}
+	hash, err := r.Hash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, ErrInvalidSignature
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Verify checks that the signature was made by its signer.
+func (r *Registration) Verify(sig *Signature) error {
+	signer, err := r.Recover(sig.Signature)
+	if err != nil {
+		return err
+	}
+	if signer != sig.Signer {
+		return ErrInvalidSignature
+	}
+	return nil
+}
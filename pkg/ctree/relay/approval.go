@@ -0,0 +1,170 @@
+// Package relay submits the credential approvals of students on their
+// behalf, so that students do not need to hold ether.
+//
+// The node contracts only accept approvals sent by the subject of the
+// credential and have no trusted forwarder, so an approval is a signed
+// approveCredential transaction of the student: the student signs it
+// offline, with a gas price chosen by the relayer, and the relayer funds
+// its exact cost from a sponsor account before broadcasting it.
+package relay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ApproveMethod is the method of the node, course and faculty contracts
+// approving a credential, which must be sent by its subject.
+const ApproveMethod = "approveCredential"
+
+const approveABI = `[{"type":"function","name":"approveCredential","stateMutability":"nonpayable","inputs":[{"name":"digest","type":"bytes32"}],"outputs":[]}]`
+
+var (
+	ErrNotApproval  = errors.New("transaction is not a credential approval")
+	ErrInvalidTx    = errors.New("invalid approval transaction")
+	ErrGasPriceLow  = errors.New("gas price below the relayer price")
+	ErrGasLimitHigh = errors.New("gas limit above the relayer limit")
+)
+
+var parsedApproveABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(approveABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Approval is the approval of a credential signed by its subject.
+type Approval struct {
+	Tx       *types.Transaction
+	Subject  common.Address
+	Contract common.Address
+	Digest   common.Hash
+}
+
+// Hash returns the hash of the approval transaction.
+func (a *Approval) Hash() common.Hash {
+	return a.Tx.Hash()
+}
+
+// Cost returns the maximum amount of wei the subject pays for the approval.
+func (a *Approval) Cost() *big.Int {
+	return new(big.Int).Mul(a.Tx.GasPrice(), new(big.Int).SetUint64(a.Tx.Gas()))
+}
+
+// ApprovalBackend is the read-only access to the chain a student needs to
+// prepare an approval.
+type ApprovalBackend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+}
+
+// Nonces hands out the nonces of the approvals signed by the subjects.
+// The pending nonce of a subject does not count the approvals queued by the
+// relayer and not yet broadcast, so concurrent approvals of a subject would
+// otherwise share it.
+type Nonces struct {
+	backend ApprovalBackend
+
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+// NewNonces creates the nonces of the subjects of the given chain.
+func NewNonces(backend ApprovalBackend) *Nonces {
+	return &Nonces{backend: backend, next: make(map[common.Address]uint64)}
+}
+
+// Next returns the next nonce of the subject, the pending nonce of the
+// subject if it is ahead of the nonces handed out.
+func (n *Nonces) Next(ctx context.Context, subject common.Address) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	nonce, err := n.backend.PendingNonceAt(ctx, subject)
+	if err != nil {
+		return 0, err
+	}
+	if next := n.next[subject]; next > nonce {
+		nonce = next
+	}
+	n.next[subject] = nonce + 1
+	return nonce, nil
+}
+
+// SignApproval signs the approval of the credential digest by the contract,
+// with the key of its subject.
+func SignApproval(key *ecdsa.PrivateKey, signer types.Signer, nonce uint64, contract common.Address, digest [32]byte, gasLimit uint64, gasPrice *big.Int) (*Approval, error) {
+	input, err := parsedApproveABI.Pack(ApproveMethod, digest)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &contract,
+		Gas:      gasLimit,
+		GasPrice: gasPrice,
+		Data:     input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Approval{
+		Tx:       tx,
+		Subject:  crypto.PubkeyToAddress(key.PublicKey),
+		Contract: contract,
+		Digest:   digest,
+	}, nil
+}
+
+// PrepareApproval signs the approval of the credential digest with the
+// given nonce of the subject and the gas it needs, which does not require
+// the subject to have a balance. Concurrent approvals of a subject need
+// distinct nonces, e.g. handed out by Nonces.
+func PrepareApproval(ctx context.Context, backend ApprovalBackend, key *ecdsa.PrivateKey, signer types.Signer, nonce uint64, contract common.Address, digest [32]byte, gasPrice *big.Int) (*Approval, error) {
+	subject := crypto.PubkeyToAddress(key.PublicKey)
+	input, err := parsedApproveABI.Pack(ApproveMethod, digest)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := backend.EstimateGas(ctx, ethereum.CallMsg{From: subject, To: &contract, Data: input})
+	if err != nil {
+		return nil, err
+	}
+	return SignApproval(key, signer, nonce, contract, digest, gas, gasPrice)
+}
+
+// DecodeApproval checks that the transaction is a signed approveCredential
+// call without value and returns its approval.
+func DecodeApproval(signer types.Signer, tx *types.Transaction) (*Approval, error) {
+	if tx.To() == nil || tx.Value().Sign() != 0 {
+		return nil, ErrNotApproval
+	}
+	method := parsedApproveABI.Methods[ApproveMethod]
+	data := tx.Data()
+	if len(data) < 4 || string(data[:4]) != string(method.ID) {
+		return nil, ErrNotApproval
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil || len(args) != 1 {
+		return nil, ErrNotApproval
+	}
+	digest, ok := args[0].([32]byte)
+	if !ok {
+		return nil, ErrNotApproval
+	}
+	subject, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, ErrInvalidTx
+	}
+	return &Approval{Tx: tx, Subject: subject, Contract: *tx.To(), Digest: digest}, nil
+}
@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Request is an approval sent to a relayer, as a raw signed transaction.
+type Request struct {
+	Tx hexutil.Bytes `json:"tx"`
+}
+
+// Response is a queued approval.
+type Response struct {
+	Hash     common.Hash    `json:"hash"`
+	Subject  common.Address `json:"subject"`
+	Contract common.Address `json:"contract"`
+	Digest   common.Hash    `json:"digest"`
+}
+
+type statsResponse struct {
+	Stats
+	Throughput float64 `json:"throughput"`
+}
+
+// Handler serves the relayer over HTTP:
+//
+//	POST /approvals a Request with an approval, queued for the next batch
+//	GET  /stats     the statistics of the relayed approvals
+func Handler(r *Relayer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/approvals", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body := &Request{}
+		if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(body.Tx); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a, err := r.Enqueue(req.Context(), tx)
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(&Response{Hash: a.Hash(), Subject: a.Subject, Contract: a.Contract, Digest: a.Digest})
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s := r.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&statsResponse{s, s.Throughput()})
+	})
+	return mux
+}
+
+// PostApproval sends the approval to the relayer at url.
+func PostApproval(ctx context.Context, url string, a *Approval) (*Response, error) {
+	raw, err := a.Tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&Request{Tx: raw})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/approvals", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		msg := new(bytes.Buffer)
+		_, _ = msg.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("relayer: %s: %s", resp.Status, bytes.TrimSpace(msg.Bytes()))
+	}
+	r := &Response{}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
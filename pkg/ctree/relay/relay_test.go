@@ -0,0 +1,316 @@
+package relay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+var (
+	evaluator = backends.TestAccounts[0]
+	sponsor   = backends.TestAccounts[9]
+)
+
+type testChain struct {
+	*backends.TestBackend
+	node   *node.Node
+	signer types.Signer
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	backend := backends.NewTestBackend()
+	t.Cleanup(func() { backend.Close() })
+
+	libs, err := backend.DeployLibs(backend.TransactOpts(evaluator.Key))
+	require.NoError(t, err)
+	backend.Commit()
+	_, _, n, err := node.Deploy(backend.TransactOpts(evaluator.Key), backend, libs, node.LeafRole, []common.Address{evaluator.Address}, uint8(1))
+	require.NoError(t, err)
+	backend.Commit()
+	return &testChain{backend, n, types.LatestSigner(backend.Blockchain().Config())}
+}
+
+func (c *testChain) register(t *testing.T, subject common.Address, digest [32]byte) {
+	t.Helper()
+	_, err := c.node.RegisterCredential(c.TransactOpts(evaluator.Key), subject, digest, nil)
+	require.NoError(t, err)
+	c.Commit()
+}
+
+func (c *testChain) newRelayer() *Relayer {
+	r := NewRelayer(c, c.signer, func() (*bind.TransactOpts, error) {
+		return c.TransactOpts(sponsor.Key), nil
+	})
+	r.Wait = func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		c.Commit()
+		return c.TransactionReceipt(ctx, tx.Hash())
+	}
+	return r
+}
+
+func (c *testChain) prepare(t *testing.T, key *ecdsa.PrivateKey, digest [32]byte, nonce uint64) *Approval {
+	t.Helper()
+	gasPrice, err := c.SuggestGasPrice(context.Background())
+	require.NoError(t, err)
+	a, err := SignApproval(key, c.signer, nonce, c.node.Address(), digest, 200000, gasPrice)
+	require.NoError(t, err)
+	return a
+}
+
+func TestDecodeApproval(t *testing.T) {
+	c := newTestChain(t)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	a := c.prepare(t, key, [32]byte{1}, 0)
+
+	decoded, err := DecodeApproval(c.signer, a.Tx)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), decoded.Subject)
+	assert.Equal(t, c.node.Address(), decoded.Contract)
+	assert.Equal(t, common.Hash{1}, decoded.Digest)
+
+	other, err := types.SignNewTx(key, c.signer, &types.LegacyTx{To: &a.Contract, Gas: 21000, GasPrice: big.NewInt(1), Data: []byte{1, 2, 3, 4}})
+	require.NoError(t, err)
+	_, err = DecodeApproval(c.signer, other)
+	assert.ErrorIs(t, err, ErrNotApproval)
+}
+
+func TestRelayBatch(t *testing.T) {
+	c := newTestChain(t)
+	ctx := context.Background()
+	r := c.newRelayer()
+
+	// students without ether
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys[i] = key
+		student := crypto.PubkeyToAddress(key.PublicKey)
+		c.register(t, student, [32]byte{byte(i + 1)})
+		balance, err := c.BalanceAt(ctx, student, nil)
+		require.NoError(t, err)
+		require.Zero(t, balance.Sign())
+	}
+	// a student with two credentials to approve in the same batch
+	second := crypto.PubkeyToAddress(keys[0].PublicKey)
+	c.register(t, second, [32]byte{9})
+
+	gasPrice, err := c.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+	var approvals []*Approval
+	for i, key := range keys {
+		a, err := PrepareApproval(ctx, c, key, c.signer, 0, c.node.Address(), [32]byte{byte(i + 1)}, gasPrice)
+		require.NoError(t, err)
+		_, err = r.Enqueue(ctx, a.Tx)
+		require.NoError(t, err)
+		approvals = append(approvals, a)
+	}
+	a := c.prepare(t, keys[0], [32]byte{9}, 1)
+	_, err = r.Enqueue(ctx, a.Tx)
+	require.NoError(t, err)
+	approvals = append(approvals, a)
+	assert.Equal(t, len(approvals), r.Stats().Pending)
+
+	results := r.RelayBatch(ctx, approvals)
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		require.NotNil(t, res.Funding)
+		assert.NoError(t, c.node.VerifyCredential(ctx, true, nil, res.Approval.Subject, res.Approval.Digest))
+	}
+	stats := r.Stats()
+	assert.Equal(t, 4, stats.Relayed)
+	assert.Equal(t, 0, stats.Failed)
+	assert.Equal(t, 1, stats.Batches)
+	assert.Equal(t, 0, stats.Pending)
+	assert.Positive(t, stats.Funded.Sign())
+	assert.Positive(t, stats.GasUsed)
+}
+
+func TestRelayBatchFundingFails(t *testing.T) {
+	c := newTestChain(t)
+	ctx := context.Background()
+	errSponsor := errors.New("sponsor locked")
+	r := NewRelayer(c, c.signer, func() (*bind.TransactOpts, error) { return nil, errSponsor })
+	r.Wait = c.newRelayer().Wait
+
+	// a student without ether, and one that pays for the approval
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	poor := crypto.PubkeyToAddress(key.PublicKey)
+	rich := backends.TestAccounts[5]
+	c.register(t, poor, [32]byte{1})
+	c.register(t, rich.Address, [32]byte{2})
+	nonce, err := c.PendingNonceAt(ctx, rich.Address)
+	require.NoError(t, err)
+	approvals := []*Approval{c.prepare(t, key, [32]byte{1}, 0), c.prepare(t, rich.Key, [32]byte{2}, nonce)}
+	for _, a := range approvals {
+		_, err := r.Enqueue(ctx, a.Tx)
+		require.NoError(t, err)
+	}
+
+	results := r.RelayBatch(ctx, approvals)
+	assert.ErrorIs(t, results[0].Err, ErrFundingFails)
+	assert.ErrorContains(t, results[0].Err, errSponsor.Error())
+	assert.NoError(t, results[1].Err)
+	assert.NoError(t, c.node.VerifyCredential(ctx, true, nil, rich.Address, [32]byte{2}))
+	stats := r.Stats()
+	assert.Equal(t, 1, stats.Relayed)
+	assert.Equal(t, 1, stats.Failed)
+	assert.Equal(t, 0, stats.Pending)
+	assert.Zero(t, stats.Funded.Sign())
+}
+
+func TestRunStopped(t *testing.T) {
+	c := newTestChain(t)
+	r := c.newRelayer()
+	r.Interval = time.Hour
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	student := crypto.PubkeyToAddress(key.PublicKey)
+	c.register(t, student, [32]byte{1})
+	c.register(t, student, [32]byte{2})
+	for i := 0; i < 2; i++ {
+		_, err := r.Enqueue(context.Background(), c.prepare(t, key, [32]byte{byte(i + 1)}, uint64(i)).Tx)
+		require.NoError(t, err)
+	}
+
+	// the queued approvals fail instead of being dropped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, r.Run(ctx), context.Canceled)
+	for i := 0; i < 2; i++ {
+		res := <-r.Results()
+		assert.ErrorIs(t, res.Err, ErrStopped)
+	}
+	stats := r.Stats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, 2, stats.Failed)
+	assert.Equal(t, 0, stats.Relayed)
+}
+
+func TestConcurrentApprovals(t *testing.T) {
+	c := newTestChain(t)
+	ctx := context.Background()
+	r := c.newRelayer()
+	nonces := NewNonces(c)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	student := crypto.PubkeyToAddress(key.PublicKey)
+	digests := [][32]byte{{1}, {2}}
+	for _, d := range digests {
+		c.register(t, student, d)
+	}
+	gasPrice, err := c.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+
+	approvals := make([]*Approval, len(digests))
+	var wg sync.WaitGroup
+	for i := range digests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nonce, err := nonces.Next(ctx, student)
+			if !assert.NoError(t, err) {
+				return
+			}
+			a, err := PrepareApproval(ctx, c, key, c.signer, nonce, c.node.Address(), digests[i], gasPrice)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = r.Enqueue(ctx, a.Tx)
+			assert.NoError(t, err)
+			approvals[i] = a
+		}(i)
+	}
+	wg.Wait()
+	require.NotNil(t, approvals[0])
+	require.NotNil(t, approvals[1])
+	assert.NotEqual(t, approvals[0].Tx.Nonce(), approvals[1].Tx.Nonce())
+
+	results := r.RelayBatch(ctx, approvals)
+	for _, res := range results {
+		require.NoError(t, res.Err)
+	}
+	for _, d := range digests {
+		assert.NoError(t, c.node.VerifyCredential(ctx, true, nil, student, d))
+	}
+
+	// the next approval continues after the relayed ones
+	nonce, err := nonces.Next(ctx, student)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(digests)), nonce)
+}
+
+func TestEnqueueRejects(t *testing.T) {
+	c := newTestChain(t)
+	ctx := context.Background()
+	r := c.newRelayer()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	student := crypto.PubkeyToAddress(key.PublicKey)
+	c.register(t, student, [32]byte{1})
+
+	// credentials of another subject or not registered fail on-chain
+	_, err = r.Enqueue(ctx, c.prepare(t, key, [32]byte{2}, 0).Tx)
+	assert.ErrorIs(t, err, ErrApprovalFails)
+
+	r.MaxGas = 100000
+	_, err = r.Enqueue(ctx, c.prepare(t, key, [32]byte{1}, 0).Tx)
+	assert.ErrorIs(t, err, ErrGasLimitHigh)
+
+	r.MaxGas = 0
+	r.GasPrice = new(big.Int).Lsh(big.NewInt(1), 64)
+	_, err = r.Enqueue(ctx, c.prepare(t, key, [32]byte{1}, 0).Tx)
+	assert.ErrorIs(t, err, ErrGasPriceLow)
+}
+
+func TestHandler(t *testing.T) {
+	c := newTestChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := c.newRelayer()
+	r.BatchSize = 1
+	srv := httptest.NewServer(Handler(r))
+	defer srv.Close()
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	student := crypto.PubkeyToAddress(key.PublicKey)
+	c.register(t, student, [32]byte{1})
+
+	a := c.prepare(t, key, [32]byte{1}, 0)
+	resp, err := PostApproval(ctx, srv.URL, a)
+	require.NoError(t, err)
+	assert.Equal(t, a.Hash(), resp.Hash)
+	assert.Equal(t, student, resp.Subject)
+
+	res := <-r.Results()
+	require.NoError(t, res.Err)
+	assert.Equal(t, a.Hash(), res.Receipt.TxHash)
+
+	_, err = PostApproval(ctx, srv.URL, c.prepare(t, key, [32]byte{2}, 1))
+	assert.ErrorContains(t, err, "400")
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,344 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	ErrQueueFull     = errors.New("relayer queue is full")
+	ErrStopped       = errors.New("relayer stopped")
+	ErrApprovalFails = errors.New("approval would fail")
+	ErrFundingFails  = errors.New("funding of the subject failed")
+)
+
+// Backend is the access to the chain needed by the relayer.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// TxOptsFunc returns the transaction options of the sponsor account
+// funding the approvals.
+type TxOptsFunc func() (*bind.TransactOpts, error)
+
+// Result is the outcome of a relayed approval.
+type Result struct {
+	Approval *Approval
+	Funding  *types.Transaction
+	Receipt  *types.Receipt
+	Err      error
+}
+
+// Stats are the throughput and cost of the relayed approvals.
+type Stats struct {
+	Relayed  int           `json:"relayed"`
+	Failed   int           `json:"failed"`
+	Batches  int           `json:"batches"`
+	Pending  int           `json:"pending"`
+	Funded   *big.Int      `json:"funded"`
+	GasUsed  uint64        `json:"gasUsed"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Throughput returns the number of relayed approvals per second.
+func (s Stats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Relayed) / s.Duration.Seconds()
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("relayed: %d failed: %d batches: %d funded: %s wei gas used: %d throughput: %.2f approvals/s",
+		s.Relayed, s.Failed, s.Batches, s.Funded, s.GasUsed, s.Throughput())
+}
+
+// Relayer queues the approvals of students, funds their cost from a
+// sponsor account and broadcasts them in batches.
+type Relayer struct {
+	backend Backend
+	signer  types.Signer
+	txOpts  TxOptsFunc
+
+	// GasPrice is the minimum gas price of the approvals, and MaxGas
+	// the maximum gas limit the sponsor funds per approval.
+	GasPrice *big.Int
+	MaxGas   uint64
+	// BatchSize is the maximum number of approvals relayed at once, and
+	// Interval the longest time an approval waits for a batch to fill.
+	BatchSize int
+	Interval  time.Duration
+	// Wait waits for a transaction to be mined, defaults to bind.WaitMined.
+	Wait func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error)
+
+	queue   chan *Approval
+	results chan *Result
+	mu      sync.Mutex
+	stats   Stats
+}
+
+// NewRelayer creates a relayer of the approvals signed for the chain of
+// signer, funded by the account of txOpts.
+func NewRelayer(backend Backend, signer types.Signer, txOpts TxOptsFunc) *Relayer {
+	r := &Relayer{
+		backend:   backend,
+		signer:    signer,
+		txOpts:    txOpts,
+		MaxGas:    500000,
+		BatchSize: 32,
+		Interval:  time.Second,
+		queue:     make(chan *Approval, 1024),
+		results:   make(chan *Result, 1024),
+		stats:     Stats{Funded: new(big.Int)},
+	}
+	r.Wait = func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		return bind.WaitMined(ctx, r.backend, tx)
+	}
+	return r
+}
+
+// Check verifies that the transaction is an approval the relayer accepts
+// and that it would succeed if sent now.
+func (r *Relayer) Check(ctx context.Context, tx *types.Transaction) (*Approval, error) {
+	a, err := DecodeApproval(r.signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	if r.GasPrice != nil && tx.GasPrice().Cmp(r.GasPrice) < 0 {
+		return nil, ErrGasPriceLow
+	}
+	if r.MaxGas > 0 && tx.Gas() > r.MaxGas {
+		return nil, ErrGasLimitHigh
+	}
+	msg := ethereum.CallMsg{From: a.Subject, To: &a.Contract, Gas: tx.Gas(), Data: tx.Data()}
+	if _, err := r.backend.CallContract(ctx, msg, nil); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrApprovalFails, err)
+	}
+	return a, nil
+}
+
+// Enqueue checks the approval transaction and queues it for the next batch.
+func (r *Relayer) Enqueue(ctx context.Context, tx *types.Transaction) (*Approval, error) {
+	a, err := r.Check(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case r.queue <- a:
+		r.mu.Lock()
+		r.stats.Pending++
+		r.mu.Unlock()
+		return a, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Results returns the outcome of the approvals relayed by Run. Results
+// are dropped if they are not read.
+func (r *Relayer) Results() <-chan *Result {
+	return r.results
+}
+
+// Stats returns the statistics of the relayed approvals.
+func (r *Relayer) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.Funded = new(big.Int).Set(r.stats.Funded)
+	return s
+}
+
+// Run relays the queued approvals in batches until the context is done.
+// The approvals still queued when it is done fail with ErrStopped.
+func (r *Relayer) Run(ctx context.Context) error {
+	var batch []*Approval
+	timer := time.NewTimer(r.Interval)
+	defer timer.Stop()
+	for {
+		flush := false
+		select {
+		case <-ctx.Done():
+			r.stop(batch)
+			return ctx.Err()
+		case a := <-r.queue:
+			batch = append(batch, a)
+			flush = len(batch) >= r.BatchSize
+		case <-timer.C:
+			flush = len(batch) > 0
+			timer.Reset(r.Interval)
+		}
+		if !flush {
+			continue
+		}
+		r.send(r.RelayBatch(ctx, batch))
+		batch = nil
+	}
+}
+
+// send delivers the results, dropping the ones that are not read.
+func (r *Relayer) send(results []*Result) {
+	for _, res := range results {
+		select {
+		case r.results <- res:
+		default:
+		}
+	}
+}
+
+// stop fails the approvals of the batch and of the queue with ErrStopped.
+func (r *Relayer) stop(batch []*Approval) {
+	for drained := false; !drained; {
+		select {
+		case a := <-r.queue:
+			batch = append(batch, a)
+		default:
+			drained = true
+		}
+	}
+	results := make([]*Result, len(batch))
+	for i, a := range batch {
+		results[i] = &Result{Approval: a, Err: ErrStopped}
+	}
+	r.mu.Lock()
+	r.stats.Pending -= len(batch)
+	if r.stats.Pending < 0 {
+		r.stats.Pending = 0
+	}
+	r.stats.Failed += len(batch)
+	r.mu.Unlock()
+	r.send(results)
+}
+
+// RelayBatch funds the subjects of the approvals lacking the balance to
+// pay for them, and broadcasts the approvals once the fundings are mined.
+// The error of each approval, including the failure to fund its subject,
+// is given in its result.
+func (r *Relayer) RelayBatch(ctx context.Context, batch []*Approval) []*Result {
+	start := time.Now()
+	results := make([]*Result, len(batch))
+	costs := make(map[common.Address]*big.Int)
+	var subjects []common.Address
+	for i, a := range batch {
+		results[i] = &Result{Approval: a}
+		if _, ok := costs[a.Subject]; !ok {
+			costs[a.Subject] = new(big.Int)
+			subjects = append(subjects, a.Subject)
+		}
+		costs[a.Subject].Add(costs[a.Subject], a.Cost())
+	}
+
+	funded := new(big.Int)
+	fundings := make(map[common.Address]*types.Transaction)
+	amounts := make(map[common.Address]*big.Int)
+	failed := make(map[common.Address]error)
+	for _, s := range subjects {
+		tx, amount, err := r.fund(ctx, s, costs[s])
+		if err != nil {
+			failed[s] = fmt.Errorf("%w: %s: %v", ErrFundingFails, s.Hex(), err)
+			continue
+		}
+		if tx != nil {
+			fundings[s] = tx
+			amounts[s] = amount
+		}
+	}
+	for s, tx := range fundings {
+		receipt, err := r.Wait(ctx, tx)
+		switch {
+		case err != nil:
+			failed[s] = fmt.Errorf("%w: %s: %v", ErrFundingFails, s.Hex(), err)
+		case receipt.Status != types.ReceiptStatusSuccessful:
+			failed[s] = fmt.Errorf("%w: %s", ErrFundingFails, s.Hex())
+		default:
+			funded.Add(funded, amounts[s])
+		}
+	}
+
+	// the approvals of a subject are broadcast in the order of their nonces,
+	// whatever the order they were queued in
+	sent := make([]*Result, len(results))
+	copy(sent, results)
+	sort.SliceStable(sent, func(i, j int) bool {
+		return sent[i].Approval.Tx.Nonce() < sent[j].Approval.Tx.Nonce()
+	})
+	for _, res := range sent {
+		res.Funding = fundings[res.Approval.Subject]
+		if err, ok := failed[res.Approval.Subject]; ok {
+			res.Err = err
+			continue
+		}
+		res.Err = r.backend.SendTransaction(ctx, res.Approval.Tx)
+	}
+	var gasUsed uint64
+	for _, res := range sent {
+		if res.Err != nil {
+			continue
+		}
+		res.Receipt, res.Err = r.Wait(ctx, res.Approval.Tx)
+		if res.Err == nil {
+			gasUsed += res.Receipt.GasUsed
+			if res.Receipt.Status != types.ReceiptStatusSuccessful {
+				res.Err = ErrApprovalFails
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Batches++
+	r.stats.Pending -= len(batch)
+	if r.stats.Pending < 0 {
+		r.stats.Pending = 0
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			r.stats.Failed++
+		} else {
+			r.stats.Relayed++
+		}
+	}
+	r.stats.Funded.Add(r.stats.Funded, funded)
+	r.stats.GasUsed += gasUsed
+	r.stats.Duration += time.Since(start)
+	return results
+}
+
+// fund transfers to the subject the missing balance to pay for cost.
+func (r *Relayer) fund(ctx context.Context, subject common.Address, cost *big.Int) (*types.Transaction, *big.Int, error) {
+	balance, err := r.backend.BalanceAt(ctx, subject, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if balance.Cmp(cost) >= 0 {
+		return nil, nil, nil
+	}
+	amount := new(big.Int).Sub(cost, balance)
+	opts, err := r.txOpts()
+	if err != nil {
+		return nil, nil, err
+	}
+	o := *opts
+	o.Context = ctx
+	o.Value = amount
+	o.GasLimit = params.TxGas
+	tx, err := bind.NewBoundContract(subject, abi.ABI{}, r.backend, r.backend, r.backend).Transfer(&o)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, amount, nil
+}
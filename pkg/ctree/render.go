@@ -0,0 +1,184 @@
+package ctree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Format is an output format of the credential tree of a report.
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatDOT  Format = "dot"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatDOT, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (text|dot|json)", s)
+}
+
+// Render writes the credential tree of the report in the given format.
+func (r *VerificationReport) Render(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, r, nil)
+	case FormatDOT:
+		return WriteDOT(w, r)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// TextStyle formats the results of the checks and the error labels of
+// the text output, e.g. with colors.
+type TextStyle struct {
+	Status func(ok bool) string
+	Error  func(label string) string
+}
+
+// PlainText is the text style without formatting.
+var PlainText = &TextStyle{
+	Status: func(ok bool) string {
+		if ok {
+			return "ok"
+		}
+		return "failed"
+	},
+	Error: func(label string) string { return label },
+}
+
+// WriteText writes the credential tree of the report as an indented text
+// tree in the style, or in plain text if it is nil.
+func WriteText(w io.Writer, r *VerificationReport, style *TextStyle) error {
+	if style == nil {
+		style = PlainText
+	}
+	tw := &textWriter{w: w, style: style}
+	tw.report(r, "")
+	return tw.err
+}
+
+type textWriter struct {
+	w     io.Writer
+	style *TextStyle
+	err   error
+}
+
+func (tw *textWriter) printf(format string, args ...interface{}) {
+	if tw.err == nil {
+		_, tw.err = fmt.Fprintf(tw.w, format, args...)
+	}
+}
+
+func (tw *textWriter) report(r *VerificationReport, indent string) {
+	role := "inner"
+	if r.Leaf {
+		role = "leaf"
+	}
+	tw.printf("%sContract %s (%s, quorum %d of %d owners)\n", indent, r.Contract.Hex(), role, r.Quorum, len(r.Owners))
+	if r.Root != (common.Hash{}) {
+		tw.printf("%s  root: %s [%s]\n", indent, r.Root.Hex(), tw.style.Status(r.RootMatch))
+	}
+	for _, err := range r.Errors {
+		tw.printf("%s  %s %v\n", indent, tw.style.Error("error:"), err)
+	}
+	for _, c := range r.Credentials {
+		tw.printf("%s  Credential %s\n", indent, c.Digest.Hex())
+		tw.printf("%s    registrar: %s block: %v\n", indent, c.Registrar.Hex(), c.InsertedBlock)
+		tw.printf("%s    signed: %d/%d [%s] approved: [%s]\n", indent, c.signed(), len(c.Signers), tw.style.Status(c.QuorumSigned), tw.style.Status(c.Approved))
+		if c.Revocation != nil {
+			tw.printf("%s    revoked by %s at block %v (reason %s)\n", indent, c.Revocation.Revoker.Hex(), c.Revocation.RevokedBlock, c.Revocation.ReasonName)
+		}
+		for _, err := range c.Errors {
+			tw.printf("%s    %s %v\n", indent, tw.style.Error("error:"), err)
+		}
+		for _, wr := range c.Witnesses {
+			tw.report(wr, indent+"    ")
+		}
+	}
+}
+
+// signed returns the number of owners that signed the credential.
+func (c *CredentialReport) signed() int {
+	n := 0
+	for _, s := range c.Signers {
+		if s.Signed {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteDOT writes the credential tree of the report as a Graphviz graph.
+// Contracts are boxes linked to their credentials, which are linked to the
+// contracts of their witnesses. Contracts that are witnesses of many
+// credentials are drawn once. Credentials with errors are red, revoked
+// ones are also dashed. The identifiers and labels are quoted, so that
+// revocation reasons cannot break the graph.
+func WriteDOT(w io.Writer, r *VerificationReport) error {
+	dw := &textWriter{w: w}
+	dw.printf("digraph ctree {\n")
+	dw.printf("  rankdir=TB;\n")
+	dw.printf("  node [fontname=\"monospace\", fontsize=10];\n")
+	drawn := make(map[common.Address]bool)
+	var draw func(r *VerificationReport)
+	draw = func(r *VerificationReport) {
+		if drawn[r.Contract] {
+			return
+		}
+		drawn[r.Contract] = true
+		role := "inner"
+		if r.Leaf {
+			role = "leaf"
+		}
+		label := fmt.Sprintf("%s\n%s, quorum %d of %d", r.Contract.Hex(), role, r.Quorum, len(r.Owners))
+		if r.Root != (common.Hash{}) {
+			label += fmt.Sprintf("\nroot %s", shortHash(r.Root))
+		}
+		dw.printf("  %q [shape=box, label=%q%s];\n", r.Contract.Hex(), label, dotColor(len(r.Errors) == 0, false))
+		for _, c := range r.Credentials {
+			id := r.Contract.Hex() + "/" + c.Digest.Hex()
+			label := fmt.Sprintf("%s\nsigned %d/%d, approved %t", shortHash(c.Digest), c.signed(), len(c.Signers), c.Approved)
+			if c.Revocation != nil {
+				label += fmt.Sprintf("\nrevoked: %s", c.Revocation.ReasonName)
+			}
+			dw.printf("  %q [shape=ellipse, label=%q%s];\n", id, label, dotColor(len(c.Errors) == 0, c.Revoked))
+			dw.printf("  %q -> %q;\n", r.Contract.Hex(), id)
+			for _, wr := range c.Witnesses {
+				dw.printf("  %q -> %q [style=dotted];\n", id, wr.Contract.Hex())
+				draw(wr)
+			}
+		}
+	}
+	draw(r)
+	dw.printf("}\n")
+	return dw.err
+}
+
+func dotColor(ok, revoked bool) string {
+	switch {
+	case revoked:
+		return ", color=red, style=dashed"
+	case !ok:
+		return ", color=red"
+	}
+	return ""
+}
+
+func shortHash(h common.Hash) string {
+	s := h.Hex()
+	return s[:10] + "..." + s[len(s)-8:]
+}
@@ -0,0 +1,54 @@
+package ctree
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func renderReport() *VerificationReport {
+	return &VerificationReport{
+		Contract: common.HexToAddress("0x01"),
+		Quorum:   1,
+		Owners:   []common.Address{common.HexToAddress("0x0a")},
+		Errors:   Errors{errors.New("root mismatch")},
+		Credentials: []*CredentialReport{{
+			Digest:  common.HexToHash("0x02"),
+			Revoked: true,
+			Revocation: &RevocationReport{
+				RevokedBlock: big.NewInt(7),
+				ReasonName:   `copied "exam" \ retake`,
+			},
+			Errors: Errors{errors.New("credential revoked")},
+		}},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var text strings.Builder
+	style := &TextStyle{
+		Status: func(ok bool) string { return map[bool]string{true: "+", false: "-"}[ok] },
+		Error:  func(label string) string { return "<" + label + ">" },
+	}
+	assert.NoError(t, WriteText(&text, renderReport(), style))
+	assert.Contains(t, text.String(), "  <error:> root mismatch\n")
+	assert.Contains(t, text.String(), "    <error:> credential revoked\n")
+	assert.Contains(t, text.String(), "signed: 0/0 [-] approved: [-]")
+
+	text.Reset()
+	assert.NoError(t, WriteText(&text, renderReport(), nil))
+	assert.Contains(t, text.String(), "  error: root mismatch\n")
+	assert.Contains(t, text.String(), "[failed]")
+}
+
+func TestWriteDOT(t *testing.T) {
+	var dot strings.Builder
+	assert.NoError(t, WriteDOT(&dot, renderReport()))
+	assert.Contains(t, dot.String(), `\nrevoked: copied \"exam\" \\ retake", color=red, style=dashed];`)
+	assert.Contains(t, dot.String(), `label="0x0000000000000000000000000000000000000001\ninner, quorum 1 of 1", color=red];`)
+	assert.True(t, strings.HasSuffix(dot.String(), "}\n"))
+}
@@ -0,0 +1,333 @@
+package ctree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/relab/credbench/pkg/schemes"
+)
+
+var ErrDocumentMismatch = errors.New("document does not match the credential proof")
+
+// VerificationReport describes the verification of the credentials issued
+// by a node of the credential tree to a subject. The reports of the nodes
+// used as witnesses are linked in the credential reports, mirroring the
+// credential tree.
+type VerificationReport struct {
+	Contract    common.Address      `json:"contract"`
+	Subject     common.Address      `json:"subject"`
+	Leaf        bool                `json:"leaf"`
+	Owners      []common.Address    `json:"owners,omitempty"`
+	Quorum      uint8               `json:"quorum"`
+	Root        common.Hash         `json:"root"`
+	RootMatch   bool                `json:"rootMatch"`
+	Credentials []*CredentialReport `json:"credentials,omitempty"`
+	Errors      Errors              `json:"errors,omitempty"`
+}
+
+// CredentialReport describes the verification of an issued credential proof.
+type CredentialReport struct {
+	Digest         common.Hash           `json:"digest"`
+	Registrar      common.Address        `json:"registrar"`
+	Subject        common.Address        `json:"subject"`
+	InsertedBlock  *big.Int              `json:"insertedBlock"`
+	BlockTimestamp *big.Int              `json:"blockTimestamp"`
+	Approved       bool                  `json:"approved"`
+	Signers        []SignerStatus        `json:"signers"`
+	QuorumSigned   bool                  `json:"quorumSigned"`
+	Revoked        bool                  `json:"revoked"`
+	Revocation     *RevocationReport     `json:"revocation,omitempty"`
+	EvidenceRoot   common.Hash           `json:"evidenceRoot"`
+	Witnesses      []*VerificationReport `json:"witnesses,omitempty"`
+	Errors         Errors                `json:"errors,omitempty"`
+}
+
+// SignerStatus reports whether an owner signed a credential.
+type SignerStatus struct {
+	Owner  common.Address `json:"owner"`
+	Signed bool           `json:"signed"`
+}
+
+// RevocationReport describes the revocation proof of a credential.
+type RevocationReport struct {
+	Revoker      common.Address `json:"revoker"`
+	RevokedBlock *big.Int       `json:"revokedBlock"`
+	Reason       common.Hash    `json:"reason"`
+	ReasonName   string         `json:"reasonName"`
+}
+
+// Errors is a list of verification errors.
+type Errors []error
+
+// MarshalJSON encodes the errors as a list of messages.
+func (errs Errors) MarshalJSON() ([]byte, error) {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return json.Marshal(msgs)
+}
+
+// Valid reports whether no errors were found in the credential tree.
+func (r *VerificationReport) Valid() bool {
+	return r.Err() == nil
+}
+
+// Err returns the first error found in a pre-order traversal of the report.
+func (r *VerificationReport) Err() error {
+	if len(r.Errors) > 0 {
+		return r.Errors[0]
+	}
+	for _, c := range r.Credentials {
+		if err := c.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Err returns the first error found in the credential or its witnesses.
+func (c *CredentialReport) Err() error {
+	if len(c.Errors) > 0 {
+		return c.Errors[0]
+	}
+	for _, w := range c.Witnesses {
+		if err := w.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllErrors returns all errors found in the credential tree, annotated with
+// the contract and digest where they were found. Errors of nodes that are
+// witnesses of many credentials are reported once.
+func (r *VerificationReport) AllErrors() []error {
+	var errs []error
+	visited := make(map[*VerificationReport]bool)
+	var walk func(r *VerificationReport)
+	walk = func(r *VerificationReport) {
+		if visited[r] {
+			return
+		}
+		visited[r] = true
+		for _, err := range r.Errors {
+			errs = append(errs, fmt.Errorf("contract %s: %w", r.Contract.Hex(), err))
+		}
+		for _, c := range r.Credentials {
+			for _, err := range c.Errors {
+				errs = append(errs, fmt.Errorf("contract %s: digest %s: %w", r.Contract.Hex(), c.Digest.Hex(), err))
+			}
+			for _, w := range c.Witnesses {
+				walk(w)
+			}
+		}
+	}
+	walk(r)
+	return errs
+}
+
+// DocumentReport describes the verification of a credential document
+// against the credential proof of its digest issued by a node.
+type DocumentReport struct {
+	Contract   common.Address    `json:"contract"`
+	Credential *CredentialReport `json:"credential"`
+	Validity   *ValidityReport   `json:"validity,omitempty"`
+	Mismatches []FieldMismatch   `json:"mismatches,omitempty"`
+}
+
+// ValidityReport describes the validity period of a credential document
+// at the timestamp of the block it was verified at.
+type ValidityReport struct {
+	ValidFrom      *time.Time `json:"validFrom,omitempty"`
+	ValidUntil     *time.Time `json:"validUntil,omitempty"`
+	BlockNumber    *big.Int   `json:"blockNumber"`
+	BlockTimestamp *big.Int   `json:"blockTimestamp"`
+	Err            error      `json:"-"`
+}
+
+// Status returns the validity of the document at the block: valid,
+// not yet valid or expired.
+func (v *ValidityReport) Status() string {
+	switch {
+	case v.Err == nil:
+		return "valid"
+	case errors.Is(v.Err, schemes.ErrNotYetValid):
+		return "not yet valid"
+	case errors.Is(v.Err, schemes.ErrExpired):
+		return "expired"
+	}
+	return v.Err.Error()
+}
+
+// MarshalJSON adds the status to the validity report.
+func (v *ValidityReport) MarshalJSON() ([]byte, error) {
+	type report ValidityReport
+	return json.Marshal(&struct {
+		*report
+		Status string `json:"status"`
+	}{(*report)(v), v.Status()})
+}
+
+// FieldMismatch is a field of a credential document whose
+// value differs from the one recorded on-chain.
+type FieldMismatch struct {
+	Field    string `json:"field"`
+	Document string `json:"document"`
+	Chain    string `json:"chain"`
+}
+
+func (m FieldMismatch) String() string {
+	return fmt.Sprintf("%s: document %q, chain %q", m.Field, m.Document, m.Chain)
+}
+
+// Valid reports whether the document matches a valid credential proof.
+func (r *DocumentReport) Valid() bool {
+	return r.Err() == nil
+}
+
+// Err returns the first verification error of the credential proof, the
+// validity error of the document if it is expired or not yet valid, or
+// ErrDocumentMismatch if any field of the document does not match.
+func (r *DocumentReport) Err() error {
+	if err := r.Credential.Err(); err != nil {
+		return err
+	}
+	if r.Validity != nil && r.Validity.Err != nil {
+		return r.Validity.Err
+	}
+	if len(r.Mismatches) > 0 {
+		return ErrDocumentMismatch
+	}
+	return nil
+}
+
+// ImpactReport describes the credentials that use a credential as
+// evidence through the roots of their witnesses, directly or through
+// other dependent credentials.
+type ImpactReport struct {
+	Contract   common.Address   `json:"contract"`
+	Digest     common.Hash      `json:"digest"`
+	Subject    common.Address   `json:"subject"`
+	Witnesses  []common.Address `json:"witnesses,omitempty"`
+	Revoked    bool             `json:"revoked"`
+	Dependents []*ImpactReport  `json:"dependents,omitempty"`
+}
+
+// Affected returns all dependent credentials in pre-order.
+func (r *ImpactReport) Affected() []*ImpactReport {
+	var affected []*ImpactReport
+	for _, d := range r.Dependents {
+		affected = append(affected, d)
+		affected = append(affected, d.Affected()...)
+	}
+	return affected
+}
+
+// Subjects returns the subjects of the dependent credentials.
+func (r *ImpactReport) Subjects() []common.Address {
+	var subjects []common.Address
+	seen := make(map[common.Address]bool)
+	for _, d := range r.Affected() {
+		if !seen[d.Subject] {
+			seen[d.Subject] = true
+			subjects = append(subjects, d.Subject)
+		}
+	}
+	return subjects
+}
+
+// Action is a follow-up action of a revocation.
+type Action string
+
+const (
+	// ActionRevoke revokes a dependent credential.
+	ActionRevoke Action = "revoke"
+	// ActionReissue issues a new credential in place of a dependent
+	// credential, with its witnesses but the node of the credential it
+	// depends on.
+	ActionReissue Action = "reissue"
+)
+
+// Step is a follow-up action of a revocation on a credential or subject.
+type Step struct {
+	Action    Action           `json:"action"`
+	Contract  common.Address   `json:"contract"`
+	Subject   common.Address   `json:"subject"`
+	Digest    common.Hash      `json:"digest,omitempty"`
+	Witnesses []common.Address `json:"witnesses,omitempty"`
+}
+
+// Plan returns the steps needed to restore the credential tree after the
+// revocation of the credential: the dependent credentials are revoked and
+// issued again without the witnesses whose roots aggregate a revoked
+// credential. The roots of the subject cannot be aggregated again instead,
+// since the contracts only aggregate valid credentials and only accept roots
+// that aggregate all the credentials of the subject.
+func (r *ImpactReport) Plan() []Step {
+	var steps []Step
+	for _, d := range r.Dependents {
+		if !d.Revoked {
+			steps = append(steps, Step{Action: ActionRevoke, Contract: d.Contract, Subject: d.Subject, Digest: d.Digest})
+		}
+		steps = append(steps, d.Plan()...)
+		var witnesses []common.Address
+		for _, w := range d.Witnesses {
+			if w != r.Contract {
+				witnesses = append(witnesses, w)
+			}
+		}
+		steps = append(steps, Step{Action: ActionReissue, Contract: d.Contract, Subject: d.Subject, Digest: d.Digest, Witnesses: witnesses})
+	}
+	return steps
+}
+
+// PendingCredential describes a credential proof issued by a node that was
+// neither approved nor revoked, waiting for the signatures of the owners or,
+// once signed by a quorum, for the approval of the subject.
+type PendingCredential struct {
+	Contract      common.Address `json:"contract"`
+	Digest        common.Hash    `json:"digest"`
+	Subject       common.Address `json:"subject"`
+	Registrar     common.Address `json:"registrar"`
+	InsertedBlock *big.Int       `json:"insertedBlock"`
+	Signers       []SignerStatus `json:"signers"`
+	Signatures    uint64         `json:"signatures"`
+	Quorum        uint8          `json:"quorum"`
+	QuorumSigned  bool           `json:"quorumSigned"`
+}
+
+// Missing returns the owners that did not sign the credential.
+func (p *PendingCredential) Missing() []common.Address {
+	var missing []common.Address
+	for _, s := range p.Signers {
+		if !s.Signed {
+			missing = append(missing, s.Owner)
+		}
+	}
+	return missing
+}
+
+// WaitingOn returns the accounts the credential is waiting on: the owners
+// that did not sign it until it is signed by a quorum, and then the subject.
+func (p *PendingCredential) WaitingOn() []common.Address {
+	if p.QuorumSigned {
+		return []common.Address{p.Subject}
+	}
+	return p.Missing()
+}
+
+// IsWaitingOn reports whether the credential is waiting on the account.
+func (p *PendingCredential) IsWaitingOn(account common.Address) bool {
+	for _, a := range p.WaitingOn() {
+		if a == account {
+			return true
+		}
+	}
+	return false
+}
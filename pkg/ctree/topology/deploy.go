@@ -0,0 +1,214 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+// Backend is a backend where contracts can be deployed and their
+// transactions waited for.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+}
+
+// TxOptsFunc returns the options to sign the next transaction of an account.
+type TxOptsFunc func(account common.Address) (*bind.TransactOpts, error)
+
+// DeployedNode records a node of a deployed topology.
+type DeployedNode struct {
+	Path     string           `json:"path"`
+	Parent   string           `json:"parent,omitempty"`
+	Address  common.Address   `json:"address"`
+	Leaf     bool             `json:"leaf"`
+	Owners   []common.Address `json:"owners"`
+	Quorum   uint8            `json:"quorum"`
+	Children []common.Address `json:"children,omitempty"`
+	Tx       common.Hash      `json:"tx"`
+}
+
+// Deployment records the nodes of a deployed topology, parents before
+// their children.
+type Deployment struct {
+	Name  string          `json:"name,omitempty"`
+	Nodes []*DeployedNode `json:"nodes"`
+}
+
+// Node returns the node with the given path, or nil if there is none.
+func (d *Deployment) Node(path string) *DeployedNode {
+	for _, n := range d.Nodes {
+		if n.Path == path {
+			return n
+		}
+	}
+	return nil
+}
+
+// Leaves returns the leaves of the deployed trees.
+func (d *Deployment) Leaves() []*DeployedNode {
+	var leaves []*DeployedNode
+	for _, n := range d.Nodes {
+		if n.Leaf {
+			leaves = append(leaves, n)
+		}
+	}
+	return leaves
+}
+
+// Deployer deploys the node contracts of a topology and links each inner
+// node to its children.
+type Deployer struct {
+	backend Backend
+	libs    map[string]string
+	from    common.Address
+	txOpts  TxOptsFunc
+
+	// Wait waits for a transaction to be included in a block.
+	// By default, it waits until the transaction is mined.
+	Wait func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error)
+}
+
+// NewDeployer creates a deployer of node contracts linked to the given
+// libraries. The contracts are deployed by the from account and the
+// children are added by the first owner of their parent.
+func NewDeployer(backend Backend, libs map[string]string, from common.Address, txOpts TxOptsFunc) *Deployer {
+	return &Deployer{
+		backend: backend,
+		libs:    libs,
+		from:    from,
+		txOpts:  txOpts,
+		Wait: func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+			return bind.WaitMined(ctx, backend, tx)
+		},
+	}
+}
+
+// Plan returns the nodes that deploying the topology creates, parents
+// before their children, without their addresses.
+func (s *Spec) Plan() (*Deployment, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	deployment := &Deployment{Name: s.Name}
+	var expand func(parent string, nodes []*NodeSpec)
+	expand = func(parent string, nodes []*NodeSpec) {
+		for _, n := range nodes {
+			for _, name := range n.names() {
+				path := join(parent, name)
+				deployment.Nodes = append(deployment.Nodes, &DeployedNode{
+					Path:   path,
+					Parent: parent,
+					Leaf:   n.IsLeaf(),
+					Owners: n.Owners,
+					Quorum: n.quorum(),
+				})
+				expand(path, n.Children)
+			}
+		}
+	}
+	expand("", s.Nodes)
+	return deployment, nil
+}
+
+// Deploy deploys all nodes of the topology and then links the inner nodes
+// to their children, waiting for each step to be mined. If the deployment
+// fails, the nodes deployed so far are returned with the error.
+func (d *Deployer) Deploy(ctx context.Context, spec *Spec) (*Deployment, error) {
+	deployment, err := spec.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []*types.Transaction
+	for _, n := range deployment.Nodes {
+		tx, err := d.deployNode(ctx, n)
+		if err != nil {
+			return deployment, &Error{Path: n.Path, Err: err}
+		}
+		txs = append(txs, tx)
+	}
+	if err := d.waitAll(ctx, deployment.Nodes, txs); err != nil {
+		return deployment, err
+	}
+
+	var linked []*DeployedNode
+	txs = txs[:0]
+	for _, n := range deployment.Nodes {
+		if n.Parent == "" {
+			continue
+		}
+		parent := deployment.Node(n.Parent)
+		tx, err := d.addChild(ctx, parent, n.Address)
+		if err != nil {
+			return deployment, &Error{Path: parent.Path, Err: fmt.Errorf("adding child %s: %w", n.Path, err)}
+		}
+		parent.Children = append(parent.Children, n.Address)
+		linked = append(linked, parent)
+		txs = append(txs, tx)
+	}
+	if err := d.waitAll(ctx, linked, txs); err != nil {
+		return deployment, err
+	}
+	return deployment, nil
+}
+
+func (d *Deployer) opts(ctx context.Context, account common.Address) (*bind.TransactOpts, error) {
+	opts, err := d.txOpts(account)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Context == nil {
+		opts.Context = ctx
+	}
+	return opts, nil
+}
+
+func (d *Deployer) deployNode(ctx context.Context, n *DeployedNode) (*types.Transaction, error) {
+	opts, err := d.opts(ctx, d.from)
+	if err != nil {
+		return nil, err
+	}
+	role := node.InnerRole
+	if n.Leaf {
+		role = node.LeafRole
+	}
+	addr, tx, _, err := node.Deploy(opts, d.backend, d.libs, role, n.Owners, n.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	n.Address = addr
+	n.Tx = tx.Hash()
+	return tx, nil
+}
+
+func (d *Deployer) addChild(ctx context.Context, parent *DeployedNode, child common.Address) (*types.Transaction, error) {
+	opts, err := d.opts(ctx, parent.Owners[0])
+	if err != nil {
+		return nil, err
+	}
+	n, err := node.NewNode(parent.Address, d.backend)
+	if err != nil {
+		return nil, err
+	}
+	return n.AddNode(opts, child)
+}
+
+// waitAll waits for the transactions sent for the given nodes.
+func (d *Deployer) waitAll(ctx context.Context, nodes []*DeployedNode, txs []*types.Transaction) error {
+	for i, tx := range txs {
+		receipt, err := d.Wait(ctx, tx)
+		if err != nil {
+			return &Error{Path: nodes[i].Path, Err: err}
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return &Error{Path: nodes[i].Path, Err: fmt.Errorf("transaction %s failed", tx.Hash().Hex())}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package topology describes credential trees of arbitrary depth and
+// deploys them as linked node contracts.
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Separator separates the names of the nodes in the path of a node.
+const Separator = "/"
+
+var (
+	ErrNoNodes         = errors.New("topology has no nodes")
+	ErrNoName          = errors.New("node has no name")
+	ErrInvalidName     = errors.New("node name contains the path separator")
+	ErrDuplicatedName  = errors.New("node name is already used by a sibling")
+	ErrNoOwners        = errors.New("node has no owners")
+	ErrInvalidOwner    = errors.New("invalid owner address")
+	ErrDuplicatedOwner = errors.New("owner is listed more than once")
+	ErrInvalidQuorum   = errors.New("quorum is greater than the number of owners")
+	ErrInvalidCount    = errors.New("count must not be negative")
+	ErrUnknownFormat   = errors.New("unknown topology format")
+)
+
+// Spec describes the credential trees to deploy. Each of the top-level
+// nodes is the root of a tree, e.g. a university whose children are
+// faculties, departments, programmes and, at the leaves, courses.
+type Spec struct {
+	Name  string      `json:"name,omitempty" yaml:"name,omitempty"`
+	Nodes []*NodeSpec `json:"nodes" yaml:"nodes"`
+}
+
+// NodeSpec describes a node of the credential tree. Nodes without children
+// are deployed as leaves and the others as inner nodes, linked to the
+// nodes of their children. A quorum of zero requires all owners to sign.
+// Count deploys several copies of the node and its subtree, named after
+// the node with the index of the copy, e.g. course-0, course-1.
+type NodeSpec struct {
+	Name     string           `json:"name" yaml:"name"`
+	Owners   []common.Address `json:"owners" yaml:"owners"`
+	Quorum   uint8            `json:"quorum,omitempty" yaml:"quorum,omitempty"`
+	Count    int              `json:"count,omitempty" yaml:"count,omitempty"`
+	Children []*NodeSpec      `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// IsLeaf returns whether the node is deployed as a leaf.
+func (n *NodeSpec) IsLeaf() bool {
+	return len(n.Children) == 0
+}
+
+// quorum returns the number of signatures required by the node.
+func (n *NodeSpec) quorum() uint8 {
+	if n.Quorum == 0 {
+		return uint8(len(n.Owners))
+	}
+	return n.Quorum
+}
+
+// names returns the names of the copies of the node.
+func (n *NodeSpec) names() []string {
+	if n.Count <= 1 {
+		return []string{n.Name}
+	}
+	names := make([]string, n.Count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", n.Name, i)
+	}
+	return names
+}
+
+// Error is returned when a node of the topology is not valid.
+type Error struct {
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("node %s: %v", e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the nodes can be deployed: all nodes are named,
+// siblings have different names and the owners and quorum are accepted by
+// the node contract.
+func (s *Spec) Validate() error {
+	if len(s.Nodes) == 0 {
+		return &Error{Err: ErrNoNodes}
+	}
+	return validateNodes("", s.Nodes)
+}
+
+func validateNodes(parent string, nodes []*NodeSpec) error {
+	names := make(map[string]bool)
+	for _, n := range nodes {
+		path := join(parent, n.Name)
+		if err := n.validate(); err != nil {
+			return &Error{Path: path, Err: err}
+		}
+		for _, name := range n.names() {
+			if names[name] {
+				return &Error{Path: join(parent, name), Err: ErrDuplicatedName}
+			}
+			names[name] = true
+		}
+		if err := validateNodes(path, n.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NodeSpec) validate() error {
+	switch {
+	case n.Name == "":
+		return ErrNoName
+	case strings.Contains(n.Name, Separator):
+		return ErrInvalidName
+	case len(n.Owners) == 0:
+		return ErrNoOwners
+	case int(n.Quorum) > len(n.Owners):
+		return ErrInvalidQuorum
+	case n.Count < 0:
+		return ErrInvalidCount
+	}
+	seen := make(map[common.Address]bool, len(n.Owners))
+	for _, o := range n.Owners {
+		if o == (common.Address{}) {
+			return ErrInvalidOwner
+		}
+		if seen[o] {
+			return fmt.Errorf("%w: %s", ErrDuplicatedOwner, o.Hex())
+		}
+		seen[o] = true
+	}
+	return nil
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + Separator + name
+}
+
+// Parse decodes a topology in the given format, json or yaml,
+// and validates it.
+func Parse(data []byte, format string) (*Spec, error) {
+	spec := &Spec{}
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(spec); err != nil {
+			return nil, err
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(spec); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Load reads a topology file, whose format is given by its extension.
+func Load(filename string) (*Spec, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+}
@@ -0,0 +1,272 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/backends"
+	"github.com/relab/credbench/pkg/ctree/node"
+)
+
+var (
+	rector    = backends.TestAccounts[0].Address
+	dean      = backends.TestAccounts[1].Address
+	head      = backends.TestAccounts[2].Address
+	evaluator = backends.TestAccounts[3].Address
+)
+
+var specYAML = fmt.Sprintf(`
+name: university
+nodes:
+  - name: university
+    owners: [%s]
+    children:
+      - name: faculty
+        owners: [%s, %s]
+        quorum: 1
+        children:
+          - name: department
+            owners: [%s]
+            children:
+              - name: programme
+                owners: [%s]
+                children:
+                  - name: course
+                    owners: [%s]
+                    count: 3
+`, rector.Hex(), rector.Hex(), dean.Hex(), head.Hex(), head.Hex(), evaluator.Hex())
+
+var specJSON = fmt.Sprintf(`{
+  "name": "university",
+  "nodes": [{
+    "name": "university",
+    "owners": ["%s"],
+    "children": [{
+      "name": "faculty",
+      "owners": ["%s", "%s"],
+      "quorum": 1,
+      "children": [{
+        "name": "department",
+        "owners": ["%s"],
+        "children": [{
+          "name": "programme",
+          "owners": ["%s"],
+          "children": [{"name": "course", "owners": ["%s"], "count": 3}]
+        }]
+      }]
+    }]
+  }]
+}`, rector.Hex(), rector.Hex(), dean.Hex(), head.Hex(), head.Hex(), evaluator.Hex())
+
+func TestParse(t *testing.T) {
+	fromYAML, err := Parse([]byte(specYAML), "yaml")
+	require.NoError(t, err)
+	fromJSON, err := Parse([]byte(specJSON), "json")
+	require.NoError(t, err)
+	assert.Equal(t, fromJSON, fromYAML)
+
+	faculty := fromYAML.Nodes[0].Children[0]
+	assert.Equal(t, []common.Address{rector, dean}, faculty.Owners)
+	assert.Equal(t, uint8(1), faculty.quorum())
+	assert.Equal(t, uint8(1), fromYAML.Nodes[0].quorum())
+
+	_, err = Parse([]byte(specJSON), "toml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+	_, err = Parse([]byte(`{"nodes": [{"name": "a", "owner": []}]}`), "json")
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	owners := []common.Address{rector}
+	tests := []struct {
+		spec *Spec
+		path string
+		err  error
+	}{
+		{&Spec{}, "", ErrNoNodes},
+		{&Spec{Nodes: []*NodeSpec{{Owners: owners}}}, "", ErrNoName},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a/b", Owners: owners}}}, "a/b", ErrInvalidName},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a"}}}, "a", ErrNoOwners},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: owners, Quorum: 2}}}, "a", ErrInvalidQuorum},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: owners, Count: -1}}}, "a", ErrInvalidCount},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: []common.Address{{}}}}}, "a", ErrInvalidOwner},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: []common.Address{rector, rector}}}}, "a", ErrDuplicatedOwner},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: owners, Children: []*NodeSpec{
+			{Name: "b", Owners: owners},
+			{Name: "b", Owners: owners},
+		}}}}, "a/b", ErrDuplicatedName},
+		{&Spec{Nodes: []*NodeSpec{{Name: "a", Owners: owners, Children: []*NodeSpec{
+			{Name: "b", Owners: owners, Count: 2},
+			{Name: "b-1", Owners: owners},
+		}}}}, "a/b-1", ErrDuplicatedName},
+	}
+	for _, tt := range tests {
+		err := tt.spec.Validate()
+		assert.ErrorIs(t, err, tt.err)
+		var terr *Error
+		if assert.True(t, errors.As(err, &terr)) {
+			assert.Equal(t, tt.path, terr.Path)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	spec, err := Parse([]byte(specYAML), "yaml")
+	require.NoError(t, err)
+	plan, err := spec.Plan()
+	require.NoError(t, err)
+	require.Len(t, plan.Nodes, 7)
+	for _, n := range plan.Nodes {
+		assert.Equal(t, common.Address{}, n.Address, n.Path)
+		if n.Parent != "" {
+			assert.NotNil(t, plan.Node(n.Parent), n.Path)
+		}
+	}
+	assert.Len(t, plan.Leaves(), 3)
+
+	spec.Nodes[0].Name = ""
+	_, err = spec.Plan()
+	assert.Error(t, err)
+}
+
+func TestDeploy(t *testing.T) {
+	backend := backends.NewTestBackend()
+	defer backend.Close()
+	ctx := context.Background()
+
+	keys := make(map[common.Address]*backends.Account)
+	for i := range backends.TestAccounts {
+		keys[backends.TestAccounts[i].Address] = &backends.TestAccounts[i]
+	}
+	txOpts := func(account common.Address) (*bind.TransactOpts, error) {
+		acc, ok := keys[account]
+		if !ok {
+			return nil, fmt.Errorf("unknown account %s", account.Hex())
+		}
+		// a block of the simulated chain only fits one node deployment
+		backend.Commit()
+		return backend.TransactOpts(acc.Key), nil
+	}
+	libs, err := backend.DeployLibs(backend.TransactOpts(backends.TestAccounts[0].Key))
+	require.NoError(t, err)
+
+	spec, err := Parse([]byte(specYAML), "yaml")
+	require.NoError(t, err)
+	d := NewDeployer(backend, libs, backends.TestAccounts[9].Address, txOpts)
+	d.Wait = func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		backend.Commit()
+		return backend.TransactionReceipt(ctx, tx.Hash())
+	}
+	deployment, err := d.Deploy(ctx, spec)
+	require.NoError(t, err)
+
+	paths := []string{
+		"university",
+		"university/faculty",
+		"university/faculty/department",
+		"university/faculty/department/programme",
+		"university/faculty/department/programme/course-0",
+		"university/faculty/department/programme/course-1",
+		"university/faculty/department/programme/course-2",
+	}
+	require.Len(t, deployment.Nodes, len(paths))
+	for i, dn := range deployment.Nodes {
+		assert.Equal(t, paths[i], dn.Path)
+		n, err := node.NewNode(dn.Address, backend)
+		require.NoError(t, err)
+
+		leaf, err := n.IsLeaf(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, dn.Leaf, leaf, dn.Path)
+		owners, err := n.GetOwnersContext(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, dn.Owners, owners, dn.Path)
+		quorum, err := n.QuorumContext(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, dn.Quorum, quorum, dn.Path)
+
+		children, err := n.GetChildren(ctx, nil)
+		require.NoError(t, err)
+		var want []common.Address
+		for _, c := range deployment.Nodes {
+			if c.Parent == dn.Path {
+				want = append(want, c.Address)
+			}
+		}
+		assert.Equal(t, want, dn.Children, dn.Path)
+		assert.ElementsMatch(t, want, children, dn.Path)
+	}
+	assert.Len(t, deployment.Leaves(), 3)
+
+	// the leaves issue credentials that witness the credentials of their parent
+	subject := backends.TestAccounts[5]
+	digest := [32]byte{1}
+	leaves := deployment.Leaves()
+	courses := make([]common.Address, len(leaves))
+	for i, dn := range leaves {
+		courses[i] = dn.Address
+		n, err := node.NewNode(dn.Address, backend)
+		require.NoError(t, err)
+		_, err = n.RegisterCredential(backend.TransactOpts(keys[evaluator].Key), subject.Address, digest, nil)
+		require.NoError(t, err)
+		backend.Commit()
+		_, err = n.ApproveCredential(backend.TransactOpts(subject.Key), digest)
+		require.NoError(t, err)
+		backend.Commit()
+		_, err = n.AggregateCredentials(backend.TransactOpts(keys[evaluator].Key), subject.Address, [][32]byte{digest})
+		require.NoError(t, err)
+		backend.Commit()
+	}
+	programme, err := node.NewNode(deployment.Node(leaves[0].Parent).Address, backend)
+	require.NoError(t, err)
+	_, err = programme.RegisterCredential(backend.TransactOpts(keys[head].Key), subject.Address, [32]byte{2}, courses)
+	require.NoError(t, err)
+	backend.Commit()
+	_, err = programme.ApproveCredential(backend.TransactOpts(subject.Key), [32]byte{2})
+	require.NoError(t, err)
+	backend.Commit()
+	assert.NoError(t, programme.VerifyCredentialTree(ctx, true, nil, subject.Address))
+}
+
+func TestDeployPartial(t *testing.T) {
+	backend := backends.NewTestBackend()
+	defer backend.Close()
+	libs, err := backend.DeployLibs(backend.TransactOpts(backends.TestAccounts[0].Key))
+	require.NoError(t, err)
+
+	spec := &Spec{Nodes: []*NodeSpec{{
+		Name:     "faculty",
+		Owners:   []common.Address{dean},
+		Children: []*NodeSpec{{Name: "course", Owners: []common.Address{evaluator}}},
+	}}}
+	// the owner of the faculty cannot sign the transaction adding the course
+	txOpts := func(account common.Address) (*bind.TransactOpts, error) {
+		if account != backends.TestAccounts[0].Address {
+			return nil, errors.New("no key")
+		}
+		backend.Commit()
+		return backend.TransactOpts(backends.TestAccounts[0].Key), nil
+	}
+	d := NewDeployer(backend, libs, backends.TestAccounts[0].Address, txOpts)
+	d.Wait = func(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+		backend.Commit()
+		return backend.TransactionReceipt(ctx, tx.Hash())
+	}
+	deployment, err := d.Deploy(context.Background(), spec)
+	var terr *Error
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, "faculty", terr.Path)
+	require.Len(t, deployment.Nodes, 2)
+	for _, n := range deployment.Nodes {
+		assert.NotEqual(t, common.Address{}, n.Address)
+	}
+	assert.Empty(t, deployment.Node("faculty").Children)
+}
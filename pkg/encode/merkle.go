@@ -0,0 +1,137 @@
+package encode
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrNoLeaves     = errors.New("no leaves to aggregate")
+	ErrLeafNotFound = errors.New("leaf not found")
+)
+
+// Domain separation prefixes of the leaves and inner nodes of the
+// Merkle tree, preventing an inner node from being proved as a leaf.
+const (
+	leafPrefix byte = 0x00
+	nodePrefix byte = 0x01
+)
+
+// MerkleProof is an inclusion proof of a leaf in a Merkle tree
+// with the given number of leaves.
+type MerkleProof struct {
+	Leaf     common.Hash   `json:"leaf"`
+	Index    uint64        `json:"index"`
+	Leaves   uint64        `json:"leaves"`
+	Siblings []common.Hash `json:"siblings"`
+}
+
+func hashLeaf(leaf [32]byte) [32]byte {
+	return crypto.Keccak256Hash([]byte{leafPrefix}, leaf[:])
+}
+
+func hashNode(left, right [32]byte) [32]byte {
+	return crypto.Keccak256Hash([]byte{nodePrefix}, left[:], right[:])
+}
+
+// nextLevel hashes each pair of nodes of a level. The last node of
+// a level with an odd number of nodes is promoted to the next level.
+func nextLevel(level [][32]byte) [][32]byte {
+	next := make([][32]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashNode(level[i], level[i+1]))
+	}
+	return next
+}
+
+func leafLevel(leaves [][32]byte) [][32]byte {
+	level := make([][32]byte, len(leaves))
+	for i, l := range leaves {
+		level[i] = hashLeaf(l)
+	}
+	return level
+}
+
+// MerkleRoot aggregates the leaves in a Merkle tree, keeping their order.
+func MerkleRoot(leaves [][32]byte) ([32]byte, error) {
+	if len(leaves) == 0 {
+		return [32]byte{}, ErrNoLeaves
+	}
+	level := leafLevel(leaves)
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0], nil
+}
+
+// NewMerkleProof returns the inclusion proof of the leaf at
+// the given index in the Merkle tree of the leaves.
+func NewMerkleProof(leaves [][32]byte, index int) (*MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrLeafNotFound
+	}
+	p := &MerkleProof{
+		Leaf:   leaves[index],
+		Index:  uint64(index),
+		Leaves: uint64(len(leaves)),
+	}
+	level := leafLevel(leaves)
+	for i := index; len(level) > 1; i /= 2 {
+		if s := i ^ 1; s < len(level) {
+			p.Siblings = append(p.Siblings, level[s])
+		}
+		level = nextLevel(level)
+	}
+	return p, nil
+}
+
+// NewMerkleProofOf returns the inclusion proof of the first
+// occurrence of the leaf in the Merkle tree of the leaves.
+func NewMerkleProofOf(leaves [][32]byte, leaf [32]byte) (*MerkleProof, error) {
+	for i, l := range leaves {
+		if l == leaf {
+			return NewMerkleProof(leaves, i)
+		}
+	}
+	return nil, ErrLeafNotFound
+}
+
+// Root computes the root of the Merkle tree from the proof.
+func (p *MerkleProof) Root() ([32]byte, error) {
+	if p.Index >= p.Leaves {
+		return [32]byte{}, ErrLeafNotFound
+	}
+	h := hashLeaf(p.Leaf)
+	siblings := p.Siblings
+	for i, n := p.Index, p.Leaves; n > 1; i, n = i/2, (n+1)/2 {
+		if i == n-1 && n%2 == 1 {
+			continue // promoted without sibling
+		}
+		if len(siblings) == 0 {
+			return [32]byte{}, errors.New("merkle proof too short")
+		}
+		if i%2 == 0 {
+			h = hashNode(h, siblings[0])
+		} else {
+			h = hashNode(siblings[0], h)
+		}
+		siblings = siblings[1:]
+	}
+	if len(siblings) > 0 {
+		return [32]byte{}, errors.New("merkle proof too long")
+	}
+	return h, nil
+}
+
+// Verify checks whether the proof is an inclusion proof of its leaf
+// in the Merkle tree with the given root.
+func (p *MerkleProof) Verify(root [32]byte) bool {
+	r, err := p.Root()
+	return err == nil && r == root
+}
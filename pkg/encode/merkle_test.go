@@ -0,0 +1,65 @@
+package encode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func leaves(n int) [][32]byte {
+	l := make([][32]byte, n)
+	for i := range l {
+		l[i] = crypto.Keccak256Hash([]byte{byte(i)})
+	}
+	return l
+}
+
+func TestMerkleRoot(t *testing.T) {
+	_, err := MerkleRoot(nil)
+	assert.ErrorIs(t, err, ErrNoLeaves)
+
+	l := leaves(3)
+	root, err := MerkleRoot(l)
+	assert.NoError(t, err)
+	expected := hashNode(hashNode(hashLeaf(l[0]), hashLeaf(l[1])), hashLeaf(l[2]))
+	assert.Equal(t, expected, root)
+
+	single, err := MerkleRoot(l[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, hashLeaf(l[0]), single)
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		l := leaves(n)
+		root, err := MerkleRoot(l)
+		assert.NoError(t, err)
+		for i := range l {
+			p, err := NewMerkleProof(l, i)
+			assert.NoError(t, err)
+			assert.True(t, p.Verify(root), "leaves %d index %d", n, i)
+
+			wrong := *p
+			wrong.Leaf = crypto.Keccak256Hash([]byte("wrong"))
+			assert.False(t, wrong.Verify(root), "leaves %d index %d", n, i)
+		}
+	}
+
+	l := leaves(4)
+	root, _ := MerkleRoot(l)
+	p, err := NewMerkleProofOf(l, l[2])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), p.Index)
+
+	// an inner node is not a valid leaf
+	inner := &MerkleProof{Leaf: hashNode(hashLeaf(l[0]), hashLeaf(l[1])), Index: 0, Leaves: 2, Siblings: p.Siblings[1:]}
+	assert.False(t, inner.Verify(root))
+
+	swapped := *p
+	swapped.Index = 3
+	assert.False(t, swapped.Verify(root))
+
+	_, err = NewMerkleProofOf(l, crypto.Keccak256Hash([]byte("missing")))
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+}
@@ -1,15 +1,25 @@
 package faculty
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 
+	"github.com/relab/credbench/pkg/ctree"
 	"github.com/relab/credbench/pkg/ctree/node"
 	"github.com/relab/credbench/pkg/deployer"
 	bindings "github.com/relab/go-credbindings/faculty"
 )
 
+var (
+	ErrSemesterRegistered = errors.New("semester already registered")
+	ErrNoCourses          = errors.New("empty list of courses")
+)
+
 // Faculty is a Go wrapper around an on-chain faculty contract.
 type Faculty struct {
 	*node.Node
@@ -55,16 +65,64 @@ func (f Faculty) Address() common.Address {
 	return f.address
 }
 
+// SemesterExists returns whether the semester is registered.
+//
+// Deprecated: Use SemesterExistsContext.
 func (f Faculty) SemesterExists(opts *bind.CallOpts, semester [32]byte) (bool, error) {
 	return f.contract.SemesterExists(opts, semester)
 }
 
+// GetCoursesBySemester returns the courses of the semester.
+//
+// Deprecated: Use GetCoursesBySemesterContext.
 func (f Faculty) GetCoursesBySemester(opts *bind.CallOpts, semester []byte) ([]common.Address, error) {
 	var s [32]byte
 	copy(s[:], semester[:]) // truncate to 32 bytes
 	return f.contract.GetCoursesBySemester(opts, s)
 }
 
+// SemesterExistsContext returns whether the semester is registered at the
+// block of the call options.
+func (f Faculty) SemesterExistsContext(ctx context.Context, opts *ctree.CallOpts, semester [32]byte) (bool, error) {
+	ok, err := f.contract.SemesterExists(opts.Bind(ctx), semester)
+	if err != nil {
+		return false, &ctree.CallError{Contract: f.address, Method: "semesterExists", Err: err}
+	}
+	return ok, nil
+}
+
+// GetCoursesBySemesterContext returns the courses of the semester at the
+// block of the call options.
+func (f Faculty) GetCoursesBySemesterContext(ctx context.Context, opts *ctree.CallOpts, semester []byte) ([]common.Address, error) {
+	var s [32]byte
+	copy(s[:], semester[:]) // truncate to 32 bytes
+	courses, err := f.contract.GetCoursesBySemester(opts.Bind(ctx), s)
+	if err != nil {
+		return nil, &ctree.CallError{Contract: f.address, Method: "getCoursesBySemester", Err: err}
+	}
+	return courses, nil
+}
+
 func (f *Faculty) RegisterSemester(opts *bind.TransactOpts, semester [32]byte, courses []common.Address) (*types.Transaction, error) {
 	return f.contract.RegisterSemester(opts, semester, courses)
 }
+
+// CheckRegisterSemester checks that the contract would accept the
+// registration of the courses of a semester by the sender.
+func (f *Faculty) CheckRegisterSemester(ctx context.Context, opts *ctree.CallOpts, sender common.Address, semester [32]byte, courses []common.Address) error {
+	const method = "registerSemester"
+	if err := f.CheckOwner(ctx, opts, method, sender); err != nil {
+		return err
+	}
+	if len(courses) == 0 {
+		return &ctree.PreflightError{Contract: f.address, Method: method, Sender: sender, Err: ErrNoCourses}
+	}
+	ok, err := f.SemesterExistsContext(ctx, opts, semester)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return &ctree.PreflightError{Contract: f.address, Method: method, Sender: sender, Err: fmt.Errorf("%w: %x", ErrSemesterRegistered, semester)}
+	}
+	return nil
+}
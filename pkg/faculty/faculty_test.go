@@ -1,18 +1,31 @@
 package faculty
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/relab/credbench/pkg/backends"
 	"github.com/relab/credbench/pkg/course"
+	"github.com/relab/credbench/pkg/ctree"
+	"github.com/relab/credbench/pkg/ctree/bundle"
 	"github.com/relab/credbench/pkg/ctree/node"
+	"github.com/relab/credbench/pkg/ctree/notary"
+	"github.com/relab/credbench/pkg/ctree/owners"
 	"github.com/relab/credbench/pkg/encode"
 
 	pb "github.com/relab/credbench/pkg/schemes"
@@ -47,7 +60,7 @@ func NewTestFaculty(t *testing.T, adms backends.Accounts, quorum uint8) *TestFac
 }
 
 func deployFaculty(backend *backends.TestBackend, prvKey *ecdsa.PrivateKey, adms []common.Address, quorum uint8) (common.Address, *Faculty, error) {
-	opts := bind.NewKeyedTransactor(prvKey)
+	opts := backend.TransactOpts(prvKey)
 
 	libs, err := backend.DeployLibs(opts)
 	if err != nil {
@@ -66,11 +79,11 @@ func TestNewFaculty(t *testing.T) {
 	tf := NewTestFaculty(t, admsAccount, 2)
 	defer tf.Backend.Close()
 
-	if ok, err := tf.Faculty.IsOwner(&bind.CallOpts{Pending: true}, tf.Adms[0].Address); !ok {
+	if ok, err := tf.Faculty.IsOwnerContext(context.Background(), &ctree.CallOpts{Pending: true}, tf.Adms[0].Address); !ok {
 		t.Fatalf("IsOwner expected to be true but return: %t, %v", ok, err)
 	}
 
-	adms, err := tf.Faculty.GetOwners(&bind.CallOpts{Pending: true})
+	adms, err := tf.Faculty.GetOwnersContext(context.Background(), &ctree.CallOpts{Pending: true})
 	if err != nil {
 		t.Fatalf("GetOwners expected no errors but got: %v", err)
 	}
@@ -84,7 +97,7 @@ func TestFacultyAddCourse(t *testing.T) {
 	tf := NewTestFaculty(t, adms, uint8(len(adms)))
 	defer tf.Backend.Close()
 
-	opts := bind.NewKeyedTransactor(adms[0].Key)
+	opts := tf.Backend.TransactOpts(adms[0].Key)
 	courseAddr, _, _, err := course.DeployCourse(opts, tf.Backend, tf.Backend.GetLibs(), evaluators.Addresses(), uint8(len(evaluators)))
 	if err != nil {
 		t.Fatalf("Failed to deploy course: %v", err)
@@ -130,7 +143,7 @@ func TestCreateDiploma(t *testing.T) {
 	var coursesAddresses []common.Address
 	for i := 0; i < 4; i++ {
 		// adm creates course
-		opts := bind.NewKeyedTransactor(adms[0].Key)
+		opts := tf.Backend.TransactOpts(adms[0].Key)
 		courseAddr, _, _, err := course.DeployCourse(opts, tf.Backend, tf.Backend.GetLibs(), evaluators.Addresses(), uint8(len(evaluators)))
 		if err != nil {
 			t.Fatalf("Failed to deploy course: %v", err)
@@ -153,7 +166,7 @@ func TestCreateDiploma(t *testing.T) {
 		}
 
 		// Adding a student
-		opts = bind.NewKeyedTransactor(evaluators[0].Key)
+		opts = tf.Backend.TransactOpts(evaluators[0].Key)
 		_, err = courseInstance.AddStudent(opts, student.Address)
 		if err != nil {
 			t.Fatalf("Failed to add student to course %s: %v", courseInstance.Address().Hex(), err)
@@ -181,9 +194,9 @@ func TestCreateDiploma(t *testing.T) {
 
 		for _, a := range c.Course.Assignments {
 			// Publish digest of assignment credential
-			digest := pb.Hash(a)
+			digest := hashCredential(t, a)
 			courseDigests[caddr] = append(courseDigests[caddr], digest)
-			opts := bind.NewKeyedTransactor(evaluators[0].Key)
+			opts := tf.Backend.TransactOpts(evaluators[0].Key)
 			_, err := courseInstance.RegisterCredential(opts, student.Address, digest, []common.Address{})
 			if err != nil {
 				t.Fatalf("RegisterCredential expected no error, got: %v", err)
@@ -196,14 +209,14 @@ func TestCreateDiploma(t *testing.T) {
 			assert.Equal(t, digest, proof.Digest)
 
 			// Second evaluator confirms
-			opts = bind.NewKeyedTransactor(evaluators[1].Key)
+			opts = tf.Backend.TransactOpts(evaluators[1].Key)
 			_, err = courseInstance.RegisterCredential(opts, student.Address, digest, []common.Address{})
 			if err != nil {
 				t.Fatalf("RegisterCredential expected no error, got: %v", err)
 			}
 			tf.Backend.Commit()
 
-			opts = bind.NewKeyedTransactor(student.Key)
+			opts = tf.Backend.TransactOpts(student.Key)
 			_, err = courseInstance.ApproveCredential(opts, digest)
 			if err != nil {
 				t.Fatalf("ApproveCredential expected no error, got: %v", err)
@@ -212,9 +225,9 @@ func TestCreateDiploma(t *testing.T) {
 		}
 
 		// issue final course certificate
-		digest := pb.Hash(c)
+		digest := hashCredential(t, c)
 		courseDigests[caddr] = append(courseDigests[caddr], digest)
-		opts := bind.NewKeyedTransactor(evaluators[0].Key)
+		opts := tf.Backend.TransactOpts(evaluators[0].Key)
 		_, err := courseInstance.RegisterCredential(opts, student.Address, digest, []common.Address{})
 		if err != nil {
 			t.Fatalf("RegisterCredential expected no error, got: %v", err)
@@ -227,14 +240,14 @@ func TestCreateDiploma(t *testing.T) {
 		assert.Equal(t, digest, proof.Digest)
 
 		// Second evaluator also signs the credential
-		opts = bind.NewKeyedTransactor(evaluators[1].Key)
+		opts = tf.Backend.TransactOpts(evaluators[1].Key)
 		_, err = courseInstance.RegisterCredential(opts, student.Address, digest, []common.Address{})
 		if err != nil {
 			t.Fatalf("RegisterCredential expected no error, got: %v", err)
 		}
 		tf.Backend.Commit()
 
-		opts = bind.NewKeyedTransactor(student.Key)
+		opts = tf.Backend.TransactOpts(student.Key)
 		_, err = courseInstance.ApproveCredential(opts, digest)
 		if err != nil {
 			t.Fatalf("ApproveCredential expected no error, got: %v", err)
@@ -252,14 +265,14 @@ func TestCreateDiploma(t *testing.T) {
 		caddr := common.HexToAddress(c.Course.GetId())
 		courseInstance, _ := course.NewCourse(caddr, tf.Backend)
 
-		opts := bind.NewKeyedTransactor(evaluators[0].Key)
+		opts := tf.Backend.TransactOpts(evaluators[0].Key)
 		_, err := courseInstance.AggregateCredentials(opts, student.Address, courseDigests[caddr])
 		if err != nil {
 			t.Fatalf("Failed to aggregate course credentials: %v", err)
 		}
 		tf.Backend.Commit()
 
-		root, err := courseInstance.GetRoot(nil, student.Address)
+		root, err := courseInstance.GetRoot(context.Background(), nil, student.Address)
 		if err != nil {
 			t.Fatalf("Root not found for course %s: %v", caddr.Hex(), err)
 		}
@@ -268,9 +281,9 @@ func TestCreateDiploma(t *testing.T) {
 	}
 
 	diplomaCredential := pb.NewFakeDiplomaCredential(adms[0].Address.Hex(), diploma)
-	digest := pb.Hash(diplomaCredential)
+	digest := hashCredential(t, diplomaCredential)
 
-	opts := bind.NewKeyedTransactor(adms[0].Key)
+	opts := tf.Backend.TransactOpts(adms[0].Key)
 	_, err := tf.Faculty.RegisterCredential(opts, student.Address, digest, coursesAddresses)
 	if err != nil {
 		t.Fatalf("RegisterRootCredential expected no error, got: %v", err)
@@ -284,28 +297,753 @@ func TestCreateDiploma(t *testing.T) {
 	}
 
 	digestRoot, _ := encode.EncodeByteArray(roots)
-	root, _ := tf.Faculty.GetEvidenceRoot(nil, digest)
+	root, _ := tf.Faculty.GetEvidenceRoot(context.Background(), nil, digest)
 	assert.Equal(t, digestRoot, root)
 
-	d := tf.Faculty.GetCredentialProof(nil, digest)
+	d, err := tf.Faculty.GetCredentialProof(context.Background(), nil, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
 	assert.Equal(t, digest, d.Digest)
 
 	// Second administration staff confirm the diploma credentail
-	opts = bind.NewKeyedTransactor(adms[1].Key)
+	opts = tf.Backend.TransactOpts(adms[1].Key)
 	_, err = tf.Faculty.RegisterCredential(opts, student.Address, digest, coursesAddresses)
 	if err != nil {
 		t.Fatalf("Failed to register diploma credential: %v", err)
 	}
 	tf.Backend.Commit()
 
-	opts = bind.NewKeyedTransactor(student.Key)
+	opts = tf.Backend.TransactOpts(student.Key)
 	_, err = tf.Faculty.ApproveCredential(opts, digest)
 	if err != nil {
 		t.Fatalf("failed to confirm issued credential: %v", err)
 	}
 	tf.Backend.Commit()
 
-	if ok, _ := tf.Faculty.IsQuorumSigned(nil, digest); !ok {
+	if ok, _ := tf.Faculty.IsQuorumSigned(context.Background(), nil, digest); !ok {
 		t.Fatalf("Digest %x should be signed", digest)
 	}
 }
+
+// IssueTestDiploma deploys courses as children of the faculty, issues and
+// aggregates the course credentials of the student and registers a diploma
+// credential using the courses as witnesses. It returns the diploma digest.
+func (tf *TestFaculty) IssueTestDiploma(t *testing.T, evaluators backends.Accounts, student backends.Account, courses int) [32]byte {
+	var coursesAddresses []common.Address
+	for i := 0; i < courses; i++ {
+		opts := tf.Backend.TransactOpts(tf.Adms[0].Key)
+		courseAddr, _, courseInstance, err := course.DeployCourse(opts, tf.Backend, tf.Backend.GetLibs(), evaluators.Addresses(), uint8(len(evaluators)))
+		if err != nil {
+			t.Fatalf("Failed to deploy course: %v", err)
+		}
+		tf.Backend.Commit()
+		coursesAddresses = append(coursesAddresses, courseAddr)
+
+		if _, err = tf.Faculty.AddNode(opts, courseAddr); err != nil {
+			t.Fatalf("Failed to add child node: %v", err)
+		}
+		if _, err = courseInstance.AddStudent(tf.Backend.TransactOpts(evaluators[0].Key), student.Address); err != nil {
+			t.Fatalf("Failed to add student to course %s: %v", courseAddr.Hex(), err)
+		}
+		tf.Backend.Commit()
+
+		var digests [][32]byte
+		for j := 0; j < 2; j++ {
+			digest := pb.GenerateRandomDigest(student.Address.Bytes(), 32)
+			tf.issueCredential(t, courseInstance.Node, evaluators, student, digest, []common.Address{})
+			digests = append(digests, digest)
+		}
+		if _, err = courseInstance.AggregateCredentials(tf.Backend.TransactOpts(evaluators[0].Key), student.Address, digests); err != nil {
+			t.Fatalf("Failed to aggregate course credentials: %v", err)
+		}
+		tf.Backend.Commit()
+	}
+
+	digest := pb.GenerateRandomDigest(student.Address.Bytes(), 32)
+	tf.issueCredential(t, tf.Faculty.Node, tf.Adms, student, digest, coursesAddresses)
+	return digest
+}
+
+// hashCredential returns the digest of the credential with the default algorithm.
+func hashCredential(t *testing.T, c pb.Credential) [32]byte {
+	t.Helper()
+	digest, err := pb.DefaultHashAlgorithm.Hash(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func (tf *TestFaculty) issueCredential(t *testing.T, n *node.Node, owners backends.Accounts, student backends.Account, digest [32]byte, witnesses []common.Address) {
+	for _, o := range owners {
+		if _, err := n.RegisterCredential(tf.Backend.TransactOpts(o.Key), student.Address, digest, witnesses); err != nil {
+			t.Fatalf("RegisterCredential expected no error, got: %v", err)
+		}
+		tf.Backend.Commit()
+	}
+	if _, err := n.ApproveCredential(tf.Backend.TransactOpts(student.Key), digest); err != nil {
+		t.Fatalf("ApproveCredential expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+}
+
+func TestVerifyCredentialTree(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	digest := tf.IssueTestDiploma(t, evaluators, student, 4)
+
+	if err := tf.Faculty.VerifyCredentialTree(context.Background(), true, nil, student.Address); err != nil {
+		t.Fatalf("on-chain VerifyCredentialTree expected no error, got: %v", err)
+	}
+	for _, workers := range []int{1, 2, 8} {
+		err := tf.Faculty.VerifyCredentialTreeContext(context.Background(), nil, student.Address, workers)
+		if err != nil {
+			t.Errorf("VerifyCredentialTreeContext with %d workers expected no error, got: %v", workers, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := tf.Faculty.VerifyCredentialTreeContext(ctx, nil, student.Address, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = tf.Faculty.Revoke(tf.Backend.TransactOpts(adms[0].Key), digest, [32]byte{})
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	err = tf.Faculty.VerifyCredentialTreeContext(context.Background(), nil, student.Address, 2)
+	assert.ErrorIs(t, err, node.ErrCredentialRevoked)
+	err = tf.Faculty.VerifyCredentialTree(context.Background(), false, nil, student.Address)
+	assert.ErrorIs(t, err, node.ErrCredentialRevoked)
+}
+
+func TestVerifyCredentialTreeReport(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	digest := tf.IssueTestDiploma(t, evaluators, student, 3)
+
+	report, err := tf.Faculty.VerifyCredentialTreeReport(context.Background(), nil, student.Address, 2)
+	if err != nil {
+		t.Fatalf("VerifyCredentialTreeReport expected no error, got: %v", err)
+	}
+	assert.True(t, report.Valid())
+	assert.Empty(t, report.AllErrors())
+	assert.Len(t, report.Credentials, 1)
+	diploma := report.Credentials[0]
+	assert.Equal(t, common.Hash(digest), diploma.Digest)
+	assert.True(t, diploma.QuorumSigned)
+	assert.Len(t, diploma.Signers, len(adms))
+	assert.Len(t, diploma.Witnesses, 3)
+	for _, w := range diploma.Witnesses {
+		assert.True(t, w.Leaf)
+		assert.True(t, w.RootMatch)
+		assert.Len(t, w.Credentials, 2)
+	}
+
+	_, err = tf.Faculty.Revoke(tf.Backend.TransactOpts(adms[0].Key), digest, notary.ReasonAdministrativeError)
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	report, err = tf.Faculty.VerifyCredentialTreeReport(context.Background(), nil, student.Address, 2)
+	if err != nil {
+		t.Fatalf("VerifyCredentialTreeReport expected no error, got: %v", err)
+	}
+	assert.False(t, report.Valid())
+	assert.ErrorIs(t, report.Err(), node.ErrCredentialRevoked)
+	assert.Len(t, report.AllErrors(), 1)
+	assert.True(t, report.Credentials[0].Revoked)
+	assert.Equal(t, adms[0].Address, report.Credentials[0].Revocation.Revoker)
+	assert.Equal(t, notary.ReasonAdministrativeError.Hash(), report.Credentials[0].Revocation.Reason)
+	assert.Equal(t, "administrative-error", report.Credentials[0].Revocation.ReasonName)
+}
+
+// destroyedBackend serves a contract as if its code was removed, or fails
+// the calls to it as a backend during an outage.
+type destroyedBackend struct {
+	*backends.TestBackend
+	contract common.Address
+	err      error
+}
+
+var errOutage = errors.New("backend unavailable")
+
+func (b *destroyedBackend) CodeAt(ctx context.Context, contract common.Address, block *big.Int) ([]byte, error) {
+	if contract == b.contract {
+		return nil, b.err
+	}
+	return b.TestBackend.CodeAt(ctx, contract, block)
+}
+
+func (b *destroyedBackend) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	if call.To != nil && *call.To == b.contract {
+		return nil, b.err
+	}
+	return b.TestBackend.CallContract(ctx, call, block)
+}
+
+func TestCredentialTreeNotNodeWitness(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	digest := tf.IssueTestDiploma(t, evaluators, student, 2)
+	cp, err := tf.Faculty.GetCredentialProof(context.Background(), nil, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destroyed := cp.Witnesses[1]
+
+	// the witness is reported, and the rest of the tree verified
+	backend := &destroyedBackend{TestBackend: tf.Backend, contract: destroyed}
+	report, err := node.CredentialTree(context.Background(), nil, backend, tf.Faculty.Address(), student.Address, 2)
+	if err != nil {
+		t.Fatalf("CredentialTree expected no error, got: %v", err)
+	}
+	diploma := report.Credentials[0]
+	assert.Equal(t, ctree.Errors{node.ErrWrongEvidenceRoot}, diploma.Errors)
+	if assert.Len(t, diploma.Witnesses, 2) {
+		assert.Empty(t, diploma.Witnesses[0].Errors)
+		assert.Len(t, diploma.Witnesses[0].Credentials, 2)
+		assert.Equal(t, destroyed, diploma.Witnesses[1].Contract)
+		assert.Equal(t, ctree.Errors{node.ErrNotNodeContract}, diploma.Witnesses[1].Errors)
+	}
+	assert.Len(t, report.AllErrors(), 2)
+
+	// an outage is not a verification failure
+	backend.err = errOutage
+	_, err = node.CredentialTree(context.Background(), nil, backend, tf.Faculty.Address(), student.Address, 2)
+	assert.ErrorIs(t, err, errOutage)
+}
+
+func TestVerificationBundle(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	digest := tf.IssueTestDiploma(t, evaluators, student, 2)
+	ctx := context.Background()
+	header, err := tf.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := bundle.Export(ctx, tf.Backend, tf.Faculty.Address(), student.Address, nil)
+	if err != nil {
+		t.Fatalf("Export expected no error, got: %v", err)
+	}
+
+	// revoking after the pinned block does not change the bundle
+	_, err = tf.Faculty.Revoke(tf.Backend.TransactOpts(adms[0].Key), digest, [32]byte{1})
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func() *bundle.Bundle {
+		var b bundle.Bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+		return &b
+	}
+
+	report, err := bundle.Verify(ctx, load(), header.Hash())
+	if err != nil {
+		t.Fatalf("Verify expected no error, got: %v", err)
+	}
+	assert.True(t, report.Valid())
+	assert.Equal(t, common.Hash(digest), report.Credentials[0].Digest)
+	assert.Len(t, report.Credentials[0].Witnesses, 2)
+
+	_, err = bundle.Verify(ctx, load(), common.Hash{1})
+	assert.ErrorIs(t, err, bundle.ErrUntrustedHeader)
+
+	tampered := load()
+	for _, a := range tampered.Accounts {
+		if a.Address == tf.Faculty.Address() {
+			a.Storage[0].Value[31] ^= 1
+		}
+	}
+	_, err = bundle.Verify(ctx, tampered, header.Hash())
+	assert.ErrorIs(t, err, bundle.ErrInvalidProof)
+
+	incomplete := load()
+	for _, a := range incomplete.Accounts {
+		if a.Address == tf.Faculty.Address() {
+			a.Storage = a.Storage[1:]
+		}
+	}
+	_, err = bundle.Verify(ctx, incomplete, header.Hash())
+	assert.ErrorIs(t, err, bundle.ErrIncompleteBundle)
+
+	wrongReport := load()
+	wrongReport.Report = []byte(`{}`)
+	_, err = bundle.Verify(ctx, wrongReport, header.Hash())
+	assert.ErrorIs(t, err, bundle.ErrReportMismatch)
+}
+
+func TestVerifyEvidenceRoot(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	digest := tf.IssueTestDiploma(t, evaluators, student, 2)
+	cp, err := tf.Faculty.GetCredentialProof(context.Background(), nil, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evidenceRoot, err := node.EvidenceRoot(context.Background(), nil, tf.Backend, student.Address, cp.Witnesses)
+	assert.NoError(t, err)
+	assert.Equal(t, cp.EvidenceRoot, evidenceRoot)
+	assert.NoError(t, tf.Faculty.VerifyCredentialTree(context.Background(), false, nil, student.Address))
+
+	// the course root changes after the diploma was issued
+	c, err := course.NewCourse(cp.Witnesses[1], tf.Backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := pb.GenerateRandomDigest(student.Address.Bytes(), 32)
+	tf.issueCredential(t, c.Node, evaluators, student, extra, []common.Address{})
+	digests, err := c.GetDigests(context.Background(), nil, student.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.AggregateCredentials(tf.Backend.TransactOpts(evaluators[0].Key), student.Address, digests); err != nil {
+		t.Fatalf("AggregateCredentials expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	err = tf.Faculty.VerifyCredential(context.Background(), false, nil, student.Address, digest)
+	assert.ErrorIs(t, err, node.ErrWrongEvidenceRoot)
+	err = tf.Faculty.VerifyCredentialTree(context.Background(), false, nil, student.Address)
+	assert.ErrorIs(t, err, node.ErrWrongEvidenceRoot)
+
+	report, err := tf.Faculty.VerifyCredentialTreeReport(context.Background(), nil, student.Address, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, ctree.Errors{node.ErrWrongEvidenceRoot}, report.Credentials[0].Errors)
+	assert.Len(t, report.AllErrors(), 1)
+}
+
+func TestRevocationImpact(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	diploma := tf.IssueTestDiploma(t, evaluators, student, 2)
+	cp, err := tf.Faculty.GetCredentialProof(context.Background(), nil, diploma)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnesses := cp.Witnesses
+	c, err := course.NewCourse(witnesses[0], tf.Backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests, err := c.GetDigests(context.Background(), nil, student.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Revoke(tf.Backend.TransactOpts(evaluators[0].Key), digests[0], [32]byte{}); err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	// the parent of the course is the account that deployed it
+	report, err := node.RevocationImpact(context.Background(), nil, tf.Backend, c.Address(), digests[0], nil)
+	if err != nil {
+		t.Fatalf("RevocationImpact expected no error, got: %v", err)
+	}
+	assert.Empty(t, report.Affected())
+
+	known := []common.Address{tf.Faculty.Address(), witnesses[1]}
+	report, err = node.RevocationImpact(context.Background(), nil, tf.Backend, c.Address(), digests[0], known)
+	if err != nil {
+		t.Fatalf("RevocationImpact expected no error, got: %v", err)
+	}
+	assert.True(t, report.Revoked)
+	assert.Len(t, report.Dependents, 1)
+	dep := report.Dependents[0]
+	assert.Equal(t, tf.Faculty.Address(), dep.Contract)
+	assert.Equal(t, common.Hash(diploma), dep.Digest)
+	assert.False(t, dep.Revoked)
+	assert.Equal(t, []common.Address{student.Address}, report.Subjects())
+	assert.Equal(t, []ctree.Step{
+		{Action: ctree.ActionRevoke, Contract: tf.Faculty.Address(), Subject: student.Address, Digest: diploma},
+		{Action: ctree.ActionReissue, Contract: tf.Faculty.Address(), Subject: student.Address, Digest: diploma, Witnesses: witnesses[1:]},
+	}, report.Plan())
+
+	// a credential issued after the diploma is not in its evidence root
+	late := pb.GenerateRandomDigest(student.Address.Bytes(), 32)
+	tf.issueCredential(t, c.Node, evaluators, student, late, []common.Address{})
+	if _, err = c.Revoke(tf.Backend.TransactOpts(evaluators[0].Key), late, [32]byte{}); err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+	report, err = node.RevocationImpact(context.Background(), nil, tf.Backend, c.Address(), late, known)
+	assert.NoError(t, err)
+	assert.True(t, report.Revoked)
+	assert.Empty(t, report.Affected())
+
+	// the diploma is not used as evidence
+	report, err = node.RevocationImpact(context.Background(), nil, tf.Backend, tf.Faculty.Address(), diploma, known)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Affected())
+	assert.Empty(t, report.Plan())
+
+	_, err = node.RevocationImpact(context.Background(), nil, tf.Backend, witnesses[1], [32]byte{1}, known)
+	assert.ErrorIs(t, err, node.ErrCredentialNotFound)
+}
+
+func TestReplayEvents(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	diploma := tf.IssueTestDiploma(t, evaluators, student, 1)
+	ctx := context.Background()
+	children, err := tf.Faculty.GetChildren(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contracts := append([]common.Address{tf.Faculty.Address()}, children...)
+
+	events, err := node.ReplayEvents(ctx, tf.Backend, node.EventQuery{Contracts: contracts}, nil)
+	if err != nil {
+		t.Fatalf("ReplayEvents expected no error, got: %v", err)
+	}
+	count := make(map[node.EventKind]int)
+	for i, e := range events {
+		count[e.Meta().Kind]++
+		if i > 0 {
+			prev := events[i-1].Meta()
+			assert.True(t, prev.BlockNumber < e.Meta().BlockNumber || prev.LogIndex < e.Meta().LogIndex)
+		}
+	}
+	assert.Equal(t, 1, count[node.EventChildAdded])
+	assert.Equal(t, 1, count[node.EventStudentEnrolled])
+	assert.Equal(t, 2, count[node.EventRegistered])
+	assert.Equal(t, 1, count[node.EventAggregated])
+	assert.Equal(t, 3, count[node.EventApproved])
+	assert.Positive(t, count[node.EventSigned])
+
+	q := node.EventQuery{
+		Contracts: contracts,
+		Kinds:     []node.EventKind{node.EventAggregated, node.EventApproved},
+		Digests:   []common.Hash{diploma},
+	}
+	events, err = node.ReplayEvents(ctx, tf.Backend, q, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		aggregated := events[0].(*node.CredentialAggregated)
+		assert.Equal(t, tf.Faculty.Address(), aggregated.Contract)
+		assert.Equal(t, student.Address, aggregated.Subject)
+		assert.Equal(t, children, aggregated.Witnesses)
+		approved := events[1].(*node.CredentialApproved)
+		assert.Equal(t, common.Hash(diploma), approved.Digest)
+	}
+
+	events, err = node.ReplayEvents(ctx, tf.Backend, node.EventQuery{Contracts: contracts, Subjects: []common.Address{evaluators[0].Address}}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// droppingBackend drops the log subscriptions on demand, as a websocket
+// connection that is lost.
+type droppingBackend struct {
+	*backends.TestBackend
+	mu   sync.Mutex
+	subs []*droppingSub
+}
+
+type droppingSub struct {
+	event.Subscription
+	err chan error
+}
+
+func (s *droppingSub) Err() <-chan error { return s.err }
+
+var errDropped = errors.New("connection dropped")
+
+func (b *droppingBackend) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub, err := b.TestBackend.SubscribeFilterLogs(ctx, q, ch)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &droppingSub{sub, make(chan error, 1)}
+	b.subs = append(b.subs, s)
+	return s, nil
+}
+
+func (b *droppingBackend) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subs {
+		s.Unsubscribe()
+		s.err <- errDropped
+	}
+	b.subs = nil
+}
+
+func (b *droppingBackend) subscriptions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+	diploma := tf.IssueTestDiploma(t, evaluators, student, 1)
+
+	backoff := node.ResubscribeBackoff
+	node.ResubscribeBackoff = 10 * time.Millisecond
+	defer func() { node.ResubscribeBackoff = backoff }()
+
+	backend := &droppingBackend{TestBackend: tf.Backend}
+	ctx := context.Background()
+	children, err := tf.Faculty.GetChildren(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := course.NewCourse(children[0], tf.Backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := make(chan node.Event)
+	q := node.EventQuery{
+		Contracts: []common.Address{tf.Faculty.Address(), c.Address()},
+		Kinds:     []node.EventKind{node.EventApproved, node.EventRevoked},
+		FromBlock: big.NewInt(0),
+	}
+	sub, err := node.SubscribeEvents(ctx, backend, q, sink)
+	if err != nil {
+		t.Fatalf("SubscribeEvents expected no error, got: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	next := func() node.Event {
+		t.Helper()
+		select {
+		case e := <-sink:
+			return e
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		return nil
+	}
+
+	// replayed, the course credentials are approved before the diploma
+	var approved *node.CredentialApproved
+	for i := 0; i < 3; i++ {
+		approved = next().(*node.CredentialApproved)
+	}
+	assert.Equal(t, tf.Faculty.Address(), approved.Contract)
+	assert.Equal(t, common.Hash(diploma), approved.Digest)
+
+	// revoked while the connection is lost
+	backend.drop()
+	_, err = tf.Faculty.Revoke(tf.Backend.TransactOpts(adms[0].Key), diploma, notary.ReasonRegrade)
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+
+	revoked := next().(*node.CredentialRevoked)
+	assert.Equal(t, common.Hash(diploma), revoked.Digest)
+	assert.Equal(t, student.Address, revoked.Subject)
+	assert.Equal(t, "regrade", revoked.ReasonName)
+
+	// live after resubscribing, without repeating the replayed events
+	for backend.subscriptions() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	digest := pb.GenerateRandomDigest(student.Address.Bytes(), 32)
+	tf.issueCredential(t, c.Node, evaluators, student, digest, []common.Address{})
+	approved = next().(*node.CredentialApproved)
+	assert.Equal(t, c.Address(), approved.Contract)
+	assert.Equal(t, common.Hash(digest), approved.Digest)
+}
+
+func TestRenderCredentialTree(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student := backends.TestAccounts[4]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	diploma := tf.IssueTestDiploma(t, evaluators, student, 2)
+	_, err := tf.Faculty.Revoke(tf.Backend.TransactOpts(adms[0].Key), diploma, notary.ReasonPlagiarism)
+	if err != nil {
+		t.Fatalf("Revoke expected no error, got: %v", err)
+	}
+	tf.Backend.Commit()
+	ctx := context.Background()
+
+	report, err := node.CredentialTree(ctx, nil, tf.Backend, tf.Faculty.Address(), student.Address, 2)
+	if err != nil {
+		t.Fatalf("CredentialTree expected no error, got: %v", err)
+	}
+	children, err := tf.Faculty.GetChildren(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	assert.NoError(t, report.Render(&text, ctree.FormatText))
+	assert.Equal(t, 3, strings.Count(text.String(), "Contract "))
+	assert.Equal(t, 5, strings.Count(text.String(), "Credential "))
+	assert.Contains(t, text.String(), "(reason plagiarism)")
+
+	var dot strings.Builder
+	assert.NoError(t, report.Render(&dot, ctree.FormatDOT))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph ctree {"))
+	for _, c := range append(children, tf.Faculty.Address()) {
+		assert.Equal(t, 1, strings.Count(dot.String(), fmt.Sprintf("%q [shape=box", c.Hex())))
+	}
+	assert.Equal(t, 2, strings.Count(dot.String(), "[style=dotted]"))
+	assert.Contains(t, dot.String(), "revoked: plagiarism")
+
+	var out bytes.Buffer
+	assert.NoError(t, report.Render(&out, ctree.FormatJSON))
+	var decoded struct {
+		Contract    common.Address
+		Credentials []struct {
+			Digest    common.Hash
+			Witnesses []struct{ Contract common.Address }
+		}
+	}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, tf.Faculty.Address(), decoded.Contract)
+	if assert.Len(t, decoded.Credentials, 1) {
+		assert.Equal(t, common.Hash(diploma), decoded.Credentials[0].Digest)
+		assert.Len(t, decoded.Credentials[0].Witnesses, 2)
+	}
+
+	_, err = ctree.ParseFormat("svg")
+	assert.Error(t, err)
+}
+
+func TestPreflightChecks(t *testing.T) {
+	adms := backends.TestAccounts[:2]
+	evaluators := backends.TestAccounts[2:4]
+	student, other := backends.TestAccounts[4], backends.TestAccounts[5]
+
+	tf := NewTestFaculty(t, adms, uint8(len(adms)))
+	defer tf.Backend.Close()
+
+	ctx := context.Background()
+	f := tf.Faculty
+	opts := tf.Backend.TransactOpts(adms[0].Key)
+	courseAddr, _, c, err := course.DeployCourse(opts, tf.Backend, tf.Backend.GetLibs(), evaluators.Addresses(), 1)
+	if err != nil {
+		t.Fatalf("Failed to deploy course: %v", err)
+	}
+	tf.Backend.Commit()
+
+	assert.ErrorIs(t, f.CheckAddNode(ctx, nil, student.Address, courseAddr), node.ErrNotOwner)
+	assert.ErrorIs(t, f.CheckAddNode(ctx, nil, adms[0].Address, f.Address()), node.ErrSelfChild)
+	assert.ErrorIs(t, c.CheckAddNode(ctx, nil, evaluators[0].Address, f.Address()), node.ErrLeafNode)
+	assert.NoError(t, f.CheckAddNode(ctx, nil, adms[0].Address, courseAddr))
+
+	digest := [32]byte{1}
+	err = f.CheckRegisterCredential(ctx, nil, adms[0].Address, student.Address, digest, nil)
+	assert.ErrorIs(t, err, node.ErrNoWitnesses)
+	err = f.CheckRegisterCredential(ctx, nil, adms[0].Address, student.Address, digest, []common.Address{courseAddr})
+	assert.ErrorIs(t, err, node.ErrNotChild)
+	_, err = f.RegisterCredential(opts, student.Address, digest, []common.Address{courseAddr})
+	assert.Error(t, err)
+
+	if _, err = f.AddNode(opts, courseAddr); err != nil {
+		t.Fatalf("Failed to add child node: %v", err)
+	}
+	tf.Backend.Commit()
+	assert.ErrorIs(t, f.CheckAddNode(ctx, nil, adms[0].Address, courseAddr), node.ErrChildAdded)
+
+	// the student has no root in the course yet
+	err = f.CheckRegisterCredential(ctx, nil, adms[0].Address, student.Address, digest, []common.Address{courseAddr})
+	assert.ErrorIs(t, err, node.ErrRootNotFound)
+	_, err = f.RegisterCredential(opts, student.Address, digest, []common.Address{courseAddr})
+	assert.Error(t, err)
+
+	courseDigest := [32]byte{2}
+	tf.issueCredential(t, c.Node, evaluators[:1], student, courseDigest, nil)
+	if _, err = c.AggregateCredentials(tf.Backend.TransactOpts(evaluators[0].Key), student.Address, [][32]byte{courseDigest}); err != nil {
+		t.Fatalf("Failed to aggregate course credentials: %v", err)
+	}
+	tf.Backend.Commit()
+	assert.NoError(t, f.CheckRegisterCredential(ctx, nil, adms[0].Address, student.Address, digest, []common.Address{courseAddr}))
+	_, err = f.RegisterCredential(opts, student.Address, digest, []common.Address{courseAddr})
+	assert.NoError(t, err)
+	tf.Backend.Commit()
+
+	// a new course credential changes the root of the witness
+	tf.issueCredential(t, c.Node, evaluators[:1], student, [32]byte{3}, nil)
+	if _, err = c.AggregateCredentials(tf.Backend.TransactOpts(evaluators[0].Key), student.Address, [][32]byte{courseDigest, {3}}); err != nil {
+		t.Fatalf("Failed to aggregate course credentials: %v", err)
+	}
+	tf.Backend.Commit()
+	err = f.CheckRegisterCredential(ctx, nil, adms[1].Address, student.Address, digest, []common.Address{courseAddr})
+	assert.ErrorIs(t, err, node.ErrWrongEvidenceRoot)
+	_, err = f.RegisterCredential(tf.Backend.TransactOpts(adms[1].Key), student.Address, digest, []common.Address{courseAddr})
+	assert.Error(t, err)
+
+	semester := [32]byte{4}
+	assert.ErrorIs(t, f.CheckRegisterSemester(ctx, nil, student.Address, semester, []common.Address{courseAddr}), node.ErrNotOwner)
+	assert.ErrorIs(t, f.CheckRegisterSemester(ctx, nil, adms[0].Address, semester, nil), ErrNoCourses)
+	assert.NoError(t, f.CheckRegisterSemester(ctx, nil, adms[0].Address, semester, []common.Address{courseAddr}))
+	if _, err = f.RegisterSemester(opts, semester, []common.Address{courseAddr}); err != nil {
+		t.Fatalf("Failed to register semester: %v", err)
+	}
+	tf.Backend.Commit()
+	assert.ErrorIs(t, f.CheckRegisterSemester(ctx, nil, adms[0].Address, semester, []common.Address{courseAddr}), ErrSemesterRegistered)
+
+	// the owners of a course with a partial quorum cannot be changed
+	err = c.CheckChangeOwner(ctx, nil, evaluators[0].Address, other.Address)
+	assert.ErrorIs(t, err, owners.ErrPartialQuorum)
+	_, err = c.ChangeOwner(tf.Backend.TransactOpts(evaluators[0].Key), other.Address)
+	assert.Error(t, err)
+	assert.NoError(t, f.CheckChangeOwner(ctx, nil, adms[0].Address, other.Address))
+}
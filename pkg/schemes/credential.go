@@ -0,0 +1,128 @@
+package schemes
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrUnknownCredential = errors.New("unknown credential document")
+	ErrInvalidEntityID   = errors.New("entity id is not an address")
+	ErrNotYetValid       = errors.New("credential not yet valid")
+	ErrExpired           = errors.New("credential expired")
+	ErrInvalidValidity   = errors.New("credential expires before it is valid")
+)
+
+// Credential is a credential document issued by a node contract.
+type Credential interface {
+	proto.Message
+	GetCreatedBy() string
+	GetCreatedAt() *timestamppb.Timestamp
+	GetOfferedBy() []*Entity
+	GetEvidenceDocument() string
+	GetDocumentPresence() string
+	GetAdditionalInformation() *anypb.Any
+	GetValidFrom() *timestamppb.Timestamp
+	GetValidUntil() *timestamppb.Timestamp
+}
+
+// ParseCredential parses a JSON assignment, course or diploma credential.
+func ParseCredential(data []byte) (Credential, error) {
+	candidates := []Credential{
+		&AssignmentGradeCredential{},
+		&CourseGradeCredential{},
+		&DiplomaCredential{},
+	}
+	for _, c := range candidates {
+		if protojson.Unmarshal(data, c) == nil && Subject(c) != nil {
+			return c, nil
+		}
+	}
+	return nil, ErrUnknownCredential
+}
+
+// Subject returns the student of the credential.
+func Subject(c Credential) *Entity {
+	switch c := c.(type) {
+	case *AssignmentGradeCredential:
+		return c.GetAssignment().GetStudent()
+	case *CourseGradeCredential:
+		return c.GetCourse().GetStudent()
+	case *DiplomaCredential:
+		return c.GetDiploma().GetStudent()
+	}
+	return nil
+}
+
+// Evaluators returns the evaluators that signed the credential.
+func Evaluators(c Credential) []*Entity {
+	switch c := c.(type) {
+	case *AssignmentGradeCredential:
+		return c.GetAssignment().GetEvaluators()
+	case *CourseGradeCredential:
+		return c.GetCourse().GetEvaluators()
+	case *DiplomaCredential:
+		return c.GetDiploma().GetEvaluators()
+	}
+	return nil
+}
+
+// Address returns the Ethereum address of an entity id,
+// which can be an address or a DID ending with an address.
+func Address(id string) (common.Address, error) {
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		id = id[i+1:]
+	}
+	if !common.IsHexAddress(id) {
+		return common.Address{}, ErrInvalidEntityID
+	}
+	return common.HexToAddress(id), nil
+}
+
+// SetValidity sets the validity period of the credential. A zero time
+// leaves the period open at that end.
+func SetValidity(c Credential, from, until time.Time) {
+	ts := func(t time.Time) *timestamppb.Timestamp {
+		if t.IsZero() {
+			return nil
+		}
+		return timestamppb.New(t)
+	}
+	switch c := c.(type) {
+	case *AssignmentGradeCredential:
+		c.ValidFrom, c.ValidUntil = ts(from), ts(until)
+	case *CourseGradeCredential:
+		c.ValidFrom, c.ValidUntil = ts(from), ts(until)
+	case *DiplomaCredential:
+		c.ValidFrom, c.ValidUntil = ts(from), ts(until)
+	}
+}
+
+// CheckValidity checks that the time is within the validity period of the
+// credential: not before validFrom and before validUntil. Credentials
+// without validFrom or validUntil are valid since, or until, any time.
+func CheckValidity(c Credential, t time.Time) error {
+	return CheckValidityPeriod(c.GetValidFrom(), c.GetValidUntil(), t)
+}
+
+// CheckValidityPeriod is CheckValidity for the validFrom and validUntil
+// of a credential, e.g. disclosed by a presentation.
+func CheckValidityPeriod(from, until *timestamppb.Timestamp, t time.Time) error {
+	if from != nil && until != nil && until.AsTime().Before(from.AsTime()) {
+		return ErrInvalidValidity
+	}
+	if from != nil && t.Before(from.AsTime()) {
+		return ErrNotYetValid
+	}
+	if until != nil && !t.Before(until.AsTime()) {
+		return ErrExpired
+	}
+	return nil
+}
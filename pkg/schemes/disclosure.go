@@ -0,0 +1,277 @@
+package schemes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/relab/credbench/pkg/encode"
+)
+
+// SaltSize is the size of the salts of the claims, which prevent the
+// undisclosed claims from being guessed from their hash.
+const SaltSize = 16
+
+var (
+	ErrClaimNotFound     = errors.New("claim not found")
+	ErrInvalidDisclosure = errors.New("invalid disclosure")
+)
+
+var subjectPath = regexp.MustCompile(`^/[A-Za-z]+/student/id$`)
+
+// validityPaths are the claims of the validity period of a credential,
+// always disclosed so that the verifier can check it.
+var validityPaths = []string{"/validFrom", "/validUntil"}
+
+// Claim is a field of a credential document: its JSON pointer (RFC 6901)
+// in the protojson encoding of the document, its canonical JSON value and
+// the salt of its hash.
+type Claim struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+	Salt  hexutil.Bytes   `json:"salt"`
+}
+
+// Hash returns the Keccak-256 of the canonical JSON of the salt, path and
+// value of the claim, as an array.
+func (c *Claim) Hash() ([32]byte, error) {
+	data, err := json.Marshal([]interface{}{c.Salt.String(), c.Path, c.Value})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if data, err = Canonicalize(data); err != nil {
+		return [32]byte{}, err
+	}
+	return crypto.Keccak256Hash(data), nil
+}
+
+// SaltedCredential is a credential document split in salted claims, one
+// per field, given to its holder. Its digest is the Merkle root of the
+// hashes of the claims, sorted by path, so that the holder can disclose
+// some claims only, with their inclusion proof.
+type SaltedCredential struct {
+	Type     string   `json:"type"`
+	Contract string   `json:"contract,omitempty"`
+	Claims   []*Claim `json:"claims"`
+}
+
+// Disclosure is a disclosed claim with its inclusion proof in the digest
+// of the credential.
+type Disclosure struct {
+	*Claim
+	Proof *encode.MerkleProof `json:"proof"`
+}
+
+// Presentation discloses some claims of a salted credential.
+type Presentation struct {
+	Type     string        `json:"type"`
+	Contract string        `json:"contract,omitempty"`
+	Digest   common.Hash   `json:"digest"`
+	Claims   []*Disclosure `json:"claims"`
+}
+
+// NewSaltedCredential splits the credential document in claims salted
+// with random bytes read from rand, e.g. crypto/rand.Reader.
+func NewSaltedCredential(c Credential, rand io.Reader) (*SaltedCredential, error) {
+	data, err := CanonicalJSON(c)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	s := &SaltedCredential{Type: string(c.ProtoReflect().Descriptor().Name())}
+	if err := s.addClaims("", doc, rand); err != nil {
+		return nil, err
+	}
+	sort.Slice(s.Claims, func(i, j int) bool { return s.Claims[i].Path < s.Claims[j].Path })
+	return s, nil
+}
+
+// addClaims adds the claims of the fields of a JSON value, the empty
+// objects and arrays being fields themselves.
+func (s *SaltedCredential) addClaims(path string, v interface{}, rand io.Reader) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for k, e := range v {
+				if err := s.addClaims(path+"/"+escapePointer(k), e, rand); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			for i, e := range v {
+				if err := s.addClaims(path+"/"+strconv.Itoa(i), e, rand); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if data, err = Canonicalize(data); err != nil {
+		return err
+	}
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return err
+	}
+	s.Claims = append(s.Claims, &Claim{Path: path, Value: data, Salt: salt})
+	return nil
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func (s *SaltedCredential) leaves() ([][32]byte, error) {
+	leaves := make([][32]byte, len(s.Claims))
+	for i, c := range s.Claims {
+		h, err := c.Hash()
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = h
+	}
+	return leaves, nil
+}
+
+// Digest returns the Merkle root of the hashes of the claims, which is
+// registered as the digest of the credential.
+func (s *SaltedCredential) Digest() ([32]byte, error) {
+	leaves, err := s.leaves()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return encode.MerkleRoot(leaves)
+}
+
+// Disclose returns a presentation disclosing the claims at the paths, or
+// nested in them: "/course/assignments/0" discloses every field of the
+// first assignment of a course. The validity period of the credential, if
+// any, is always disclosed.
+func (s *SaltedCredential) Disclose(paths ...string) (*Presentation, error) {
+	leaves, err := s.leaves()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := encode.MerkleRoot(leaves)
+	if err != nil {
+		return nil, err
+	}
+	p := &Presentation{Type: s.Type, Contract: s.Contract, Digest: digest}
+	found := make(map[string]bool, len(paths))
+	for i, c := range s.Claims {
+		disclosed := false
+		for _, path := range validityPaths {
+			disclosed = disclosed || c.Path == path
+		}
+		for _, path := range paths {
+			if c.Path == path || strings.HasPrefix(c.Path, strings.TrimSuffix(path, "/")+"/") {
+				disclosed, found[path] = true, true
+			}
+		}
+		if !disclosed {
+			continue
+		}
+		proof, err := encode.NewMerkleProof(leaves, i)
+		if err != nil {
+			return nil, err
+		}
+		p.Claims = append(p.Claims, &Disclosure{Claim: c, Proof: proof})
+	}
+	for _, path := range paths {
+		if !found[path] {
+			return nil, fmt.Errorf("%w: %s", ErrClaimNotFound, path)
+		}
+	}
+	return p, nil
+}
+
+// Verify checks that the disclosed claims belong to the digest of the
+// presentation.
+func (p *Presentation) Verify() error {
+	if len(p.Claims) == 0 {
+		return fmt.Errorf("%w: no claims", ErrInvalidDisclosure)
+	}
+	for _, d := range p.Claims {
+		if d.Claim == nil || d.Proof == nil {
+			return fmt.Errorf("%w: claim without proof", ErrInvalidDisclosure)
+		}
+		h, err := d.Hash()
+		if err != nil {
+			return err
+		}
+		if h != d.Proof.Leaf || !d.Proof.Verify(p.Digest) {
+			return fmt.Errorf("%w: %s", ErrInvalidDisclosure, d.Path)
+		}
+	}
+	return nil
+}
+
+// Claim returns the value of the disclosed claim at the path.
+func (p *Presentation) Claim(path string) (json.RawMessage, bool) {
+	for _, d := range p.Claims {
+		if d.Claim != nil && d.Path == path {
+			return d.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Subject returns the address of the student of the credential, if it is
+// disclosed.
+func (p *Presentation) Subject() (common.Address, bool) {
+	for _, d := range p.Claims {
+		if d.Claim == nil || !subjectPath.MatchString(d.Path) {
+			continue
+		}
+		var id string
+		if err := json.Unmarshal(d.Value, &id); err != nil {
+			return common.Address{}, false
+		}
+		addr, err := Address(id)
+		return addr, err == nil
+	}
+	return common.Address{}, false
+}
+
+// Validity returns the disclosed validity period of the credential, nil
+// at the ends it leaves open.
+func (p *Presentation) Validity() (from, until *timestamppb.Timestamp, err error) {
+	ts := make([]*timestamppb.Timestamp, len(validityPaths))
+	for i, path := range validityPaths {
+		v, ok := p.Claim(path)
+		if !ok {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidDisclosure, path, err)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidDisclosure, path, err)
+		}
+		ts[i] = timestamppb.New(t)
+	}
+	return ts[0], ts[1], nil
+}
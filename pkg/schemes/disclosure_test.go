@@ -0,0 +1,133 @@
+package schemes
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/relab/credbench/pkg/encode"
+)
+
+func saltedCourse(t *testing.T) (*CourseGradeCredential, *SaltedCredential) {
+	c := fakeCredentials(t)[1].(*CourseGradeCredential)
+	s, err := NewSaltedCredential(c, rand.Reader)
+	require.NoError(t, err)
+	return c, s
+}
+
+func TestSaltedCredential(t *testing.T) {
+	c, s := saltedCourse(t)
+	assert.Equal(t, "CourseGradeCredential", s.Type)
+	for i, claim := range s.Claims {
+		assert.Len(t, claim.Salt, SaltSize)
+		if i > 0 {
+			assert.Less(t, s.Claims[i-1].Path, claim.Path)
+		}
+	}
+
+	digest, err := s.Digest()
+	require.NoError(t, err)
+	leaves, err := s.leaves()
+	require.NoError(t, err)
+	root, err := encode.MerkleRoot(leaves)
+	require.NoError(t, err)
+	assert.Equal(t, root, digest)
+
+	// the salts make the digests of the same document unlinkable
+	other, err := NewSaltedCredential(c, rand.Reader)
+	require.NoError(t, err)
+	otherDigest, err := other.Digest()
+	require.NoError(t, err)
+	assert.NotEqual(t, digest, otherDigest)
+	assert.Equal(t, len(s.Claims), len(other.Claims))
+}
+
+func TestDisclose(t *testing.T) {
+	c, s := saltedCourse(t)
+	p, err := s.Disclose("/course/finalGrade", "/course/totalCredits", "/course/student")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(p.Claims), 3)
+
+	// the presentation is verified as sent to the verifier
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	received := &Presentation{}
+	require.NoError(t, json.Unmarshal(data, received))
+	require.NoError(t, received.Verify())
+	digest, err := s.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, common.Hash(digest), received.Digest)
+
+	grade, ok := received.Claim("/course/finalGrade")
+	require.True(t, ok)
+	assert.JSONEq(t, fmt.Sprintf(`"%d"`, c.Course.FinalGrade), string(grade))
+	_, ok = received.Claim("/course/name")
+	assert.False(t, ok, "undisclosed claim")
+	subject, ok := received.Subject()
+	require.True(t, ok)
+	assert.Equal(t, common.HexToAddress(Subject(c).GetId()), subject)
+
+	_, err = s.Disclose("/course/grade")
+	assert.ErrorIs(t, err, ErrClaimNotFound)
+
+	// the fields nested in a path are disclosed
+	p, err = s.Disclose("/course/assignments/1/")
+	require.NoError(t, err)
+	assert.Greater(t, len(p.Claims), 5)
+	require.NoError(t, p.Verify())
+	_, ok = p.Subject()
+	assert.False(t, ok, "assignment student is not the subject")
+}
+
+func TestDiscloseValidity(t *testing.T) {
+	c := fakeCredentials(t)[0]
+	s, err := NewSaltedCredential(c, rand.Reader)
+	require.NoError(t, err)
+	p, err := s.Disclose("/assignment/name")
+	require.NoError(t, err)
+	require.NoError(t, p.Verify())
+	from, until, err := p.Validity()
+	require.NoError(t, err)
+	assert.True(t, from.AsTime().Equal(c.GetValidFrom().AsTime()))
+	assert.True(t, until.AsTime().Equal(c.GetValidUntil().AsTime()))
+
+	// credentials without validity period have none disclosed
+	_, s = saltedCourse(t)
+	p, err = s.Disclose("/course/finalGrade")
+	require.NoError(t, err)
+	from, until, err = p.Validity()
+	require.NoError(t, err)
+	assert.Nil(t, from)
+	assert.Nil(t, until)
+}
+
+func TestDiscloseTampered(t *testing.T) {
+	_, s := saltedCourse(t)
+	tests := []struct {
+		name   string
+		tamper func(p *Presentation)
+	}{
+		{"value", func(p *Presentation) { p.Claims[0].Value = json.RawMessage(`"tampered"`) }},
+		{"path", func(p *Presentation) { p.Claims[0].Path = "/course/totalCredits" }},
+		{"salt", func(p *Presentation) { p.Claims[0].Salt = make([]byte, SaltSize) }},
+		{"digest", func(p *Presentation) { p.Digest = common.Hash{1} }},
+		{"proof", func(p *Presentation) { p.Claims[0].Proof.Index++ }},
+		{"no claims", func(p *Presentation) { p.Claims = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := s.Disclose("/course/finalGrade")
+			require.NoError(t, err)
+			// disclosures share the claims of the credential
+			claim := *p.Claims[0].Claim
+			p.Claims[0].Claim = &claim
+			tt.tamper(p)
+			assert.ErrorIs(t, p.Verify(), ErrInvalidDisclosure)
+		})
+	}
+}
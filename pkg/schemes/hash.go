@@ -0,0 +1,408 @@
+package schemes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// HashAlgorithm identifies how the digest of a credential document is
+// computed. The algorithms are versioned: the encoding of an algorithm
+// never changes, so that the digests registered on-chain can be reproduced
+// later. A new encoding is a new algorithm.
+type HashAlgorithm string
+
+const (
+	// ProtoSHA256 is the SHA-256 of the canonical protobuf encoding of the
+	// document: the set fields in field number order, the repeated scalars
+	// packed and the map entries sorted by key. For documents without map
+	// fields, it is the digest of the proto.Marshal encoding.
+	ProtoSHA256 HashAlgorithm = "proto-sha256-v1"
+	// JCSKeccak256 is the Keccak-256 of the protojson encoding of the
+	// document canonicalized with the JSON Canonicalization Scheme (RFC 8785).
+	JCSKeccak256 HashAlgorithm = "jcs-keccak256-v1"
+	// URDNA2015SHA256 is the SHA-256 of the document converted to a
+	// verifiable credential without status and processed as JSON-LD, with
+	// the contexts shipped with the package: of its RDF dataset
+	// canonicalized with URDNA2015, serialized as N-Quads.
+	URDNA2015SHA256 HashAlgorithm = "urdna2015-sha256-v1"
+
+	// DefaultHashAlgorithm is the algorithm of the digests registered
+	// by the bench.
+	DefaultHashAlgorithm = ProtoSHA256
+)
+
+var (
+	ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+	ErrUnknownFields        = errors.New("document has unknown fields")
+)
+
+// HashAlgorithms returns the supported hash algorithms.
+func HashAlgorithms() []HashAlgorithm {
+	return []HashAlgorithm{ProtoSHA256, JCSKeccak256, URDNA2015SHA256}
+}
+
+// ParseHashAlgorithm returns the hash algorithm of an identifier. The
+// empty identifier is the default algorithm, which digests recorded
+// without an algorithm use.
+func ParseHashAlgorithm(id string) (HashAlgorithm, error) {
+	if id == "" {
+		return DefaultHashAlgorithm, nil
+	}
+	for _, a := range HashAlgorithms() {
+		if string(a) == id {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, id)
+}
+
+// Hash returns the digest of the message with the algorithm. Messages with
+// unknown fields, e.g. decoded with an outdated schema, are not hashed,
+// since their digest could not be reproduced.
+func (a HashAlgorithm) Hash(m proto.Message) ([32]byte, error) {
+	switch a {
+	case ProtoSHA256:
+		data, err := CanonicalProto(m)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return sha256.Sum256(data), nil
+	case JCSKeccak256:
+		data, err := CanonicalJSON(m)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		var digest [32]byte
+		copy(digest[:], crypto.Keccak256(data))
+		return digest, nil
+	case URDNA2015SHA256:
+		c, ok := m.(Credential)
+		if !ok {
+			return [32]byte{}, ErrUnknownCredential
+		}
+		if _, err := CanonicalProto(m); err != nil {
+			return [32]byte{}, err
+		}
+		vc, err := ToVC(c, common.Address{})
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return HashVC(vc)
+	}
+	return [32]byte{}, fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, string(a))
+}
+
+// CanonicalProto returns the canonical protobuf encoding of the message,
+// which does not depend on the protobuf library: the set fields in field
+// number order, the repeated scalars packed and the map entries, with both
+// key and value, sorted by key. The values of Any fields are kept as
+// encoded by their producer.
+func CanonicalProto(m proto.Message) ([]byte, error) {
+	return appendCanonicalMessage(nil, m.ProtoReflect())
+}
+
+func sortedFields(md protoreflect.MessageDescriptor) []protoreflect.FieldDescriptor {
+	fields := make([]protoreflect.FieldDescriptor, md.Fields().Len())
+	for i := range fields {
+		fields[i] = md.Fields().Get(i)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Number() < fields[j].Number() })
+	return fields
+}
+
+func appendCanonicalMessage(b []byte, m protoreflect.Message) ([]byte, error) {
+	if len(m.GetUnknown()) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFields, m.Descriptor().FullName())
+	}
+	var err error
+	for _, fd := range sortedFields(m.Descriptor()) {
+		if !m.Has(fd) {
+			continue
+		}
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			b, err = appendCanonicalList(b, fd, v.List())
+		case fd.IsMap():
+			b, err = appendCanonicalMap(b, fd, v.Map())
+		default:
+			b, err = appendCanonicalField(b, fd, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func appendCanonicalList(b []byte, fd protoreflect.FieldDescriptor, list protoreflect.List) ([]byte, error) {
+	var err error
+	if packable(fd.Kind()) {
+		var packed []byte
+		for i := 0; i < list.Len(); i++ {
+			packed = appendScalar(packed, fd.Kind(), list.Get(i))
+		}
+		b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+		return protowire.AppendBytes(b, packed), nil
+	}
+	for i := 0; i < list.Len(); i++ {
+		if b, err = appendCanonicalField(b, fd, list.Get(i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func appendCanonicalMap(b []byte, fd protoreflect.FieldDescriptor, m protoreflect.Map) ([]byte, error) {
+	keys := make([]protoreflect.MapKey, 0, m.Len())
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return lessMapKey(keys[i], keys[j]) })
+	for _, k := range keys {
+		entry, err := appendCanonicalField(nil, fd.MapKey(), k.Value())
+		if err != nil {
+			return nil, err
+		}
+		if entry, err = appendCanonicalField(entry, fd.MapValue(), m.Get(k)); err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b, nil
+}
+
+func lessMapKey(a, b protoreflect.MapKey) bool {
+	switch v := a.Interface().(type) {
+	case bool:
+		return !v && b.Bool()
+	case int32, int64:
+		return a.Int() < b.Int()
+	case uint32, uint64:
+		return a.Uint() < b.Uint()
+	}
+	return a.String() < b.String()
+}
+
+func appendCanonicalField(b []byte, fd protoreflect.FieldDescriptor, v protoreflect.Value) ([]byte, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		nested, err := appendCanonicalMessage(nil, v.Message())
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+		return protowire.AppendBytes(b, nested), nil
+	case protoreflect.GroupKind:
+		return nil, fmt.Errorf("unsupported group field %s", fd.FullName())
+	case protoreflect.StringKind:
+		b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+		return protowire.AppendString(b, v.String()), nil
+	case protoreflect.BytesKind:
+		b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+		return protowire.AppendBytes(b, v.Bytes()), nil
+	}
+	b = protowire.AppendTag(b, fd.Number(), wireType(fd.Kind()))
+	return appendScalar(b, fd.Kind(), v), nil
+}
+
+// packable reports whether repeated fields of the kind are packed.
+func packable(k protoreflect.Kind) bool {
+	switch k {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
+
+func wireType(k protoreflect.Kind) protowire.Type {
+	switch k {
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+		return protowire.Fixed32Type
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+		return protowire.Fixed64Type
+	}
+	return protowire.VarintType
+}
+
+func appendScalar(b []byte, k protoreflect.Kind, v protoreflect.Value) []byte {
+	switch k {
+	case protoreflect.BoolKind:
+		return protowire.AppendVarint(b, protowire.EncodeBool(v.Bool()))
+	case protoreflect.EnumKind:
+		return protowire.AppendVarint(b, uint64(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		return protowire.AppendVarint(b, uint64(v.Int()))
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		return protowire.AppendVarint(b, v.Uint())
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(v.Int()))
+	case protoreflect.Fixed32Kind:
+		return protowire.AppendFixed32(b, uint32(v.Uint()))
+	case protoreflect.Sfixed32Kind:
+		return protowire.AppendFixed32(b, uint32(v.Int()))
+	case protoreflect.FloatKind:
+		return protowire.AppendFixed32(b, math.Float32bits(float32(v.Float())))
+	case protoreflect.Fixed64Kind:
+		return protowire.AppendFixed64(b, v.Uint())
+	case protoreflect.Sfixed64Kind:
+		return protowire.AppendFixed64(b, uint64(v.Int()))
+	case protoreflect.DoubleKind:
+		return protowire.AppendFixed64(b, math.Float64bits(v.Float()))
+	}
+	return b
+}
+
+// CanonicalJSON returns the protojson encoding of the message, with the
+// JSON field names, canonicalized with the JSON Canonicalization Scheme
+// (RFC 8785): no whitespace, the object members sorted by the UTF-16 code
+// units of their names and the numbers serialized as in ECMAScript.
+func CanonicalJSON(m proto.Message) ([]byte, error) {
+	if _, err := CanonicalProto(m); err != nil {
+		return nil, err
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(data)
+}
+
+// Canonicalize canonicalizes a JSON document with the JSON
+// Canonicalization Scheme (RFC 8785).
+func Canonicalize(data []byte) ([]byte, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return fmt.Errorf("invalid JSON number %s", v)
+		}
+		buf.WriteString(formatES6(f))
+	case string:
+		writeCanonicalString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return lessUTF16(names[i], names[j]) })
+		buf.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, name)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[name]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported JSON value %T", v)
+	}
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	x, y := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// writeCanonicalString writes a JSON string escaping only the quotation
+// mark, the reverse solidus and the control characters.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatES6 formats a number as the ECMAScript Number.prototype.toString.
+func formatES6(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	sign := exp[0]
+	exp = strings.TrimLeft(exp[1:], "0")
+	return mantissa + "e" + string(sign) + exp
+}
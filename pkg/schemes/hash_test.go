@@ -0,0 +1,159 @@
+package schemes
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// hashVector is a golden test vector: the digests of the document must
+// never change, whatever the version of the protobuf library.
+type hashVector struct {
+	Name      string          `json:"name"`
+	Algorithm HashAlgorithm   `json:"algorithm"`
+	Document  json.RawMessage `json:"document"`
+	Digest    string          `json:"digest"`
+}
+
+func readHashVectors(t *testing.T) []hashVector {
+	data, err := os.ReadFile(filepath.Join("testdata", "hash-vectors.json"))
+	require.NoError(t, err)
+	var vectors []hashVector
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	return vectors
+}
+
+func TestHashVectors(t *testing.T) {
+	vectors := readHashVectors(t)
+	algorithms := make(map[HashAlgorithm]int)
+	for _, v := range vectors {
+		t.Run(v.Name+"/"+string(v.Algorithm), func(t *testing.T) {
+			c, err := ParseCredential(v.Document)
+			require.NoError(t, err)
+			digest, err := v.Algorithm.Hash(c)
+			require.NoError(t, err)
+			assert.Equal(t, v.Digest, common.Hash(digest).Hex())
+			algorithms[v.Algorithm]++
+		})
+	}
+	for _, a := range HashAlgorithms() {
+		assert.NotZero(t, algorithms[a], "no vectors for %s", a)
+	}
+}
+
+func TestHashCompatibility(t *testing.T) {
+	// the digests registered before the algorithms were versioned are
+	// the SHA-256 of proto.Marshal, which is deterministic without maps
+	for _, v := range readHashVectors(t) {
+		c, err := ParseCredential(v.Document)
+		require.NoError(t, err)
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(c)
+		require.NoError(t, err)
+		canonical, err := CanonicalProto(c)
+		require.NoError(t, err)
+		assert.Equal(t, data, canonical, v.Name)
+		digest, err := DefaultHashAlgorithm.Hash(c)
+		require.NoError(t, err)
+		assert.Equal(t, sha256.Sum256(data), digest, v.Name)
+	}
+	for _, c := range fakeCredentials(t) {
+		data, err := proto.Marshal(c)
+		require.NoError(t, err)
+		digest, err := DefaultHashAlgorithm.Hash(c)
+		require.NoError(t, err)
+		assert.Equal(t, sha256.Sum256(data), digest)
+	}
+}
+
+func TestHashMapOrder(t *testing.T) {
+	grades := map[string]int64{"gpa": 87, "thesis": 92, "ects": 180, "a": 1, "b": 2, "c": 3, "d": 4}
+	want := make(map[HashAlgorithm][32]byte)
+	for i := 0; i < 20; i++ {
+		d := &DiplomaCredential{Diploma: &Diploma{Grades: make(map[string]int64)}}
+		for k, v := range grades {
+			d.Diploma.Grades[k] = v
+		}
+		for _, a := range HashAlgorithms() {
+			digest, err := a.Hash(d)
+			require.NoError(t, err)
+			if i == 0 {
+				want[a] = digest
+			}
+			assert.Equal(t, want[a], digest, a)
+		}
+	}
+}
+
+func TestHashUnknownFields(t *testing.T) {
+	a := &AssignmentGradeCredential{CreatedBy: "0x3D9B4C8d7E1a7fCc2Aa8e9b1F6f5A2b7C9e0d1F2"}
+	a.ProtoReflect().SetUnknown([]byte{0xf8, 0x01, 0x01}) // field 31, varint 1
+	for _, alg := range HashAlgorithms() {
+		_, err := alg.Hash(a)
+		assert.ErrorIs(t, err, ErrUnknownFields)
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	a, err := ParseHashAlgorithm("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHashAlgorithm, a)
+	for _, want := range HashAlgorithms() {
+		a, err := ParseHashAlgorithm(string(want))
+		require.NoError(t, err)
+		assert.Equal(t, want, a)
+	}
+	_, err = ParseHashAlgorithm("sha256")
+	assert.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+	_, err = HashAlgorithm("sha256").Hash(&Entity{})
+	assert.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+}
+
+func TestCanonicalize(t *testing.T) {
+	// example of RFC 8785, section 3.2.2
+	input := `{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`
+	want := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	got, err := Canonicalize([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+
+	// members sorted by UTF-16 code units, section 3.2.3
+	got, err = Canonicalize([]byte(`{"\u20ac": 1, "\ud83d\ude00": 2, "\r": 3, "1": 4, "\u0080": 5, "\u00f6": 6}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"\\r\":3,\"1\":4,\"\u0080\":5,\"ö\":6,\"€\":1,\"😀\":2}", string(got))
+
+	for in, want := range map[string]string{"0": "0", "-0": "0", "1e21": "1e+21", "1e20": "100000000000000000000", "0.000001": "0.000001", "1e-7": "1e-7", "-1.5": "-1.5"} {
+		got, err := Canonicalize([]byte(in))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got), in)
+	}
+}
+
+func TestHashAlgorithmsSchema(t *testing.T) {
+	// the verifiable credentials name the algorithm of their status digest
+	data, err := JSONSchema("vc")
+	require.NoError(t, err)
+	var schema struct {
+		Properties struct {
+			CredentialStatus struct {
+				Properties struct {
+					DigestAlgorithm struct {
+						Enum []HashAlgorithm `json:"enum"`
+					} `json:"digestAlgorithm"`
+				} `json:"properties"`
+			} `json:"credentialStatus"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	assert.Equal(t, HashAlgorithms(), schema.Properties.CredentialStatus.Properties.DigestAlgorithm.Enum)
+}
@@ -0,0 +1,685 @@
+package schemes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The JSON-LD contexts of the verifiable credentials, loaded locally: the
+// documents are never fetched from the network.
+var (
+	//go:embed base-vc-v1.json
+	baseVCContext []byte
+	//go:embed credbench-v1.json
+	credbenchContext []byte
+
+	ldContexts = map[string][]byte{
+		VCContext:        baseVCContext,
+		CredbenchContext: credbenchContext,
+	}
+)
+
+var (
+	ErrUnknownContext = errors.New("unknown JSON-LD context")
+	ErrUnsupportedLD  = errors.New("unsupported JSON-LD feature")
+)
+
+const (
+	rdfNS        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xsdNS        = "http://www.w3.org/2001/XMLSchema#"
+	rdfType      = rdfNS + "type"
+	rdfFirst     = rdfNS + "first"
+	rdfRest      = rdfNS + "rest"
+	rdfNil       = rdfNS + "nil"
+	rdfJSON      = rdfNS + "JSON"
+	rdfLangStr   = rdfNS + "langString"
+	xsdString    = xsdNS + "string"
+	xsdBoolean   = xsdNS + "boolean"
+	xsdInteger   = xsdNS + "integer"
+	xsdDouble    = xsdNS + "double"
+	ldJSONType   = "@json"
+	ldMaxContext = 32 // nesting of the contexts loaded by a document
+)
+
+var absoluteIRI = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:[^\s<>"{}|\\^` + "`" + `]*$`)
+
+// ldTermDef is the definition of a term of a JSON-LD context.
+type ldTermDef struct {
+	id         string // IRI or keyword
+	typ        string // @id, @vocab, @json or the datatype IRI of the values
+	container  string
+	context    interface{} // scoped context
+	hasContext bool
+}
+
+// ldContext is an active JSON-LD context. It implements the subset of
+// JSON-LD 1.1 used by the verifiable credentials: term definitions,
+// compact IRIs, @vocab, type-scoped and property-scoped contexts, and
+// typed, @id, @vocab, @json and @list values.
+type ldContext struct {
+	terms    map[string]*ldTermDef // nil definitions are terms mapped to null
+	vocab    string
+	previous *ldContext // the context before a type-scoped context
+}
+
+func (c *ldContext) clone() *ldContext {
+	n := &ldContext{terms: make(map[string]*ldTermDef, len(c.terms)), vocab: c.vocab, previous: c.previous}
+	for k, v := range c.terms {
+		n.terms[k] = v
+	}
+	return n
+}
+
+func isKeyword(s string) bool {
+	return strings.HasPrefix(s, "@")
+}
+
+// process returns the context resulting of processing a local context. The
+// type-scoped contexts are not propagated to the nested node objects.
+func (c *ldContext) process(local interface{}, propagate bool) (*ldContext, error) {
+	result := c.clone()
+	if !propagate && result.previous == nil {
+		result.previous = c
+	}
+	if err := result.apply(local, 0); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ldContext) apply(local interface{}, depth int) error {
+	if depth > ldMaxContext {
+		return fmt.Errorf("%w: context overflow", ErrUnsupportedLD)
+	}
+	switch local := local.(type) {
+	case nil:
+		c.terms, c.vocab = make(map[string]*ldTermDef), ""
+	case []interface{}:
+		for _, l := range local {
+			if err := c.apply(l, depth+1); err != nil {
+				return err
+			}
+		}
+	case string:
+		data, ok := ldContexts[local]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownContext, local)
+		}
+		doc, err := decodeJSON(data)
+		if err != nil {
+			return err
+		}
+		m, _ := doc.(map[string]interface{})
+		return c.apply(m["@context"], depth+1)
+	case map[string]interface{}:
+		if v, ok := local["@vocab"]; ok {
+			switch v := v.(type) {
+			case nil:
+				c.vocab = ""
+			case string:
+				vocab, err := c.expandIRI(v, true, true, local, map[string]bool{})
+				if err != nil {
+					return err
+				}
+				c.vocab = vocab
+			}
+		}
+		defined := make(map[string]bool)
+		terms := make([]string, 0, len(local))
+		for term := range local {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		for _, term := range terms {
+			if err := c.define(local, term, defined); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: context %v", ErrUnsupportedLD, local)
+	}
+	return nil
+}
+
+// define creates the definition of a term of a local context, and of the
+// terms it depends on.
+func (c *ldContext) define(local map[string]interface{}, term string, defined map[string]bool) error {
+	if defined[term] || isKeyword(term) {
+		return nil
+	}
+	defined[term] = true
+	def := &ldTermDef{}
+	switch v := local[term].(type) {
+	case nil:
+		c.terms[term] = nil
+		return nil
+	case string:
+		def.id = v
+	case map[string]interface{}:
+		if id, ok := v["@id"]; ok {
+			if id == nil {
+				c.terms[term] = nil
+				return nil
+			}
+			def.id, _ = id.(string)
+		}
+		if typ, ok := v["@type"].(string); ok {
+			t, err := c.expandIRI(typ, false, true, local, defined)
+			if err != nil {
+				return err
+			}
+			def.typ = t
+		}
+		def.container, _ = v["@container"].(string)
+		if ctx, ok := v["@context"]; ok {
+			def.context, def.hasContext = ctx, true
+		}
+		if _, ok := v["@reverse"]; ok {
+			return fmt.Errorf("%w: @reverse term %s", ErrUnsupportedLD, term)
+		}
+	default:
+		return fmt.Errorf("%w: term definition %s", ErrUnsupportedLD, term)
+	}
+	if def.id == "" {
+		def.id = term
+	}
+	if !isKeyword(def.id) {
+		id, err := c.expandIRI(def.id, false, true, local, defined)
+		if err != nil {
+			return err
+		}
+		def.id = id
+	}
+	c.terms[term] = def
+	return nil
+}
+
+// expandIRI expands a term, compact IRI or relative IRI. Relative IRIs are
+// not resolved, as documents have no base IRI, and are dropped when
+// converted to RDF.
+func (c *ldContext) expandIRI(value string, documentRelative, vocab bool, local map[string]interface{}, defined map[string]bool) (string, error) {
+	if isKeyword(value) {
+		return value, nil
+	}
+	if local != nil {
+		if _, ok := local[value]; ok {
+			if err := c.define(local, value, defined); err != nil {
+				return "", err
+			}
+		}
+	}
+	if vocab {
+		if def, ok := c.terms[value]; ok {
+			if def == nil {
+				return "", nil
+			}
+			return def.id, nil
+		}
+	}
+	if i := strings.IndexByte(value, ':'); i > 0 {
+		prefix, suffix := value[:i], value[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, nil
+		}
+		if local != nil {
+			if _, ok := local[prefix]; ok {
+				if err := c.define(local, prefix, defined); err != nil {
+					return "", err
+				}
+			}
+		}
+		if def, ok := c.terms[prefix]; ok && def != nil {
+			return def.id + suffix, nil
+		}
+		return value, nil
+	}
+	if vocab && c.vocab != "" {
+		return c.vocab + value, nil
+	}
+	return value, nil
+}
+
+func (c *ldContext) iri(value string, vocab bool) string {
+	iri, _ := c.expandIRI(value, true, vocab, nil, nil)
+	return iri
+}
+
+// expand expands a JSON-LD element, returning nil if it is dropped.
+func (c *ldContext) expand(property string, element interface{}) (interface{}, error) {
+	switch e := element.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		result := []interface{}{}
+		for _, item := range e {
+			v, err := c.expand(property, item)
+			if err != nil {
+				return nil, err
+			}
+			if list, ok := v.([]interface{}); ok {
+				result = append(result, list...)
+			} else if v != nil {
+				result = append(result, v)
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		return c.expandObject(property, e)
+	}
+	if property == "" || property == "@graph" {
+		return nil, nil
+	}
+	return c.expandValue(property, element), nil
+}
+
+func (c *ldContext) expandValue(property string, value interface{}) interface{} {
+	if def := c.terms[property]; def != nil {
+		s, isString := value.(string)
+		switch def.typ {
+		case "@id":
+			if isString {
+				return map[string]interface{}{"@id": c.iri(s, false)}
+			}
+		case "@vocab":
+			if isString {
+				return map[string]interface{}{"@id": c.iri(s, true)}
+			}
+		case "", "@none":
+		default:
+			return map[string]interface{}{"@value": value, "@type": def.typ}
+		}
+	}
+	return map[string]interface{}{"@value": value}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func asArray(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{v}
+}
+
+func (c *ldContext) expandObject(property string, m map[string]interface{}) (interface{}, error) {
+	ctx := c
+	if ctx.previous != nil {
+		valueObject := false
+		for k := range m {
+			valueObject = valueObject || c.iri(k, true) == "@value"
+		}
+		onlyID := len(m) == 1 && c.iri(sortedKeys(m)[0], true) == "@id"
+		if !valueObject && !onlyID {
+			ctx = ctx.previous
+		}
+	}
+	var err error
+	if local, ok := m["@context"]; ok {
+		if ctx, err = ctx.process(local, true); err != nil {
+			return nil, err
+		}
+	}
+	typeScoped := ctx
+	for _, k := range sortedKeys(m) {
+		if typeScoped.iri(k, true) != "@type" {
+			continue
+		}
+		var types []string
+		for _, t := range asArray(m[k]) {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			if def := typeScoped.terms[t]; def != nil && def.hasContext {
+				if ctx, err = ctx.process(def.context, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	result := make(map[string]interface{})
+	for _, key := range sortedKeys(m) {
+		if key == "@context" {
+			continue
+		}
+		value := m[key]
+		prop := ctx.iri(key, true)
+		if prop == "" || (!isKeyword(prop) && !strings.Contains(prop, ":")) {
+			continue
+		}
+		if isKeyword(prop) {
+			switch prop {
+			case "@id":
+				if s, ok := value.(string); ok {
+					result["@id"] = ctx.iri(s, false)
+				}
+			case "@type":
+				var types []interface{}
+				for _, t := range asArray(value) {
+					if s, ok := t.(string); ok {
+						types = append(types, typeScoped.iri(s, true))
+					}
+				}
+				result["@type"] = types
+			case "@value", "@language":
+				result[prop] = value
+			case "@graph", "@list", "@set":
+				p := property
+				if prop == "@graph" {
+					p = "@graph"
+				}
+				expanded, err := ctx.expand(p, value)
+				if err != nil {
+					return nil, err
+				}
+				if expanded != nil {
+					result[prop] = asArray(expanded)
+				}
+			case "@reverse", "@nest", "@included":
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedLD, prop)
+			}
+			continue
+		}
+
+		def := ctx.terms[key]
+		var expanded interface{}
+		if def != nil && def.typ == ldJSONType {
+			expanded = map[string]interface{}{"@value": value, "@type": ldJSONType}
+		} else {
+			termCtx := ctx
+			if def != nil && def.hasContext {
+				if termCtx, err = ctx.process(def.context, true); err != nil {
+					return nil, err
+				}
+			}
+			if expanded, err = termCtx.expand(key, value); err != nil {
+				return nil, err
+			}
+			if expanded == nil {
+				continue
+			}
+		}
+		if def != nil {
+			switch def.container {
+			case "@list":
+				expanded = map[string]interface{}{"@list": asArray(expanded)}
+			case "@graph":
+				var graphs []interface{}
+				for _, item := range asArray(expanded) {
+					graphs = append(graphs, map[string]interface{}{"@graph": []interface{}{item}})
+				}
+				expanded = graphs
+			}
+		}
+		prev, _ := result[prop].([]interface{})
+		result[prop] = append(prev, asArray(expanded)...)
+	}
+
+	if v, ok := result["@value"]; ok && v == nil {
+		return nil, nil
+	}
+	if set, ok := result["@set"]; ok {
+		return set, nil
+	}
+	return result, nil
+}
+
+// rdfTerm is an IRI, a blank node or a literal.
+type rdfTerm struct {
+	kind     byte // 'i' IRI, 'b' blank node, 'l' literal
+	value    string
+	datatype string
+	language string
+}
+
+func (t rdfTerm) String() string {
+	switch t.kind {
+	case 'i':
+		return "<" + t.value + ">"
+	case 'b':
+		return t.value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range t.value {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	switch {
+	case t.language != "":
+		b.WriteString("@" + t.language)
+	case t.datatype != "" && t.datatype != xsdString:
+		b.WriteString("^^<" + t.datatype + ">")
+	}
+	return b.String()
+}
+
+// rdfQuad is a triple of the default graph, or of a named graph.
+type rdfQuad struct {
+	subject, predicate, object, graph rdfTerm
+}
+
+// NQuad serializes the quad as a line of N-Quads.
+func (q rdfQuad) NQuad() string {
+	s := q.subject.String() + " " + q.predicate.String() + " " + q.object.String()
+	if q.graph.kind != 0 {
+		s += " " + q.graph.String()
+	}
+	return s + " .\n"
+}
+
+// rdfBuilder converts an expanded JSON-LD document to an RDF dataset.
+type rdfBuilder struct {
+	quads  []rdfQuad
+	blanks map[string]string
+}
+
+func (b *rdfBuilder) blank(label string) rdfTerm {
+	if label != "" {
+		if id, ok := b.blanks[label]; ok {
+			return rdfTerm{kind: 'b', value: id}
+		}
+	}
+	id := "_:b" + strconv.Itoa(len(b.blanks))
+	if label == "" {
+		label = id + "#generated"
+	}
+	b.blanks[label] = id
+	return rdfTerm{kind: 'b', value: id}
+}
+
+func (b *rdfBuilder) resource(iri string) (rdfTerm, bool) {
+	if strings.HasPrefix(iri, "_:") {
+		return b.blank(iri), true
+	}
+	return rdfTerm{kind: 'i', value: iri}, absoluteIRI.MatchString(iri)
+}
+
+func (b *rdfBuilder) add(s, p, o rdfTerm) {
+	b.quads = append(b.quads, rdfQuad{subject: s, predicate: p, object: o})
+}
+
+// node adds the triples of a node object and returns its subject. Nodes
+// whose identifier is a relative IRI are dropped, as in JSON-LD.
+func (b *rdfBuilder) node(n map[string]interface{}) (rdfTerm, bool, error) {
+	if _, ok := n["@graph"]; ok {
+		return rdfTerm{}, false, fmt.Errorf("%w: named graphs", ErrUnsupportedLD)
+	}
+	subject, valid := b.blank(""), true
+	if id, ok := n["@id"].(string); ok {
+		delete(b.blanks, subject.value+"#generated")
+		subject, valid = b.resource(id)
+	}
+	types, _ := n["@type"].([]interface{})
+	for _, t := range types {
+		if o, ok := b.resource(t.(string)); ok && valid {
+			b.add(subject, rdfTerm{kind: 'i', value: rdfType}, o)
+		}
+	}
+	for _, prop := range sortedKeys(n) {
+		if isKeyword(prop) {
+			continue
+		}
+		predicate, ok := b.resource(prop)
+		for _, item := range asArray(n[prop]) {
+			o, oValid, err := b.object(item)
+			if err != nil {
+				return rdfTerm{}, false, err
+			}
+			if ok && valid && oValid && predicate.kind == 'i' {
+				b.add(subject, predicate, o)
+			}
+		}
+	}
+	return subject, valid, nil
+}
+
+func (b *rdfBuilder) object(item interface{}) (rdfTerm, bool, error) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return rdfTerm{}, false, nil
+	}
+	if v, ok := m["@value"]; ok {
+		t, err := literal(v, m)
+		return t, err == nil, err
+	}
+	if list, ok := m["@list"]; ok {
+		return b.list(asArray(list))
+	}
+	return b.node(m)
+}
+
+func (b *rdfBuilder) list(items []interface{}) (rdfTerm, bool, error) {
+	head := rdfTerm{kind: 'i', value: rdfNil}
+	for i := len(items) - 1; i >= 0; i-- {
+		o, ok, err := b.object(items[i])
+		if err != nil {
+			return rdfTerm{}, false, err
+		}
+		node := b.blank("")
+		if ok {
+			b.add(node, rdfTerm{kind: 'i', value: rdfFirst}, o)
+		}
+		b.add(node, rdfTerm{kind: 'i', value: rdfRest}, head)
+		head = node
+	}
+	return head, true, nil
+}
+
+// literal converts a value object to an RDF literal.
+func literal(v interface{}, m map[string]interface{}) (rdfTerm, error) {
+	datatype, _ := m["@type"].(string)
+	if datatype == ldJSONType {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		canonical, err := Canonicalize(data)
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		return rdfTerm{kind: 'l', value: string(canonical), datatype: rdfJSON}, nil
+	}
+	t := rdfTerm{kind: 'l', datatype: datatype}
+	switch v := v.(type) {
+	case bool:
+		t.value = strconv.FormatBool(v)
+		if t.datatype == "" {
+			t.datatype = xsdBoolean
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		integral := f == math.Trunc(f) && math.Abs(f) < 1e21
+		if integral && t.datatype != xsdDouble {
+			n, _ := new(big.Float).SetFloat64(f).Int(nil)
+			t.value = n.String()
+			if t.datatype == "" {
+				t.datatype = xsdInteger
+			}
+		} else {
+			t.value = canonicalDouble(f)
+			if t.datatype == "" {
+				t.datatype = xsdDouble
+			}
+		}
+	case string:
+		t.value = v
+		if lang, ok := m["@language"].(string); ok && t.datatype == "" {
+			t.language, t.datatype = strings.ToLower(lang), rdfLangStr
+		}
+		if t.datatype == "" {
+			t.datatype = xsdString
+		}
+	default:
+		return rdfTerm{}, fmt.Errorf("%w: value %v", ErrUnsupportedLD, v)
+	}
+	return t, nil
+}
+
+// canonicalDouble formats a double in the canonical lexical form of
+// xsd:double used by JSON-LD, e.g. 1.5E0.
+func canonicalDouble(f float64) string {
+	s := strconv.FormatFloat(f, 'e', 15, 64)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	mantissa = strings.TrimRight(mantissa, "0")
+	if strings.HasSuffix(mantissa, ".") {
+		mantissa += "0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
+
+// toRDF expands a JSON-LD document with its contexts, which must be
+// shipped with the package, and converts it to an RDF dataset.
+func toRDF(data []byte) ([]rdfQuad, error) {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &ldContext{terms: make(map[string]*ldTermDef)}
+	expanded, err := ctx.expand("", doc)
+	if err != nil {
+		return nil, err
+	}
+	b := &rdfBuilder{blanks: make(map[string]string)}
+	for _, item := range asArray(expanded) {
+		if n, ok := item.(map[string]interface{}); ok {
+			if _, _, err := b.node(n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.quads, nil
+}
@@ -0,0 +1,254 @@
+package schemes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestCanonicalNQuads(t *testing.T) {
+	doc := `{
+		"@context": ["https://www.w3.org/2018/credentials/v1", "https://relab.github.io/credbench/credentials/v1"],
+		"id": "did:eth-uis:0x01",
+		"type": ["VerifiableCredential"],
+		"issuer": "did:eth-uis:0x02",
+		"issuanceDate": "2023-01-01T00:00:00Z",
+		"credentialSubject": {
+			"name": "Alice \"A\"",
+			"credits": 7.5,
+			"grade": 87,
+			"passed": true,
+			"course": {"b": 1, "a": [true, null]}
+		},
+		"evidence": [{"id": "0x03", "verifier": "dropped with its relative id"}]
+	}`
+	want := `<did:eth-uis:0x01> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .
+<did:eth-uis:0x01> <https://www.w3.org/2018/credentials#credentialSubject> _:c14n0 .
+<did:eth-uis:0x01> <https://www.w3.org/2018/credentials#issuanceDate> "2023-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+<did:eth-uis:0x01> <https://www.w3.org/2018/credentials#issuer> <did:eth-uis:0x02> .
+_:c14n0 <https://relab.github.io/credbench/vocab#course> "{\"a\":[true,null],\"b\":1}"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON> .
+_:c14n0 <https://relab.github.io/credbench/vocab#credits> "7.5E0"^^<http://www.w3.org/2001/XMLSchema#double> .
+_:c14n0 <https://relab.github.io/credbench/vocab#grade> "87"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:c14n0 <https://relab.github.io/credbench/vocab#name> "Alice \"A\"" .
+_:c14n0 <https://relab.github.io/credbench/vocab#passed> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+`
+	got, err := CanonicalNQuads([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+
+	// the contexts are never fetched
+	_, err = CanonicalNQuads([]byte(`{"@context": "https://www.w3.org/ns/credentials/v2", "id": "urn:x"}`))
+	assert.ErrorIs(t, err, ErrUnknownContext)
+}
+
+func blankQuad(s, p, o string) rdfQuad {
+	term := func(v string) rdfTerm {
+		if strings.HasPrefix(v, "_:") {
+			return rdfTerm{kind: 'b', value: v}
+		}
+		return rdfTerm{kind: 'i', value: v}
+	}
+	return rdfQuad{subject: term(s), predicate: term(p), object: term(o)}
+}
+
+func TestCanonicalQuads(t *testing.T) {
+	// blank nodes that cannot be told apart by their first degree hash
+	got := canonicalQuads([]rdfQuad{blankQuad("_:x", "urn:p", "_:y"), blankQuad("_:y", "urn:p", "_:x")})
+	assert.Equal(t, "_:c14n0 <urn:p> _:c14n1 .\n_:c14n1 <urn:p> _:c14n0 .\n", strings.Join(got, ""))
+
+	// isomorphic datasets, whatever their labels and order
+	cycles := []rdfQuad{
+		blankQuad("_:a", "urn:p", "_:b"), blankQuad("_:b", "urn:p", "_:c"), blankQuad("_:c", "urn:p", "_:a"),
+		blankQuad("_:d", "urn:p", "_:e"), blankQuad("_:e", "urn:p", "_:d"),
+		blankQuad("_:a", "urn:q", "urn:x"), blankQuad("_:d", "urn:q", "urn:x"),
+	}
+	relabeled := make([]rdfQuad, len(cycles))
+	labels := map[string]string{"_:a": "_:e", "_:b": "_:d", "_:c": "_:c", "_:d": "_:b", "_:e": "_:a"}
+	for i, q := range cycles {
+		relabeled[len(cycles)-1-i] = q.relabel(func(b string) string { return labels[b] })
+	}
+	want := canonicalQuads(cycles)
+	assert.Equal(t, want, canonicalQuads(relabeled))
+	assert.Equal(t, want, canonicalQuads(append(relabeled, relabeled[0])), "duplicate quads")
+	assert.Len(t, want, len(cycles))
+}
+
+func TestHashVC(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	for _, c := range fakeCredentials(t) {
+		digest, err := URDNA2015SHA256.Hash(c)
+		require.NoError(t, err)
+
+		// the digest of the exported credential, with its status and proof
+		vc, err := ToVC(c, contract)
+		require.NoError(t, err)
+		ld := vc.LinkedData()
+		ld.SetStatus(contract, URDNA2015SHA256, digest)
+		ld.Proof = json.RawMessage(`{"type": "EcdsaSecp256k1Signature2019"}`)
+		got, err := HashVC(ld)
+		require.NoError(t, err)
+		assert.Equal(t, digest, got)
+
+		// which imports back to the same document
+		data, err := json.Marshal(ld)
+		require.NoError(t, err)
+		_, doc, err := ValidateVC(data)
+		require.NoError(t, err)
+		got, err = URDNA2015SHA256.Hash(doc)
+		require.NoError(t, err)
+		assert.Equal(t, digest, got)
+		assert.True(t, proto.Equal(Subject(c), Subject(doc)))
+
+		// any change of the document changes the digest
+		changed := proto.Clone(c).(Credential)
+		changed.ProtoReflect().Set(changed.ProtoReflect().Descriptor().Fields().ByName("created_by"), protoreflect.ValueOfString("0x00000000000000000000000000000000000000aa"))
+		got, err = URDNA2015SHA256.Hash(changed)
+		require.NoError(t, err)
+		assert.NotEqual(t, digest, got)
+	}
+}
+
+// parseNQuads parses the N-Quads of the test suites, one statement a line.
+func parseNQuads(t *testing.T, data string) []rdfQuad {
+	var quads []rdfQuad
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var terms []rdfTerm
+		for line != "." {
+			term, rest := parseNQuadsTerm(t, line)
+			terms = append(terms, term)
+			line = strings.TrimLeft(rest, " \t")
+		}
+		require.True(t, len(terms) == 3 || len(terms) == 4, "statement with %d terms", len(terms))
+		q := rdfQuad{subject: terms[0], predicate: terms[1], object: terms[2]}
+		if len(terms) == 4 {
+			q.graph = terms[3]
+		}
+		quads = append(quads, q)
+	}
+	return quads
+}
+
+func parseNQuadsTerm(t *testing.T, s string) (rdfTerm, string) {
+	switch {
+	case s[0] == '<':
+		end := strings.IndexByte(s, '>')
+		require.Positive(t, end, "unterminated IRI: %s", s)
+		return rdfTerm{kind: 'i', value: s[1:end]}, s[end+1:]
+	case strings.HasPrefix(s, "_:"):
+		end := strings.IndexAny(s, " \t")
+		require.Positive(t, end, "unterminated blank node: %s", s)
+		return rdfTerm{kind: 'b', value: s[:end]}, s[end:]
+	case s[0] != '"':
+		t.Fatalf("unexpected term: %s", s)
+	}
+	var b strings.Builder
+	i := 1
+	for ; s[i] != '"'; i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'u', 'U':
+			n := 4
+			if s[i] == 'U' {
+				n = 8
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			require.NoError(t, err)
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			c, ok := map[byte]byte{'t': '\t', 'b': '\b', 'n': '\n', 'r': '\r', 'f': '\f', '"': '"', '\'': '\'', '\\': '\\'}[s[i]]
+			require.True(t, ok, "unknown escape: \\%c", s[i])
+			b.WriteByte(c)
+		}
+	}
+	term, rest := rdfTerm{kind: 'l', value: b.String()}, s[i+1:]
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		end := strings.IndexAny(rest, " \t")
+		term.language, rest = rest[1:end], rest[end:]
+	case strings.HasPrefix(rest, "^^"):
+		datatype, r := parseNQuadsTerm(t, rest[2:])
+		term.datatype, rest = datatype.value, r
+	}
+	return term, rest
+}
+
+func TestURDNA2015Conformance(t *testing.T) {
+	// the RDF Dataset Normalization test suite of the W3C, see its LICENSE.md
+	dir := filepath.Join("testdata", "urdna2015")
+	data, err := os.ReadFile(filepath.Join(dir, "manifest-urdna2015.jsonld"))
+	require.NoError(t, err)
+	var manifest struct {
+		Entries []struct {
+			ID     string `json:"id"`
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Action string `json:"action"`
+			Result string `json:"result"`
+		} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	require.NotEmpty(t, manifest.Entries)
+	for _, e := range manifest.Entries {
+		require.Equal(t, "rdfn:Urdna2015EvalTest", e.Type)
+		t.Run(strings.TrimPrefix(e.ID, "manifest-urdna2015#")+"/"+e.Name, func(t *testing.T) {
+			in, err := os.ReadFile(filepath.Join(dir, e.Action))
+			require.NoError(t, err)
+			want, err := os.ReadFile(filepath.Join(dir, e.Result))
+			require.NoError(t, err)
+			got := canonicalQuads(parseNQuads(t, string(in)))
+			assert.Equal(t, string(want), strings.Join(got, ""))
+		})
+	}
+}
+
+// relativeObject matches the quads whose object is a relative IRI.
+var relativeObject = regexp.MustCompile(`(?m)^.* <[^:>]*> \.\n`)
+
+func TestCanonicalNQuadsReference(t *testing.T) {
+	// The fixtures are the output of json-gold v0.5.0 normalizing the
+	// documents with URDNA2015 (JSON-LD 1.1, the local contexts, no base
+	// IRI). json-gold keeps the types that are not terms of the contexts as
+	// relative IRIs, which are not RDF and which jsonld.js and CanonicalNQuads
+	// drop, so those quads are not compared.
+	comment := regexp.MustCompile(`(?m)\s//.*$`)
+	for _, name := range []string{"course-vc", "diploma-vc", "exam-vc"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("example", name+".json"))
+			require.NoError(t, err)
+			var doc map[string]interface{}
+			require.NoError(t, json.Unmarshal(comment.ReplaceAll(data, nil), &doc))
+			delete(doc, "proof")
+			data, err = json.Marshal(doc)
+			require.NoError(t, err)
+			checkReferenceNQuads(t, name, data)
+		})
+	}
+
+}
+
+func checkReferenceNQuads(t *testing.T, name string, data []byte) {
+	want, err := os.ReadFile(filepath.Join("testdata", "jsonld", name+".nq"))
+	require.NoError(t, err)
+	got, err := CanonicalNQuads(data)
+	require.NoError(t, err)
+	assert.Equal(t, string(relativeObject.ReplaceAll(want, nil)), string(got))
+}
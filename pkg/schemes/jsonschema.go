@@ -0,0 +1,291 @@
+package schemes
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//go:embed schema/*.json
+var schemaFiles embed.FS
+
+// jsonSchemas are the embedded JSON Schemas of the credential documents,
+// by file name.
+var jsonSchemas = loadSchemas()
+
+func loadSchemas() map[string]map[string]interface{} {
+	entries, err := schemaFiles.ReadDir("schema")
+	if err != nil {
+		panic(err)
+	}
+	schemas := make(map[string]map[string]interface{})
+	for _, e := range entries {
+		data, err := schemaFiles.ReadFile(path.Join("schema", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		s, err := decodeJSON(data)
+		if err != nil {
+			panic(fmt.Sprintf("schema %s: %v", e.Name(), err))
+		}
+		schemas[e.Name()] = s.(map[string]interface{})
+	}
+	return schemas
+}
+
+// JSONSchema returns the JSON Schema of a credential type: assignment,
+// course, diploma or vc.
+func JSONSchema(kind string) ([]byte, error) {
+	return schemaFiles.ReadFile(path.Join("schema", kind+".json"))
+}
+
+// decodeJSON decodes a JSON value, keeping the numbers as json.Number.
+func decodeJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// schemaValidator validates JSON values against the credential schemas.
+// It supports the keywords used by them: $ref, allOf, type, const, enum,
+// required, properties, additionalProperties, items, contains, minItems,
+// minLength, pattern, format (date-time), minimum and maximum.
+type schemaValidator struct {
+	fields []FieldError
+}
+
+// validateSchema validates a decoded JSON value against the schema file.
+func validateSchema(file string, value interface{}) []FieldError {
+	v := &schemaValidator{}
+	v.validate(file, jsonSchemas[file], value, "")
+	return v.fields
+}
+
+func (v *schemaValidator) fail(field, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+// resolve returns the file and the schema referenced by ref, relative to
+// the file of the referencing schema.
+func (v *schemaValidator) resolve(file, ref string) (string, map[string]interface{}) {
+	name, pointer := ref, ""
+	if i := strings.IndexByte(ref, '#'); i >= 0 {
+		name, pointer = ref[:i], ref[i+1:]
+	}
+	if name != "" {
+		file = name
+	}
+	var s interface{} = jsonSchemas[file]
+	for _, p := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if p == "" {
+			continue
+		}
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			return file, nil
+		}
+		s = m[p]
+	}
+	m, _ := s.(map[string]interface{})
+	return file, m
+}
+
+// matches reports whether the value is valid against the schema.
+func (v *schemaValidator) matches(file string, s map[string]interface{}, value interface{}) bool {
+	sub := &schemaValidator{}
+	sub.validate(file, s, value, "")
+	return len(sub.fields) == 0
+}
+
+func (v *schemaValidator) validate(file string, s map[string]interface{}, value interface{}, field string) {
+	if s == nil {
+		return
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		refFile, refSchema := v.resolve(file, ref)
+		if refSchema == nil {
+			v.fail(field, "unresolved schema %s", ref)
+			return
+		}
+		v.validate(refFile, refSchema, value, field)
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			m, _ := sub.(map[string]interface{})
+			v.validate(file, m, value, field)
+		}
+	}
+	if t, ok := s["type"]; ok && !hasJSONType(t, value) {
+		v.fail(field, "%s is not of type %v", jsonType(value), t)
+		return
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, value) {
+		v.fail(field, "must be %v", c)
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || jsonEqual(e, value)
+		}
+		if !found {
+			v.fail(field, "must be one of %v", enum)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(file, s, value, field)
+	case []interface{}:
+		v.validateArray(file, s, value, field)
+	case string:
+		v.validateString(s, value, field)
+	case json.Number:
+		v.validateNumber(s, value, field)
+	}
+}
+
+func (v *schemaValidator) validateObject(file string, s map[string]interface{}, value map[string]interface{}, field string) {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			if _, ok := value[r.(string)]; !ok {
+				v.fail(field+"/"+r.(string), "required")
+			}
+		}
+	}
+	properties, _ := s["properties"].(map[string]interface{})
+	for name, fieldValue := range value {
+		if p, ok := properties[name].(map[string]interface{}); ok {
+			v.validate(file, p, fieldValue, field+"/"+name)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(field+"/"+name, "unknown field")
+			}
+		case map[string]interface{}:
+			v.validate(file, additional, fieldValue, field+"/"+name)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(file string, s map[string]interface{}, value []interface{}, field string) {
+	if min, ok := s["minItems"].(json.Number); ok {
+		if n, _ := min.Int64(); int64(len(value)) < n {
+			v.fail(field, "must have at least %d items", n)
+		}
+	}
+	if items, ok := s["items"].(map[string]interface{}); ok {
+		for i, item := range value {
+			v.validate(file, items, item, fmt.Sprintf("%s/%d", field, i))
+		}
+	}
+	if contains, ok := s["contains"].(map[string]interface{}); ok {
+		found := false
+		for _, item := range value {
+			found = found || v.matches(file, contains, item)
+		}
+		if !found {
+			v.fail(field, "must contain %v", contains)
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(s map[string]interface{}, value string, field string) {
+	if min, ok := s["minLength"].(json.Number); ok {
+		if n, _ := min.Int64(); int64(len(value)) < n {
+			v.fail(field, "must have at least %d characters", n)
+		}
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if re, err := regexp.Compile(pattern); err != nil || !re.MatchString(value) {
+			v.fail(field, "%q does not match %s", value, pattern)
+		}
+	}
+	if format, ok := s["format"].(string); ok && format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			v.fail(field, "%q is not a date-time", value)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(s map[string]interface{}, value json.Number, field string) {
+	n, err := value.Float64()
+	if err != nil {
+		v.fail(field, "invalid number %s", value)
+		return
+	}
+	if min, ok := s["minimum"].(json.Number); ok {
+		if m, _ := min.Float64(); n < m {
+			v.fail(field, "%s is less than %s", value, min)
+		}
+	}
+	if max, ok := s["maximum"].(json.Number); ok {
+		if m, _ := max.Float64(); n > m {
+			v.fail(field, "%s is greater than %s", value, max)
+		}
+	}
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// hasJSONType reports whether the value is of the type, or one of the
+// types, of a schema.
+func hasJSONType(types interface{}, value interface{}) bool {
+	t := jsonType(value)
+	match := func(want interface{}) bool {
+		return want == t || (want == "number" && t == "integer")
+	}
+	if list, ok := types.([]interface{}); ok {
+		for _, want := range list {
+			if match(want) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(types)
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, _ := x.Float64()
+		fy, _ := y.Float64()
+		return fx == fy
+	}
+	return reflect.DeepEqual(a, b)
+}
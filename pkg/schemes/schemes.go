@@ -1,7 +1,6 @@
 package schemes
 
 import (
-	"crypto/sha256"
 	"io/ioutil"
 
 	"google.golang.org/protobuf/encoding/protojson"
@@ -10,11 +9,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func Hash(pb proto.Message) [32]byte {
-	data, _ := proto.Marshal(pb)
-	return sha256.Sum256(data)
-}
-
 func ParseJSON(path string, m proto.Message) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,273 @@
+package schemes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// blankIssuer issues the labels of the blank nodes, in order.
+type blankIssuer struct {
+	prefix string
+	issued map[string]string
+	order  []string
+}
+
+func newBlankIssuer(prefix string) *blankIssuer {
+	return &blankIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+func (i *blankIssuer) issue(id string) string {
+	if label, ok := i.issued[id]; ok {
+		return label
+	}
+	label := i.prefix + strconv.Itoa(len(i.order))
+	i.issued[id] = label
+	i.order = append(i.order, id)
+	return label
+}
+
+func (i *blankIssuer) clone() *blankIssuer {
+	c := &blankIssuer{prefix: i.prefix, issued: make(map[string]string, len(i.issued)), order: append([]string(nil), i.order...)}
+	for k, v := range i.issued {
+		c.issued[k] = v
+	}
+	return c
+}
+
+// urdna2015 is the state of the RDF Dataset Normalization algorithm
+// URDNA2015, which labels the blank nodes of a dataset canonically.
+type urdna2015 struct {
+	quads     map[string][]rdfQuad // quads mentioning each blank node
+	canonical *blankIssuer
+}
+
+func sha256Hex(s string) string {
+	digest := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(digest[:])
+}
+
+func (q rdfQuad) blanks() []rdfTerm {
+	var ts []rdfTerm
+	for _, t := range []rdfTerm{q.subject, q.object, q.graph} {
+		if t.kind == 'b' {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func (q rdfQuad) relabel(label func(string) string) rdfQuad {
+	for _, t := range []*rdfTerm{&q.subject, &q.object, &q.graph} {
+		if t.kind == 'b' {
+			t.value = label(t.value)
+		}
+	}
+	return q
+}
+
+// canonicalQuads returns the quads of the dataset, without duplicates,
+// with their blank nodes labeled canonically by URDNA2015 and sorted in
+// the code point order of their N-Quads serialization.
+func canonicalQuads(quads []rdfQuad) []string {
+	u := &urdna2015{quads: make(map[string][]rdfQuad), canonical: newBlankIssuer("_:c14n")}
+	seen := make(map[rdfQuad]bool, len(quads))
+	unique := quads[:0:0]
+	for _, q := range quads {
+		if !seen[q] {
+			seen[q] = true
+			unique = append(unique, q)
+		}
+	}
+	quads = unique
+	for _, q := range quads {
+		for _, b := range q.blanks() {
+			u.quads[b.value] = append(u.quads[b.value], q)
+		}
+	}
+
+	// issue the labels of the blank nodes with a unique first degree hash
+	pending := make(map[string]bool, len(u.quads))
+	for id := range u.quads {
+		pending[id] = true
+	}
+	var hashToBlanks map[string][]string
+	for simple := true; simple; {
+		simple = false
+		hashToBlanks = make(map[string][]string)
+		for id := range pending {
+			h := u.hashFirstDegree(id)
+			hashToBlanks[h] = append(hashToBlanks[h], id)
+		}
+		for _, h := range sortedHashes(hashToBlanks) {
+			if ids := hashToBlanks[h]; len(ids) == 1 {
+				u.canonical.issue(ids[0])
+				delete(pending, ids[0])
+				delete(hashToBlanks, h)
+				simple = true
+			}
+		}
+	}
+
+	// disambiguate the others by hashing their N-degree neighbourhood
+	for _, h := range sortedHashes(hashToBlanks) {
+		type result struct {
+			hash   string
+			issuer *blankIssuer
+		}
+		var results []result
+		ids := hashToBlanks[h]
+		sort.Strings(ids)
+		for _, id := range ids {
+			if _, ok := u.canonical.issued[id]; ok {
+				continue
+			}
+			issuer := newBlankIssuer("_:b")
+			issuer.issue(id)
+			hash, issuer := u.hashNDegree(id, issuer)
+			results = append(results, result{hash, issuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].hash < results[j].hash })
+		for _, r := range results {
+			for _, id := range r.issuer.order {
+				u.canonical.issue(id)
+			}
+		}
+	}
+
+	lines := make([]string, len(quads))
+	for i, q := range quads {
+		lines[i] = q.relabel(u.canonical.issue).NQuad()
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func sortedHashes(m map[string][]string) []string {
+	hashes := make([]string, 0, len(m))
+	for h := range m {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func (u *urdna2015) hashFirstDegree(id string) string {
+	var lines []string
+	for _, q := range u.quads[id] {
+		lines = append(lines, q.relabel(func(b string) string {
+			if b == id {
+				return "_:a"
+			}
+			return "_:z"
+		}).NQuad())
+	}
+	sort.Strings(lines)
+	return sha256Hex(strings.Join(lines, ""))
+}
+
+func (u *urdna2015) hashRelated(related string, q rdfQuad, issuer *blankIssuer, position string) string {
+	var id string
+	if label, ok := u.canonical.issued[related]; ok {
+		id = label
+	} else if label, ok := issuer.issued[related]; ok {
+		id = label
+	} else {
+		id = u.hashFirstDegree(related)
+	}
+	input := position
+	if position != "g" {
+		input += q.predicate.String()
+	}
+	return sha256Hex(input + id)
+}
+
+func (u *urdna2015) hashNDegree(id string, issuer *blankIssuer) (string, *blankIssuer) {
+	related := make(map[string][]string)
+	for _, q := range u.quads[id] {
+		for _, c := range []struct {
+			term     rdfTerm
+			position string
+		}{{q.subject, "s"}, {q.object, "o"}, {q.graph, "g"}} {
+			if c.term.kind == 'b' && c.term.value != id {
+				h := u.hashRelated(c.term.value, q, issuer, c.position)
+				related[h] = append(related[h], c.term.value)
+			}
+		}
+	}
+
+	var data strings.Builder
+	for _, h := range sortedHashes(related) {
+		data.WriteString(h)
+		var chosenPath string
+		var chosenIssuer *blankIssuer
+		permute(related[h], func(perm []string) {
+			issuerCopy := issuer.clone()
+			var path strings.Builder
+			var recursion []string
+			worse := func() bool {
+				return chosenIssuer != nil && path.Len() >= len(chosenPath) && path.String() > chosenPath
+			}
+			for _, r := range perm {
+				if label, ok := u.canonical.issued[r]; ok {
+					path.WriteString(label)
+				} else {
+					if _, ok := issuerCopy.issued[r]; !ok {
+						recursion = append(recursion, r)
+					}
+					path.WriteString(issuerCopy.issue(r))
+				}
+				if worse() {
+					return
+				}
+			}
+			for _, r := range recursion {
+				hash, resultIssuer := u.hashNDegree(r, issuerCopy)
+				path.WriteString(issuerCopy.issue(r))
+				path.WriteString("<" + hash + ">")
+				issuerCopy = resultIssuer
+				if worse() {
+					return
+				}
+			}
+			if chosenIssuer == nil || path.String() < chosenPath {
+				chosenPath, chosenIssuer = path.String(), issuerCopy
+			}
+		})
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return sha256Hex(data.String()), issuer
+}
+
+// permute calls f with every permutation of the list.
+func permute(list []string, f func([]string)) {
+	perm := append([]string(nil), list...)
+	sort.Strings(perm)
+	var generate func(k int)
+	generate = func(k int) {
+		if k == len(perm) {
+			f(append([]string(nil), perm...))
+			return
+		}
+		for i := k; i < len(perm); i++ {
+			perm[k], perm[i] = perm[i], perm[k]
+			generate(k + 1)
+			perm[k], perm[i] = perm[i], perm[k]
+		}
+	}
+	generate(0)
+}
+
+// CanonicalNQuads expands a JSON-LD document with its contexts, which must
+// be shipped with the package, converts it to an RDF dataset and returns
+// the dataset canonicalized with URDNA2015 as N-Quads.
+func CanonicalNQuads(data []byte) ([]byte, error) {
+	quads, err := toRDF(data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(canonicalQuads(quads), "")), nil
+}
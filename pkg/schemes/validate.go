@@ -0,0 +1,273 @@
+package schemes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var ErrInvalidDocument = errors.New("invalid credential document")
+
+// GradingSystem is the range of the grades of a grading system.
+type GradingSystem struct {
+	Min, Max int64
+}
+
+// Contains reports whether the grade is within the grading system.
+func (g GradingSystem) Contains(grade int64) bool {
+	return grade >= g.Min && grade <= g.Max
+}
+
+// GradingSystems are the grading systems of the course and diploma grades.
+var GradingSystems = map[string]GradingSystem{
+	"ECTS":     {0, 100}, // percentage
+	"U.S":      {0, 100},
+	"UK":       {0, 100},
+	"13-scale": {0, 13},
+}
+
+// assignmentGrades is the range of the assignment grades, percentages.
+var assignmentGrades = GradingSystem{0, 100}
+
+// FieldError is a field of a credential document failing validation.
+type FieldError struct {
+	Field  string // JSON pointer of the field
+	Reason string
+}
+
+func (f FieldError) String() string {
+	field := f.Field
+	if field == "" {
+		field = "/"
+	}
+	return field + ": " + f.Reason
+}
+
+// ValidationError lists the fields of a credential document failing
+// validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		reasons[i] = f.String()
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidDocument, strings.Join(reasons, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidDocument
+}
+
+func validationError(fields []FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return &ValidationError{Fields: fields}
+}
+
+// credentialKind returns the schema kind of a credential document.
+func credentialKind(c Credential) string {
+	switch c.(type) {
+	case *AssignmentGradeCredential:
+		return "assignment"
+	case *CourseGradeCredential:
+		return "course"
+	case *DiplomaCredential:
+		return "diploma"
+	}
+	return ""
+}
+
+// ValidateJSON parses and validates a JSON assignment, course or diploma
+// credential document. See Validate.
+func ValidateJSON(data []byte, subject common.Address) (Credential, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	var c Credential
+	switch {
+	case fields["assignment"] != nil:
+		c = &AssignmentGradeCredential{}
+	case fields["course"] != nil:
+		c = &CourseGradeCredential{}
+	case fields["diploma"] != nil:
+		c = &DiplomaCredential{}
+	default:
+		return nil, ErrUnknownCredential
+	}
+	if err := protojson.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if err := Validate(c, subject); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate validates a credential document against the JSON Schema of its
+// type and checks that its content is consistent: the grades are within
+// the grading system, the credits are positive, the entities are
+// identified by their addresses and the students of the aggregated
+// credentials are the student of the document. If the subject is not zero,
+// the student must be the subject.
+func Validate(c Credential, subject common.Address) error {
+	kind := credentialKind(c)
+	if kind == "" {
+		return ErrUnknownCredential
+	}
+	// the canonical JSON of the document, as it is hashed, rather than
+	// the input, whose fields may use their proto names.
+	data, err := protojson.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	value, err := decodeJSON(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	fields := validateSchema(kind+".json", value)
+	v := &documentValidator{fields: fields}
+	v.credential("", c, subject)
+	return validationError(v.fields)
+}
+
+// ValidateVC parses and validates a verifiable credential against the
+// JSON Schema of the envelope, and then the credential document it holds,
+// whose student must be the subject of the verifiable credential.
+func ValidateVC(data []byte) (*VerifiableCredential, Credential, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if err := validationError(validateSchema("vc.json", value)); err != nil {
+		return nil, nil, err
+	}
+	vc, err := ParseVC(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := FromVC(vc)
+	if err != nil {
+		return nil, nil, err
+	}
+	subject, err := Address(vc.CredentialSubject.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Validate(c, subject); err != nil {
+		return nil, nil, err
+	}
+	return vc, c, nil
+}
+
+// documentValidator checks the content of the credential documents.
+type documentValidator struct {
+	fields []FieldError
+}
+
+func (v *documentValidator) fail(field, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+// issuer checks that the entity offering the credential, the issuing
+// contract, is identified by its address. The ids of the students and
+// evaluators are checked by the schemas.
+func (v *documentValidator) issuer(field string, e *Entity) {
+	if _, err := Address(e.GetId()); err != nil {
+		v.fail(field+"/id", "%v: %q", err, e.GetId())
+	}
+}
+
+func (v *documentValidator) grade(field string, grade int64, g GradingSystem) {
+	if !g.Contains(grade) {
+		v.fail(field, "grade %d is not within [%d, %d]", grade, g.Min, g.Max)
+	}
+}
+
+func (v *documentValidator) gradingSystem(field, name string) (GradingSystem, bool) {
+	g, ok := GradingSystems[name]
+	if !ok && name != "" {
+		v.fail(field, "unknown grading system %q", name)
+	}
+	return g, ok
+}
+
+// credits checks that the credits are positive. Zero credits are missing,
+// which the schemas report.
+func (v *documentValidator) credits(field string, credits int64) {
+	if credits < 0 {
+		v.fail(field, "credits must be positive, got %d", credits)
+	}
+}
+
+// credential checks a credential document and the credentials it
+// aggregates, whose student must be the subject.
+func (v *documentValidator) credential(field string, c Credential, subject common.Address) {
+	if offeredBy := c.GetOfferedBy(); len(offeredBy) > 0 {
+		v.issuer(field+"/offeredBy/0", offeredBy[0])
+	}
+	from, until := c.GetValidFrom(), c.GetValidUntil()
+	if from != nil && until != nil && until.AsTime().Before(from.AsTime()) {
+		v.fail(field+"/validUntil", "%v", ErrInvalidValidity)
+	}
+	var student common.Address
+	switch c := c.(type) {
+	case *AssignmentGradeCredential:
+		field += "/assignment"
+		a := c.GetAssignment()
+		student = v.student(field+"/student", a.GetStudent(), subject)
+		v.grade(field+"/grade", a.GetGrade(), assignmentGrades)
+	case *CourseGradeCredential:
+		field += "/course"
+		course := c.GetCourse()
+		student = v.student(field+"/student", course.GetStudent(), subject)
+		if g, ok := v.gradingSystem(field+"/gradingSystem", course.GetGradingSystem()); ok {
+			v.grade(field+"/finalGrade", course.GetFinalGrade(), g)
+		}
+		v.credits(field+"/totalCredits", course.GetTotalCredits())
+		for i, a := range course.GetAssignments() {
+			v.credential(fmt.Sprintf("%s/assignments/%d", field, i), a, student)
+		}
+	case *DiplomaCredential:
+		field += "/diploma"
+		d := c.GetDiploma()
+		student = v.student(field+"/student", d.GetStudent(), subject)
+		if g, ok := v.gradingSystem(field+"/gradingSystem", d.GetGradingSystem()); ok {
+			names := make([]string, 0, len(d.GetGrades()))
+			for name := range d.GetGrades() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				v.grade(field+"/grades/"+name, d.GetGrades()[name], g)
+			}
+		}
+		v.credits(field+"/totalCredits", d.GetTotalCredits())
+		for i, course := range d.GetCourses() {
+			v.credential(fmt.Sprintf("%s/courses/%d", field, i), course, student)
+		}
+	}
+}
+
+// student checks that the student is the subject, if not zero, and returns
+// its address, or the subject if the student has no valid id.
+func (v *documentValidator) student(field string, e *Entity, subject common.Address) common.Address {
+	addr, err := Address(e.GetId())
+	if err != nil {
+		return subject
+	}
+	if subject != (common.Address{}) && addr != subject {
+		v.fail(field+"/id", "%v: student %s, subject %s", ErrSubjectMismatch, addr.Hex(), subject.Hex())
+	}
+	return addr
+}
@@ -0,0 +1,123 @@
+package schemes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	require.ErrorIs(t, err, ErrInvalidDocument)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "got %v", err)
+	fields := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		fields[i] = f.Field
+	}
+	return fields
+}
+
+func TestValidateFakeCredentials(t *testing.T) {
+	for _, c := range fakeCredentials(t) {
+		subject, err := Address(Subject(c).GetId())
+		require.NoError(t, err)
+		assert.NoError(t, Validate(c, subject))
+		assert.NoError(t, Validate(c, common.Address{}))
+
+		// the fields can use their JSON or proto names
+		for _, opts := range []protojson.MarshalOptions{{}, {UseProtoNames: true}} {
+			data, err := opts.Marshal(c)
+			require.NoError(t, err)
+			parsed, err := ValidateJSON(data, subject)
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(c, parsed))
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	other := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	tests := []struct {
+		name    string
+		modify  func(c *CourseGradeCredential)
+		subject common.Address
+		want    []string
+	}{
+		{"missing creation", func(c *CourseGradeCredential) { c.CreatedAt = nil }, common.Address{}, []string{"/createdAt"}},
+		{"issuer not an address", func(c *CourseGradeCredential) { c.OfferedBy[0].Id = "course" }, common.Address{}, []string{"/offeredBy/0/id"}},
+		{"student not an address", func(c *CourseGradeCredential) { c.Course.Student = &Entity{Id: "did:eth-uis:0x1234"} }, common.Address{}, []string{"/course/student/id"}},
+		{"no evaluators", func(c *CourseGradeCredential) { c.Course.Evaluators = nil }, common.Address{}, []string{"/course/evaluators"}},
+		{"unknown grading system", func(c *CourseGradeCredential) { c.Course.GradingSystem = "ETCS" }, common.Address{}, []string{"/course/gradingSystem"}},
+		{"final grade", func(c *CourseGradeCredential) { c.Course.FinalGrade = 101 }, common.Address{}, []string{"/course/finalGrade"}},
+		{"assignment grade", func(c *CourseGradeCredential) { c.Course.Assignments[1].Assignment.Grade = -1 }, common.Address{}, []string{"/course/assignments/1/assignment/grade"}},
+		{"missing credits", func(c *CourseGradeCredential) { c.Course.TotalCredits = 0 }, common.Address{}, []string{"/course/totalCredits"}},
+		{"negative credits", func(c *CourseGradeCredential) { c.Course.TotalCredits = -5 }, common.Address{}, []string{"/course/totalCredits"}},
+		{"validity", func(c *CourseGradeCredential) {
+			SetValidity(c, time.Unix(1700000000, 0), time.Unix(1600000000, 0))
+		}, common.Address{}, []string{"/validUntil"}},
+		{"subject", func(c *CourseGradeCredential) {}, other, []string{"/course/student/id"}},
+		{"assignment student", func(c *CourseGradeCredential) {
+			c.Course.Assignments[2].Assignment.Student = &Entity{Id: other.Hex()}
+		}, common.Address{}, []string{"/course/assignments/2/assignment/student/id"}},
+		{"several fields", func(c *CourseGradeCredential) {
+			c.Course.FinalGrade = 200
+			c.Course.Name = ""
+		}, common.Address{}, []string{"/course/finalGrade", "/course/name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fakeCredentials(t)[1].(*CourseGradeCredential)
+			tt.modify(c)
+			assert.Equal(t, tt.want, invalidFields(t, Validate(c, tt.subject)))
+		})
+	}
+}
+
+func TestValidateJSONErrors(t *testing.T) {
+	_, err := ValidateJSON([]byte(`{"createdBy": "0x3D9B4C8d7E1a7fCc2Aa8e9b1F6f5A2b7C9e0d1F2"}`), common.Address{})
+	assert.ErrorIs(t, err, ErrUnknownCredential)
+	_, err = ValidateJSON([]byte(`{"assignment": {"grade": "A"}}`), common.Address{})
+	assert.ErrorIs(t, err, ErrInvalidDocument)
+	_, err = ValidateJSON([]byte(`{"assignment": {"grade": 5}, "createdby": "x"}`), common.Address{})
+	assert.ErrorIs(t, err, ErrInvalidDocument)
+}
+
+func TestValidateVC(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	for _, c := range fakeCredentials(t) {
+		vc, err := ToVC(c, contract)
+		require.NoError(t, err)
+		data, err := json.Marshal(vc)
+		require.NoError(t, err)
+		_, doc, err := ValidateVC(data)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(c, doc))
+	}
+
+	vc, err := ToVC(fakeCredentials(t)[0], contract)
+	require.NoError(t, err)
+	vc.Context = []string{"https://example.com/credentials"}
+	vc.CredentialStatus.Digest = "0x1234"
+	data, err := json.Marshal(vc)
+	require.NoError(t, err)
+	_, _, err = ValidateVC(data)
+	assert.Equal(t, []string{"/@context", "/credentialStatus/digest"}, invalidFields(t, err))
+}
+
+func TestJSONSchema(t *testing.T) {
+	for _, kind := range []string{"assignment", "course", "diploma", "vc"} {
+		data, err := JSONSchema(kind)
+		require.NoError(t, err)
+		assert.True(t, json.Valid(data), kind)
+	}
+	_, err := JSONSchema("thesis")
+	assert.Error(t, err)
+}
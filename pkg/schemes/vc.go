@@ -0,0 +1,597 @@
+package schemes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// VCContext is the JSON-LD context of the W3C Verifiable Credentials
+	// Data Model, defined in base-vc-v1.json.
+	VCContext = "https://www.w3.org/2018/credentials/v1"
+	// CredbenchContext is the JSON-LD context of the terms of the
+	// credentials of the bench, defined in credbench-v1.json.
+	CredbenchContext = "https://relab.github.io/credbench/credentials/v1"
+	// VCType is the type shared by all verifiable credentials.
+	VCType = "VerifiableCredential"
+	// DIDPrefix prefixes the identifiers of the credentials and contracts.
+	DIDPrefix = "did:eth-uis:"
+	// StatusType is the type of the status of the credentials issued by
+	// a node contract, which can be checked on-chain.
+	StatusType = "CredentialTreeStatus2020"
+	// DocumentEvidence is the type of the evidence holding the evidence
+	// document of a credential.
+	DocumentEvidence = "CredentialDocument"
+)
+
+var (
+	ErrNotVC           = errors.New("not a verifiable credential")
+	ErrUnknownVCType   = errors.New("unknown verifiable credential type")
+	ErrSubjectMismatch = errors.New("credential subject does not match the document")
+	ErrInvalidVCDate   = errors.New("invalid verifiable credential date")
+)
+
+// VerifiableCredential is a credential document in the W3C Verifiable
+// Credentials Data Model, serialized as JSON-LD. The subject summarizes the
+// document and holds it in full, so that it can be converted back.
+type VerifiableCredential struct {
+	Context           []string        `json:"@context"`
+	ID                string          `json:"id,omitempty"`
+	Parent            string          `json:"parent,omitempty"`
+	Type              []string        `json:"type"`
+	Issuer            *VCIssuer       `json:"issuer"`
+	IssuanceDate      string          `json:"issuanceDate,omitempty"`
+	ValidFrom         string          `json:"validFrom,omitempty"`
+	ExpirationDate    string          `json:"expirationDate,omitempty"`
+	CredentialSubject *VCSubject      `json:"credentialSubject"`
+	Holder            *VCHolder       `json:"holder,omitempty"`
+	Evidence          []*VCEvidence   `json:"evidence,omitempty"`
+	CredentialStatus  *VCStatus       `json:"credentialStatus,omitempty"`
+	Proof             json.RawMessage `json:"proof,omitempty"`
+}
+
+// VCIssuer is the entity offering the credential and the evaluators
+// signing it.
+type VCIssuer struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name,omitempty"`
+	CreatedBy string      `json:"createdBy,omitempty"`
+	CoIssuers []*VCEntity `json:"coIssuers,omitempty"`
+	Signers   []*VCEntity `json:"signers,omitempty"`
+}
+
+// VCEntity is an entity referenced by a verifiable credential.
+type VCEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+// VCSubject is the student of the credential. One of Assignment, Course or
+// Diploma holds the document, encoded with protojson.
+type VCSubject struct {
+	ID                    string          `json:"id"`
+	Name                  string          `json:"name,omitempty"`
+	Degree                *VCDegree       `json:"degree,omitempty"`
+	DegreeType            string          `json:"degreeType,omitempty"`
+	Assignment            json.RawMessage `json:"assignment,omitempty"`
+	Course                json.RawMessage `json:"course,omitempty"`
+	Diploma               json.RawMessage `json:"diploma,omitempty"`
+	AdditionalInformation json.RawMessage `json:"additionalInformation,omitempty"`
+}
+
+// VCDegree summarizes the graded activity of the credential.
+type VCDegree struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// VCHolder is the holder of the credential, its subject.
+type VCHolder struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+// VCEvidence is the evidence document of the credential, or one of the
+// credentials it aggregates.
+type VCEvidence struct {
+	ID               string   `json:"id,omitempty"`
+	Type             []string `json:"type,omitempty"`
+	Verifier         string   `json:"verifier,omitempty"`
+	EvidenceDocument string   `json:"evidenceDocument,omitempty"`
+	SubjectPresence  string   `json:"subjectPresence,omitempty"`
+	DocumentPresence string   `json:"documentPresence,omitempty"`
+}
+
+// VCStatus points at the credential proof of the document in a node
+// contract, where its revocation can be checked, and names the algorithm
+// of its digest.
+type VCStatus struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Contract        string `json:"contract"`
+	Digest          string `json:"digest"`
+	DigestAlgorithm string `json:"digestAlgorithm,omitempty"` // the default algorithm if not set
+}
+
+// DID returns the decentralized identifier of a digest or an address.
+func DID(id []byte) string {
+	return DIDPrefix + "0x" + hex.EncodeToString(id)
+}
+
+// ParseVC parses a JSON-LD verifiable credential.
+func ParseVC(data []byte) (*VerifiableCredential, error) {
+	vc := &VerifiableCredential{}
+	if err := json.Unmarshal(data, vc); err != nil {
+		return nil, err
+	}
+	if !vc.hasType(VCType) || vc.CredentialSubject == nil || vc.Issuer == nil {
+		return nil, ErrNotVC
+	}
+	return vc, nil
+}
+
+func (vc *VerifiableCredential) hasType(t string) bool {
+	for _, s := range vc.Type {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the contract and digest of the credential status.
+func (vc *VerifiableCredential) Status() (common.Address, common.Hash, error) {
+	s := vc.CredentialStatus
+	if s == nil || s.Type != StatusType {
+		return common.Address{}, common.Hash{}, fmt.Errorf("%w: no %s status", ErrNotVC, StatusType)
+	}
+	if !common.IsHexAddress(s.Contract) {
+		return common.Address{}, common.Hash{}, fmt.Errorf("%w: status contract %q", ErrNotVC, s.Contract)
+	}
+	return common.HexToAddress(s.Contract), common.HexToHash(s.Digest), nil
+}
+
+// SetStatus sets the status of the credential to the credential proof of
+// its digest, computed with the algorithm, in the contract.
+func (vc *VerifiableCredential) SetStatus(contract common.Address, alg HashAlgorithm, digest [32]byte) {
+	vc.CredentialStatus = &VCStatus{
+		ID:              DID(contract.Bytes()) + "#" + common.Hash(digest).Hex(),
+		Type:            StatusType,
+		Contract:        contract.Hex(),
+		Digest:          common.Hash(digest).Hex(),
+		DigestAlgorithm: string(alg),
+	}
+}
+
+// LinkedData returns a copy of the credential that can be processed as
+// JSON-LD: it uses the context of the terms of the bench, and the
+// entities identified by their address are identified by their DID, since
+// JSON-LD drops the relative identifiers.
+func (vc *VerifiableCredential) LinkedData() *VerifiableCredential {
+	ld := vc.mapIDs(ldID)
+	ld.Context = []string{VCContext, CredbenchContext}
+	return ld
+}
+
+// linked reports whether the credential uses the context of the bench.
+func (vc *VerifiableCredential) linked() bool {
+	for _, c := range vc.Context {
+		if c == CredbenchContext {
+			return true
+		}
+	}
+	return false
+}
+
+// ldID returns the DID of an address, which is not an absolute IRI.
+func ldID(id string) string {
+	if strings.HasPrefix(id, "0x") && common.IsHexAddress(id) {
+		return DIDPrefix + id
+	}
+	return id
+}
+
+// plainID returns the address of which ldID returns the DID. The entities
+// identified by such a DID in the document are thus identified by their
+// address once converted back.
+func plainID(id string) string {
+	if rest := strings.TrimPrefix(id, DIDPrefix); rest != id && ldID(rest) == id {
+		return rest
+	}
+	return id
+}
+
+// mapIDs returns a copy of the credential with the identifiers of the
+// credential, its entities and evidence mapped by f.
+func (vc *VerifiableCredential) mapIDs(f func(string) string) *VerifiableCredential {
+	c := *vc
+	c.ID, c.Parent = f(vc.ID), f(vc.Parent)
+	entities := func(es []*VCEntity) []*VCEntity {
+		var ms []*VCEntity
+		for _, e := range es {
+			ms = append(ms, &VCEntity{ID: f(e.ID), Name: e.Name, Role: e.Role})
+		}
+		return ms
+	}
+	if vc.Issuer != nil {
+		issuer := *vc.Issuer
+		issuer.ID = f(issuer.ID)
+		issuer.CoIssuers, issuer.Signers = entities(issuer.CoIssuers), entities(issuer.Signers)
+		c.Issuer = &issuer
+	}
+	if vc.CredentialSubject != nil {
+		subject := *vc.CredentialSubject
+		subject.ID = f(subject.ID)
+		c.CredentialSubject = &subject
+	}
+	if vc.Holder != nil {
+		c.Holder = &VCHolder{ID: f(vc.Holder.ID), Type: vc.Holder.Type}
+	}
+	c.Evidence = nil
+	for _, e := range vc.Evidence {
+		evidence := *e
+		evidence.ID = f(e.ID)
+		c.Evidence = append(c.Evidence, &evidence)
+	}
+	if vc.CredentialStatus != nil {
+		status := *vc.CredentialStatus
+		status.ID = f(status.ID)
+		c.CredentialStatus = &status
+	}
+	return &c
+}
+
+// HashVC returns the SHA-256 of the credential processed as JSON-LD: of its
+// RDF dataset canonicalized with URDNA2015, serialized as N-Quads. Its
+// status and proof, which refer to the digest, are not hashed.
+func HashVC(vc *VerifiableCredential) ([32]byte, error) {
+	ld := vc.LinkedData()
+	ld.CredentialStatus, ld.Proof = nil, nil
+	data, err := json.Marshal(ld)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	nquads, err := CanonicalNQuads(data)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(nquads), nil
+}
+
+// ToVC converts a credential document to a verifiable credential. If the
+// contract is not zero, the status points at the credential proof of the
+// document in the contract.
+func ToVC(c Credential, contract common.Address) (*VerifiableCredential, error) {
+	digest, err := DefaultHashAlgorithm.Hash(c)
+	if err != nil {
+		return nil, err
+	}
+	subject := Subject(c)
+	vc := &VerifiableCredential{
+		Context: []string{VCContext},
+		ID:      DID(digest[:]),
+		Type:    []string{VCType, string(c.ProtoReflect().Descriptor().Name())},
+		Issuer: &VCIssuer{
+			CreatedBy: c.GetCreatedBy(),
+			Signers:   vcEntities(Evaluators(c)),
+		},
+		IssuanceDate:   vcTime(c.GetCreatedAt()),
+		ValidFrom:      vcTime(c.GetValidFrom()),
+		ExpirationDate: vcTime(c.GetValidUntil()),
+		CredentialSubject: &VCSubject{
+			ID:   subject.GetId(),
+			Name: subject.GetName(),
+		},
+		Holder: &VCHolder{ID: subject.GetId(), Type: "owner"},
+	}
+	if offeredBy := c.GetOfferedBy(); len(offeredBy) > 0 {
+		vc.Issuer.ID, vc.Issuer.Name = offeredBy[0].GetId(), offeredBy[0].GetName()
+		vc.Issuer.CoIssuers = vcEntities(offeredBy[1:])
+	}
+	if contract != (common.Address{}) {
+		vc.SetStatus(contract, DefaultHashAlgorithm, digest)
+		if vc.Issuer.ID == "" {
+			vc.Issuer.ID = DID(contract.Bytes())
+		}
+	}
+
+	s := vc.CredentialSubject
+	switch c := c.(type) {
+	case *AssignmentGradeCredential:
+		a := c.GetAssignment()
+		s.Degree, s.DegreeType = vcDegree(a.GetType(), a.GetName()), "Assignment"
+		s.Assignment, err = protojson.Marshal(a)
+		vc.Evidence = vcDocument(c, a.GetStudentPresence())
+	case *CourseGradeCredential:
+		course := c.GetCourse()
+		s.Degree, s.DegreeType = vcDegree(course.GetType(), course.GetName()), "Course"
+		vc.Evidence = vcDocument(c, course.GetStudentPresence())
+		for _, a := range course.GetAssignments() {
+			e, err := vcAggregated(a, a.GetAssignment().GetType(), a.GetAssignment().GetStudentPresence())
+			if err != nil {
+				return nil, err
+			}
+			vc.Evidence = append(vc.Evidence, e)
+		}
+		s.Course, err = protojson.Marshal(course)
+	case *DiplomaCredential:
+		d := c.GetDiploma()
+		s.Degree, s.DegreeType = vcDegree(d.GetType(), d.GetName()), "Diploma"
+		vc.Evidence = vcDocument(c, d.GetStudentPresence())
+		for _, course := range d.GetCourses() {
+			e, err := vcAggregated(course, course.GetCourse().GetType(), course.GetCourse().GetStudentPresence())
+			if err != nil {
+				return nil, err
+			}
+			vc.Evidence = append(vc.Evidence, e)
+		}
+		s.Diploma, err = protojson.Marshal(d)
+	default:
+		return nil, ErrUnknownCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info := c.GetAdditionalInformation(); info != nil {
+		s.AdditionalInformation, err = protojson.Marshal(info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vc, nil
+}
+
+// FromVC converts a verifiable credential to a credential document. The
+// document is decoded from the subject if it holds it, or else built from
+// the summary of the subject, the issuer and the evidence document, as in
+// the examples of the schemes. The DIDs of the entities of a credential
+// converted to linked data are converted back to their addresses.
+func FromVC(vc *VerifiableCredential) (Credential, error) {
+	if vc.CredentialSubject == nil || vc.Issuer == nil {
+		return nil, ErrNotVC
+	}
+	if vc.linked() {
+		vc = vc.mapIDs(plainID)
+	}
+	s := vc.CredentialSubject
+	createdAt, err := vcTimestamp("issuanceDate", vc.IssuanceDate)
+	if err != nil {
+		return nil, err
+	}
+	validFrom, err := vcTimestamp("validFrom", vc.ValidFrom)
+	if err != nil {
+		return nil, err
+	}
+	validUntil, err := vcTimestamp("expirationDate", vc.ExpirationDate)
+	if err != nil {
+		return nil, err
+	}
+	var offeredBy []*Entity
+	if vc.Issuer.ID != "" || vc.Issuer.Name != "" {
+		offeredBy = append(offeredBy, &Entity{Id: vc.Issuer.ID, Name: vc.Issuer.Name})
+	}
+	offeredBy = append(offeredBy, entities(vc.Issuer.CoIssuers)...)
+	var info *anypb.Any
+	if len(s.AdditionalInformation) > 0 {
+		info = &anypb.Any{}
+		if err := protojson.Unmarshal(s.AdditionalInformation, info); err != nil {
+			return nil, err
+		}
+	}
+	evidence := vc.document()
+
+	var c Credential
+	switch kind := vc.kind(); kind {
+	case "assignment":
+		a := &AssignmentGrade{}
+		if err := decodeSubject(s, s.Assignment, a); err != nil {
+			return nil, err
+		}
+		if len(s.Assignment) == 0 {
+			a.Name, a.Type, a.StudentPresence = s.Degree.name(), s.Degree.types(), evidence.SubjectPresence
+			a.Evaluators, a.Student = entities(vc.Issuer.Signers), &Entity{Id: s.ID, Name: s.Name}
+		}
+		c = &AssignmentGradeCredential{
+			Assignment: a, CreatedBy: vc.Issuer.CreatedBy, CreatedAt: createdAt, OfferedBy: offeredBy,
+			EvidenceDocument: evidence.EvidenceDocument, DocumentPresence: evidence.DocumentPresence,
+			AdditionalInformation: info, ValidFrom: validFrom, ValidUntil: validUntil,
+		}
+	case "course":
+		course := &CourseGrade{}
+		if err := decodeSubject(s, s.Course, course); err != nil {
+			return nil, err
+		}
+		if len(s.Course) == 0 {
+			course.Name, course.Type, course.StudentPresence = s.Degree.name(), s.Degree.types(), evidence.SubjectPresence
+			course.Evaluators, course.Student = entities(vc.Issuer.Signers), &Entity{Id: s.ID, Name: s.Name}
+		}
+		c = &CourseGradeCredential{
+			Course: course, CreatedBy: vc.Issuer.CreatedBy, CreatedAt: createdAt, OfferedBy: offeredBy,
+			EvidenceDocument: evidence.EvidenceDocument, DocumentPresence: evidence.DocumentPresence,
+			AdditionalInformation: info, ValidFrom: validFrom, ValidUntil: validUntil,
+		}
+	case "diploma":
+		d := &Diploma{}
+		if err := decodeSubject(s, s.Diploma, d); err != nil {
+			return nil, err
+		}
+		if len(s.Diploma) == 0 {
+			d.Name, d.Type, d.StudentPresence = s.Degree.name(), s.Degree.types(), evidence.SubjectPresence
+			d.Evaluators, d.Student = entities(vc.Issuer.Signers), &Entity{Id: s.ID, Name: s.Name}
+		}
+		c = &DiplomaCredential{
+			Diploma: d, CreatedBy: vc.Issuer.CreatedBy, CreatedAt: createdAt, OfferedBy: offeredBy,
+			EvidenceDocument: evidence.EvidenceDocument, DocumentPresence: evidence.DocumentPresence,
+			AdditionalInformation: info, ValidFrom: validFrom, ValidUntil: validUntil,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownVCType, vc.Type)
+	}
+	return c, nil
+}
+
+// kind returns the kind of document of the credential, given by its type,
+// the document held by the subject or the degree type of the subject.
+func (vc *VerifiableCredential) kind() string {
+	s := vc.CredentialSubject
+	switch {
+	case vc.hasType("AssignmentGradeCredential"), len(s.Assignment) > 0:
+		return "assignment"
+	case vc.hasType("CourseGradeCredential"), len(s.Course) > 0:
+		return "course"
+	case vc.hasType("DiplomaCredential"), len(s.Diploma) > 0:
+		return "diploma"
+	}
+	switch strings.ToLower(s.DegreeType) {
+	case "assignment", "exam":
+		return "assignment"
+	case "course":
+		return "course"
+	case "diploma":
+		return "diploma"
+	}
+	return ""
+}
+
+// document returns the evidence holding the evidence document of the
+// credential, if any.
+func (vc *VerifiableCredential) document() *VCEvidence {
+	for _, e := range vc.Evidence {
+		for _, t := range e.Type {
+			if t == DocumentEvidence {
+				return e
+			}
+		}
+	}
+	return &VCEvidence{}
+}
+
+// decodeSubject decodes the document held by the subject, checking that
+// its student is the subject.
+func decodeSubject(s *VCSubject, data json.RawMessage, m proto.Message) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := protojson.Unmarshal(data, m); err != nil {
+		return err
+	}
+	if st, ok := m.(interface{ GetStudent() *Entity }); ok && !sameEntity(st.GetStudent().GetId(), s.ID) {
+		return fmt.Errorf("%w: %s is not %s", ErrSubjectMismatch, s.ID, st.GetStudent().GetId())
+	}
+	return nil
+}
+
+// sameEntity reports whether two identifiers identify the same entity,
+// e.g. an address and its DID.
+func sameEntity(a, b string) bool {
+	if a == b {
+		return true
+	}
+	addrA, errA := Address(a)
+	addrB, errB := Address(b)
+	return errA == nil && errB == nil && addrA == addrB
+}
+
+func (d *VCDegree) name() string {
+	if d == nil {
+		return ""
+	}
+	return d.Name
+}
+
+func (d *VCDegree) types() []string {
+	if d == nil || d.Type == "" {
+		return nil
+	}
+	return []string{d.Type}
+}
+
+func vcDegree(types []string, name string) *VCDegree {
+	d := &VCDegree{Name: name}
+	if len(types) > 0 {
+		d.Type = types[0]
+	}
+	return d
+}
+
+// vcDocument returns the evidence of the evidence document of a credential.
+func vcDocument(c Credential, subjectPresence string) []*VCEvidence {
+	if c.GetEvidenceDocument() == "" && c.GetDocumentPresence() == "" {
+		return nil
+	}
+	e := &VCEvidence{
+		Type:             []string{DocumentEvidence},
+		EvidenceDocument: c.GetEvidenceDocument(),
+		SubjectPresence:  subjectPresence,
+		DocumentPresence: c.GetDocumentPresence(),
+	}
+	if offeredBy := c.GetOfferedBy(); len(offeredBy) > 0 {
+		e.Verifier = offeredBy[0].GetId()
+	}
+	return []*VCEvidence{e}
+}
+
+// vcAggregated returns the evidence of a credential aggregated by another.
+func vcAggregated(c Credential, types []string, subjectPresence string) (*VCEvidence, error) {
+	digest, err := DefaultHashAlgorithm.Hash(c)
+	if err != nil {
+		return nil, err
+	}
+	e := &VCEvidence{
+		ID:               DID(digest[:]),
+		Type:             types,
+		EvidenceDocument: c.GetEvidenceDocument(),
+		SubjectPresence:  subjectPresence,
+		DocumentPresence: c.GetDocumentPresence(),
+	}
+	if offeredBy := c.GetOfferedBy(); len(offeredBy) > 0 {
+		e.Verifier = offeredBy[0].GetId()
+	}
+	return e, nil
+}
+
+func vcEntities(es []*Entity) []*VCEntity {
+	var vs []*VCEntity
+	for _, e := range es {
+		vs = append(vs, &VCEntity{ID: e.GetId(), Name: e.GetName(), Role: e.GetRole()})
+	}
+	return vs
+}
+
+func entities(vs []*VCEntity) []*Entity {
+	var es []*Entity
+	for _, v := range vs {
+		es = append(es, &Entity{Id: v.ID, Name: v.Name, Role: v.Role})
+	}
+	return es
+}
+
+func vcTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
+}
+
+func vcTimestamp(field, s string) (*timestamppb.Timestamp, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %q", ErrInvalidVCDate, field, s)
+	}
+	return timestamppb.New(t), nil
+}
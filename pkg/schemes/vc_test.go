@@ -0,0 +1,195 @@
+package schemes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// stripComments removes the // comments of the annotated examples.
+func stripComments(data []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString && c == '\\' && i+1 < len(data):
+			out = append(out, c, data[i+1])
+			i++
+			continue
+		case c == '"':
+			inString = !inString
+		case !inString && c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func readExample(t *testing.T, name string) *VerifiableCredential {
+	data, err := os.ReadFile(filepath.Join("example", name))
+	require.NoError(t, err)
+	vc, err := ParseVC(stripComments(data))
+	require.NoError(t, err)
+	return vc
+}
+
+func TestVCExamples(t *testing.T) {
+	tests := []struct {
+		file string
+		want Credential
+		err  error
+	}{
+		{"exam-vc.json", &AssignmentGradeCredential{}, nil},
+		{"course-vc.json", &CourseGradeCredential{}, nil},
+		// the example is issued at 19:73:24
+		{"diploma-vc.json", nil, ErrInvalidVCDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			vc := readExample(t, tt.file)
+			c, err := FromVC(vc)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, c)
+
+			s := vc.CredentialSubject
+			assert.Equal(t, s.ID, Subject(c).GetId())
+			assert.Equal(t, vc.Issuer.ID, c.GetOfferedBy()[0].GetId())
+			assert.Equal(t, vc.Issuer.Name, c.GetOfferedBy()[0].GetName())
+			require.Len(t, Evaluators(c), len(vc.Issuer.Signers))
+			for i, e := range Evaluators(c) {
+				assert.Equal(t, vc.Issuer.Signers[i].ID, e.GetId())
+				assert.Equal(t, vc.Issuer.Signers[i].Role, e.GetRole())
+			}
+			issued, err := time.Parse(time.RFC3339, vc.IssuanceDate)
+			require.NoError(t, err)
+			assert.Equal(t, issued, c.GetCreatedAt().AsTime())
+			validFrom, err := time.Parse(time.RFC3339, vc.ValidFrom)
+			require.NoError(t, err)
+			assert.Equal(t, validFrom, c.GetValidFrom().AsTime())
+
+			// the exported credential holds the document
+			exported, err := ToVC(c, common.Address{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{VCContext}, exported.Context)
+			assert.Equal(t, vc.Issuer.ID, exported.Issuer.ID)
+			assert.Equal(t, vc.Issuer.Signers, exported.Issuer.Signers)
+			assert.Equal(t, vc.IssuanceDate, exported.IssuanceDate)
+			assert.Equal(t, vc.ValidFrom, exported.ValidFrom)
+			assert.Equal(t, s.ID, exported.CredentialSubject.ID)
+			assert.Equal(t, s.Degree, exported.CredentialSubject.Degree)
+			assert.Nil(t, exported.CredentialStatus)
+
+			imported, err := FromVC(exported)
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(c, imported), "got %v, want %v", imported, c)
+		})
+	}
+}
+
+func fakeCredentials(t *testing.T) []Credential {
+	teacher := "0x3D9B4C8d7E1a7fCc2Aa8e9b1F6f5A2b7C9e0d1F2"
+	student := "0x8a2F1d4E6c7B9a0D3e5F7c1B2a4D6e8F0c2B4d6A"
+	course := "0x1b2C3d4E5f6A7b8C9d0E1f2A3b4C5d6E7f8A9b0C"
+	faculty := "0x9f8E7d6C5b4A3f2E1d0C9b8A7f6E5d4C3b2A1f0E"
+
+	a := NewFakeAssignmentGradeCredential(teacher, &Entity{Id: course, Name: "Course"}, NewFakeAssignmentGrade(teacher, student))
+	SetValidity(a, time.Unix(1600000000, 0), time.Unix(1700000000, 0))
+	info, err := anypb.New(timestamppb.New(time.Unix(1600000000, 500)))
+	require.NoError(t, err)
+	a.AdditionalInformation = info
+
+	courses := GenerateFakeCoursesGrade(teacher, student, []string{course})
+	cc := GenerateFakeCoursesGradeCredentials(teacher, courses)
+	d := NewFakeDiplomaCredential(teacher, GenerateFakeDiploma(faculty, teacher, student, []string{course}))
+	d.OfferedBy = append(d.OfferedBy, &Entity{Id: "did:eth-uis:university", Name: "University", Role: "partner"})
+	return []Credential{a, cc[0], d}
+}
+
+func TestVCRoundTrip(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	for _, c := range fakeCredentials(t) {
+		vc, err := ToVC(c, contract)
+		require.NoError(t, err)
+		digest, err := DefaultHashAlgorithm.Hash(c)
+		require.NoError(t, err)
+		assert.Equal(t, DID(digest[:]), vc.ID)
+		assert.Equal(t, string(c.ProtoReflect().Descriptor().Name()), vc.Type[1])
+
+		data, err := json.Marshal(vc)
+		require.NoError(t, err)
+		parsed, err := ParseVC(data)
+		require.NoError(t, err)
+		addr, d, err := parsed.Status()
+		require.NoError(t, err)
+		assert.Equal(t, contract, addr)
+		assert.Equal(t, common.Hash(digest), d)
+		assert.Equal(t, string(DefaultHashAlgorithm), parsed.CredentialStatus.DigestAlgorithm)
+
+		imported, err := FromVC(parsed)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(c, imported), "got %v, want %v", imported, c)
+	}
+}
+
+func TestVCAggregatedEvidence(t *testing.T) {
+	c := fakeCredentials(t)[1].(*CourseGradeCredential)
+	vc, err := ToVC(c, common.Address{})
+	require.NoError(t, err)
+
+	// the evidence document and then the assignments of the course
+	require.Len(t, vc.Evidence, 1+len(c.GetCourse().GetAssignments()))
+	assert.Equal(t, []string{DocumentEvidence}, vc.Evidence[0].Type)
+	assert.Equal(t, c.GetEvidenceDocument(), vc.Evidence[0].EvidenceDocument)
+	for i, a := range c.GetCourse().GetAssignments() {
+		digest, err := DefaultHashAlgorithm.Hash(a)
+		require.NoError(t, err)
+		assert.Equal(t, DID(digest[:]), vc.Evidence[i+1].ID)
+		assert.Equal(t, a.GetOfferedBy()[0].GetId(), vc.Evidence[i+1].Verifier)
+	}
+}
+
+func TestVCErrors(t *testing.T) {
+	_, err := ParseVC([]byte(`{"type": ["Credential"], "issuer": {}, "credentialSubject": {}}`))
+	assert.ErrorIs(t, err, ErrNotVC)
+
+	vc := readExample(t, "exam-vc.json")
+	vc.CredentialSubject.DegreeType = "thesis"
+	vc.Type = []string{VCType}
+	_, err = FromVC(vc)
+	assert.ErrorIs(t, err, ErrUnknownVCType)
+
+	c := fakeCredentials(t)[0]
+	vc, err = ToVC(c, common.Address{})
+	require.NoError(t, err)
+	_, _, err = vc.Status()
+	assert.ErrorIs(t, err, ErrNotVC)
+	vc.CredentialSubject.ID = "0x0000000000000000000000000000000000000001"
+	_, err = FromVC(vc)
+	assert.ErrorIs(t, err, ErrSubjectMismatch)
+
+	// documents that cannot be hashed have no identifier
+	c.ProtoReflect().SetUnknown([]byte{0xf8, 0x01, 0x01})
+	_, err = ToVC(c, common.Address{})
+	assert.ErrorIs(t, err, ErrUnknownFields)
+}